@@ -0,0 +1,412 @@
+package crud
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	querybun "github.com/goliatone/go-crud/pkg/go-query-bun"
+	"github.com/goliatone/go-repository-bun"
+	"github.com/uptrace/bun"
+)
+
+// Query parameters read by the aggregate route. Each takes a comma separated
+// list of field names.
+const (
+	GroupByQueryParam = "group_by"
+	SumQueryParam     = "sum"
+	AvgQueryParam     = "avg"
+	MinQueryParam     = "min"
+	MaxQueryParam     = "max"
+)
+
+var aggregateQueryParams = []string{GroupByQueryParam, SumQueryParam, AvgQueryParam, MinQueryParam, MaxQueryParam}
+
+// AggregateSpec selects the groups and aggregates computed by an aggregate
+// query. Fields are the JSON names exposed by the resource. COUNT(*) is always
+// computed.
+type AggregateSpec struct {
+	GroupBy []string `json:"group_by,omitempty"`
+	Sum     []string `json:"sum,omitempty"`
+	Avg     []string `json:"avg,omitempty"`
+	Min     []string `json:"min,omitempty"`
+	Max     []string `json:"max,omitempty"`
+}
+
+// AggregateGroup holds the aggregates for one group, keyed by field name.
+type AggregateGroup struct {
+	Key   map[string]any     `json:"key,omitempty"`
+	Count int64              `json:"count"`
+	Sum   map[string]float64 `json:"sum,omitempty"`
+	Avg   map[string]float64 `json:"avg,omitempty"`
+	Min   map[string]any     `json:"min,omitempty"`
+	Max   map[string]any     `json:"max,omitempty"`
+}
+
+// AggregateResult is returned by aggregate queries. Without GroupBy it holds a
+// single group covering every matching row.
+type AggregateResult struct {
+	GroupBy []string         `json:"group_by,omitempty"`
+	Groups  []AggregateGroup `json:"groups"`
+}
+
+// AggregateService is implemented by services that can compute aggregates.
+// The repository-backed service and the NewService layers implement it.
+type AggregateService[T any] interface {
+	Aggregate(ctx Context, spec AggregateSpec, criteria []repository.SelectCriteria) (AggregateResult, error)
+}
+
+func aggregateServiceOf[T any](svc any) (AggregateService[T], error) {
+	if agg, ok := svc.(AggregateService[T]); ok && agg != nil {
+		return agg, nil
+	}
+	return nil, UnsupportedOperationError{Operation: OpAggregate}
+}
+
+type aggregateColumn struct {
+	field  string
+	column string
+	alias  string
+}
+
+type aggregatePlan struct {
+	groupBy []aggregateColumn
+	sum     []aggregateColumn
+	avg     []aggregateColumn
+	min     []aggregateColumn
+	max     []aggregateColumn
+}
+
+// planAggregate resolves spec fields to columns through fields (JSON name to
+// column). Unknown or denied fields are rejected, and so are sums and averages
+// of fields whose Go type in types is not numeric.
+func planAggregate(spec AggregateSpec, fields map[string]string, types map[string]reflect.Type) (aggregatePlan, error) {
+	var plan aggregatePlan
+	resolve := func(prefix string, names []string) ([]aggregateColumn, error) {
+		numeric := prefix == "sum" || prefix == "avg"
+		out := make([]aggregateColumn, 0, len(names))
+		for i, name := range names {
+			name = strings.TrimSpace(name)
+			column, ok := fields[name]
+			if !ok || column == "" {
+				return nil, &ValidationError{fmt.Errorf("unknown aggregate field %q", name)}
+			}
+			if typ, known := types[name]; numeric && known && !isNumericType(typ) {
+				return nil, &ValidationError{fmt.Errorf("%s needs a numeric field, %q is %s", prefix, name, typ)}
+			}
+			out = append(out, aggregateColumn{field: name, column: column, alias: fmt.Sprintf("%s_%d", prefix, i)})
+		}
+		return out, nil
+	}
+
+	var err error
+	if plan.groupBy, err = resolve("group", spec.GroupBy); err != nil {
+		return plan, err
+	}
+	if plan.sum, err = resolve("sum", spec.Sum); err != nil {
+		return plan, err
+	}
+	if plan.avg, err = resolve("avg", spec.Avg); err != nil {
+		return plan, err
+	}
+	if plan.min, err = resolve("min", spec.Min); err != nil {
+		return plan, err
+	}
+	if plan.max, err = resolve("max", spec.Max); err != nil {
+		return plan, err
+	}
+	return plan, nil
+}
+
+// planPolicyAggregate plans spec over the fields the field policy exposes.
+// Denied fields are unknown, and masked fields cannot be grouped or passed to
+// min and max, which would return their raw values.
+func planPolicyAggregate(spec AggregateSpec, fields map[string]string, types map[string]reflect.Type, policy resolvedFieldPolicy) (aggregatePlan, error) {
+	exposed := make(map[string]string, len(fields))
+	for field, column := range fields {
+		if policy.allowsField(field) {
+			exposed[field] = column
+		}
+	}
+	for _, names := range [][]string{spec.GroupBy, spec.Min, spec.Max} {
+		for _, name := range names {
+			if policy.maskFor(strings.TrimSpace(name)) != nil {
+				return aggregatePlan{}, &ValidationError{fmt.Errorf("masked field %q cannot be grouped or compared", strings.TrimSpace(name))}
+			}
+		}
+	}
+	return planAggregate(spec, exposed, types)
+}
+
+var numericNullTypes = []reflect.Type{
+	reflect.TypeFor[sql.NullByte](),
+	reflect.TypeFor[sql.NullInt16](),
+	reflect.TypeFor[sql.NullInt32](),
+	reflect.TypeFor[sql.NullInt64](),
+	reflect.TypeFor[sql.NullFloat64](),
+}
+
+// isNumericType reports whether typ holds a number SUM and AVG can work on.
+func isNumericType(typ reflect.Type) bool {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if slices.Contains(numericNullTypes, typ) {
+		return true
+	}
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	default:
+		return false
+	}
+}
+
+func (p aggregatePlan) apply(q *bun.SelectQuery) *bun.SelectQuery {
+	for _, col := range p.groupBy {
+		q = q.ColumnExpr("?TableAlias.? AS ?", bun.Ident(col.column), bun.Ident(col.alias)).
+			GroupExpr("?TableAlias.?", bun.Ident(col.column)).
+			OrderExpr("?TableAlias.?", bun.Ident(col.column))
+	}
+	q = q.ColumnExpr("COUNT(*) AS ?", bun.Ident("agg_count"))
+	for _, agg := range []struct {
+		fn   string
+		cols []aggregateColumn
+	}{{"SUM", p.sum}, {"AVG", p.avg}, {"MIN", p.min}, {"MAX", p.max}} {
+		for _, col := range agg.cols {
+			q = q.ColumnExpr(agg.fn+"(?TableAlias.?) AS ?", bun.Ident(col.column), bun.Ident(col.alias))
+		}
+	}
+	return q
+}
+
+func (p aggregatePlan) result(rows []map[string]any) AggregateResult {
+	result := AggregateResult{Groups: make([]AggregateGroup, 0, len(rows))}
+	for _, col := range p.groupBy {
+		result.GroupBy = append(result.GroupBy, col.field)
+	}
+	for _, row := range rows {
+		group := AggregateGroup{Count: int64(aggregateFloat(row["agg_count"]))}
+		if len(p.groupBy) > 0 {
+			group.Key = make(map[string]any, len(p.groupBy))
+			for _, col := range p.groupBy {
+				group.Key[col.field] = aggregateValue(row[col.alias])
+			}
+		}
+		group.Sum = aggregateFloats(p.sum, row)
+		group.Avg = aggregateFloats(p.avg, row)
+		group.Min = aggregateValues(p.min, row)
+		group.Max = aggregateValues(p.max, row)
+		result.Groups = append(result.Groups, group)
+	}
+	return result
+}
+
+func aggregateFloats(cols []aggregateColumn, row map[string]any) map[string]float64 {
+	if len(cols) == 0 {
+		return nil
+	}
+	out := make(map[string]float64, len(cols))
+	for _, col := range cols {
+		out[col.field] = aggregateFloat(row[col.alias])
+	}
+	return out
+}
+
+func aggregateValues(cols []aggregateColumn, row map[string]any) map[string]any {
+	if len(cols) == 0 {
+		return nil
+	}
+	out := make(map[string]any, len(cols))
+	for _, col := range cols {
+		out[col.field] = aggregateValue(row[col.alias])
+	}
+	return out
+}
+
+// aggregateValue normalizes driver values; text columns may scan as []byte.
+func aggregateValue(value any) any {
+	if raw, ok := value.([]byte); ok {
+		return string(raw)
+	}
+	return value
+}
+
+func aggregateFloat(value any) float64 {
+	switch v := aggregateValue(value).(type) {
+	case int64:
+		return float64(v)
+	case int:
+		return float64(v)
+	case float64:
+		return v
+	case float32:
+		return float64(v)
+	case string:
+		f, _ := strconv.ParseFloat(v, 64)
+		return f
+	default:
+		return 0
+	}
+}
+
+// aggregateCriteria keeps the filter and search criteria of plan; pagination,
+// ordering, selects and includes do not apply to aggregates.
+func aggregateCriteria(plan querybun.Plan) []repository.SelectCriteria {
+	criteria := make([]querybun.Criteria, 0, len(plan.Joins)+len(plan.Filters)+len(plan.Search))
+	criteria = append(criteria, plan.Joins...)
+	criteria = append(criteria, plan.Filters...)
+	criteria = append(criteria, plan.Search...)
+	return adaptQueryBunCriteria(criteria)
+}
+
+func aggregateSpecFromContext(ctx Context) AggregateSpec {
+	list := func(name string) []string {
+		var out []string
+		for _, part := range strings.Split(ctx.Query(name), ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				out = append(out, part)
+			}
+		}
+		return out
+	}
+	return AggregateSpec{
+		GroupBy: list(GroupByQueryParam),
+		Sum:     list(SumQueryParam),
+		Avg:     list(AvgQueryParam),
+		Min:     list(MinQueryParam),
+		Max:     list(MaxQueryParam),
+	}
+}
+
+// BuildAggregateCriteriaFromOptions builds the filter, search and soft delete
+// criteria of opts for AggregateWith. Pagination and ordering are ignored.
+func BuildAggregateCriteriaFromOptions[T any](opts ListQueryOptions, qbOpts ...QueryBuilderOption) ([]repository.SelectCriteria, error) {
+	cfg := queryBuilderConfig{}
+	for _, opt := range qbOpts {
+		if opt != nil {
+			opt(&cfg)
+		}
+	}
+
+	plan, err := querybun.BuildQueryPlan(toQueryBunListOptions(opts), queryBunConfig[T](cfg))
+	if err != nil {
+		return nil, convertQueryBunError(err)
+	}
+	criteria := aggregateCriteria(plan)
+	criteria = append(criteria, softDeleteCriteria(typeOf[T](), opts.WithDeleted, opts.OnlyDeleted)...)
+	return criteria, nil
+}
+
+// --- repository service ---
+
+// Aggregate runs a GROUP BY query over the rows matching criteria.
+func (s *repositoryService[T]) Aggregate(ctx Context, spec AggregateSpec, criteria []repository.SelectCriteria) (AggregateResult, error) {
+	plan, err := planAggregate(spec, getAllowedFields[T](), getFieldTypes(typeOf[T]()))
+	if err != nil {
+		return AggregateResult{}, err
+	}
+
+	var db bun.IDB
+	if tx, ok := TxFromContext(ctx.UserContext()); ok {
+		db = tx
+	} else if provider, ok := s.repo.(repository.DBProvider); ok && provider.DB() != nil {
+		db = provider.DB()
+	} else {
+		return AggregateResult{}, UnsupportedOperationError{Operation: OpAggregate}
+	}
+
+	q := db.NewSelect().Model(s.repo.Handlers().NewRecord())
+	for _, c := range criteria {
+		q = q.Apply(c)
+	}
+	q = plan.apply(q)
+
+	var rows []map[string]any
+	if err := q.Scan(ctx.UserContext(), &rows); err != nil {
+		return AggregateResult{}, err
+	}
+	return plan.result(rows), nil
+}
+
+// --- controller ---
+
+// Aggregate computes counts, sums, averages, minimums and maximums, optionally
+// grouped, over the rows matching the list filters:
+// GET /users/aggregate?group_by=status&sum=amount&avg=score
+func (c *Controller[T]) Aggregate(ctx Context) error {
+	ctx = c.applyContextFactory(ctx)
+	meta, policy, err := c.prepareAggregate(ctx)
+	if err != nil {
+		return c.resp.OnError(ctx, err, OpAggregate)
+	}
+
+	criteria, _, err := BuildQueryCriteriaWithLogger[T](ctx, OpAggregate, c.logger, c.queryLoggingEnabled, c.policyQueryOptions(policy)...)
+	if err != nil {
+		return c.resp.OnError(ctx, err, OpAggregate)
+	}
+
+	result, err := c.aggregate(ctx, meta, policy, aggregateSpecFromContext(ctx), criteria)
+	if err != nil {
+		return c.resp.OnError(ctx, err, OpAggregate)
+	}
+	return writeResult(c.resp, ctx, http.StatusOK, APIResponse[AggregateResult]{Success: true, Data: result}, OpAggregate)
+}
+
+// AggregateWith computes spec over the rows matching criteria using guard +
+// field policy semantics.
+func (c *Controller[T]) AggregateWith(ctx Context, spec AggregateSpec, criteria []repository.SelectCriteria) (AggregateResult, error) {
+	ctx = c.applyContextFactory(ctx)
+	meta, policy, err := c.prepareAggregate(ctx)
+	if err != nil {
+		return AggregateResult{}, err
+	}
+	return c.aggregate(ctx, meta, policy, spec, append([]repository.SelectCriteria(nil), criteria...))
+}
+
+// prepareAggregate resolves guard and policy for an aggregate. Aggregates
+// never load the rows they count, so controllers with an Authorizer reject
+// them with ForbiddenError.
+func (c *Controller[T]) prepareAggregate(ctx Context) (guardRequestContext, resolvedFieldPolicy, error) {
+	if c.authorizer != nil {
+		return guardRequestContext{}, resolvedFieldPolicy{}, &ForbiddenError{Operation: OpAggregate, Reason: "aggregates cannot be checked by the record authorizer"}
+	}
+	meta, err := c.resolveGuardContext(ctx, OpAggregate)
+	if err != nil {
+		return meta, resolvedFieldPolicy{}, err
+	}
+	policy, err := c.resolveFieldPolicy(ctx, OpAggregate, meta)
+	if err != nil {
+		return meta, policy, err
+	}
+	c.logFieldPolicyDecision(policy)
+	c.attachHookContext(ctx, OpAggregate)
+	return meta, policy, nil
+}
+
+func (c *Controller[T]) aggregate(ctx Context, meta guardRequestContext, policy resolvedFieldPolicy, spec AggregateSpec, criteria []repository.SelectCriteria) (AggregateResult, error) {
+	if err := c.checkAggregateSpec(spec, policy); err != nil {
+		return AggregateResult{}, err
+	}
+	agg, err := aggregateServiceOf[T](c.resolvedReadService())
+	if err != nil {
+		return AggregateResult{}, err
+	}
+	criteria = c.applyScopeCriteria(criteria, meta.scope)
+	criteria = c.applyFieldPolicyCriteria(criteria, policy)
+	return agg.Aggregate(ctx, spec, criteria)
+}
+
+// checkAggregateSpec validates spec against the controller field map as the
+// field policy exposes it. Aggregates and facets share it.
+func (c *Controller[T]) checkAggregateSpec(spec AggregateSpec, policy resolvedFieldPolicy) error {
+	fields := getOrBuildFieldMap(indirectType(c.resourceType), c.fieldMapProvider)
+	_, err := planPolicyAggregate(spec, fields, getFieldTypes(typeOf[T]()), policy)
+	return err
+}
@@ -0,0 +1,105 @@
+package crud
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func aggregateRequest(t *testing.T, app *fiber.App, path string) (int, AggregateResult) {
+	t.Helper()
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, path, nil), -1)
+	require.NoError(t, err)
+	var payload APIResponse[AggregateResult]
+	if resp.StatusCode == http.StatusOK {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&payload))
+	}
+	return resp.StatusCode, payload.Data
+}
+
+func TestController_Aggregate_GroupsAndFilters(t *testing.T) {
+	app, db := setupApp(t)
+	defer db.Close()
+
+	insertTestUsers(t, db,
+		&TestUser{Name: "Ann", Age: 20},
+		&TestUser{Name: "Ann", Age: 30},
+		&TestUser{Name: "Bob", Age: 40},
+	)
+
+	status, result := aggregateRequest(t, app, "/test-users/aggregate?group_by=name&sum=age&avg=age&min=age&max=age")
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, []string{"name"}, result.GroupBy)
+	require.Len(t, result.Groups, 2)
+
+	ann := result.Groups[0]
+	assert.Equal(t, "Ann", ann.Key["name"])
+	assert.EqualValues(t, 2, ann.Count)
+	assert.InDelta(t, 50, ann.Sum["age"], 0.001)
+	assert.InDelta(t, 25, ann.Avg["age"], 0.001)
+	assert.EqualValues(t, 20, ann.Min["age"])
+	assert.EqualValues(t, 30, ann.Max["age"])
+	assert.Equal(t, "Bob", result.Groups[1].Key["name"])
+
+	status, result = aggregateRequest(t, app, "/test-users/aggregate?sum=age&age__gte=30")
+	require.Equal(t, http.StatusOK, status)
+	require.Len(t, result.Groups, 1)
+	assert.Nil(t, result.Groups[0].Key)
+	assert.EqualValues(t, 2, result.Groups[0].Count)
+	assert.InDelta(t, 70, result.Groups[0].Sum["age"], 0.001)
+
+	status, _ = aggregateRequest(t, app, "/test-users/aggregate?sum=missing")
+	assert.Equal(t, http.StatusUnprocessableEntity, status, "fields outside the field map are rejected")
+
+	status, _ = aggregateRequest(t, app, "/test-users/aggregate?avg=name")
+	assert.Equal(t, http.StatusUnprocessableEntity, status, "sums and averages need numeric fields")
+
+	status, _ = aggregateRequest(t, app, "/test-users/aggregate?max=name")
+	assert.Equal(t, http.StatusOK, status, "min and max work on any comparable field")
+}
+
+func TestController_Aggregate_HonoursFieldPolicyDeny(t *testing.T) {
+	provider := func(req FieldPolicyRequest[*TestUser]) (FieldPolicy, error) {
+		if req.Operation == OpAggregate {
+			return FieldPolicy{Name: "aggregate:no-age", Deny: []string{"age"}}, nil
+		}
+		return FieldPolicy{}, nil
+	}
+	app, db := setupApp(t, WithFieldPolicyProvider(provider))
+	defer db.Close()
+	insertTestUsers(t, db, &TestUser{Name: "Ann", Age: 20})
+
+	status, _ := aggregateRequest(t, app, "/test-users/aggregate?avg=age")
+	assert.Equal(t, http.StatusUnprocessableEntity, status)
+
+	status, result := aggregateRequest(t, app, "/test-users/aggregate?group_by=name")
+	require.Equal(t, http.StatusOK, status)
+	require.Len(t, result.Groups, 1)
+	assert.EqualValues(t, 1, result.Groups[0].Count)
+}
+
+func TestController_Aggregate_RejectsMaskedFields(t *testing.T) {
+	provider := func(req FieldPolicyRequest[*TestUser]) (FieldPolicy, error) {
+		return FieldPolicy{Name: "masked-email-age", Mask: map[string]FieldMaskFunc{
+			"email": func(any) any { return "***" },
+			"age":   func(any) any { return 0 },
+		}}, nil
+	}
+	app, db := setupApp(t, WithFieldPolicyProvider(provider))
+	defer db.Close()
+	insertTestUsers(t, db, &TestUser{Name: "Ann", Email: "ann@example.com", Age: 20})
+
+	for _, query := range []string{"group_by=email", "min=age", "max=email"} {
+		status, _ := aggregateRequest(t, app, "/test-users/aggregate?"+query)
+		assert.Equal(t, http.StatusUnprocessableEntity, status, "masked values are not returned raw: %s", query)
+	}
+
+	status, result := aggregateRequest(t, app, "/test-users/aggregate?group_by=name")
+	require.Equal(t, http.StatusOK, status)
+	require.Len(t, result.Groups, 1)
+}
@@ -0,0 +1,248 @@
+package crud
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// AuthorizationDecision is the verdict of an Authorizer on one record.
+type AuthorizationDecision struct {
+	Allowed bool
+	// Reason explains a denial; it is surfaced in errors and audit logs.
+	Reason string
+	// Policy names the rule that decided, for audit logs.
+	Policy string
+}
+
+// Authorizer decides per record whether an operation may proceed. Controllers
+// consult it after records are loaded, so rules may depend on data that scope
+// guards cannot express as column filters (e.g. collaborators stored in
+// another table).
+type Authorizer[T any] interface {
+	Can(ctx Context, op CrudOperation, record T) (AuthorizationDecision, error)
+}
+
+// BatchAuthorizer is an Authorizer that can decide many records with one
+// lookup. Lists, exports and batch writes use CanBatch when available; it must
+// return one decision per record, in order.
+type BatchAuthorizer[T any] interface {
+	Authorizer[T]
+	CanBatch(ctx Context, op CrudOperation, records []T) ([]AuthorizationDecision, error)
+}
+
+// AuthorizerFunc adapts a function to the Authorizer interface.
+type AuthorizerFunc[T any] func(ctx Context, op CrudOperation, record T) (AuthorizationDecision, error)
+
+// Can calls f.
+func (f AuthorizerFunc[T]) Can(ctx Context, op CrudOperation, record T) (AuthorizationDecision, error) {
+	return f(ctx, op, record)
+}
+
+// DeniedRowMode selects what lists do with rows the Authorizer denies.
+type DeniedRowMode string
+
+const (
+	// DeniedRowsDrop removes denied rows from the page. The reported total
+	// still counts them, since it comes from the query before authorization.
+	// It is the default.
+	DeniedRowsDrop DeniedRowMode = "drop"
+	// DeniedRowsRedact keeps denied rows with only their primary key set.
+	DeniedRowsRedact DeniedRowMode = "redact"
+)
+
+// AuthorizerConfig configures WithAuthorizer.
+type AuthorizerConfig struct {
+	DeniedRows DeniedRowMode
+}
+
+// ForbiddenError reports a record the Authorizer denied. The JSON error
+// encoder reports it as 403 FORBIDDEN.
+type ForbiddenError struct {
+	Operation CrudOperation
+	Reason    string
+}
+
+func (e *ForbiddenError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("%s not allowed on this record", e.Operation)
+	}
+	return fmt.Sprintf("%s not allowed on this record: %s", e.Operation, e.Reason)
+}
+
+// AuthorizationAudit captures the record authorization outcome emitted to
+// logs, in the manner of FieldPolicyAudit.
+type AuthorizationAudit struct {
+	Resource  string
+	Operation CrudOperation
+	Allowed   int
+	Denied    int
+	// DeniedIDs lists the keys of the denied records.
+	DeniedIDs []string
+	// Policies and Reasons list the distinct deciding policies and denial
+	// reasons.
+	Policies []string
+	Reasons  []string
+}
+
+// LogAuthorizationDecision writes the audit entry using the provided logger.
+func LogAuthorizationDecision(logger Logger, audit AuthorizationAudit) {
+	if logger == nil {
+		return
+	}
+
+	fields := Fields{
+		"resource":  audit.Resource,
+		"operation": audit.Operation,
+		"allowed":   audit.Allowed,
+		"denied":    audit.Denied,
+	}
+	if len(audit.DeniedIDs) > 0 {
+		fields["denied_ids"] = strings.Join(audit.DeniedIDs, ",")
+	}
+	if len(audit.Policies) > 0 {
+		fields["policies"] = strings.Join(audit.Policies, ",")
+	}
+	if len(audit.Reasons) > 0 {
+		fields["reasons"] = strings.Join(audit.Reasons, "; ")
+	}
+
+	if withFields, ok := logger.(loggerWithFields); ok {
+		withFields.WithFields(fields).Info("record authorization applied")
+		return
+	}
+	logger.Info("record authorization applied: %d allowed, %d denied", audit.Allowed, audit.Denied)
+}
+
+type recordAuthorizer[T any] struct {
+	authorizer Authorizer[T]
+	deniedRows DeniedRowMode
+}
+
+// authorizeRecords decides every record, using CanBatch when available.
+func (c *Controller[T]) authorizeRecords(ctx Context, op CrudOperation, records []T) ([]AuthorizationDecision, error) {
+	authz := c.authorizer.authorizer
+	if batch, ok := authz.(BatchAuthorizer[T]); ok {
+		decisions, err := batch.CanBatch(ctx, op, records)
+		if err != nil {
+			return nil, err
+		}
+		if len(decisions) != len(records) {
+			return nil, fmt.Errorf("crud: authorizer returned %d decisions for %d records", len(decisions), len(records))
+		}
+		return decisions, nil
+	}
+	decisions := make([]AuthorizationDecision, len(records))
+	for i, record := range records {
+		decision, err := authz.Can(ctx, op, record)
+		if err != nil {
+			return nil, err
+		}
+		decisions[i] = decision
+	}
+	return decisions, nil
+}
+
+// authorizeRecord checks a loaded record, failing with ForbiddenError when the
+// Authorizer denies op on it.
+func (c *Controller[T]) authorizeRecord(ctx Context, op CrudOperation, record T) error {
+	return c.authorizeAll(ctx, op, []T{record})
+}
+
+// authorizeAll checks loaded records with one Authorizer call, failing with
+// ForbiddenError for the first record denied op.
+func (c *Controller[T]) authorizeAll(ctx Context, op CrudOperation, records []T) error {
+	if c.authorizer == nil || len(records) == 0 {
+		return nil
+	}
+	decisions, err := c.authorizeRecords(ctx, op, records)
+	if err != nil {
+		return err
+	}
+	c.logAuthorization(op, records, decisions)
+	for _, decision := range decisions {
+		if !decision.Allowed {
+			return &ForbiddenError{Operation: op, Reason: decision.Reason}
+		}
+	}
+	return nil
+}
+
+// attachUpsertAuthorization makes upserts that update a stored row check it
+// with the Authorizer before writing, and returns a function restoring the
+// previous user context.
+func (c *Controller[T]) attachUpsertAuthorization(ctx Context, op CrudOperation) func() {
+	setter, ok := ctx.(userContextSetter)
+	if c.authorizer == nil || !ok {
+		return func() {}
+	}
+	previous := ctx.UserContext()
+	setter.SetUserContext(contextWithUpsertWrite(previous, func(action UpsertAction, _ T, stored T) error {
+		if action != UpsertUpdated {
+			return nil
+		}
+		return c.authorizeRecord(ctx, op, stored)
+	}))
+	return func() { setter.SetUserContext(previous) }
+}
+
+// authorizeList applies the Authorizer to a page of records: denied rows are
+// dropped or redacted according to the configured DeniedRowMode.
+func (c *Controller[T]) authorizeList(ctx Context, op CrudOperation, records []T) ([]T, error) {
+	if c.authorizer == nil || len(records) == 0 {
+		return records, nil
+	}
+	decisions, err := c.authorizeRecords(ctx, op, records)
+	if err != nil {
+		return nil, err
+	}
+	c.logAuthorization(op, records, decisions)
+
+	kept := make([]T, 0, len(records))
+	for i, record := range records {
+		switch {
+		case decisions[i].Allowed:
+			kept = append(kept, record)
+		case c.authorizer.deniedRows == DeniedRowsRedact:
+			kept = append(kept, redactedRecord(record))
+		}
+	}
+	return kept, nil
+}
+
+func (c *Controller[T]) logAuthorization(op CrudOperation, records []T, decisions []AuthorizationDecision) {
+	audit := AuthorizationAudit{Resource: c.resource, Operation: op}
+	addOnce := func(list *[]string, value string) {
+		if value != "" && !slices.Contains(*list, value) {
+			*list = append(*list, value)
+		}
+	}
+	for i, decision := range decisions {
+		addOnce(&audit.Policies, decision.Policy)
+		if decision.Allowed {
+			audit.Allowed++
+			continue
+		}
+		audit.Denied++
+		audit.DeniedIDs = append(audit.DeniedIDs, c.recordID(records[i]))
+		addOnce(&audit.Reasons, decision.Reason)
+	}
+	LogAuthorizationDecision(c.logger, audit)
+}
+
+// redactedRecord returns a new record carrying only the primary key of record.
+func redactedRecord[T any](record T) T {
+	rv, ok := recordStructValue(record)
+	if !ok {
+		return record
+	}
+	out := reflect.New(rv.Type())
+	for _, field := range primaryKeyFields(rv.Type()) {
+		out.Elem().FieldByIndex(field.index).Set(rv.FieldByIndex(field.index))
+	}
+	if reflect.TypeFor[T]().Kind() == reflect.Pointer {
+		return out.Interface().(T)
+	}
+	return out.Elem().Interface().(T)
+}
@@ -0,0 +1,265 @@
+package crud
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/goliatone/go-repository-bun"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// draftAuthorizer denies every operation on users aged 0 ("drafts") and
+// counts how it was consulted.
+type draftAuthorizer struct {
+	can, canBatch int
+}
+
+func (a *draftAuthorizer) Can(ctx Context, op CrudOperation, record *TestUser) (AuthorizationDecision, error) {
+	a.can++
+	if record.Age == 0 {
+		return AuthorizationDecision{Reason: "drafts are private", Policy: "drafts"}, nil
+	}
+	return AuthorizationDecision{Allowed: true, Policy: "drafts"}, nil
+}
+
+type batchDraftAuthorizer struct{ draftAuthorizer }
+
+func (a *batchDraftAuthorizer) CanBatch(ctx Context, op CrudOperation, records []*TestUser) ([]AuthorizationDecision, error) {
+	a.canBatch++
+	out := make([]AuthorizationDecision, len(records))
+	for i, record := range records {
+		out[i] = AuthorizationDecision{Allowed: record.Age > 0}
+	}
+	return out, nil
+}
+
+func listUsers(t *testing.T, app *fiber.App) map[string]any {
+	t.Helper()
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/test-users?order=name", nil), -1)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var payload map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&payload))
+	return payload
+}
+
+func TestController_Authorizer_SingleRecords(t *testing.T) {
+	authz := &draftAuthorizer{}
+	logger := newRecordingLogger()
+	app, db := setupApp(t, WithAuthorizer[*TestUser](authz), WithLogger[*TestUser](logger))
+	defer db.Close()
+
+	draft := &TestUser{Name: "Draft", Email: "draft@example.com"}
+	published := &TestUser{Name: "Published", Email: "published@example.com", Age: 30}
+	insertTestUsers(t, db, draft, published)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/test-user/"+draft.ID.String(), nil), -1)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/test-user/"+published.ID.String(), nil), -1)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	status, payload := writeRequest(t, app, http.MethodPut, "/test-user/"+draft.ID.String(), map[string]any{"name": "Renamed"})
+	assert.Equal(t, http.StatusForbidden, status)
+	assert.Equal(t, "FORBIDDEN", errorTextCode(t, payload))
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodDelete, "/test-user/"+draft.ID.String(), nil), -1)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	body, err := json.Marshal([]string{published.ID.String(), draft.ID.String()})
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodDelete, "/test-user/batch", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err = app.Test(req, -1)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Equal(t, 2, countTestUsers(t, db))
+
+	var denied *logEntry
+	for _, entry := range logger.Entries() {
+		if entry.message == "record authorization applied" && entry.fields["denied"] == 1 {
+			denied = &entry
+			break
+		}
+	}
+	require.NotNil(t, denied, "expected an audit entry for the denied read")
+	assert.Equal(t, draft.ID.String(), denied.fields["denied_ids"])
+	assert.Equal(t, "drafts are private", denied.fields["reasons"])
+	assert.Equal(t, "drafts", denied.fields["policies"])
+}
+
+func TestController_Authorizer_ListsDropDeniedRows(t *testing.T) {
+	authz := &draftAuthorizer{}
+	app, db := setupApp(t, WithAuthorizer[*TestUser](authz))
+	defer db.Close()
+
+	insertTestUsers(t, db,
+		&TestUser{Name: "A Draft", Email: "a@example.com"},
+		&TestUser{Name: "B Published", Email: "b@example.com", Age: 30},
+	)
+
+	payload := listUsers(t, app)
+	data := payload["data"].([]any)
+	require.Len(t, data, 1)
+	assert.Equal(t, "B Published", data[0].(map[string]any)["name"])
+	assert.EqualValues(t, 2, payload["$meta"].(map[string]any)["count"], "the count is the total before authorization")
+	assert.Equal(t, 2, authz.can)
+}
+
+func TestController_Authorizer_ListsRedactDeniedRows(t *testing.T) {
+	authz := &batchDraftAuthorizer{}
+	app, db := setupApp(t, WithAuthorizer[*TestUser](authz, AuthorizerConfig{DeniedRows: DeniedRowsRedact}))
+	defer db.Close()
+
+	draft := &TestUser{Name: "A Draft", Email: "a@example.com"}
+	insertTestUsers(t, db, draft, &TestUser{Name: "B Published", Email: "b@example.com", Age: 30})
+
+	data := listUsers(t, app)["data"].([]any)
+	require.Len(t, data, 2)
+	redacted := data[0].(map[string]any)
+	assert.Equal(t, draft.ID.String(), redacted["id"])
+	assert.Empty(t, redacted["name"])
+	assert.Empty(t, redacted["email"])
+	assert.Equal(t, "B Published", data[1].(map[string]any)["name"])
+
+	assert.Equal(t, 1, authz.canBatch, "lists decide every row with one CanBatch call")
+	assert.Zero(t, authz.can)
+}
+
+func TestController_Authorizer_BatchesDecideTogether(t *testing.T) {
+	authz := &batchDraftAuthorizer{}
+	app, db := setupApp(t, WithAuthorizer[*TestUser](authz))
+	defer db.Close()
+
+	draft := &TestUser{Name: "Draft", Email: "draft@example.com"}
+	published := &TestUser{Name: "Published", Email: "published@example.com", Age: 30}
+	insertTestUsers(t, db, draft, published)
+
+	resp, err := app.Test(batchRequest(http.MethodPut, "/test-user/batch", []map[string]any{
+		{"id": published.ID.String(), "name": "Renamed"},
+		{"id": draft.ID.String(), "name": "Renamed"},
+	}), -1)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Equal(t, 1, authz.canBatch, "batch updates decide every record with one CanBatch call")
+
+	resp, err = app.Test(batchRequest(http.MethodDelete, "/test-user/batch", []string{published.ID.String(), draft.ID.String()}), -1)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Equal(t, 2, authz.canBatch, "batch deletes decide every record with one CanBatch call")
+	assert.Zero(t, authz.can)
+	assert.Equal(t, 2, countTestUsers(t, db))
+
+	var stored TestUser
+	require.NoError(t, db.NewSelect().Model(&stored).Where("id = ?", published.ID).Scan(context.Background()))
+	assert.Equal(t, "Published", stored.Name)
+}
+
+func TestController_Authorizer_BatchLookupErrorsAreNotNotFound(t *testing.T) {
+	failing := errors.New("connection reset")
+	app, db := setupApp(t,
+		WithAuthorizer[*TestUser](&draftAuthorizer{}),
+		WithServiceFuncs(ServiceFuncs[*TestUser]{
+			Show: func(ctx Context, id string, criteria []repository.SelectCriteria) (*TestUser, error) {
+				if id == uuid.Nil.String() {
+					return nil, sql.ErrNoRows
+				}
+				return nil, failing
+			},
+		}),
+	)
+	defer db.Close()
+
+	resp, err := app.Test(batchRequest(http.MethodDelete, "/test-user/batch", []string{uuid.Nil.String()}), -1)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, err = app.Test(batchRequest(http.MethodDelete, "/test-user/batch", []string{uuid.New().String()}), -1)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode, "database errors are not reported as not found")
+}
+
+func TestController_Authorizer_UpsertsExportsAndFilterMutations(t *testing.T) {
+	authz := &draftAuthorizer{}
+	app, db := setupApp(t, WithAuthorizer[*TestUser](authz))
+	defer db.Close()
+
+	draft := &TestUser{Name: "Draft", Email: "draft@example.com"}
+	insertTestUsers(t, db, draft, &TestUser{Name: "Published", Email: "published@example.com", Age: 30})
+
+	resp := upsertRequest(t, app, "/test-user", map[string]any{"name": "Taken", "email": draft.Email, "age": 40})
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode, "upserts are checked against the stored row")
+	resp = upsertRequest(t, app, "/test-user", map[string]any{"name": "New", "email": "new@example.com"})
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "inserts have no stored row to check")
+
+	var stored TestUser
+	require.NoError(t, db.NewSelect().Model(&stored).Where("id = ?", draft.ID).Scan(context.Background()))
+	assert.Equal(t, "Draft", stored.Name)
+
+	resp, body := exportRequest(t, app, "/test-users/export?select=name,age&order=name")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "name,age\nPublished,30\n", string(body), "exports drop denied rows")
+
+	status, _ := filterMutationRequest(t, app, http.MethodPatch, "/test-users?age__gte=30", map[string]any{"name": "Bulk"})
+	assert.Equal(t, http.StatusForbidden, status, "filter mutations cannot be authorized per record")
+	status, _ = filterMutationRequest(t, app, http.MethodDelete, "/test-users?age__gte=30", nil)
+	assert.Equal(t, http.StatusForbidden, status)
+	assert.Equal(t, 3, countTestUsers(t, db))
+}
+
+func TestController_Authorizer_AggregatesAndFacets(t *testing.T) {
+	authz := &draftAuthorizer{}
+	app, db := setupApp(t, WithAuthorizer[*TestUser](authz))
+	defer db.Close()
+	insertTestUsers(t, db,
+		&TestUser{Name: "Draft", Email: "draft@example.com"},
+		&TestUser{Name: "Published", Email: "published@example.com", Age: 30},
+	)
+
+	status, _ := aggregateRequest(t, app, "/test-users/aggregate?group_by=name&min=age")
+	assert.Equal(t, http.StatusForbidden, status, "aggregates would count and expose denied rows")
+	status, _ = listRequest(t, app, "/test-users?facets=name")
+	assert.Equal(t, http.StatusForbidden, status, "facets would list the values of denied rows")
+	status, _ = listRequest(t, app, "/test-users")
+	assert.Equal(t, http.StatusOK, status)
+}
+
+func TestController_Authorizer_RestoreAndPurge(t *testing.T) {
+	authz := AuthorizerFunc[*trashedNote](func(_ Context, _ CrudOperation, note *trashedNote) (AuthorizationDecision, error) {
+		return AuthorizationDecision{Allowed: note.Title != "Draft", Reason: "drafts are private"}, nil
+	})
+	app, db, note := setupTrashedNoteApp(t, WithAuthorizer[*trashedNote](authz))
+	_, err := db.NewDelete().Model(note).WherePK().Exec(context.Background())
+	require.NoError(t, err)
+	path := "/trashed-note/" + note.ID.String()
+
+	resp, err := app.Test(batchRequest(http.MethodPost, path+"/restore", nil), -1)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	resp, err = app.Test(batchRequest(http.MethodPost, "/trashed-note/batch/restore", []string{note.ID.String()}), -1)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	resp, err = app.Test(batchRequest(http.MethodDelete, path+"/purge", nil), -1)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	resp, err = app.Test(batchRequest(http.MethodDelete, "/trashed-note/batch/purge", []string{note.ID.String()}), -1)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	count, err := db.NewSelect().Model((*trashedNote)(nil)).WhereAllWithDeleted().Count(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
@@ -0,0 +1,221 @@
+package crud
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	goerrors "github.com/goliatone/go-errors"
+	"github.com/goliatone/go-repository-bun"
+	"github.com/uptrace/bun"
+)
+
+const (
+	// BatchAtomicQueryParam selects the batch mode. `?atomic=false` processes
+	// each item independently and responds with per-item results.
+	BatchAtomicQueryParam = "atomic"
+
+	ctxKeyTx requestContextKey = "crud.tx"
+)
+
+// BatchItemResult reports the outcome of a single item in a non-atomic batch.
+// Error is the error the controller's error encoder reports for the item.
+type BatchItemResult struct {
+	Index  int             `json:"index"`
+	ID     string          `json:"id,omitempty"`
+	Status int             `json:"status"`
+	Error  *goerrors.Error `json:"error,omitempty"`
+}
+
+// ContextWithTx stores a transaction on ctx. Repository-backed services run
+// their statements on it, and hooks can reach it through HookContext.Tx.
+func ContextWithTx(ctx context.Context, tx bun.IDB) context.Context {
+	if ctx == nil || tx == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, ctxKeyTx, tx)
+}
+
+// TxFromContext returns the transaction stored with ContextWithTx.
+func TxFromContext(ctx context.Context) (bun.IDB, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	tx, ok := ctx.Value(ctxKeyTx).(bun.IDB)
+	return tx, ok && tx != nil
+}
+
+// isAtomicBatch reports whether the request asked for all-or-nothing
+// processing, which is the default.
+func isAtomicBatch(ctx Context) bool {
+	raw := strings.TrimSpace(ctx.Query(BatchAtomicQueryParam))
+	if raw == "" {
+		return true
+	}
+	atomic, err := strconv.ParseBool(raw)
+	return err != nil || atomic
+}
+
+// runInTx executes fn inside a transaction attached to the request context so
+// hooks and the repository service share it. It joins a transaction that is
+// already present and runs fn directly when the repository does not expose a
+// bun.DB or ctx cannot carry a new user context.
+func (c *Controller[T]) runInTx(ctx Context, fn func() error) error {
+	base := ctx.UserContext()
+	if _, ok := TxFromContext(base); ok {
+		return fn()
+	}
+	setter, ok := ctx.(userContextSetter)
+	if !ok {
+		return fn()
+	}
+	provider, ok := c.Repo.(repository.DBProvider)
+	if !ok || provider.DB() == nil {
+		return fn()
+	}
+	if base == nil {
+		base = context.Background()
+	}
+
+	defer setter.SetUserContext(base)
+	return provider.DB().RunInTx(base, nil, func(txCtx context.Context, tx bun.Tx) error {
+		setter.SetUserContext(ContextWithTx(base, tx))
+		return fn()
+	})
+}
+
+// runBatchItems processes each record in its own transaction and collects a
+// result per item instead of failing the whole batch. fn writes through the
+// single-record service methods, so per-record hooks run for each item and
+// batch hooks do not run.
+func (c *Controller[T]) runBatchItems(ctx Context, op CrudOperation, meta guardRequestContext, records []T, successStatus int, fn func(record T) (T, error)) []BatchItemResult {
+	base := ctx.UserContext()
+	setter, _ := ctx.(userContextSetter)
+	results := make([]BatchItemResult, len(records))
+	for i, record := range records {
+		var processed T
+		err := c.runInTx(ctx, func() error {
+			var err error
+			processed, err = fn(record)
+			return err
+		})
+		if setter != nil {
+			setter.SetUserContext(base)
+		}
+
+		result := BatchItemResult{Index: i, Status: successStatus}
+		if err == nil {
+			record = processed
+		} else {
+			result.Error, result.Status = encodeErrorBody(ctx, c.resp.OnError, err, op)
+		}
+		if id, idErr := formatRecordID(c.Repo.Handlers(), c.idCodec, record); idErr == nil {
+			result.ID = id
+		}
+		c.emitActivityEvents(ctx, op, meta, []T{record}, err)
+		results[i] = result
+	}
+	return results
+}
+
+// writeBatchResults responds with 207 Multi-Status and the per-item results.
+func (c *Controller[T]) writeBatchResults(ctx Context, op CrudOperation, results []BatchItemResult) error {
+	success := true
+	for _, result := range results {
+		if result.Error != nil {
+			success = false
+			break
+		}
+	}
+	return writeResult(c.resp, ctx, http.StatusMultiStatus, map[string]any{
+		"$meta":   &Filters{Count: len(results), Operation: string(op)},
+		"success": success,
+		"data":    results,
+	}, op)
+}
+
+// prepareBatchUpdates loads the stored records behind records, authorizes
+// them with one Authorizer call, checks the versions they carry, merges each
+// record into its stored copy and enforces the writable fields of policy and
+// the scope on the result. It returns the merged records and their expected
+// versions.
+func (c *Controller[T]) prepareBatchUpdates(ctx Context, svc Service[T], criteria []repository.SelectCriteria, policy resolvedFieldPolicy, scope ScopeFilter, records []T) ([]T, []string, error) {
+	stored, err := c.loadBatchTargets(ctx, svc, OpUpdateBatch, criteria, records)
+	if err != nil {
+		return nil, nil, err
+	}
+	merged := make([]T, len(records))
+	versions := make([]string, len(records))
+	for i, rec := range records {
+		existing := stored[i]
+		if versions[i], err = checkRecordPrecondition(existing, rec); err != nil {
+			return nil, nil, err
+		}
+		if merged[i], err = mergeRecordWithExisting(rec, existing); err != nil {
+			return nil, nil, err
+		}
+		merged[i] = mergeVirtualMaps(existing, merged[i], c.virtualFieldDefs, c.mergePolicy)
+		if merged[i], err = enforceUpdateWrite(policy, scope, OpUpdateBatch, merged[i], existing); err != nil {
+			return nil, nil, err
+		}
+	}
+	return merged, versions, nil
+}
+
+// updateBatchAtomic prepares every record and updates them in one transaction.
+// records is updated in place with the merged values.
+func (c *Controller[T]) updateBatchAtomic(ctx Context, svc Service[T], criteria []repository.SelectCriteria, policy resolvedFieldPolicy, scope ScopeFilter, records []T) ([]T, error) {
+	var updated []T
+	err := c.runInTx(ctx, func() error {
+		merged, versions, err := c.prepareBatchUpdates(ctx, svc, criteria, policy, scope, records)
+		if err != nil {
+			return err
+		}
+		copy(records, merged)
+		if slices.ContainsFunc(versions, func(v string) bool { return v != "" }) {
+			attachExpectedVersions(ctx, versions...)
+		}
+		updated, err = svc.UpdateBatch(ctx, records)
+		return err
+	})
+	return updated, err
+}
+
+// checkDeleteTargets loads each record of a batch delete with criteria and
+// authorizes them, so deletes by ID only reach rows the request can see and
+// may delete.
+func (c *Controller[T]) checkDeleteTargets(ctx Context, svc Service[T], criteria []repository.SelectCriteria, records []T) error {
+	if len(criteria) == 0 && c.authorizer == nil {
+		return nil
+	}
+	_, err := c.loadBatchTargets(ctx, svc, OpDeleteBatch, criteria, records)
+	return err
+}
+
+// loadBatchTargets loads each record of a batch addressed by ID with criteria,
+// so IDs outside the request scope are reported as not found while other
+// lookup errors pass through, authorizes op on
+// the stored records with one Authorizer call and returns them.
+func (c *Controller[T]) loadBatchTargets(ctx Context, svc Service[T], op CrudOperation, criteria []repository.SelectCriteria, records []T) ([]T, error) {
+	stored := make([]T, 0, len(records))
+	for _, record := range records {
+		id, err := formatRecordID(c.Repo.Handlers(), c.idCodec, record)
+		if err != nil {
+			return nil, &ValidationError{err}
+		}
+		existing, err := svc.Show(ctx, id, slices.Clone(criteria))
+		if repository.IsRecordNotFound(err) {
+			return nil, &NotFoundError{err}
+		}
+		if err != nil {
+			return nil, err
+		}
+		stored = append(stored, existing)
+	}
+	if err := c.authorizeAll(ctx, op, stored); err != nil {
+		return nil, err
+	}
+	return stored, nil
+}
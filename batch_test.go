@@ -0,0 +1,152 @@
+package crud
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	goerrors "github.com/goliatone/go-errors"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+)
+
+func batchRequest(method, path string, body any) *http.Request {
+	payload, _ := json.Marshal(body)
+	req := httptest.NewRequest(method, path, bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func countTestUsers(t *testing.T, db *bun.DB) int {
+	t.Helper()
+	count, err := db.NewSelect().Model((*TestUser)(nil)).Count(context.Background())
+	require.NoError(t, err)
+	return count
+}
+
+func TestController_CreateBatch_RollsBackWithHooks(t *testing.T) {
+	var sawTx bool
+	app, db := setupApp(t, WithLifecycleHooks(LifecycleHooks[*TestUser]{
+		AfterCreateBatch: []HookBatchFunc[*TestUser]{
+			func(hctx HookContext, records []*TestUser) error {
+				tx, ok := hctx.Tx()
+				sawTx = ok
+				if ok {
+					_, err := tx.NewInsert().Model(&TestUser{ID: uuid.New(), Name: "Audit", Email: "tx-audit@example.com"}).Exec(hctx.Context.UserContext())
+					require.NoError(t, err)
+				}
+				return errors.New("audit failed")
+			},
+		},
+	}))
+	defer db.Close()
+
+	resp, err := app.Test(batchRequest(http.MethodPost, "/test-user/batch", []map[string]any{
+		{"name": "Tx One", "email": "tx-one@example.com"},
+		{"name": "Tx Two", "email": "tx-two@example.com"},
+	}), -1)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	assert.True(t, sawTx, "batch hooks run inside the transaction")
+	assert.Zero(t, countTestUsers(t, db), "records and hook writes are rolled back")
+}
+
+func TestController_Batch_NonAtomicReportsPerItemResults(t *testing.T) {
+	app, db := setupApp(t)
+	defer db.Close()
+
+	existing := &TestUser{ID: uuid.New(), Name: "Taken", Email: "taken@example.com"}
+	insertTestUsers(t, db, existing)
+
+	resp, err := app.Test(batchRequest(http.MethodPost, "/test-user/batch?atomic=false", []map[string]any{
+		{"name": "Fresh", "email": "fresh@example.com"},
+		{"name": "Duplicate", "email": "taken@example.com"},
+	}), -1)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusMultiStatus, resp.StatusCode)
+
+	var out struct {
+		Success bool              `json:"success"`
+		Data    []BatchItemResult `json:"data"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+	assert.False(t, out.Success)
+	require.Len(t, out.Data, 2)
+	assert.Equal(t, 0, out.Data[0].Index)
+	assert.Equal(t, http.StatusCreated, out.Data[0].Status)
+	assert.NotEmpty(t, out.Data[0].ID)
+	assert.Nil(t, out.Data[0].Error)
+	assert.Equal(t, 1, out.Data[1].Index)
+	assert.GreaterOrEqual(t, out.Data[1].Status, http.StatusBadRequest)
+	require.NotNil(t, out.Data[1].Error)
+	assert.Equal(t, out.Data[1].Status, out.Data[1].Error.Code)
+	assert.Equal(t, 2, countTestUsers(t, db), "the valid row is kept")
+
+	resp, err = app.Test(batchRequest(http.MethodPut, "/test-user/batch?atomic=false", []map[string]any{
+		{"id": existing.ID, "name": "Renamed"},
+		{"id": uuid.New(), "name": "Missing"},
+	}), -1)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusMultiStatus, resp.StatusCode)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+	assert.Equal(t, http.StatusOK, out.Data[0].Status)
+	assert.Equal(t, http.StatusNotFound, out.Data[1].Status)
+
+	var stored TestUser
+	require.NoError(t, db.NewSelect().Model(&stored).Where("id = ?", existing.ID).Scan(context.Background()))
+	assert.Equal(t, "Renamed", stored.Name)
+}
+
+type resultRecordingHandler[T any] struct {
+	ResponseHandler[T]
+	statuses []int
+}
+
+func (h *resultRecordingHandler[T]) OnResult(ctx Context, status int, result any, op CrudOperation) error {
+	h.statuses = append(h.statuses, status)
+	return ctx.Status(status).JSON(result)
+}
+
+func TestController_Batch_NonAtomicUsesControllerResponses(t *testing.T) {
+	var single, batch int
+	handler := &resultRecordingHandler[*TestUser]{ResponseHandler: NewDefaultResponseHandler[*TestUser]()}
+	app, db := setupApp(t,
+		WithResponseHandler[*TestUser](handler),
+		WithErrorEncoder[*TestUser](ProblemJSONErrorEncoder(WithProblemJSONStatusResolver(func(*goerrors.Error, CrudOperation) int {
+			return http.StatusTeapot
+		}))),
+		WithLifecycleHooks(LifecycleHooks[*TestUser]{
+			BeforeCreate: []HookFunc[*TestUser]{func(HookContext, *TestUser) error { single++; return nil }},
+			BeforeCreateBatch: []HookBatchFunc[*TestUser]{func(HookContext, []*TestUser) error {
+				batch++
+				return nil
+			}},
+		}),
+	)
+	defer db.Close()
+	insertTestUsers(t, db, &TestUser{ID: uuid.New(), Name: "Taken", Email: "taken@example.com"})
+
+	resp, err := app.Test(batchRequest(http.MethodPost, "/test-user/batch?atomic=false", []map[string]any{
+		{"name": "Fresh", "email": "fresh@example.com"},
+		{"name": "Duplicate", "email": "taken@example.com"},
+	}), -1)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusMultiStatus, resp.StatusCode)
+	assert.Equal(t, []int{http.StatusMultiStatus}, handler.statuses, "207 responses go through the response handler")
+
+	var out struct {
+		Data []BatchItemResult `json:"data"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+	require.Len(t, out.Data, 2)
+	assert.Equal(t, http.StatusTeapot, out.Data[1].Status, "item errors follow the controller's error encoder")
+	assert.Equal(t, http.StatusTeapot, out.Data[1].Error.Code)
+	assert.Equal(t, 2, single, "items are written with the single-record hooks")
+	assert.Zero(t, batch)
+}
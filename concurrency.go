@@ -0,0 +1,440 @@
+package crud
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ettle/strcase"
+	"github.com/goliatone/go-repository-bun"
+	"github.com/uptrace/bun"
+)
+
+const (
+	// TAG_CRUD_VERSION marks the optimistic concurrency field: `crud:"version"`.
+	TAG_CRUD_VERSION = "version"
+
+	versionFallbackColumn                   = "updated_at"
+	ctxKeyExpectedVersion requestContextKey = "crud.expected_version"
+)
+
+// PreconditionFailedError reports an optimistic concurrency conflict: the
+// stored record version does not match the version the caller expected.
+type PreconditionFailedError struct{ error }
+
+func newPreconditionFailed(expected string) *PreconditionFailedError {
+	return &PreconditionFailedError{fmt.Errorf("record version does not match %q", expected)}
+}
+
+// ContextWithExpectedVersion requests an optimistic concurrency check for the
+// next Update or Delete executed with ctx. It is the non-HTTP equivalent of
+// an If-Match header.
+func ContextWithExpectedVersion(ctx context.Context, version string) context.Context {
+	if ctx == nil || strings.TrimSpace(version) == "" {
+		return ctx
+	}
+	return ContextWithExpectedVersions(ctx, strings.TrimSpace(version))
+}
+
+// ContextWithExpectedVersions is the batch form of ContextWithExpectedVersion.
+// Versions align by position with the records passed to UpdateBatch; empty
+// entries skip the check for that record.
+func ContextWithExpectedVersions(ctx context.Context, versions ...string) context.Context {
+	if ctx == nil || len(versions) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, ctxKeyExpectedVersion, append([]string{}, versions...))
+}
+
+// ExpectedVersionsFromContext returns versions stored with ContextWithExpectedVersions.
+func ExpectedVersionsFromContext(ctx context.Context) []string {
+	if ctx == nil {
+		return nil
+	}
+	if versions, ok := ctx.Value(ctxKeyExpectedVersion).([]string); ok {
+		return versions
+	}
+	return nil
+}
+
+func expectedVersionAt(ctx context.Context, index int) string {
+	versions := ExpectedVersionsFromContext(ctx)
+	if index < 0 || index >= len(versions) {
+		return ""
+	}
+	return strings.TrimSpace(versions[index])
+}
+
+// ETagForVersion renders a strong entity tag for a record version.
+func ETagForVersion(version string) string {
+	return strconv.Quote(version)
+}
+
+// parseIfMatch returns the entity tags listed in an If-Match header. Weak
+// validators are compared by their opaque value.
+func parseIfMatch(header string) (tags []string, wildcard bool) {
+	for raw := range strings.SplitSeq(header, ",") {
+		tag := strings.TrimSpace(raw)
+		if tag == "" {
+			continue
+		}
+		if tag == "*" {
+			return nil, true
+		}
+		tag = strings.TrimPrefix(tag, "W/")
+		if unquoted, err := strconv.Unquote(tag); err == nil {
+			tag = unquoted
+		}
+		tags = append(tags, tag)
+	}
+	return tags, false
+}
+
+type versionField struct {
+	index    []int
+	column   string
+	explicit bool
+}
+
+var versionFieldCache sync.Map // map[reflect.Type]versionField
+
+// versionFieldFor resolves the field tagged `crud:"version"`, falling back to
+// the `updated_at` column.
+func versionFieldFor(typ reflect.Type) (versionField, bool) {
+	for typ != nil && typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return versionField{}, false
+	}
+	if cached, ok := versionFieldCache.Load(typ); ok {
+		field := cached.(versionField)
+		return field, field.index != nil
+	}
+	var explicit, fallback versionField
+	collectVersionFields(typ, nil, &explicit, &fallback)
+	field := explicit
+	if field.index == nil {
+		field = fallback
+	}
+	versionFieldCache.Store(typ, field)
+	return field, field.index != nil
+}
+
+func collectVersionFields(typ reflect.Type, parent []int, explicit, fallback *versionField) {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		index := append(append([]int{}, parent...), i)
+		bunTag := field.Tag.Get(TAG_BUN)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && bunTag == "" {
+			collectVersionFields(field.Type, index, explicit, fallback)
+			continue
+		}
+		if !field.IsExported() || bunTag == "-" {
+			continue
+		}
+		column := strings.TrimSpace(strings.Split(bunTag, ",")[0])
+		if column == "" {
+			column = strcase.ToSnake(field.Name)
+		}
+		if explicit.index == nil && hasTagOption(field.Tag.Get(TAG_CRUD), TAG_CRUD_VERSION) {
+			*explicit = versionField{index: index, column: column, explicit: true}
+			continue
+		}
+		if fallback.index == nil && column == versionFallbackColumn {
+			*fallback = versionField{index: index, column: column}
+		}
+	}
+}
+
+func hasTagOption(tag, option string) bool {
+	for part := range strings.SplitSeq(tag, ",") {
+		if strings.TrimSpace(part) == option {
+			return true
+		}
+	}
+	return false
+}
+
+func (f versionField) value(record any) (reflect.Value, bool) {
+	rv, ok := recordStructValue(record)
+	if !ok {
+		return reflect.Value{}, false
+	}
+	fv, err := rv.FieldByIndexErr(f.index)
+	if err != nil {
+		return reflect.Value{}, false
+	}
+	return fv, true
+}
+
+// recordVersion returns the version token of record, if the model is versioned.
+func recordVersion(record any) (string, bool) {
+	field, ok := versionFieldFor(reflect.TypeOf(record))
+	if !ok {
+		return "", false
+	}
+	fv, ok := field.value(record)
+	if !ok {
+		return "", false
+	}
+	for fv.Kind() == reflect.Pointer {
+		if fv.IsNil() {
+			return "", false
+		}
+		fv = fv.Elem()
+	}
+	if t, ok := fv.Interface().(time.Time); ok {
+		if t.IsZero() {
+			return "", false
+		}
+		// Databases keep timestamps to the microsecond (bun writes them that
+		// way), so finer digits would never match a stored value.
+		return strconv.FormatInt(t.UnixMicro(), 10), true
+	}
+	switch fv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(fv.Int(), 10), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(fv.Uint(), 10), true
+	case reflect.String:
+		return fv.String(), fv.String() != ""
+	default:
+		return "", false
+	}
+}
+
+// recordETag returns the ETag header value for record.
+func recordETag(record any) (string, bool) {
+	version, ok := recordVersion(record)
+	if !ok {
+		return "", false
+	}
+	return ETagForVersion(version), true
+}
+
+// listETag derives a weak ETag from the ids and versions of records.
+func listETag[T any](handlers repository.ModelHandlers[T], codec IDCodec, records []T) (string, bool) {
+	hash := sha256.New()
+	for _, record := range records {
+		version, ok := recordVersion(record)
+		if !ok {
+			return "", false
+		}
+		id, err := formatRecordID(handlers, codec, record)
+		if err != nil {
+			return "", false
+		}
+		fmt.Fprintf(hash, "%s:%s;", id, version)
+	}
+	return "W/" + strconv.Quote(hex.EncodeToString(hash.Sum(nil))[:32]), true
+}
+
+// versionArg converts a version token into the field's Go type for binding.
+func (f versionField) versionArg(record any, version string) (any, error) {
+	fv, ok := f.value(record)
+	if !ok {
+		return nil, fmt.Errorf("version field not found on %T", record)
+	}
+	typ := fv.Type()
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ == reflect.TypeFor[time.Time]() {
+		micros, err := strconv.ParseInt(version, 10, 64)
+		if err != nil {
+			return nil, newPreconditionFailed(version)
+		}
+		return time.UnixMicro(micros).UTC(), nil
+	}
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(version, 10, 64)
+		if err != nil {
+			return nil, newPreconditionFailed(version)
+		}
+		return n, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(version, 10, 64)
+		if err != nil {
+			return nil, newPreconditionFailed(version)
+		}
+		return n, nil
+	case reflect.String:
+		return version, nil
+	default:
+		return nil, fmt.Errorf("unsupported version field type %s", typ)
+	}
+}
+
+// bump advances the record version: explicit numeric versions are
+// incremented and timestamp versions, including the `updated_at` fallback,
+// are set to the current time. from is the version the update is conditioned
+// on; when empty the record's current value is used.
+func (f versionField) bump(record any, from any) {
+	fv, ok := f.value(record)
+	if !ok {
+		return
+	}
+	if fv.Kind() == reflect.Pointer {
+		if fv.IsNil() {
+			if !fv.CanSet() {
+				return
+			}
+			fv.Set(reflect.New(fv.Type().Elem()))
+		}
+		fv = fv.Elem()
+	}
+	if !fv.CanSet() {
+		return
+	}
+	if current, ok := fv.Interface().(time.Time); ok {
+		if previous, ok := from.(time.Time); ok {
+			current = previous
+		}
+		fv.Set(reflect.ValueOf(nextVersionTime(current)))
+		return
+	}
+	if !f.explicit {
+		return
+	}
+	switch fv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		current := fv.Int()
+		if n, ok := from.(int64); ok {
+			current = n
+		}
+		fv.SetInt(current + 1)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		current := fv.Uint()
+		if n, ok := from.(uint64); ok {
+			current = n
+		}
+		fv.SetUint(current + 1)
+	}
+}
+
+// nextVersionTime returns the current time at microsecond precision, moved
+// past previous so two writes within the same microsecond still differ.
+func nextVersionTime(previous time.Time) time.Time {
+	next := time.Now().UTC().Truncate(time.Microsecond)
+	if floor := previous.UTC().Truncate(time.Microsecond); !next.After(floor) {
+		next = floor.Add(time.Microsecond)
+	}
+	return next
+}
+
+// prepareVersionedUpdate bumps the record version and returns the criteria
+// that make the UPDATE conditional on expected. Without an expected version
+// only explicit `crud:"version"` fields are bumped, so an `updated_at` set by
+// the caller or a hook is kept.
+func prepareVersionedUpdate(record any, expected string) ([]repository.UpdateCriteria, error) {
+	field, ok := versionFieldFor(reflect.TypeOf(record))
+	if !ok {
+		if expected != "" {
+			return nil, newPreconditionFailed(expected)
+		}
+		return nil, nil
+	}
+	if expected == "" {
+		if field.explicit {
+			field.bump(record, nil)
+		}
+		return nil, nil
+	}
+	arg, err := field.versionArg(record, expected)
+	if err != nil {
+		return nil, err
+	}
+	field.bump(record, arg)
+	column := field.column
+	return []repository.UpdateCriteria{func(q *bun.UpdateQuery) *bun.UpdateQuery {
+		return q.Where("?TableAlias.? = ?", bun.Ident(column), arg)
+	}}, nil
+}
+
+// explicitRecordVersion returns the value of a non-zero `crud:"version"` field.
+func explicitRecordVersion(record any) (string, bool) {
+	field, ok := versionFieldFor(reflect.TypeOf(record))
+	if !ok || !field.explicit {
+		return "", false
+	}
+	if fv, ok := field.value(record); !ok || fv.IsZero() {
+		return "", false
+	}
+	return recordVersion(record)
+}
+
+// checkPrecondition validates the requested version against the loaded
+// record and returns the matched version to enforce on write. The version is
+// taken from the If-Match header, then ContextWithExpectedVersion, then an
+// explicit version carried by payload. An empty result means no check.
+func checkPrecondition(ctx Context, existing, payload any) (string, error) {
+	var tags []string
+	if header := requestHeader(ctx, "If-Match"); header != "" {
+		var wildcard bool
+		if tags, wildcard = parseIfMatch(header); wildcard {
+			return "", nil
+		}
+	} else if expected := expectedVersionFromRequest(ctx); expected != "" {
+		tags = []string{expected}
+	} else if version, ok := explicitRecordVersion(payload); ok {
+		tags = []string{version}
+	}
+	if len(tags) == 0 {
+		return "", nil
+	}
+	current, ok := recordVersion(existing)
+	if !ok {
+		return "", newPreconditionFailed(tags[0])
+	}
+	for _, tag := range tags {
+		if tag == current {
+			return current, nil
+		}
+	}
+	return "", newPreconditionFailed(tags[0])
+}
+
+// checkRecordPrecondition compares an explicit version carried by a batch
+// payload record with the stored record.
+func checkRecordPrecondition(existing, payload any) (string, error) {
+	version, ok := explicitRecordVersion(payload)
+	if !ok {
+		return "", nil
+	}
+	if current, _ := recordVersion(existing); current != version {
+		return "", newPreconditionFailed(version)
+	}
+	return version, nil
+}
+
+func expectedVersionFromRequest(ctx Context) string {
+	if ctx == nil {
+		return ""
+	}
+	return expectedVersionAt(ctx.UserContext(), 0)
+}
+
+func attachExpectedVersions(ctx Context, versions ...string) {
+	if ctx == nil || len(versions) == 0 {
+		return
+	}
+	updated := ContextWithExpectedVersions(ctx.UserContext(), versions...)
+	if setter, ok := ctx.(userContextSetter); ok && updated != nil {
+		setter.SetUserContext(updated)
+	}
+}
+
+func setETagHeader(ctx Context, etag string) {
+	if etag == "" {
+		return
+	}
+	setResponseHeader(ctx, "ETag", etag)
+}
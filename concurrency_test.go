@@ -0,0 +1,202 @@
+package crud
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+
+	"github.com/goliatone/go-repository-bun"
+)
+
+type versionedDoc struct {
+	bun.BaseModel `bun:"table:versioned_docs,alias:vd"`
+
+	ID      uuid.UUID `bun:"id,pk,notnull" json:"id"`
+	Title   string    `bun:"title" json:"title"`
+	Version int64     `bun:"version,notnull" json:"version" crud:"version"`
+}
+
+func newVersionedDocRepository(db *bun.DB) repository.Repository[*versionedDoc] {
+	return repository.NewRepository(db, repository.ModelHandlers[*versionedDoc]{
+		NewRecord:     func() *versionedDoc { return &versionedDoc{} },
+		GetID:         func(doc *versionedDoc) uuid.UUID { return doc.ID },
+		SetID:         func(doc *versionedDoc, id uuid.UUID) { doc.ID = id },
+		GetIdentifier: func() string { return "Title" },
+	})
+}
+
+func setupVersionedDocApp(t *testing.T) (*fiber.App, repository.Repository[*versionedDoc], *versionedDoc) {
+	t.Helper()
+	db := newCodecTestDB(t, (*versionedDoc)(nil))
+	repo := newVersionedDocRepository(db)
+	doc := &versionedDoc{ID: uuid.New(), Title: "Draft", Version: 1}
+	_, err := db.NewInsert().Model(doc).Exec(context.Background())
+	require.NoError(t, err)
+
+	app := fiber.New()
+	NewController(repo).RegisterRoutes(NewFiberAdapter(app))
+	return app, repo, doc
+}
+
+func versionedDocRequest(method, path, ifMatch string, body any) *http.Request {
+	var payload []byte
+	if body != nil {
+		payload, _ = json.Marshal(body)
+	}
+	req := httptest.NewRequest(method, path, bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	if ifMatch != "" {
+		req.Header.Set("If-Match", ifMatch)
+	}
+	return req
+}
+
+func TestController_OptimisticConcurrency_Update(t *testing.T) {
+	app, repo, doc := setupVersionedDocApp(t)
+	path := "/versioned-doc/" + doc.ID.String()
+
+	resp, err := app.Test(versionedDocRequest(http.MethodGet, path, "", nil), -1)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, `"1"`, resp.Header.Get("ETag"))
+
+	resp, err = app.Test(versionedDocRequest(http.MethodGet, "/versioned-docs", "", nil), -1)
+	require.NoError(t, err)
+	assert.Regexp(t, `^W/"[0-9a-f]+"$`, resp.Header.Get("ETag"))
+
+	resp, err = app.Test(versionedDocRequest(http.MethodPut, path, `"7"`, map[string]any{"title": "Stale"}), -1)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("Content-Type"), "application/problem+json")
+
+	resp, err = app.Test(versionedDocRequest(http.MethodPut, path, `"1"`, map[string]any{"title": "Final"}), -1)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, `"2"`, resp.Header.Get("ETag"))
+
+	stored, err := repo.GetByID(context.Background(), doc.ID.String())
+	require.NoError(t, err)
+	assert.Equal(t, "Final", stored.Title)
+	assert.Equal(t, int64(2), stored.Version)
+
+	resp, err = app.Test(versionedDocRequest(http.MethodPut, path, "", map[string]any{"title": "Stale body", "version": 1}), -1)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode, "a version carried in the payload is checked too")
+}
+
+func TestController_OptimisticConcurrency_Delete(t *testing.T) {
+	app, repo, doc := setupVersionedDocApp(t)
+	path := "/versioned-doc/" + doc.ID.String()
+
+	resp, err := app.Test(versionedDocRequest(http.MethodDelete, path, `"3"`, nil), -1)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+	_, err = repo.GetByID(context.Background(), doc.ID.String())
+	require.NoError(t, err)
+
+	resp, err = app.Test(versionedDocRequest(http.MethodDelete, path, `"1"`, nil), -1)
+	require.NoError(t, err)
+	assert.Less(t, resp.StatusCode, 300)
+	_, err = repo.GetByID(context.Background(), doc.ID.String())
+	assert.Error(t, err)
+}
+
+type stampedDoc struct {
+	bun.BaseModel `bun:"table:stamped_docs,alias:sd"`
+
+	ID        uuid.UUID `bun:"id,pk,notnull" json:"id"`
+	Title     string    `bun:"title" json:"title"`
+	UpdatedAt time.Time `bun:"updated_at,notnull" json:"updated_at"`
+}
+
+func TestController_OptimisticConcurrency_UpdatedAtFallback(t *testing.T) {
+	db := newCodecTestDB(t, (*stampedDoc)(nil))
+	doc := &stampedDoc{ID: uuid.New(), Title: "Draft", UpdatedAt: time.Date(2024, 5, 6, 7, 8, 9, 123456789, time.UTC)}
+	_, err := db.NewInsert().Model(doc).Exec(context.Background())
+	require.NoError(t, err)
+	app := fiber.New()
+	NewController(repository.NewRepository(db, repository.ModelHandlers[*stampedDoc]{
+		NewRecord:     func() *stampedDoc { return &stampedDoc{} },
+		GetID:         func(doc *stampedDoc) uuid.UUID { return doc.ID },
+		SetID:         func(doc *stampedDoc, id uuid.UUID) { doc.ID = id },
+		GetIdentifier: func() string { return "Title" },
+	})).RegisterRoutes(NewFiberAdapter(app))
+	path := "/stamped-doc/" + doc.ID.String()
+
+	resp, err := app.Test(versionedDocRequest(http.MethodGet, path, "", nil), -1)
+	require.NoError(t, err)
+	read := resp.Header.Get("ETag")
+	assert.Equal(t, `"1714979289123456"`, read, "versions are kept at the database's microsecond precision")
+
+	resp, err = app.Test(versionedDocRequest(http.MethodPut, path, read, map[string]any{"title": "Final"}), -1)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	written := resp.Header.Get("ETag")
+	assert.NotEqual(t, read, written, "updates advance updated_at")
+
+	resp, err = app.Test(versionedDocRequest(http.MethodPut, path, read, map[string]any{"title": "Stale"}), -1)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+
+	resp, err = app.Test(versionedDocRequest(http.MethodPut, path, "", map[string]any{"title": "Unlocked", "updated_at": "2030-01-02T03:04:05Z"}), -1)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	unlocked := resp.Header.Get("ETag")
+	assert.Equal(t, `"1893553445000000"`, unlocked, "updates without If-Match keep the given updated_at")
+
+	resp, err = app.Test(versionedDocRequest(http.MethodDelete, path, unlocked, nil), -1)
+	require.NoError(t, err)
+	assert.Less(t, resp.StatusCode, 300)
+}
+
+func TestController_OptimisticConcurrency_UpdateBatch(t *testing.T) {
+	app, repo, doc := setupVersionedDocApp(t)
+
+	body := []map[string]any{{"id": doc.ID, "title": "Batch", "version": 5}}
+	resp, err := app.Test(versionedDocRequest(http.MethodPut, "/versioned-doc/batch", "", body), -1)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+
+	body[0]["version"] = 1
+	resp, err = app.Test(versionedDocRequest(http.MethodPut, "/versioned-doc/batch", "", body), -1)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	stored, err := repo.GetByID(context.Background(), doc.ID.String())
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), stored.Version)
+}
+
+func TestRepositoryService_UpdateIsConditionalOnVersion(t *testing.T) {
+	db := newCodecTestDB(t, (*versionedDoc)(nil))
+	repo := newVersionedDocRepository(db)
+	doc := &versionedDoc{ID: uuid.New(), Title: "Draft", Version: 1}
+	_, err := db.NewInsert().Model(doc).Exec(context.Background())
+	require.NoError(t, err)
+
+	svc := NewRepositoryService(repo)
+	// A concurrent writer bumps the version after the caller read version 1.
+	_, err = db.NewUpdate().Model((*versionedDoc)(nil)).Set("version = 2").Where("id = ?", doc.ID).Exec(context.Background())
+	require.NoError(t, err)
+
+	ctx := newMockRequest()
+	ctx.userCtx = ContextWithExpectedVersion(context.Background(), "1")
+	_, err = svc.Update(ctx, &versionedDoc{ID: doc.ID, Title: "Lost update", Version: 1})
+	var precondition *PreconditionFailedError
+	require.True(t, errors.As(err, &precondition), "got %v", err)
+
+	stored, err := repo.GetByID(context.Background(), doc.ID.String())
+	require.NoError(t, err)
+	assert.Equal(t, "Draft", stored.Title)
+}
@@ -13,7 +13,6 @@ import (
 	"github.com/goliatone/go-crud/pkg/activity"
 	"github.com/goliatone/go-repository-bun"
 	"github.com/goliatone/go-router"
-	"github.com/google/uuid"
 )
 
 // CrudOperation defines the type for CRUD operations.
@@ -26,8 +25,22 @@ const (
 	OpList        CrudOperation = "list"
 	OpUpdate      CrudOperation = "update"
 	OpUpdateBatch CrudOperation = "update:batch"
+	OpPatch       CrudOperation = "patch"
 	OpDelete      CrudOperation = "delete"
 	OpDeleteBatch CrudOperation = "delete:batch"
+	OpAggregate   CrudOperation = "aggregate"
+	OpExport      CrudOperation = "export"
+	OpImport      CrudOperation = "import"
+	OpUpsert      CrudOperation = "upsert"
+	OpUpsertBatch CrudOperation = "upsert:batch"
+	// Filter mutations write every row matching the list filters.
+	OpUpdateByFilter CrudOperation = "update:filter"
+	OpDeleteByFilter CrudOperation = "delete:filter"
+	// Restore and purge routes are registered only for soft-deletable models.
+	OpRestore      CrudOperation = "restore"
+	OpRestoreBatch CrudOperation = "restore:batch"
+	OpPurge        CrudOperation = "purge"
+	OpPurgeBatch   CrudOperation = "purge:batch"
 )
 
 var operationDefaultMethods = map[CrudOperation]string{
@@ -37,8 +50,22 @@ var operationDefaultMethods = map[CrudOperation]string{
 	OpList:        http.MethodGet,
 	OpUpdate:      http.MethodPut,
 	OpUpdateBatch: http.MethodPut,
+	OpPatch:       http.MethodPatch,
 	OpDelete:      http.MethodDelete,
 	OpDeleteBatch: http.MethodDelete,
+	OpAggregate:   http.MethodGet,
+	OpExport:      http.MethodGet,
+	OpImport:      http.MethodPost,
+	OpUpsert:      http.MethodPut,
+	OpUpsertBatch: http.MethodPut,
+
+	OpUpdateByFilter: http.MethodPatch,
+	OpDeleteByFilter: http.MethodDelete,
+
+	OpRestore:      http.MethodPost,
+	OpRestoreBatch: http.MethodPost,
+	OpPurge:        http.MethodDelete,
+	OpPurgeBatch:   http.MethodDelete,
 }
 
 // MergePolicy controls how partial updates are interpreted.
@@ -94,6 +121,7 @@ type Controller[T any] struct {
 	activityEmitterHooks  *activity.Emitter   // activity log events
 	notificationEmitter   NotificationEmitter // user facing notifications
 	fieldPolicyProvider   FieldPolicyProvider[T]
+	authorizer            *recordAuthorizer[T]
 	actions               []Action[T]
 	actionDescriptors     []ActionDescriptor
 	actionRouteDefs       []router.RouteDefinition
@@ -105,11 +133,20 @@ type Controller[T any] struct {
 	routeNames            map[CrudOperation]string
 	relationProvider      router.RelationMetadataProvider
 	scopeGuard            ScopeGuardFunc[T]
+	tenancy               *TenancyConfig
 	contextFactory        func(Context) Context
 	virtualFieldsEnabled  bool
 	virtualFieldConfig    VirtualFieldHandlerConfig
 	mergePolicy           MergePolicy
 	virtualFieldDefs      []VirtualFieldDef
+	idCodec               IDCodec
+	idempotency           *idempotencyPolicy
+	exportConfig          ExportConfig
+	searchConfig          SearchConfig
+	queryLimits           QueryLimits
+	importConfig          ImportConfig
+	validator             ValidatorFunc[T]
+	upsertConflictColumns []string
 }
 
 // NewController creates a new Controller with functional options.
@@ -165,6 +202,12 @@ func (c *Controller[T]) initialize() {
 	c.attachVirtualFieldHooks()
 	c.buildService()
 
+	// The service applies tenancy itself; the controller wraps its own guard
+	// only once the service is built.
+	if c.tenancy != nil {
+		c.scopeGuard = tenancyGuard(*c.tenancy, c.scopeGuard)
+	}
+
 	if c.fieldMapProvider == nil {
 		if provider := newFieldMapProviderFromRepo(c.Repo, c.resourceType); provider != nil {
 			c.fieldMapProvider = provider
@@ -245,6 +288,16 @@ func (c *Controller[T]) RegisterRoutes(r Router) {
 		c.recordRouteMetadata(op, method, path, routeName)
 	}
 
+	// /users/aggregate and /users/export go first so :id does not shadow them
+	// when the singular and plural names match.
+	aggregatePath := fmt.Sprintf("/%s/aggregate", resources)
+	aggregateRoute := fmt.Sprintf("%s:%s", resource, OpAggregate)
+	registerRoute(OpAggregate, http.MethodGet, aggregatePath, c.Aggregate, aggregateRoute)
+
+	exportPath := fmt.Sprintf("/%s/export", resources)
+	exportRoute := fmt.Sprintf("%s:%s", resource, OpExport)
+	registerRoute(OpExport, http.MethodGet, exportPath, c.Export, exportRoute)
+
 	// /user/:id
 	showPath := fmt.Sprintf("/%s/:id", resource)
 	readRoute := fmt.Sprintf("%s:%s", resource, OpRead)
@@ -255,25 +308,54 @@ func (c *Controller[T]) RegisterRoutes(r Router) {
 	listRoute := fmt.Sprintf("%s:%s", resource, OpList)
 	registerRoute(OpList, http.MethodGet, listPath, c.Index, listRoute)
 
+	// /users?status__eq=draft
+	updateByFilterRoute := fmt.Sprintf("%s:%s", resource, OpUpdateByFilter)
+	registerRoute(OpUpdateByFilter, http.MethodPatch, listPath, c.UpdateByFilter, updateByFilterRoute)
+	deleteByFilterRoute := fmt.Sprintf("%s:%s", resource, OpDeleteByFilter)
+	registerRoute(OpDeleteByFilter, http.MethodDelete, listPath, c.DeleteByFilter, deleteByFilterRoute)
+
 	batchSegment := c.batchSegment()
 
 	// /user/batch
 	createBatchPath := fmt.Sprintf("/%s/%s", resource, batchSegment)
 	createBatchRoute := fmt.Sprintf("%s:%s", resource, OpCreateBatch)
-	registerRoute(OpCreateBatch, http.MethodPost, createBatchPath, c.CreateBatch, createBatchRoute)
+	registerRoute(OpCreateBatch, http.MethodPost, createBatchPath, c.idempotent(OpCreateBatch, c.CreateBatch), createBatchRoute)
+
+	// /user/import
+	importPath := fmt.Sprintf("/%s/import", resource)
+	importRoute := fmt.Sprintf("%s:%s", resource, OpImport)
+	registerRoute(OpImport, http.MethodPost, importPath, c.idempotent(OpImport, c.Import), importRoute)
 
 	// /user
 	createPath := fmt.Sprintf("/%s", resource)
 	createRoute := fmt.Sprintf("%s:%s", resource, OpCreate)
-	registerRoute(OpCreate, http.MethodPost, createPath, c.Create, createRoute)
+	registerRoute(OpCreate, http.MethodPost, createPath, c.idempotent(OpCreate, c.Create), createRoute)
 
 	// /user/batch
 	updateBatchRoute := fmt.Sprintf("%s:%s", resource, OpUpdateBatch)
 	registerRoute(OpUpdateBatch, http.MethodPut, createBatchPath, c.UpdateBatch, updateBatchRoute)
 
+	// /user/batch/upsert
+	upsertBatchRoute := fmt.Sprintf("%s:%s", resource, OpUpsertBatch)
+	registerRoute(OpUpsertBatch, http.MethodPut, createBatchPath+"/upsert", c.UpsertBatch, upsertBatchRoute)
+
 	// /user
+	upsertRoute := fmt.Sprintf("%s:%s", resource, OpUpsert)
+	registerRoute(OpUpsert, http.MethodPut, createPath, c.Upsert, upsertRoute)
+
+	// /user/:id
 	updateRoute := fmt.Sprintf("%s:%s", resource, OpUpdate)
-	registerRoute(OpUpdate, http.MethodPut, showPath, c.Update, updateRoute)
+	registerRoute(OpUpdate, http.MethodPut, showPath, c.idempotent(OpUpdate, c.Update), updateRoute)
+
+	// /user/:id
+	// Skip when OpUpdate was remapped to PATCH so the two handlers don't collide.
+	if enabled, method := c.routeConfig.resolve(OpUpdate, http.MethodPut); !enabled || method != http.MethodPatch {
+		patchRoute := fmt.Sprintf("%s:%s", resource, OpPatch)
+		registerRoute(OpPatch, http.MethodPatch, showPath, c.idempotent(OpPatch, c.Patch), patchRoute)
+	}
+
+	// /user/batch/restore, /user/:id/restore, /user/batch/purge, /user/:id/purge
+	c.registerSoftDeleteRoutes(resource, createBatchPath, registerRoute)
 
 	// /user/batch
 	deleteBatchRoute := fmt.Sprintf("%s:%s", resource, OpDeleteBatch)
@@ -287,6 +369,13 @@ func (c *Controller[T]) RegisterRoutes(r Router) {
 	c.refreshSchemaRegistration()
 }
 
+// RouteEnabled reports whether the controller's RouteConfig enables the route
+// of op, for registrars that expose the same operations elsewhere.
+func (c *Controller[T]) RouteEnabled(op CrudOperation) bool {
+	enabled, _ := c.routeConfig.resolve(op, "")
+	return enabled
+}
+
 func (c *Controller[T]) batchSegment() string {
 	segment := strings.TrimSpace(c.batchRouteSegment)
 	segment = strings.Trim(segment, "/")
@@ -422,12 +511,29 @@ func (c *Controller[T]) resolveFieldPolicy(ctx Context, op CrudOperation, meta g
 	return buildResolvedFieldPolicy[T](policy, baseFields, c.resource, op), nil
 }
 
+// QueryOptions returns the query builder options of the controller's list
+// routes, its search configuration and query limits, for callers building
+// criteria with BuildListCriteriaFromOptions and friends.
+func (c *Controller[T]) QueryOptions() []QueryBuilderOption {
+	return c.policyQueryOptions(resolvedFieldPolicy{})
+}
+
 func (c *Controller[T]) policyQueryOptions(decision resolvedFieldPolicy) []QueryBuilderOption {
+	opts := c.searchConfig.queryOptions()
+	if c.queryLimits != (QueryLimits{}) {
+		opts = append(opts, WithQueryLimits(c.queryLimits))
+	}
+	if c.tenancy != nil {
+		opts = append(opts, withTenancy(*c.tenancy))
+	}
+	if len(decision.relationRules) > 0 || len(decision.denySet) > 0 || len(decision.allowSet) > 0 {
+		opts = append(opts, withFieldPolicy(decision))
+	}
 	override := decision.allowedFieldOverride()
 	if len(override) == 0 {
-		return nil
+		return opts
 	}
-	return []QueryBuilderOption{WithAllowedFields(override)}
+	return append(opts, WithAllowedFields(override))
 }
 
 func (c *Controller[T]) logFieldPolicyDecision(decision resolvedFieldPolicy) {
@@ -471,6 +577,9 @@ func (c *Controller[T]) setResolvedActions(actions []resolvedAction[T]) {
 func (c *Controller[T]) registerActionRoutes(r Router, actions []resolvedAction[T], applyMeta func(method, path string, info RouterRouteInfo)) {
 	for _, action := range actions {
 		handler := c.buildActionHandler(action)
+		if action.method != http.MethodGet && action.method != http.MethodHead {
+			handler = c.idempotent(action.operation, handler)
+		}
 		info := invokeRoute(r, action.method, action.path, handler)
 		if info == nil {
 			continue
@@ -628,13 +737,17 @@ func (c *Controller[T]) attachHookContext(ctx Context, op CrudOperation) {
 
 func (c *Controller[T]) buildService() {
 	cfg := ServiceConfig[T]{
-		Repository:           c.Repo,
-		Hooks:                c.hooks,
-		ScopeGuard:           c.scopeGuard,
-		FieldPolicy:          c.fieldPolicyProvider,
-		ResourceName:         c.resource,
-		ResourceType:         c.resourceType,
-		BatchReturnOrderByID: c.batchReturnOrderByID,
+		Repository:            c.Repo,
+		Hooks:                 c.hooks,
+		ScopeGuard:            c.scopeGuard,
+		Tenancy:               c.tenancy,
+		FieldPolicy:           c.fieldPolicyProvider,
+		Validator:             c.validator,
+		ResourceName:          c.resource,
+		ResourceType:          c.resourceType,
+		BatchReturnOrderByID:  c.batchReturnOrderByID,
+		IDCodec:               c.idCodec,
+		UpsertConflictColumns: c.upsertConflictColumns,
 	}
 
 	c.service = c.composeService(cfg, c.service, true)
@@ -654,8 +767,13 @@ func (c *Controller[T]) composeService(cfg ServiceConfig[T], svc Service[T], all
 			return nil
 		}
 		svc = NewService(cfg)
-	} else if !hooksEmpty(cfg.Hooks) {
-		svc = &hooksService[T]{next: svc, hooks: cfg.Hooks}
+	} else {
+		if cfg.Validator != nil {
+			svc = &validationService[T]{next: svc, validate: cfg.Validator}
+		}
+		if !hooksEmpty(cfg.Hooks) {
+			svc = &hooksService[T]{next: svc, hooks: cfg.Hooks}
+		}
 	}
 
 	if c.serviceOverrides != nil {
@@ -699,6 +817,8 @@ func (c *Controller[T]) attachVirtualFieldHooks() {
 		BeforeUpdate: []HookFunc[T]{handler.BeforeSave},
 		AfterCreate:  []HookFunc[T]{handler.AfterLoad},
 		AfterUpdate:  []HookFunc[T]{handler.AfterLoad},
+		BeforeUpsert: []HookFunc[T]{handler.BeforeSave},
+		AfterUpsert:  []HookFunc[T]{handler.AfterLoad},
 		AfterRead:    []HookFunc[T]{handler.AfterLoad},
 		AfterList:    []HookBatchFunc[T]{handler.AfterLoadBatch},
 	}
@@ -736,6 +856,7 @@ func (c *Controller[T]) Schema(ctx Context) error {
 	if len(doc) == 0 {
 		return ctx.SendStatus(http.StatusNoContent)
 	}
+	c.annotateWritableFieldsInSchema(ctx, doc, meta.Name)
 	return ctx.JSON(doc)
 }
 
@@ -813,6 +934,8 @@ func (c *Controller[T]) compileSchemaDocument() (router.ResourceMetadata, map[st
 	}
 
 	annotateVirtualFieldsInSchema(doc, meta.Name, c.resourceType)
+	annotateSoftDeleteInSchema(doc, meta.Name, c.resourceType)
+	annotateFilterOperatorsInSchema(doc, meta.Name)
 	c.applyAdminExtensions(doc, meta)
 	return meta, doc
 }
@@ -857,6 +980,13 @@ func (c *Controller[T]) Show(ctx Context) error {
 	if err != nil {
 		return c.resp.OnError(ctx, &NotFoundError{err}, OpRead)
 	}
+	record = filterTenantRelation(ctx, c.tenancy, record)
+	if err := c.authorizeRecord(ctx, OpRead, record); err != nil {
+		return c.resp.OnError(ctx, err, OpRead)
+	}
+	if etag, ok := recordETag(record); ok {
+		setETagHeader(ctx, etag)
+	}
 	applyFieldPolicyToRecord(record, policy)
 	return c.resp.OnData(ctx, record, OpRead, filters)
 }
@@ -869,6 +999,7 @@ func (c *Controller[T]) Show(ctx Context) error {
 // GET /users?name__ilike=John&age__gte=30
 // GET /users?name__and=John,Jack
 // GET /users?name__or=John,Jack
+// GET /users?cursor=<next_cursor>&limit=10
 func (c *Controller[T]) Index(ctx Context) error {
 	ctx = c.applyContextFactory(ctx)
 	svc := c.resolvedReadService()
@@ -890,6 +1021,10 @@ func (c *Controller[T]) Index(ctx Context) error {
 	if err != nil {
 		return c.resp.OnError(ctx, err, OpList)
 	}
+	facetCriteria, err := buildFacetCriteriaFromContext[T](ctx, queryOpts...)
+	if err != nil {
+		return c.resp.OnError(ctx, err, OpList)
+	}
 	criteria = c.applyScopeCriteria(criteria, meta.scope)
 	criteria = c.applyFieldPolicyCriteria(criteria, policy)
 
@@ -899,16 +1034,36 @@ func (c *Controller[T]) Index(ctx Context) error {
 	}
 
 	filters.Count = count
-	originalOffset := filters.Offset
-	adjusted := normalizePagination(filters, count)
-	if adjusted && count > 0 && filters.Offset != originalOffset {
-		adjustedCriteria := append(criteria, paginationCriteria(filters.Limit, filters.Offset))
-		records, _, err = svc.Index(ctx, adjustedCriteria)
+	if filters.keyset != nil {
+		records, err = ApplyCursorPage(filters, records, count)
 		if err != nil {
 			return c.resp.OnError(ctx, err, OpList)
 		}
+	} else {
+		originalOffset := filters.Offset
+		adjusted := normalizePagination(filters, count)
+		if adjusted && count > 0 && filters.Offset != originalOffset {
+			adjustedCriteria := append(criteria, paginationCriteria(filters.Limit, filters.Offset))
+			records, _, err = svc.Index(ctx, adjustedCriteria)
+			if err != nil {
+				return c.resp.OnError(ctx, err, OpList)
+			}
+		}
 	}
 
+	filterTenantRelations(ctx, c.tenancy, records)
+	// Count stays the total matched before authorization: rows the
+	// Authorizer drops are not subtracted, so pages keep their offsets.
+	records, err = c.authorizeList(ctx, OpList, records)
+	if err != nil {
+		return c.resp.OnError(ctx, err, OpList)
+	}
+
+	if len(records) > 0 {
+		if etag, ok := listETag(c.Repo.Handlers(), c.idCodec, records); ok {
+			setETagHeader(ctx, etag)
+		}
+	}
 	applyFieldPolicyToSlice(records, policy)
 
 	if shouldReturnOptions(ctx) {
@@ -916,6 +1071,10 @@ func (c *Controller[T]) Index(ctx Context) error {
 		return ctx.Status(http.StatusOK).JSON(options)
 	}
 
+	filters.Highlights = c.searchHighlights(records, filters.Search)
+	if filters.Facets, err = c.facets(ctx, meta, policy, facetCriteria); err != nil {
+		return c.resp.OnError(ctx, err, OpList)
+	}
 	return c.resp.OnList(ctx, records, OpList, filters)
 }
 
@@ -940,6 +1099,10 @@ func (c *Controller[T]) Create(ctx Context) error {
 		c.emitActivityEvents(ctx, OpCreate, meta, []T{record}, err)
 		return c.resp.OnError(ctx, &ValidationError{err}, OpCreate)
 	}
+	if record, err = enforceCreateWrite(policy, meta.scope, OpCreate, record); err != nil {
+		c.emitActivityEvents(ctx, OpCreate, meta, []T{record}, err)
+		return c.resp.OnError(ctx, err, OpCreate)
+	}
 
 	createdRecord, err := svc.Create(ctx, record)
 	if err != nil {
@@ -974,7 +1137,30 @@ func (c *Controller[T]) CreateBatch(ctx Context) error {
 		return c.resp.OnError(ctx, &ValidationError{err}, OpCreateBatch)
 	}
 
-	createdRecords, err := svc.CreateBatch(ctx, records)
+	if !isAtomicBatch(ctx) {
+		results := c.runBatchItems(ctx, OpCreateBatch, meta, records, http.StatusCreated, func(record T) (T, error) {
+			record, err := enforceCreateWrite(policy, meta.scope, OpCreateBatch, record)
+			if err != nil {
+				return record, err
+			}
+			return svc.Create(ctx, record)
+		})
+		return c.writeBatchResults(ctx, OpCreateBatch, results)
+	}
+
+	for i, record := range records {
+		if records[i], err = enforceCreateWrite(policy, meta.scope, OpCreateBatch, record); err != nil {
+			c.emitActivityEvents(ctx, OpCreateBatch, meta, records, err)
+			return c.resp.OnError(ctx, err, OpCreateBatch)
+		}
+	}
+
+	var createdRecords []T
+	err = c.runInTx(ctx, func() error {
+		var err error
+		createdRecords, err = svc.CreateBatch(ctx, records)
+		return err
+	})
 	if err != nil {
 		c.emitActivityEvents(ctx, OpCreateBatch, meta, records, err)
 		return c.resp.OnError(ctx, err, OpCreateBatch)
@@ -1011,7 +1197,7 @@ func (c *Controller[T]) Update(ctx Context) error {
 	c.attachHookContext(ctx, OpUpdate)
 
 	idStr := ctx.Params("id")
-	id, err := uuid.Parse(idStr)
+	id, err := c.parseID(idStr)
 	if err != nil {
 		c.emitActivityEvents(ctx, OpUpdate, meta, nil, err)
 		return c.resp.OnError(ctx, &ValidationError{err}, OpUpdate)
@@ -1023,7 +1209,10 @@ func (c *Controller[T]) Update(ctx Context) error {
 		return c.resp.OnError(ctx, &ValidationError{err}, OpUpdate)
 	}
 
-	c.Repo.Handlers().SetID(record, id)
+	if err := setRecordID(c.Repo.Handlers(), c.idCodec, record, id); err != nil {
+		c.emitActivityEvents(ctx, OpUpdate, meta, []T{record}, err)
+		return c.resp.OnError(ctx, &ValidationError{err}, OpUpdate)
+	}
 	criteria := c.applyScopeCriteria(nil, meta.scope)
 	criteria = c.applyFieldPolicyCriteria(criteria, policy)
 	existingRecord, err := svc.Show(ctx, idStr, criteria)
@@ -1031,6 +1220,14 @@ func (c *Controller[T]) Update(ctx Context) error {
 		c.emitActivityEvents(ctx, OpUpdate, meta, []T{record}, err)
 		return c.resp.OnError(ctx, &NotFoundError{err}, OpUpdate)
 	}
+	if err := c.authorizeRecord(ctx, OpUpdate, existingRecord); err != nil {
+		c.emitActivityEvents(ctx, OpUpdate, meta, []T{record}, err)
+		return c.resp.OnError(ctx, err, OpUpdate)
+	}
+	if err := c.resolvePrecondition(ctx, existingRecord, record); err != nil {
+		c.emitActivityEvents(ctx, OpUpdate, meta, []T{record}, err)
+		return c.resp.OnError(ctx, err, OpUpdate)
+	}
 
 	record, err = mergeRecordWithExisting(record, existingRecord)
 	if err != nil {
@@ -1039,6 +1236,10 @@ func (c *Controller[T]) Update(ctx Context) error {
 	}
 	// Apply virtual map merge semantics (merge vs replace, delete-with-null).
 	record = mergeVirtualMaps(existingRecord, record, c.virtualFieldDefs, c.mergePolicy)
+	if record, err = enforceUpdateWrite(policy, meta.scope, OpUpdate, record, existingRecord); err != nil {
+		c.emitActivityEvents(ctx, OpUpdate, meta, []T{record}, err)
+		return c.resp.OnError(ctx, err, OpUpdate)
+	}
 
 	updatedRecord, err := svc.Update(ctx, record)
 	if err != nil {
@@ -1047,10 +1248,28 @@ func (c *Controller[T]) Update(ctx Context) error {
 	}
 
 	c.emitActivityEvents(ctx, OpUpdate, meta, []T{updatedRecord}, nil)
+	if etag, ok := recordETag(updatedRecord); ok {
+		setETagHeader(ctx, etag)
+	}
 	applyFieldPolicyToRecord(updatedRecord, policy)
 	return c.resp.OnData(ctx, updatedRecord, OpUpdate)
 }
 
+// Patch applies an RFC 7396 merge patch or RFC 6902 JSON patch (selected by
+// Content-Type) to the stored record and persists it through the update path.
+func (c *Controller[T]) Patch(ctx Context) error {
+	ctx = c.applyContextFactory(ctx)
+	updatedRecord, policy, err := c.patchStored(ctx, ctx.Params("id"), requestHeader(ctx, "Content-Type"), ctx.Body())
+	if err != nil {
+		return c.resp.OnError(ctx, err, OpPatch)
+	}
+	if etag, ok := recordETag(updatedRecord); ok {
+		setETagHeader(ctx, etag)
+	}
+	applyFieldPolicyToRecord(updatedRecord, policy)
+	return c.resp.OnData(ctx, updatedRecord, OpPatch)
+}
+
 func (c *Controller[T]) UpdateBatch(ctx Context) error {
 	ctx = c.applyContextFactory(ctx)
 	svc := c.resolvedWriteService()
@@ -1075,23 +1294,22 @@ func (c *Controller[T]) UpdateBatch(ctx Context) error {
 
 	criteria := c.applyScopeCriteria(nil, meta.scope)
 	criteria = c.applyFieldPolicyCriteria(criteria, policy)
-	for i, rec := range records {
-		id := c.Repo.Handlers().GetID(rec)
-		existing, err := svc.Show(ctx, id.String(), criteria)
-		if err != nil {
-			c.emitActivityEvents(ctx, OpUpdateBatch, meta, records, err)
-			return c.resp.OnError(ctx, &NotFoundError{err}, OpUpdateBatch)
-		}
-		merged, err := mergeRecordWithExisting(rec, existing)
-		if err != nil {
-			c.emitActivityEvents(ctx, OpUpdateBatch, meta, records, err)
-			return c.resp.OnError(ctx, err, OpUpdateBatch)
-		}
-		merged = mergeVirtualMaps(existing, merged, c.virtualFieldDefs, c.mergePolicy)
-		records[i] = merged
+
+	if !isAtomicBatch(ctx) {
+		results := c.runBatchItems(ctx, OpUpdateBatch, meta, records, http.StatusOK, func(record T) (T, error) {
+			merged, versions, err := c.prepareBatchUpdates(ctx, svc, criteria, policy, meta.scope, []T{record})
+			if err != nil {
+				return record, err
+			}
+			if versions[0] != "" {
+				attachExpectedVersions(ctx, versions[0])
+			}
+			return svc.Update(ctx, merged[0])
+		})
+		return c.writeBatchResults(ctx, OpUpdateBatch, results)
 	}
 
-	updatedRecords, err := svc.UpdateBatch(ctx, records)
+	updatedRecords, err := c.updateBatchAtomic(ctx, svc, criteria, policy, meta.scope, records)
 	if err != nil {
 		c.emitActivityEvents(ctx, OpUpdateBatch, meta, records, err)
 		return c.resp.OnError(ctx, err, OpUpdateBatch)
@@ -1135,6 +1353,14 @@ func (c *Controller[T]) Delete(ctx Context) error {
 		c.emitActivityEvents(ctx, OpDelete, meta, nil, err)
 		return c.resp.OnError(ctx, &NotFoundError{err}, OpDelete)
 	}
+	if err := c.authorizeRecord(ctx, OpDelete, record); err != nil {
+		c.emitActivityEvents(ctx, OpDelete, meta, []T{record}, err)
+		return c.resp.OnError(ctx, err, OpDelete)
+	}
+	if err := c.resolvePrecondition(ctx, record, nil); err != nil {
+		c.emitActivityEvents(ctx, OpDelete, meta, []T{record}, err)
+		return c.resp.OnError(ctx, err, OpDelete)
+	}
 
 	err = svc.Delete(ctx, record)
 	if err != nil {
@@ -1163,13 +1389,31 @@ func (c *Controller[T]) DeleteBatch(ctx Context) error {
 
 	c.attachHookContext(ctx, OpDeleteBatch)
 
-	records, err := c.decodeDeleteBatch(ctx)
+	records, err := c.decodeIDBatch(ctx, OpDeleteBatch)
 	if err != nil {
 		c.emitActivityEvents(ctx, OpDeleteBatch, meta, records, err)
 		return c.resp.OnError(ctx, &ValidationError{err}, OpDeleteBatch)
 	}
 
-	err = svc.DeleteBatch(ctx, records)
+	criteria := c.applyScopeCriteria(nil, meta.scope)
+	criteria = c.applyFieldPolicyCriteria(criteria, policy)
+
+	if !isAtomicBatch(ctx) {
+		results := c.runBatchItems(ctx, OpDeleteBatch, meta, records, http.StatusNoContent, func(record T) (T, error) {
+			if err := c.checkDeleteTargets(ctx, svc, criteria, []T{record}); err != nil {
+				return record, err
+			}
+			return record, svc.Delete(ctx, record)
+		})
+		return c.writeBatchResults(ctx, OpDeleteBatch, results)
+	}
+
+	err = c.runInTx(ctx, func() error {
+		if err := c.checkDeleteTargets(ctx, svc, criteria, records); err != nil {
+			return err
+		}
+		return svc.DeleteBatch(ctx, records)
+	})
 	if err != nil {
 		c.emitActivityEvents(ctx, OpDeleteBatch, meta, records, err)
 		return c.resp.OnError(ctx, err, OpDeleteBatch)
@@ -1180,8 +1424,9 @@ func (c *Controller[T]) DeleteBatch(ctx Context) error {
 	return c.resp.OnEmpty(ctx, OpDeleteBatch)
 }
 
-func (c *Controller[T]) decodeDeleteBatch(ctx Context) ([]T, error) {
-	records, err := c.deserialiMany(OpDeleteBatch, ctx)
+// decodeIDBatch reads a batch body of records or bare IDs.
+func (c *Controller[T]) decodeIDBatch(ctx Context, op CrudOperation) ([]T, error) {
+	records, err := c.deserialiMany(op, ctx)
 	if err == nil {
 		return records, nil
 	}
@@ -1204,14 +1449,46 @@ func (c *Controller[T]) decodeDeleteBatch(ctx Context) ([]T, error) {
 	return records, nil
 }
 
+// decodeDeleteBatchIDs accepts a JSON array of string or numeric IDs.
 func decodeDeleteBatchIDs(body []byte) ([]string, error) {
-	var ids []string
-	if err := json.Unmarshal(body, &ids); err != nil {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(body, &raw); err != nil {
 		return nil, err
 	}
+	ids := make([]string, 0, len(raw))
+	for _, item := range raw {
+		var id string
+		if err := json.Unmarshal(item, &id); err == nil {
+			ids = append(ids, id)
+			continue
+		}
+		var num json.Number
+		if err := json.Unmarshal(item, &num); err != nil {
+			return nil, fmt.Errorf("invalid id %s", string(item))
+		}
+		ids = append(ids, num.String())
+	}
 	return ids, nil
 }
 
+// resolvePrecondition checks the caller's expected version against existing
+// and forwards it to the service so the write itself is conditional.
+func (c *Controller[T]) resolvePrecondition(ctx Context, existing T, payload any) error {
+	expected, err := checkPrecondition(ctx, existing, payload)
+	if err != nil {
+		return err
+	}
+	if expected != "" {
+		attachExpectedVersions(ctx, expected)
+	}
+	return nil
+}
+
+// parseID validates a raw identifier with the configured IDCodec.
+func (c *Controller[T]) parseID(raw string) (any, error) {
+	return resolveIDCodec(c.idCodec).Parse(strings.TrimSpace(raw))
+}
+
 func shouldReturnOptions(ctx Context) bool {
 	return strings.EqualFold(strings.TrimSpace(ctx.Query("format")), "options")
 }
@@ -1231,7 +1508,9 @@ func (c *Controller[T]) buildOptionItems(records []T) []optionItem {
 	options := make([]optionItem, 0, len(records))
 	for _, record := range records {
 		value := ""
-		if getID != nil {
+		if !usesHandlerIDs(c.idCodec) {
+			value, _ = formatRecordID(handlers, c.idCodec, record)
+		} else if getID != nil {
 			value = strings.TrimSpace(fmt.Sprint(getID(record)))
 		}
 		if value == "" && getIdentifierValue != nil {
@@ -1336,7 +1615,8 @@ func (c *Controller[T]) emitActivityEvents(ctx Context, op CrudOperation, meta g
 }
 
 func (c *Controller[T]) buildActivityEvents(hctx HookContext, op CrudOperation, records []T, err error) []activity.Event {
-	isBatch := len(records) > 1 || op == OpCreateBatch || op == OpUpdateBatch || op == OpDeleteBatch
+	isBatch := len(records) > 1 || op == OpCreateBatch || op == OpUpdateBatch || op == OpDeleteBatch ||
+		op == OpRestoreBatch || op == OpPurgeBatch || op == OpUpsertBatch
 	verb := activityVerb(c.resourceName(), op, isBatch, err != nil)
 	baseMeta := c.activityMetadata(hctx, err)
 
@@ -1415,6 +1695,16 @@ func activityVerb(resource string, op CrudOperation, batch bool, failed bool) st
 		parts = append(parts, "update")
 	case OpDelete, OpDeleteBatch:
 		parts = append(parts, "delete")
+	case OpRestore, OpRestoreBatch:
+		parts = append(parts, "restore")
+	case OpPurge, OpPurgeBatch:
+		parts = append(parts, "purge")
+	case OpUpsert, OpUpsertBatch:
+		parts = append(parts, "upsert")
+	case OpUpdateByFilter:
+		parts = append(parts, "update.filter")
+	case OpDeleteByFilter:
+		parts = append(parts, "delete.filter")
 	default:
 		parts = append(parts, string(op))
 	}
@@ -1432,7 +1722,11 @@ func (c *Controller[T]) recordID(record T) string {
 		return ""
 	}
 	handlers := c.Repo.Handlers()
-	if handlers.GetID != nil {
+	if !usesHandlerIDs(c.idCodec) {
+		if id, err := formatRecordID(handlers, c.idCodec, record); err == nil && id != "" {
+			return id
+		}
+	} else if handlers.GetID != nil {
 		if id := strings.TrimSpace(fmt.Sprint(handlers.GetID(record))); id != "" {
 			return id
 		}
@@ -5,7 +5,6 @@ import (
 	"strings"
 
 	repository "github.com/goliatone/go-repository-bun"
-	"github.com/google/uuid"
 )
 
 // ShowByID resolves a single record using guard + field policy semantics.
@@ -35,11 +34,17 @@ func (c *Controller[T]) ShowByID(ctx Context, id string, criteria []repository.S
 		var zero T
 		return zero, &NotFoundError{err}
 	}
+	record = filterTenantRelation(ctx, c.tenancy, record)
+	if err := c.authorizeRecord(ctx, OpRead, record); err != nil {
+		var zero T
+		return zero, err
+	}
 	applyFieldPolicyToRecord(record, policy)
 	return record, nil
 }
 
 // IndexWith resolves records using guard + field policy semantics and provided criteria.
+// The count is the total matched before the Authorizer drops any rows.
 func (c *Controller[T]) IndexWith(ctx Context, criteria []repository.SelectCriteria) ([]T, int, error) {
 	ctx = c.applyContextFactory(ctx)
 	svc := c.resolvedReadService()
@@ -63,6 +68,11 @@ func (c *Controller[T]) IndexWith(ctx Context, criteria []repository.SelectCrite
 	if err != nil {
 		return nil, 0, err
 	}
+	filterTenantRelations(ctx, c.tenancy, records)
+	records, err = c.authorizeList(ctx, OpList, records)
+	if err != nil {
+		return nil, 0, err
+	}
 	applyFieldPolicyToSlice(records, policy)
 	return records, count, nil
 }
@@ -85,6 +95,12 @@ func (c *Controller[T]) CreateRecord(ctx Context, record T) (T, error) {
 	c.logFieldPolicyDecision(policy)
 	c.attachHookContext(ctx, OpCreate)
 
+	if record, err = enforceCreateWrite(policy, meta.scope, OpCreate, record); err != nil {
+		c.emitActivityEvents(ctx, OpCreate, meta, []T{record}, err)
+		var zero T
+		return zero, err
+	}
+
 	createdRecord, err := svc.Create(ctx, record)
 	if err != nil {
 		c.emitActivityEvents(ctx, OpCreate, meta, []T{record}, err)
@@ -113,7 +129,19 @@ func (c *Controller[T]) CreateRecords(ctx Context, records []T) ([]T, error) {
 	c.logFieldPolicyDecision(policy)
 	c.attachHookContext(ctx, OpCreateBatch)
 
-	createdRecords, err := svc.CreateBatch(ctx, records)
+	for i, record := range records {
+		if records[i], err = enforceCreateWrite(policy, meta.scope, OpCreateBatch, record); err != nil {
+			c.emitActivityEvents(ctx, OpCreateBatch, meta, records, err)
+			return nil, err
+		}
+	}
+
+	var createdRecords []T
+	err = c.runInTx(ctx, func() error {
+		var err error
+		createdRecords, err = svc.CreateBatch(ctx, records)
+		return err
+	})
 	if err != nil {
 		c.emitActivityEvents(ctx, OpCreateBatch, meta, records, err)
 		return nil, err
@@ -143,13 +171,17 @@ func (c *Controller[T]) UpdateRecord(ctx Context, id string, patch T) (T, error)
 	c.attachHookContext(ctx, OpUpdate)
 
 	idStr := strings.TrimSpace(id)
-	parsedID, err := uuid.Parse(idStr)
+	parsedID, err := c.parseID(idStr)
 	if err != nil {
 		c.emitActivityEvents(ctx, OpUpdate, meta, nil, err)
 		var zero T
 		return zero, &ValidationError{err}
 	}
-	c.Repo.Handlers().SetID(patch, parsedID)
+	if err := setRecordID(c.Repo.Handlers(), c.idCodec, patch, parsedID); err != nil {
+		c.emitActivityEvents(ctx, OpUpdate, meta, nil, err)
+		var zero T
+		return zero, &ValidationError{err}
+	}
 
 	criteria := c.applyScopeCriteria(nil, meta.scope)
 	criteria = c.applyFieldPolicyCriteria(criteria, policy)
@@ -159,6 +191,16 @@ func (c *Controller[T]) UpdateRecord(ctx Context, id string, patch T) (T, error)
 		var zero T
 		return zero, &NotFoundError{err}
 	}
+	if err := c.authorizeRecord(ctx, OpUpdate, existingRecord); err != nil {
+		c.emitActivityEvents(ctx, OpUpdate, meta, []T{patch}, err)
+		var zero T
+		return zero, err
+	}
+	if err := c.resolvePrecondition(ctx, existingRecord, patch); err != nil {
+		c.emitActivityEvents(ctx, OpUpdate, meta, []T{patch}, err)
+		var zero T
+		return zero, err
+	}
 
 	record, err := mergeRecordWithExisting(patch, existingRecord)
 	if err != nil {
@@ -167,6 +209,11 @@ func (c *Controller[T]) UpdateRecord(ctx Context, id string, patch T) (T, error)
 		return zero, err
 	}
 	record = mergeVirtualMaps(existingRecord, record, c.virtualFieldDefs, c.mergePolicy)
+	if record, err = enforceUpdateWrite(policy, meta.scope, OpUpdate, record, existingRecord); err != nil {
+		c.emitActivityEvents(ctx, OpUpdate, meta, []T{record}, err)
+		var zero T
+		return zero, err
+	}
 
 	updatedRecord, err := svc.Update(ctx, record)
 	if err != nil {
@@ -180,6 +227,19 @@ func (c *Controller[T]) UpdateRecord(ctx Context, id string, patch T) (T, error)
 	return updatedRecord, nil
 }
 
+// PatchRecord applies a merge patch or JSON patch document to the stored record.
+// Empty mediaType defaults to MergePatchMediaType.
+func (c *Controller[T]) PatchRecord(ctx Context, id string, mediaType string, document []byte) (T, error) {
+	ctx = c.applyContextFactory(ctx)
+	updatedRecord, policy, err := c.patchStored(ctx, id, mediaType, document)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	applyFieldPolicyToRecord(updatedRecord, policy)
+	return updatedRecord, nil
+}
+
 // UpdateRecords updates records in batch while preserving merge semantics.
 func (c *Controller[T]) UpdateRecords(ctx Context, records []T) ([]T, error) {
 	ctx = c.applyContextFactory(ctx)
@@ -198,23 +258,7 @@ func (c *Controller[T]) UpdateRecords(ctx Context, records []T) ([]T, error) {
 
 	criteria := c.applyScopeCriteria(nil, meta.scope)
 	criteria = c.applyFieldPolicyCriteria(criteria, policy)
-	for i, rec := range records {
-		id := c.Repo.Handlers().GetID(rec)
-		existing, err := svc.Show(ctx, id.String(), criteria)
-		if err != nil {
-			c.emitActivityEvents(ctx, OpUpdateBatch, meta, records, err)
-			return nil, &NotFoundError{err}
-		}
-		merged, err := mergeRecordWithExisting(rec, existing)
-		if err != nil {
-			c.emitActivityEvents(ctx, OpUpdateBatch, meta, records, err)
-			return nil, err
-		}
-		merged = mergeVirtualMaps(existing, merged, c.virtualFieldDefs, c.mergePolicy)
-		records[i] = merged
-	}
-
-	updatedRecords, err := svc.UpdateBatch(ctx, records)
+	updatedRecords, err := c.updateBatchAtomic(ctx, svc, criteria, policy, meta.scope, records)
 	if err != nil {
 		c.emitActivityEvents(ctx, OpUpdateBatch, meta, records, err)
 		return nil, err
@@ -249,6 +293,14 @@ func (c *Controller[T]) DeleteByID(ctx Context, id string) error {
 		c.emitActivityEvents(ctx, OpDelete, meta, nil, err)
 		return &NotFoundError{err}
 	}
+	if err := c.authorizeRecord(ctx, OpDelete, record); err != nil {
+		c.emitActivityEvents(ctx, OpDelete, meta, []T{record}, err)
+		return err
+	}
+	if err := c.resolvePrecondition(ctx, record, nil); err != nil {
+		c.emitActivityEvents(ctx, OpDelete, meta, []T{record}, err)
+		return err
+	}
 
 	if err := svc.Delete(ctx, record); err != nil {
 		c.emitActivityEvents(ctx, OpDelete, meta, []T{record}, err)
@@ -275,7 +327,15 @@ func (c *Controller[T]) DeleteRecords(ctx Context, records []T) error {
 	c.logFieldPolicyDecision(policy)
 	c.attachHookContext(ctx, OpDeleteBatch)
 
-	if err := svc.DeleteBatch(ctx, records); err != nil {
+	criteria := c.applyScopeCriteria(nil, meta.scope)
+	criteria = c.applyFieldPolicyCriteria(criteria, policy)
+	err = c.runInTx(ctx, func() error {
+		if err := c.checkDeleteTargets(ctx, svc, criteria, records); err != nil {
+			return err
+		}
+		return svc.DeleteBatch(ctx, records)
+	})
+	if err != nil {
 		c.emitActivityEvents(ctx, OpDeleteBatch, meta, records, err)
 		return err
 	}
@@ -291,7 +351,7 @@ func (c *Controller[T]) RecordsFromIDs(ids []string) ([]T, error) {
 
 func (c *Controller[T]) recordsFromIDs(ids []string) ([]T, error) {
 	handlers := c.Repo.Handlers()
-	if handlers.SetID == nil {
+	if usesHandlerIDs(c.idCodec) && handlers.SetID == nil {
 		return nil, fmt.Errorf("missing record id setter")
 	}
 
@@ -301,7 +361,7 @@ func (c *Controller[T]) recordsFromIDs(ids []string) ([]T, error) {
 		if id == "" {
 			return nil, fmt.Errorf("empty record id")
 		}
-		parsed, err := uuid.Parse(id)
+		parsed, err := c.parseID(id)
 		if err != nil {
 			return nil, err
 		}
@@ -310,7 +370,9 @@ func (c *Controller[T]) recordsFromIDs(ids []string) ([]T, error) {
 		if handlers.NewRecord != nil {
 			record = handlers.NewRecord()
 		}
-		handlers.SetID(record, parsed)
+		if err := setRecordID(handlers, c.idCodec, record, parsed); err != nil {
+			return nil, err
+		}
 		records = append(records, record)
 	}
 
@@ -123,7 +123,7 @@ func setupApp(t *testing.T, options ...Option[*TestUser]) (*fiber.App, *bun.DB)
 
 	// Initialize the repository and controller
 	repo := newTestUserRepository(db)
-	opts := append([]Option[*TestUser]{WithDeserializer(testUserDeserializer)}, options...)
+	opts := append([]Option[*TestUser]{WithDeserializer(testUserDeserializer), withOptInRoutes[*TestUser]()}, options...)
 	controller := NewController[*TestUser](repo, opts...)
 
 	// Register routes
@@ -133,6 +133,15 @@ func setupApp(t *testing.T, options ...Option[*TestUser]) (*fiber.App, *bun.DB)
 	return app, db
 }
 
+// withOptInRoutes enables the routes RegisterRoutes leaves off by default.
+func withOptInRoutes[T any]() Option[T] {
+	operations := make(map[CrudOperation]RouteOptions, len(optInOperations))
+	for _, op := range optInOperations {
+		operations[op] = RouteOptions{Enabled: new(true)}
+	}
+	return WithRouteConfig[T](RouteConfig{Operations: operations})
+}
+
 func setupAppWithHooks(t *testing.T, hooks LifecycleHooks[*TestUser]) (*fiber.App, repository.Repository[*TestUser], *bun.DB) {
 	app := fiber.New()
 
@@ -202,6 +211,19 @@ func insertTestUsers(t *testing.T, db *bun.DB, users ...*TestUser) {
 	}
 }
 
+// listRequest issues a GET against a TestUser list route and decodes the
+// response when it succeeds.
+func listRequest(t *testing.T, app *fiber.App, path string) (int, APIListResponse[TestUser]) {
+	t.Helper()
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, path, nil), -1)
+	require.NoError(t, err)
+	var payload APIListResponse[TestUser]
+	if resp.StatusCode == http.StatusOK {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&payload))
+	}
+	return resp.StatusCode, payload
+}
+
 func TestController_Schema_ReturnsOpenAPIDocument(t *testing.T) {
 	app, db := setupApp(t)
 	defer db.Close()
@@ -1831,6 +1853,11 @@ func TestRegisterRoutes(t *testing.T) {
 			Method: "PUT",
 			Path:   fmt.Sprintf("/%s/:id", singular),
 		},
+		{
+			Name:   fmt.Sprintf("%s:%s", singular, OpPatch),
+			Method: "PATCH",
+			Path:   fmt.Sprintf("/%s/:id", singular),
+		},
 		{
 			Name:   fmt.Sprintf("%s:%s", singular, OpDelete),
 			Method: "DELETE",
@@ -1950,6 +1977,33 @@ func TestRegisterRoutesWithDisabledOperation(t *testing.T) {
 	assert.False(t, fiberRouteExists(app, deleteBatchRoute), "delete batch route should not be registered when disabled")
 }
 
+func TestRegisterRoutesLeavesOptInRoutesOff(t *testing.T) {
+	db := newCodecTestDB(t, (*trashedNote)(nil))
+	repo := repository.NewRepository(db, repository.ModelHandlers[*trashedNote]{
+		NewRecord: func() *trashedNote { return &trashedNote{} },
+		GetID:     func(note *trashedNote) uuid.UUID { return note.ID },
+		SetID:     func(note *trashedNote, id uuid.UUID) { note.ID = id },
+	})
+	singular, _ := GetResourceName(reflect.TypeFor[trashedNote]())
+
+	app := fiber.New()
+	NewController(repo).RegisterRoutes(NewFiberAdapter(app))
+	for _, op := range optInOperations {
+		assert.False(t, fiberRouteExists(app, fmt.Sprintf("%s:%s", singular, op)), "%s is opt-in", op)
+	}
+	assert.True(t, fiberRouteExists(app, fmt.Sprintf("%s:%s", singular, OpRestore)))
+
+	app = fiber.New()
+	NewController(repo, WithRouteConfig[*trashedNote](RouteConfig{
+		Operations: map[CrudOperation]RouteOptions{OpImport: {Enabled: new(true)}, OpPurge: {Enabled: new(true)}, OpDeleteByFilter: {Enabled: new(true)}},
+	})).RegisterRoutes(NewFiberAdapter(app))
+	assert.True(t, fiberRouteExists(app, fmt.Sprintf("%s:%s", singular, OpImport)))
+	assert.True(t, fiberRouteExists(app, fmt.Sprintf("%s:%s", singular, OpDeleteByFilter)))
+	assert.False(t, fiberRouteExists(app, fmt.Sprintf("%s:%s", singular, OpUpdateByFilter)))
+	assert.True(t, fiberRouteExists(app, fmt.Sprintf("%s:%s", singular, OpPurge)))
+	assert.False(t, fiberRouteExists(app, fmt.Sprintf("%s:%s", singular, OpPurgeBatch)))
+}
+
 func TestRegisterRoutesWithMethodOverride(t *testing.T) {
 	app := fiber.New()
 	router := NewFiberAdapter(app)
@@ -1987,6 +2041,7 @@ func TestRegisterRoutesWithMethodOverride(t *testing.T) {
 	if assert.NotNil(t, route, "update route should be registered") {
 		assert.Equal(t, http.MethodPatch, route.Method, "update route should use overridden method")
 	}
+	assert.False(t, fiberRouteExists(app, fmt.Sprintf("%s:%s", singular, OpPatch)), "patch route should yield to the remapped update route")
 }
 
 func fiberRouteExists(app *fiber.App, name string) bool {
@@ -20,6 +20,11 @@ type RequestHeaderProvider interface {
 	Header(string) string
 }
 
+// ResponseHeaderSetter is implemented by Context adapters that can set response headers.
+type ResponseHeaderSetter interface {
+	SetHeader(key, value string)
+}
+
 // MutationResponseMode describes the response shape a presenter should produce.
 type MutationResponseMode string
 
@@ -193,6 +198,17 @@ func requestHeader(ctx any, key string) string {
 	return strings.TrimSpace(provider.Header(key))
 }
 
+func setResponseHeader(ctx any, key, value string) {
+	if ctx == nil {
+		return
+	}
+	setter, ok := ctx.(ResponseHeaderSetter)
+	if !ok || setter == nil {
+		return
+	}
+	setter.SetHeader(key, value)
+}
+
 func isTruthyHeader(value string) bool {
 	value = strings.TrimSpace(strings.ToLower(value))
 	if value == "" {
@@ -34,41 +34,108 @@ func ProblemJSONErrorEncoder(opts ...problemJSONEncoderOption) ErrorEncoder {
 	}
 
 	return func(ctx Context, err error, op CrudOperation) error {
-		if err == nil {
-			err = stdErrors.New("unknown error")
-		}
-
-		mapped := goerrors.MapToError(err, cfg.errorMappers)
-		if mapped == nil {
-			mapped = goerrors.New(err.Error(), goerrors.CategoryInternal)
-		}
-
-		status := cfg.statusResolver(mapped, op)
-		if status <= 0 {
-			status = http.StatusInternalServerError
-		}
-
-		mapped.WithCode(status)
-		if strings.TrimSpace(mapped.TextCode) == "" {
-			mapped.WithTextCode(goerrors.HTTPStatusToTextCode(status))
-		}
-
-		if mapped.Timestamp.IsZero() {
-			mapped.Timestamp = time.Now().UTC()
-		}
+		mapped, status := cfg.mapError(ctx, err, op)
 
 		includeStack := cfg.includeStack || goerrors.IsDevelopment
 		if includeStack && len(mapped.StackTrace) == 0 {
 			mapped.WithStackTrace()
 		}
 
-		attachErrorRequestMetadata(ctx, mapped, op)
-
 		response := mapped.ToErrorResponse(includeStack, mapped.StackTrace)
 		return ctx.Status(status).JSON(response, cfg.contentType)
 	}
 }
 
+// problemJSONError maps err the way ProblemJSONErrorEncoder does without
+// writing a response.
+func problemJSONError(ctx Context, err error, op CrudOperation) (*goerrors.Error, int) {
+	cfg := defaultProblemJSONEncoderConfig()
+	return cfg.mapError(ctx, err, op)
+}
+
+// encodeErrorBody runs encode against a context that records the response
+// instead of sending it, and returns the error and status it would have
+// written. Batch results and import summaries use it so per-item errors follow
+// the controller's error encoder. Bodies that are not go-errors responses
+// keep the status and fall back to the default problem+json mapping.
+func encodeErrorBody(ctx Context, encode ErrorEncoder, err error, op CrudOperation) (*goerrors.Error, int) {
+	recorder := &responseRecorder{Context: ctx}
+	if encode == nil || encode(recorder, err, op) != nil || recorder.status == 0 {
+		return problemJSONError(ctx, err, op)
+	}
+	var mapped *goerrors.Error
+	switch body := recorder.body.(type) {
+	case goerrors.ErrorResponse:
+		mapped = body.Error
+	case *goerrors.ErrorResponse:
+		mapped = body.Error
+	case *goerrors.Error:
+		mapped = body
+	}
+	if mapped == nil {
+		mapped, _ = problemJSONError(ctx, err, op)
+		mapped.WithCode(recorder.status)
+	}
+	return mapped, recorder.status
+}
+
+// responseRecorder is a Context whose response is kept rather than written.
+type responseRecorder struct {
+	Context
+	status int
+	body   any
+}
+
+func (r *responseRecorder) Status(status int) Response {
+	r.status = status
+	return r
+}
+
+func (r *responseRecorder) JSON(data any, ctype ...string) error {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	r.body = data
+	return nil
+}
+
+func (r *responseRecorder) SendStatus(status int) error {
+	r.status = status
+	return nil
+}
+
+func (r *responseRecorder) Header(key string) string {
+	return requestHeader(r.Context, key)
+}
+
+func (cfg problemJSONEncoderConfig) mapError(ctx Context, err error, op CrudOperation) (*goerrors.Error, int) {
+	if err == nil {
+		err = stdErrors.New("unknown error")
+	}
+
+	mapped := goerrors.MapToError(err, cfg.errorMappers)
+	if mapped == nil {
+		mapped = goerrors.New(err.Error(), goerrors.CategoryInternal)
+	}
+
+	status := cfg.statusResolver(mapped, op)
+	if status <= 0 {
+		status = http.StatusInternalServerError
+	}
+
+	mapped.WithCode(status)
+	if strings.TrimSpace(mapped.TextCode) == "" {
+		mapped.WithTextCode(goerrors.HTTPStatusToTextCode(status))
+	}
+
+	if mapped.Timestamp.IsZero() {
+		mapped.Timestamp = time.Now().UTC()
+	}
+
+	attachErrorRequestMetadata(ctx, mapped, op)
+	return mapped, status
+}
+
 // WithProblemJSONIncludeStack configures whether stack traces should be serialized.
 func WithProblemJSONIncludeStack(include bool) problemJSONEncoderOption {
 	return func(cfg *problemJSONEncoderConfig) {
@@ -115,8 +182,10 @@ func LegacyJSONErrorEncoder() ErrorEncoder {
 		switch err.(type) {
 		case *NotFoundError:
 			status = http.StatusNotFound
-		case *ValidationError:
+		case *ValidationError, *QueryValidationError:
 			status = http.StatusBadRequest
+		case *PreconditionFailedError:
+			status = http.StatusPreconditionFailed
 		}
 
 		return ctx.Status(status).JSON(map[string]any{
@@ -173,9 +242,65 @@ func mapCRUDTypedErrors(err error) *goerrors.Error {
 		if source := embeddedSourceError(validation.error); source != nil {
 			result.Source = source
 		}
+		var notWritable *NotWritableError
+		if stdErrors.As(validation.error, &notWritable) {
+			for _, field := range notWritable.Fields {
+				result.ValidationErrors = append(result.ValidationErrors, goerrors.FieldError{
+					Field:   field,
+					Message: "field is not writable",
+				})
+			}
+		}
 		return result
 	}
 
+	var forbidden *ForbiddenError
+	if stdErrors.As(err, &forbidden) {
+		return goerrors.New(forbidden.Error(), goerrors.CategoryAuthz).
+			WithCode(http.StatusForbidden).
+			WithTextCode("FORBIDDEN")
+	}
+
+	var scopeViolation *ScopeViolationError
+	if stdErrors.As(err, &scopeViolation) {
+		return goerrors.New(scopeViolation.Error(), goerrors.CategoryAuthz).
+			WithCode(http.StatusForbidden).
+			WithTextCode("SCOPE_VIOLATION").
+			WithMetadata(map[string]any{"column": scopeViolation.Column})
+	}
+
+	var tenantErr *TenantError
+	if stdErrors.As(err, &tenantErr) {
+		textCode := "TENANT_REQUIRED"
+		if tenantErr.Tenant != "" {
+			textCode = "TENANT_MISMATCH"
+		}
+		return goerrors.New(tenantErr.Error(), goerrors.CategoryAuthz).
+			WithCode(http.StatusForbidden).
+			WithTextCode(textCode)
+	}
+
+	var precondition *PreconditionFailedError
+	if stdErrors.As(err, &precondition) {
+		return goerrors.New(precondition.Error(), goerrors.CategoryConflict).
+			WithCode(http.StatusPreconditionFailed).
+			WithTextCode("PRECONDITION_FAILED")
+	}
+
+	var inFlight *IdempotencyConflictError
+	if stdErrors.As(err, &inFlight) {
+		return goerrors.New(inFlight.Error(), goerrors.CategoryConflict).
+			WithCode(http.StatusConflict).
+			WithTextCode("IDEMPOTENCY_CONFLICT")
+	}
+
+	var queryErr *QueryValidationError
+	if stdErrors.As(err, &queryErr) {
+		return goerrors.New(queryErr.Error(), goerrors.CategoryBadInput).
+			WithCode(http.StatusBadRequest).
+			WithTextCode("INVALID_QUERY")
+	}
+
 	return nil
 }
 
@@ -0,0 +1,652 @@
+package crud
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	querybun "github.com/goliatone/go-crud/pkg/go-query-bun"
+	goerrors "github.com/goliatone/go-errors"
+	"github.com/goliatone/go-repository-bun"
+	"github.com/uptrace/bun"
+)
+
+// ExportFormatQueryParam selects the export encoding: csv (default), ndjson or xlsx.
+const ExportFormatQueryParam = "format"
+
+const (
+	ExportFormatCSV    = "csv"
+	ExportFormatNDJSON = "ndjson"
+	ExportFormatXLSX   = "xlsx"
+
+	DefaultExportMaxRows   = 100000
+	DefaultExportChunkSize = 500
+)
+
+var exportContentTypes = map[string]string{
+	ExportFormatCSV:    "text/csv",
+	ExportFormatNDJSON: "application/x-ndjson",
+	ExportFormatXLSX:   "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+// ExportConfig tunes the export route.
+type ExportConfig struct {
+	// MaxRows rejects exports matching more rows. Defaults to DefaultExportMaxRows.
+	MaxRows int
+	// ChunkSize is the number of rows read per keyset page. Defaults to
+	// DefaultExportChunkSize.
+	ChunkSize int
+}
+
+func (cfg ExportConfig) normalized() ExportConfig {
+	if cfg.MaxRows <= 0 {
+		cfg.MaxRows = DefaultExportMaxRows
+	}
+	if cfg.ChunkSize <= 0 {
+		cfg.ChunkSize = DefaultExportChunkSize
+	}
+	return cfg
+}
+
+// ResponseStreamer is implemented by Context adapters that can stream a
+// response body. The export route requires it.
+type ResponseStreamer interface {
+	SendStream(r io.Reader) error
+}
+
+// exportCriteria keeps the filter, search and projection criteria of plan;
+// ordering and pagination are replaced by keyset pages.
+func exportCriteria(plan querybun.Plan) []repository.SelectCriteria {
+	criteria := make([]querybun.Criteria, 0, len(plan.Joins)+len(plan.Filters)+len(plan.Search)+len(plan.Select))
+	criteria = append(criteria, plan.Joins...)
+	criteria = append(criteria, plan.Filters...)
+	criteria = append(criteria, plan.Search...)
+	criteria = append(criteria, plan.Select...)
+	return adaptQueryBunCriteria(criteria)
+}
+
+// exportKeysetOrder returns the requested order with primary key tiebreakers.
+func exportKeysetOrder(orders []Order, keyColumns []string) []querybun.Order {
+	requested := make([]querybun.Order, len(orders))
+	for i, order := range orders {
+		requested[i] = querybun.Order{Field: order.Field, Dir: order.Dir}
+	}
+	return querybun.KeysetOrder(requested, keyColumns)
+}
+
+// exportKeyColumnsCriteria adds the keyset columns to an explicit projection
+// so the cursor can be read from every row.
+func exportKeyColumnsCriteria(selected []string, orders []querybun.Order) []repository.SelectCriteria {
+	if len(selected) == 0 {
+		return nil
+	}
+	missing := make([]string, 0, len(orders))
+	for _, order := range orders {
+		if !slices.Contains(selected, order.Field) && !slices.Contains(missing, order.Field) {
+			missing = append(missing, order.Field)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	return []repository.SelectCriteria{func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.Column(missing...)
+	}}
+}
+
+// exportColumns lists the JSON names written by an export: the select
+// parameter when present, otherwise every serialized field of T, minus fields
+// hidden by the field policy.
+func exportColumns[T any](selectParam string, policy resolvedFieldPolicy) []string {
+	available := exportFieldNames(typeOf[T]())
+	requested := available
+	if raw := strings.TrimSpace(selectParam); raw != "" {
+		requested = nil
+		for field := range strings.SplitSeq(raw, ",") {
+			field = strings.TrimSpace(field)
+			if field != "" && slices.Contains(available, field) && !slices.Contains(requested, field) {
+				requested = append(requested, field)
+			}
+		}
+	}
+	columns := make([]string, 0, len(requested))
+	for _, field := range requested {
+		if policy.allowsField(field) {
+			columns = append(columns, field)
+		}
+	}
+	return columns
+}
+
+func exportFieldNames(typ reflect.Type) []string {
+	for typ != nil && typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return nil
+	}
+	var names []string
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && field.Tag.Get("json") == "" {
+			names = append(names, exportFieldNames(field.Type)...)
+			continue
+		}
+		if !field.IsExported() || field.Tag.Get("json") == "-" {
+			continue
+		}
+		bunTag := field.Tag.Get(TAG_BUN)
+		if bunTag == "-" || strings.Contains(bunTag, "rel:") || strings.Contains(bunTag, "m2m:") {
+			continue
+		}
+		if field.Type == reflect.TypeOf(bun.BaseModel{}) {
+			continue
+		}
+		names = append(names, jsonFieldName(field))
+	}
+	return names
+}
+
+// exportRow reads columns from the JSON encoding of record, so virtual fields
+// and custom marshalers are honoured.
+func exportRow(record any, columns []string) ([]any, error) {
+	raw, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var values map[string]any
+	if err := decoder.Decode(&values); err != nil {
+		return nil, err
+	}
+	row := make([]any, len(columns))
+	for i, column := range columns {
+		row[i] = values[column]
+	}
+	return row, nil
+}
+
+func exportCellString(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(raw)
+	}
+}
+
+type exportWriter interface {
+	WriteHeader(columns []string) error
+	WriteRow(values []any) error
+	Close() error
+	// Fail ends an export that could not be completed with a trailer
+	// describing err.
+	Fail(err *goerrors.Error) error
+}
+
+func newExportWriter(format string, w io.Writer) exportWriter {
+	switch format {
+	case ExportFormatNDJSON:
+		return &ndjsonExportWriter{w: bufio.NewWriter(w)}
+	case ExportFormatXLSX:
+		return &xlsxExportWriter{zw: zip.NewWriter(w)}
+	default:
+		return &csvExportWriter{w: csv.NewWriter(w)}
+	}
+}
+
+type csvExportWriter struct {
+	w *csv.Writer
+}
+
+func (e *csvExportWriter) WriteHeader(columns []string) error {
+	return e.w.Write(columns)
+}
+
+func (e *csvExportWriter) WriteRow(values []any) error {
+	record := make([]string, len(values))
+	for i, value := range values {
+		record[i] = exportCellString(value)
+	}
+	return e.w.Write(record)
+}
+
+func (e *csvExportWriter) Close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// Fail writes a last record whose first cell is "#error".
+func (e *csvExportWriter) Fail(err *goerrors.Error) error {
+	if werr := e.w.Write([]string{"#error", err.TextCode, err.Message}); werr != nil {
+		return werr
+	}
+	return e.Close()
+}
+
+type ndjsonExportWriter struct {
+	w       *bufio.Writer
+	columns []string
+}
+
+func (e *ndjsonExportWriter) WriteHeader(columns []string) error {
+	e.columns = columns
+	return nil
+}
+
+// WriteRow writes one object per line, keeping the column order.
+func (e *ndjsonExportWriter) WriteRow(values []any) error {
+	e.w.WriteByte('{')
+	for i, column := range e.columns {
+		if i > 0 {
+			e.w.WriteByte(',')
+		}
+		key, _ := json.Marshal(column)
+		value, err := json.Marshal(values[i])
+		if err != nil {
+			return err
+		}
+		e.w.Write(key)
+		e.w.WriteByte(':')
+		e.w.Write(value)
+	}
+	e.w.WriteString("}\n")
+	return nil
+}
+
+func (e *ndjsonExportWriter) Close() error {
+	return e.w.Flush()
+}
+
+// Fail writes a last line holding the error object: {"error":{...}}.
+func (e *ndjsonExportWriter) Fail(err *goerrors.Error) error {
+	line, merr := json.Marshal(map[string]any{"error": err})
+	if merr != nil {
+		return merr
+	}
+	e.w.Write(line)
+	e.w.WriteByte('\n')
+	return e.Close()
+}
+
+// xlsxExportWriter writes a single sheet workbook with inline strings, which
+// spreadsheet applications open without a shared strings table.
+type xlsxExportWriter struct {
+	zw    *zip.Writer
+	sheet io.Writer
+}
+
+var xlsxStaticParts = []struct{ name, body string }{
+	{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/></Types>`},
+	{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`},
+	{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="Export" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+	{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/></Relationships>`},
+}
+
+func (e *xlsxExportWriter) WriteHeader(columns []string) error {
+	for _, part := range xlsxStaticParts {
+		w, err := e.zw.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, part.body); err != nil {
+			return err
+		}
+	}
+	sheet, err := e.zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+	e.sheet = sheet
+	if _, err := io.WriteString(sheet, `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`); err != nil {
+		return err
+	}
+	values := make([]any, len(columns))
+	for i, column := range columns {
+		values[i] = column
+	}
+	return e.WriteRow(values)
+}
+
+func (e *xlsxExportWriter) WriteRow(values []any) error {
+	var buf bytes.Buffer
+	buf.WriteString("<row>")
+	for _, value := range values {
+		switch v := value.(type) {
+		case nil:
+			buf.WriteString("<c/>")
+		case json.Number:
+			buf.WriteString("<c><v>")
+			buf.WriteString(v.String())
+			buf.WriteString("</v></c>")
+		case bool:
+			buf.WriteString(`<c t="b"><v>`)
+			if v {
+				buf.WriteByte('1')
+			} else {
+				buf.WriteByte('0')
+			}
+			buf.WriteString("</v></c>")
+		default:
+			buf.WriteString(`<c t="inlineStr"><is><t xml:space="preserve">`)
+			if err := xml.EscapeText(&buf, []byte(exportCellString(v))); err != nil {
+				return err
+			}
+			buf.WriteString("</t></is></c>")
+		}
+	}
+	buf.WriteString("</row>")
+	_, err := e.sheet.Write(buf.Bytes())
+	return err
+}
+
+// Fail leaves the archive without its central directory, so a failed
+// workbook cannot be opened.
+func (e *xlsxExportWriter) Fail(*goerrors.Error) error {
+	return nil
+}
+
+func (e *xlsxExportWriter) Close() error {
+	if e.sheet != nil {
+		if _, err := io.WriteString(e.sheet, "</sheetData></worksheet>"); err != nil {
+			return err
+		}
+	}
+	return e.zw.Close()
+}
+
+// exportContext is a snapshot of the request that keeps serving hooks, the
+// read service and activity events in the goroutine that streams the export
+// after the handler returned and the adapter context was released. Its user
+// context is detached from the request's cancellation. The response is the
+// stream itself, so Status, JSON and SendStatus are ignored.
+type exportContext struct {
+	ctx     context.Context
+	params  map[string]string
+	queries map[string]string
+	values  map[string][]string
+	headers map[string]string
+	body    []byte
+}
+
+func newExportContext(ctx Context) *exportContext {
+	snapshot := &exportContext{
+		ctx:     context.WithoutCancel(ctx.UserContext()),
+		params:  map[string]string{},
+		queries: map[string]string{},
+		values:  map[string][]string{},
+		headers: map[string]string{},
+		body:    bytes.Clone(ctx.Body()),
+	}
+	for key, value := range ctx.Queries() {
+		snapshot.queries[strings.Clone(key)] = strings.Clone(value)
+		values := ctx.QueryValues(key)
+		for i := range values {
+			values[i] = strings.Clone(values[i])
+		}
+		snapshot.values[strings.Clone(key)] = values
+	}
+	if provider, ok := ctx.(routeParamsProvider); ok {
+		for key, value := range provider.RouteParams() {
+			snapshot.params[strings.Clone(key)] = strings.Clone(value)
+		}
+	}
+	if provider, ok := ctx.(requestHeadersProvider); ok {
+		for key, value := range provider.RequestHeaders() {
+			snapshot.headers[http.CanonicalHeaderKey(key)] = strings.Clone(value)
+		}
+	}
+	return snapshot
+}
+
+func (e *exportContext) UserContext() context.Context       { return e.ctx }
+func (e *exportContext) SetUserContext(ctx context.Context) { e.ctx = ctx }
+
+func (e *exportContext) Params(key string, defaultValue ...string) string {
+	if value := e.params[key]; value != "" {
+		return value
+	}
+	return firstOr(defaultValue, "")
+}
+
+func (e *exportContext) BodyParser(out any) error {
+	if len(e.body) == 0 {
+		return nil
+	}
+	return json.Unmarshal(e.body, out)
+}
+
+func (e *exportContext) Query(key string, defaultValue ...string) string {
+	if value := e.queries[key]; value != "" {
+		return value
+	}
+	return firstOr(defaultValue, "")
+}
+
+func (e *exportContext) QueryValues(key string) []string {
+	return slices.Clone(e.values[key])
+}
+
+func (e *exportContext) QueryInt(key string, defaultValue ...int) int {
+	value, err := strconv.Atoi(e.Query(key))
+	if err != nil {
+		return firstOr(defaultValue, 0)
+	}
+	return value
+}
+
+func (e *exportContext) Queries() map[string]string {
+	return maps.Clone(e.queries)
+}
+
+// firstOr returns the first of the optional default values, or fallback.
+func firstOr[T any](values []T, fallback T) T {
+	if len(values) > 0 {
+		return values[0]
+	}
+	return fallback
+}
+
+func (e *exportContext) Body() []byte {
+	return e.body
+}
+
+func (e *exportContext) Header(key string) string {
+	return e.headers[http.CanonicalHeaderKey(key)]
+}
+
+func (e *exportContext) Status(int) Response       { return e }
+func (e *exportContext) JSON(any, ...string) error { return nil }
+func (e *exportContext) SendStatus(int) error      { return nil }
+
+// --- controller ---
+
+// Export streams the rows matching the list query parameters as CSV, NDJSON
+// or XLSX, reading them in keyset pages:
+// GET /users/export?format=csv&select=id,name&age__gte=30
+func (c *Controller[T]) Export(ctx Context) error {
+	ctx = c.applyContextFactory(ctx)
+	format := strings.ToLower(strings.TrimSpace(ctx.Query(ExportFormatQueryParam, ExportFormatCSV)))
+	contentType, ok := exportContentTypes[format]
+	if !ok {
+		return c.resp.OnError(ctx, &ValidationError{fmt.Errorf("unsupported export format %q", format)}, OpExport)
+	}
+	streamer, ok := ctx.(ResponseStreamer)
+	if !ok {
+		return c.resp.OnError(ctx, UnsupportedOperationError{Operation: OpExport}, OpExport)
+	}
+
+	meta, err := c.resolveGuardContext(ctx, OpExport)
+	if err != nil {
+		return c.resp.OnError(ctx, err, OpExport)
+	}
+	policy, err := c.resolveFieldPolicy(ctx, OpExport, meta)
+	if err != nil {
+		return c.resp.OnError(ctx, err, OpExport)
+	}
+	c.logFieldPolicyDecision(policy)
+	c.attachHookContext(ctx, OpExport)
+
+	criteria, filters, err := BuildQueryCriteriaWithLogger[T](ctx, OpExport, c.logger, c.queryLoggingEnabled, c.policyQueryOptions(policy)...)
+	if err != nil {
+		return c.resp.OnError(ctx, err, OpExport)
+	}
+	criteria = c.applyScopeCriteria(criteria, meta.scope)
+	criteria = c.applyFieldPolicyCriteria(criteria, policy)
+
+	orders := exportKeysetOrder(filters.Order, keyColumnsForType(typeOf[T]()))
+	criteria = append(criteria, exportKeyColumnsCriteria(filters.Fields, orders)...)
+
+	// The first page is read before streaming so guard, query and row limit
+	// errors still produce a regular error response.
+	cfg := c.exportConfig.normalized()
+	svc := c.resolvedReadService()
+	records, count, err := svc.Index(ctx, append(slices.Clone(criteria), adaptQueryBunCriteria(querybun.BuildKeysetCriteria(orders, nil, cfg.ChunkSize))...))
+	if err != nil {
+		return c.resp.OnError(ctx, err, OpExport)
+	}
+	if count > cfg.MaxRows {
+		return c.resp.OnError(ctx, &ValidationError{fmt.Errorf("export matches %d rows, the limit is %d", count, cfg.MaxRows)}, OpExport)
+	}
+
+	_, resources := GetResourceName(c.resourceType)
+	setResponseHeader(ctx, "Content-Type", contentType)
+	setResponseHeader(ctx, "Content-Disposition", fmt.Sprintf("attachment; filename=%q", resources+"."+format))
+	ctx.Status(http.StatusOK)
+
+	detached := newExportContext(ctx)
+	hctx := c.newHookContext(ctx, OpExport, meta)
+	hctx.Context = detached
+	job := exportJob[T]{
+		ctx:      detached,
+		svc:      svc,
+		criteria: criteria,
+		orders:   orders,
+		columns:  exportColumns[T](ctx.Query("select"), policy),
+		policy:   policy,
+		config:   cfg,
+		authorize: func(records []T) ([]T, error) {
+			return c.authorizeList(detached, OpExport, records)
+		},
+	}
+
+	// A page that fails mid-stream cannot change the status any more: the
+	// writer ends the file with an error trailer and the stream is closed
+	// with the error, which adapters report by aborting the response.
+	pr, pw := io.Pipe()
+	go func() {
+		w := newExportWriter(format, pw)
+		rows, err := job.run(w, records)
+		if err != nil {
+			mapped, _ := encodeErrorBody(detached, c.resp.OnError, err, OpExport)
+			_ = w.Fail(mapped)
+		}
+		c.emitExportActivity(hctx, format, rows, err)
+		pw.CloseWithError(err)
+	}()
+	return streamer.SendStream(pr)
+}
+
+type exportJob[T any] struct {
+	ctx      Context
+	svc      Service[T]
+	criteria []repository.SelectCriteria
+	orders   []querybun.Order
+	columns  []string
+	policy   resolvedFieldPolicy
+	config   ExportConfig
+	// authorize applies the Authorizer to each page, dropping or redacting
+	// denied rows as lists do.
+	authorize func([]T) ([]T, error)
+}
+
+// run writes records and every following keyset page, returning the number of
+// rows written.
+func (j exportJob[T]) run(w exportWriter, records []T) (int, error) {
+	if err := w.WriteHeader(j.columns); err != nil {
+		return 0, err
+	}
+	rows := 0
+	for len(records) > 0 {
+		cursor, err := exportCursor(records[len(records)-1], j.orders)
+		if err != nil {
+			return rows, err
+		}
+		fetched := len(records)
+		if records, err = j.authorize(records); err != nil {
+			return rows, err
+		}
+		applyFieldPolicyToSlice(records, j.policy)
+		for _, record := range records {
+			values, err := exportRow(record, j.columns)
+			if err != nil {
+				return rows, err
+			}
+			if err := w.WriteRow(values); err != nil {
+				return rows, err
+			}
+			rows++
+		}
+		if fetched < j.config.ChunkSize || rows >= j.config.MaxRows {
+			break
+		}
+		page := querybun.BuildKeysetCriteria(j.orders, cursor, min(j.config.ChunkSize, j.config.MaxRows-rows))
+		records, _, err = j.svc.Index(j.ctx, append(slices.Clone(j.criteria), adaptQueryBunCriteria(page)...))
+		if err != nil {
+			return rows, err
+		}
+	}
+	return rows, w.Close()
+}
+
+func exportCursor(record any, orders []querybun.Order) (*querybun.Cursor, error) {
+	values := make([]any, len(orders))
+	for i, order := range orders {
+		value, ok := recordColumnValue(record, order.Field)
+		if !ok {
+			return nil, fmt.Errorf("cursor column %q not found on record", order.Field)
+		}
+		values[i] = value
+	}
+	return &querybun.Cursor{Direction: querybun.CursorNext, Order: orders, Values: values}, nil
+}
+
+// emitExportActivity emits a single export event once the stream completes.
+func (c *Controller[T]) emitExportActivity(hctx HookContext, format string, rows int, err error) {
+	if c.activityEmitterHooks == nil || !c.activityEmitterHooks.Enabled() {
+		return
+	}
+	for _, evt := range c.buildActivityEvents(hctx, OpExport, nil, err) {
+		evt.Metadata["export_format"] = format
+		evt.Metadata["export_rows"] = rows
+		_ = c.activityEmitterHooks.Emit(hookUserContext(hctx), evt)
+	}
+}
@@ -0,0 +1,202 @@
+package crud
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/goliatone/go-crud/pkg/activity"
+	"github.com/goliatone/go-repository-bun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func exportRequest(t *testing.T, app *fiber.App, path string) (*http.Response, []byte) {
+	t.Helper()
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, path, nil), -1)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, body
+}
+
+func TestController_Export_CSVStreamsEveryPage(t *testing.T) {
+	capture := &activity.CaptureHook{}
+	app, db := setupApp(t,
+		WithExportConfig[*TestUser](ExportConfig{ChunkSize: 2}),
+		WithActivityHooks[*TestUser](activity.Hooks{capture}, activity.Config{Enabled: true}),
+	)
+	defer db.Close()
+
+	insertTestUsers(t, db,
+		&TestUser{Name: "Ann", Email: "ann@example.com", Age: 20},
+		&TestUser{Name: "Bob", Email: "bob@example.com", Age: 30},
+		&TestUser{Name: "Cid", Email: "cid@example.com", Age: 40},
+		&TestUser{Name: "Dee", Email: "dee@example.com", Age: 50},
+		&TestUser{Name: "Eve", Email: "eve@example.com", Age: 60},
+	)
+
+	resp, body := exportRequest(t, app, "/test-users/export?select=name,age&order=age%20desc&age__gte=30")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/csv", resp.Header.Get("Content-Type"))
+	assert.Contains(t, resp.Header.Get("Content-Disposition"), `filename="test-users.csv"`)
+
+	rows, err := csv.NewReader(bytes.NewReader(body)).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"name", "age"},
+		{"Eve", "60"},
+		{"Dee", "50"},
+		{"Cid", "40"},
+		{"Bob", "30"},
+	}, rows)
+
+	require.Len(t, capture.Events, 1)
+	assert.Equal(t, "crud.test-user.export", capture.Events[0].Verb)
+	assert.Equal(t, "csv", capture.Events[0].Metadata["export_format"])
+	assert.Equal(t, 4, capture.Events[0].Metadata["export_rows"])
+}
+
+func TestController_Export_NDJSONAppliesFieldPolicy(t *testing.T) {
+	provider := func(req FieldPolicyRequest[*TestUser]) (FieldPolicy, error) {
+		if req.Operation != OpExport {
+			return FieldPolicy{}, nil
+		}
+		return FieldPolicy{
+			Name: "export",
+			Deny: []string{"age"},
+			Mask: map[string]FieldMaskFunc{
+				"email": func(any) any { return "***" },
+			},
+		}, nil
+	}
+	app, db := setupApp(t, WithFieldPolicyProvider(provider))
+	defer db.Close()
+	insertTestUsers(t, db, &TestUser{Name: "Ann", Email: "ann@example.com", Age: 20})
+
+	resp, body := exportRequest(t, app, "/test-users/export?format=ndjson")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/x-ndjson", resp.Header.Get("Content-Type"))
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	var lines []map[string]any
+	for scanner.Scan() {
+		var line map[string]any
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+		lines = append(lines, line)
+	}
+	require.Len(t, lines, 1)
+	assert.Equal(t, "Ann", lines[0]["name"])
+	assert.Equal(t, "***", lines[0]["email"])
+	assert.NotContains(t, lines[0], "age")
+	assert.NotContains(t, lines[0], "password")
+}
+
+func TestController_Export_XLSXWorkbook(t *testing.T) {
+	app, db := setupApp(t)
+	defer db.Close()
+	insertTestUsers(t, db, &TestUser{Name: "Ann & Co", Age: 20})
+
+	resp, body := exportRequest(t, app, "/test-users/export?format=xlsx&select=name,age")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	archive, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	require.NoError(t, err)
+	var sheet string
+	for _, file := range archive.File {
+		if file.Name != "xl/worksheets/sheet1.xml" {
+			continue
+		}
+		rc, err := file.Open()
+		require.NoError(t, err)
+		raw, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		sheet = string(raw)
+	}
+	assert.Contains(t, sheet, "<t xml:space=\"preserve\">name</t>")
+	assert.Contains(t, sheet, "Ann &amp; Co")
+	assert.Contains(t, sheet, "<c><v>20</v></c>")
+	assert.Equal(t, 2, strings.Count(sheet, "<row>"))
+}
+
+func TestController_Export_RejectsOverMaxRowsAndUnknownFormat(t *testing.T) {
+	app, db := setupApp(t, WithExportConfig[*TestUser](ExportConfig{MaxRows: 1}))
+	defer db.Close()
+	insertTestUsers(t, db, &TestUser{Name: "Ann"}, &TestUser{Name: "Bob"})
+
+	resp, _ := exportRequest(t, app, "/test-users/export")
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+
+	resp, body := exportRequest(t, app, "/test-users/export?name=Ann")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, string(body), "Ann")
+
+	resp, _ = exportRequest(t, app, "/test-users/export?format=pdf")
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+}
+
+// streamContext reads the exported stream the way a client would.
+type streamContext struct {
+	*mockContext
+	body []byte
+	err  error
+}
+
+func (s *streamContext) SendStream(r io.Reader) error {
+	s.body, s.err = io.ReadAll(r)
+	return s.err
+}
+
+func TestController_Export_FailedPageEndsWithErrorTrailer(t *testing.T) {
+	db := newCodecTestDB(t, (*TestUser)(nil))
+	insertTestUsers(t, db, &TestUser{Name: "Ann", Age: 20}, &TestUser{Name: "Bob", Age: 30})
+	repo := newTestUserRepository(db)
+
+	defaults := NewService(ServiceConfig[*TestUser]{Repository: repo})
+	var pageQueries []string
+	read := ComposeService(defaults, ServiceFuncs[*TestUser]{
+		Index: func(ctx Context, criteria []repository.SelectCriteria) ([]*TestUser, int, error) {
+			pageQueries = append(pageQueries, ctx.Query("select"))
+			if len(pageQueries) > 1 {
+				return nil, 0, errors.New("connection lost")
+			}
+			return defaults.Index(ctx, criteria)
+		},
+	})
+	capture := &activity.CaptureHook{}
+	controller := NewController(repo,
+		WithReadService(read),
+		WithExportConfig[*TestUser](ExportConfig{ChunkSize: 1}),
+		WithActivityHooks[*TestUser](activity.Hooks{capture}, activity.Config{Enabled: true}),
+	)
+	controller.RegisterRoutes(NewFiberAdapter(fiber.New()))
+
+	ctx := &streamContext{mockContext: newMockRequest()}
+	ctx.queryMap["format"] = ExportFormatNDJSON
+	ctx.queryMap["select"] = "name"
+	ctx.queryMap["order"] = "age"
+	err := controller.Export(ctx)
+	require.EqualError(t, err, "connection lost", "the stream is closed with the page error")
+	lines := strings.Split(strings.TrimSpace(string(ctx.body)), "\n")
+	require.Len(t, lines, 2)
+	assert.JSONEq(t, `{"name":"Ann"}`, lines[0])
+	var trailer struct {
+		Error map[string]any `json:"error"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &trailer))
+	assert.NotEmpty(t, trailer.Error["message"])
+	assert.Equal(t, []string{"name", "name"}, pageQueries, "later pages still see the request query")
+
+	require.Len(t, capture.Events, 1)
+	assert.Equal(t, "crud.test-user.export.failed", capture.Events[0].Verb)
+	assert.Equal(t, 1, capture.Events[0].Metadata["export_rows"])
+}
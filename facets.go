@@ -0,0 +1,170 @@
+package crud
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+
+	querybun "github.com/goliatone/go-crud/pkg/go-query-bun"
+	"github.com/goliatone/go-repository-bun"
+)
+
+// FacetsQueryParam lists the fields whose value counts are returned with a
+// list response: GET /posts?facets=status,category
+const FacetsQueryParam = "facets"
+
+// FacetCount is the number of matching rows holding Value.
+type FacetCount struct {
+	Value any   `json:"value"`
+	Count int64 `json:"count"`
+}
+
+// facetFields splits comma separated facet lists, dropping blanks and
+// duplicates.
+func facetFields(values ...string) []string {
+	var out []string
+	for _, value := range values {
+		for _, field := range strings.Split(value, ",") {
+			if field = strings.TrimSpace(field); field != "" && !slices.Contains(out, field) {
+				out = append(out, field)
+			}
+		}
+	}
+	return out
+}
+
+// withoutFieldFilters drops the filters and predicates on field, so a facet
+// counts every value the other filters allow and multi-select filters on the
+// facet keep showing the unselected values. Filter expressions are kept.
+func withoutFieldFilters(opts querybun.ListOptions, field string) querybun.ListOptions {
+	if len(opts.Filters) > 0 {
+		filters := make(map[string]any, len(opts.Filters))
+		for key, value := range opts.Filters {
+			if name, _ := querybun.ParsePredicateKey(key); name != field {
+				filters[key] = value
+			}
+		}
+		opts.Filters = filters
+	}
+	if len(opts.Predicates) > 0 {
+		predicates := make([]querybun.Predicate, 0, len(opts.Predicates))
+		for _, predicate := range opts.Predicates {
+			if strings.TrimSpace(predicate.Field) != field {
+				predicates = append(predicates, predicate)
+			}
+		}
+		opts.Predicates = predicates
+	}
+	return opts
+}
+
+// buildFacetCriteria builds the filter, search and soft delete criteria of
+// each facet, leaving out the facet's own filters.
+func buildFacetCriteria[T any](opts querybun.ListOptions, fields []string, cfg queryBuilderConfig, withDeleted, onlyDeleted bool) (map[string][]repository.SelectCriteria, error) {
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	bunCfg := queryBunConfig[T](cfg)
+	out := make(map[string][]repository.SelectCriteria, len(fields))
+	for _, field := range fields {
+		plan, err := querybun.BuildQueryPlan(withoutFieldFilters(opts, field), bunCfg)
+		if err != nil {
+			return nil, convertQueryBunError(err)
+		}
+		criteria := aggregateCriteria(plan)
+		out[field] = append(criteria, softDeleteCriteria(typeOf[T](), withDeleted, onlyDeleted)...)
+	}
+	return out, nil
+}
+
+func buildFacetCriteriaFromContext[T any](ctx Context, opts ...QueryBuilderOption) (map[string][]repository.SelectCriteria, error) {
+	fields := facetFields(ctx.Query(FacetsQueryParam))
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	cfg := queryBuilderConfig{}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&cfg)
+		}
+	}
+	listOpts := queryBunOptionsFromContext(ctx, ctx.Queries())
+	delete(listOpts.Filters, FacetsQueryParam)
+	return buildFacetCriteria[T](listOpts, fields, cfg.withRequestTenant(ctx), queryFlag(ctx, WithDeletedQueryParam), queryFlag(ctx, OnlyDeletedQueryParam))
+}
+
+// BuildFacetCriteriaFromOptions builds the criteria of each field in
+// opts.Facets for FacetsWith. Each facet keeps the filters and search of opts
+// except the filters on the faceted field itself.
+func BuildFacetCriteriaFromOptions[T any](opts ListQueryOptions, qbOpts ...QueryBuilderOption) (map[string][]repository.SelectCriteria, error) {
+	cfg := queryBuilderConfig{}
+	for _, opt := range qbOpts {
+		if opt != nil {
+			opt(&cfg)
+		}
+	}
+	return buildFacetCriteria[T](toQueryBunListOptions(opts), facetFields(opts.Facets...), cfg, opts.WithDeleted, opts.OnlyDeleted)
+}
+
+// FacetsWith counts the values of each facet field over the rows matching its
+// criteria, using list guard + field policy semantics.
+func (c *Controller[T]) FacetsWith(ctx Context, criteria map[string][]repository.SelectCriteria) (map[string][]FacetCount, error) {
+	ctx = c.applyContextFactory(ctx)
+	meta, err := c.resolveGuardContext(ctx, OpList)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := c.resolveFieldPolicy(ctx, OpList, meta)
+	if err != nil {
+		return nil, err
+	}
+	c.logFieldPolicyDecision(policy)
+	c.attachHookContext(ctx, OpList)
+	return c.facets(ctx, meta, policy, criteria)
+}
+
+// facets counts every facet field through the aggregate service. Like
+// aggregates, facets never load the rows they count, so controllers with an
+// Authorizer reject them with ForbiddenError.
+func (c *Controller[T]) facets(ctx Context, meta guardRequestContext, policy resolvedFieldPolicy, criteria map[string][]repository.SelectCriteria) (map[string][]FacetCount, error) {
+	if len(criteria) == 0 {
+		return nil, nil
+	}
+	if c.authorizer != nil {
+		return nil, &ForbiddenError{Operation: OpList, Reason: "facets cannot be checked by the record authorizer"}
+	}
+	fields := make([]string, 0, len(criteria))
+	for field := range criteria {
+		fields = append(fields, field)
+	}
+	slices.Sort(fields)
+
+	if err := c.checkAggregateSpec(AggregateSpec{GroupBy: fields}, policy); err != nil {
+		return nil, err
+	}
+	agg, err := aggregateServiceOf[T](c.resolvedReadService())
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(map[string][]FacetCount, len(fields))
+	for _, field := range fields {
+		facetCriteria := append([]repository.SelectCriteria(nil), criteria[field]...)
+		facetCriteria = c.applyScopeCriteria(facetCriteria, meta.scope)
+		facetCriteria = c.applyFieldPolicyCriteria(facetCriteria, policy)
+		result, err := agg.Aggregate(ctx, AggregateSpec{GroupBy: []string{field}}, facetCriteria)
+		if err != nil {
+			return nil, err
+		}
+		counts := make([]FacetCount, 0, len(result.Groups))
+		for _, group := range result.Groups {
+			counts = append(counts, FacetCount{Value: group.Key[field], Count: group.Count})
+		}
+		// Groups arrive ordered by value; keep that order among equal counts.
+		slices.SortStableFunc(counts, func(a, b FacetCount) int {
+			return cmp.Compare(b.Count, a.Count)
+		})
+		out[field] = counts
+	}
+	return out, nil
+}
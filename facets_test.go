@@ -0,0 +1,80 @@
+package crud
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestController_Index_Facets(t *testing.T) {
+	app, db := setupApp(t)
+	defer db.Close()
+
+	insertTestUsers(t, db,
+		&TestUser{Name: "Ann", Age: 20},
+		&TestUser{Name: "Ann", Age: 30},
+		&TestUser{Name: "Bob", Age: 30},
+		&TestUser{Name: "Cid", Age: 40},
+	)
+
+	status, payload := listRequest(t, app, "/test-users?facets=name,age&name__in=Ann,Bob")
+	require.Equal(t, http.StatusOK, status)
+	assert.Len(t, payload.Data, 3)
+	assert.Equal(t, map[string][]FacetCount{
+		// The name facet ignores the name filter so unselected values remain.
+		"name": {{Value: "Ann", Count: 2}, {Value: "Bob", Count: 1}, {Value: "Cid", Count: 1}},
+		"age":  {{Value: float64(30), Count: 2}, {Value: float64(20), Count: 1}},
+	}, payload.Meta.Facets)
+
+	status, payload = listRequest(t, app, "/test-users?age__gte=30")
+	require.Equal(t, http.StatusOK, status)
+	assert.Nil(t, payload.Meta.Facets)
+
+	status, _ = listRequest(t, app, "/test-users?facets=missing")
+	assert.Equal(t, http.StatusUnprocessableEntity, status)
+}
+
+func TestController_Index_FacetsHonourScopeAndFieldPolicy(t *testing.T) {
+	guard := func(ctx Context, op CrudOperation) (ActorContext, ScopeFilter, error) {
+		scope := ScopeFilter{}
+		scope.AddColumnFilter("name", "=", "Ann")
+		return ActorContext{ActorID: "actor-facets"}, scope, nil
+	}
+	provider := func(req FieldPolicyRequest[*TestUser]) (FieldPolicy, error) {
+		return FieldPolicy{Name: "list:no-age", Deny: []string{"age"}}, nil
+	}
+	app, db := setupApp(t, WithScopeGuard[*TestUser](guard), WithFieldPolicyProvider(provider))
+	defer db.Close()
+
+	insertTestUsers(t, db,
+		&TestUser{Name: "Ann", Age: 20},
+		&TestUser{Name: "Bob", Age: 30},
+	)
+
+	status, payload := listRequest(t, app, "/test-users?facets=name")
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, map[string][]FacetCount{"name": {{Value: "Ann", Count: 1}}}, payload.Meta.Facets)
+
+	status, _ = listRequest(t, app, "/test-users?facets=age")
+	assert.Equal(t, http.StatusUnprocessableEntity, status, "denied fields cannot be faceted")
+}
+
+func TestController_Index_FacetsRejectMaskedFields(t *testing.T) {
+	provider := func(req FieldPolicyRequest[*TestUser]) (FieldPolicy, error) {
+		return FieldPolicy{Name: "list:masked-email", Mask: map[string]FieldMaskFunc{
+			"email": func(any) any { return "***" },
+		}}, nil
+	}
+	app, db := setupApp(t, WithFieldPolicyProvider(provider))
+	defer db.Close()
+	insertTestUsers(t, db, &TestUser{Name: "Ann", Email: "ann@example.com"})
+
+	status, _ := listRequest(t, app, "/test-users?facets=email")
+	assert.Equal(t, http.StatusUnprocessableEntity, status, "masked values are not returned raw")
+
+	status, payload := listRequest(t, app, "/test-users?facets=name")
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, map[string][]FacetCount{"name": {{Value: "Ann", Count: 1}}}, payload.Meta.Facets)
+}
@@ -1,7 +1,9 @@
 package crud
 
 import (
+	"bytes"
 	"context"
+	"io"
 	"net/http"
 	"strconv"
 	"strings"
@@ -81,6 +83,15 @@ func (ca *crudAdapter) Body() []byte {
 	return ca.c.Body()
 }
 
+// BodyStream returns the request body as a reader. It streams the body when
+// the app is configured with StreamRequestBody.
+func (ca *crudAdapter) BodyStream() io.Reader {
+	if stream := ca.c.Request().BodyStream(); stream != nil {
+		return stream
+	}
+	return bytes.NewReader(ca.c.Body())
+}
+
 func (ca *crudAdapter) BodyParser(out any) error {
 	return ca.c.BodyParser(out)
 }
@@ -124,6 +135,29 @@ func (ca *crudAdapter) Header(key string) string {
 	return ca.c.Get(key)
 }
 
+func (ca *crudAdapter) SetHeader(key, value string) {
+	ca.c.Set(key, value)
+}
+
+func (ca *crudAdapter) RouteParams() map[string]string {
+	return ca.c.AllParams()
+}
+
+// RequestHeaders returns the first value of every request header.
+func (ca *crudAdapter) RequestHeaders() map[string]string {
+	headers := make(map[string]string)
+	for key, values := range ca.c.GetReqHeaders() {
+		if len(values) > 0 {
+			headers[key] = values[0]
+		}
+	}
+	return headers
+}
+
+func (ca *crudAdapter) SendStream(r io.Reader) error {
+	return ca.c.SendStream(r)
+}
+
 func (ca *crudAdapter) Status(status int) Response {
 	ca.statusCode = status
 	ca.c.Status(status)
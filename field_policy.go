@@ -15,8 +15,11 @@ type FieldPolicy struct {
 	// Name helps auditors identify which policy executed (e.g., "tenant-admin").
 	Name string
 	// Allow restricts responses to the listed JSON field names. Empty means inherit defaults.
+	// Dotted names such as "profiles.bio" restrict the columns loaded for
+	// included relations instead.
 	Allow []string
 	// Deny removes the listed JSON field names from the response/queryable set.
+	// Dotted names such as "profiles.ssn" apply to included relations.
 	Deny []string
 	// Mask applies field-level transformations before encoding the response.
 	Mask map[string]FieldMaskFunc
@@ -24,6 +27,19 @@ type FieldPolicy struct {
 	RowFilter ScopeFilter
 	// Labels stores arbitrary metadata surfaced in audit logs.
 	Labels map[string]string
+	// WritableAllow restricts the JSON fields creates and updates may set.
+	// Empty means every field not denied is writable.
+	WritableAllow []string
+	// WritableDeny lists JSON fields creates and updates may not set.
+	WritableDeny []string
+	// WritableOnCreate and WritableOnUpdate narrow the writable fields further
+	// for creates and for updates (including patches and filter updates).
+	// Upserts must satisfy both.
+	WritableOnCreate WritableFields
+	WritableOnUpdate WritableFields
+	// WriteMode selects whether writes to fields that are not writable fail
+	// (WriteFieldReject, the default) or are dropped (WriteFieldStrip).
+	WriteMode WriteFieldMode
 }
 
 // FieldPolicyRequest conveys the context supplied to FieldPolicyProvider.
@@ -94,6 +110,30 @@ type resolvedFieldPolicy struct {
 	maskers         map[string]FieldMaskFunc
 	rowFilter       ScopeFilter
 	audit           FieldPolicyAudit
+	// relationRules holds the dotted Allow/Deny entries keyed by the
+	// lowercase relation path.
+	relationRules map[string]relationFieldRule
+	// createWritable and updateWritable hold the writable rules of creates
+	// and updates; a field is writable when every rule allows it.
+	createWritable []relationFieldRule
+	updateWritable []relationFieldRule
+	writeMode      WriteFieldMode
+}
+
+type relationFieldRule struct {
+	allow map[string]struct{}
+	deny  map[string]struct{}
+}
+
+func (r relationFieldRule) allows(field string) bool {
+	key := normalizePolicyField(field)
+	if len(r.allow) > 0 {
+		if _, ok := r.allow[key]; !ok {
+			return false
+		}
+	}
+	_, denied := r.deny[key]
+	return !denied
 }
 
 func (r resolvedFieldPolicy) isZero() bool {
@@ -101,6 +141,9 @@ func (r resolvedFieldPolicy) isZero() bool {
 		len(r.allowSet) == 0 &&
 		len(r.denySet) == 0 &&
 		len(r.maskers) == 0 &&
+		len(r.relationRules) == 0 &&
+		len(r.createWritable) == 0 &&
+		len(r.updateWritable) == 0 &&
 		!r.rowFilter.HasFilters() &&
 		len(r.audit.Masked) == 0 &&
 		r.audit.Policy == ""
@@ -153,6 +196,7 @@ func buildResolvedFieldPolicy[T any](policy FieldPolicy, base map[string]string,
 
 	allowSet := normalizePolicyList(policy.Allow, reverse)
 	denySet := normalizePolicyList(policy.Deny, reverse)
+	relationRules := normalizeRelationPolicy(policy.Allow, policy.Deny)
 
 	var override map[string]string
 	if len(allowSet) > 0 || len(denySet) > 0 {
@@ -201,6 +245,8 @@ func buildResolvedFieldPolicy[T any](policy FieldPolicy, base map[string]string,
 		Labels:    policy.Labels,
 	}
 
+	createWritable, updateWritable := normalizeWritablePolicy(policy, reverse)
+
 	return resolvedFieldPolicy{
 		allowedOverride: override,
 		allowSet:        allowSet,
@@ -208,7 +254,42 @@ func buildResolvedFieldPolicy[T any](policy FieldPolicy, base map[string]string,
 		maskers:         maskers,
 		rowFilter:       rowFilter,
 		audit:           audit,
+		relationRules:   relationRules,
+		createWritable:  createWritable,
+		updateWritable:  updateWritable,
+		writeMode:       policy.WriteMode,
+	}
+}
+
+// normalizeRelationPolicy groups dotted Allow/Deny entries by relation path.
+func normalizeRelationPolicy(allow, deny []string) map[string]relationFieldRule {
+	rules := make(map[string]relationFieldRule)
+	add := func(values []string, isAllow bool) {
+		for _, raw := range values {
+			key := normalizePolicyField(raw)
+			idx := strings.LastIndex(key, ".")
+			if idx <= 0 || idx == len(key)-1 {
+				continue
+			}
+			path, field := key[:idx], key[idx+1:]
+			rule := rules[path]
+			set := &rule.deny
+			if isAllow {
+				set = &rule.allow
+			}
+			if *set == nil {
+				*set = make(map[string]struct{})
+			}
+			(*set)[field] = struct{}{}
+			rules[path] = rule
+		}
+	}
+	add(allow, true)
+	add(deny, false)
+	if len(rules) == 0 {
+		return nil
 	}
+	return rules
 }
 
 func normalizePolicyList(values []string, reverse map[string]string) map[string]struct{} {
@@ -0,0 +1,232 @@
+package crud
+
+import (
+	"fmt"
+	"iter"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// WriteFieldMode selects how a field policy handles writes to fields that are
+// not writable.
+type WriteFieldMode string
+
+const (
+	// WriteFieldReject fails the write with a ValidationError wrapping
+	// NotWritableError. It is the default.
+	WriteFieldReject WriteFieldMode = "reject"
+	// WriteFieldStrip drops the writes: creates keep the zero value and
+	// updates keep the stored value.
+	WriteFieldStrip WriteFieldMode = "strip"
+)
+
+// WritableFields lists the JSON fields one kind of write may (Allow) or may
+// not (Deny) set.
+type WritableFields struct {
+	Allow []string
+	Deny  []string
+}
+
+// NotWritableError lists the JSON fields a write set without the field policy
+// allowing it. It is returned wrapped in a ValidationError, and the JSON error
+// encoder reports each field under validation_errors.
+type NotWritableError struct {
+	Operation CrudOperation
+	Fields    []string
+}
+
+func (e *NotWritableError) Error() string {
+	return fmt.Sprintf("fields not writable on %s: %s", e.Operation, strings.Join(e.Fields, ", "))
+}
+
+func normalizeWritablePolicy(policy FieldPolicy, reverse map[string]string) ([]relationFieldRule, []relationFieldRule) {
+	var createRules, updateRules []relationFieldRule
+	add := func(rules *[]relationFieldRule, allow, deny []string) {
+		rule := relationFieldRule{
+			allow: normalizePolicyList(allow, reverse),
+			deny:  normalizePolicyList(deny, reverse),
+		}
+		if len(rule.allow) > 0 || len(rule.deny) > 0 {
+			*rules = append(*rules, rule)
+		}
+	}
+	add(&createRules, policy.WritableAllow, policy.WritableDeny)
+	add(&updateRules, policy.WritableAllow, policy.WritableDeny)
+	add(&createRules, policy.WritableOnCreate.Allow, policy.WritableOnCreate.Deny)
+	add(&updateRules, policy.WritableOnUpdate.Allow, policy.WritableOnUpdate.Deny)
+	return createRules, updateRules
+}
+
+// writableRules returns the rules governing the fields op writes.
+func (r resolvedFieldPolicy) writableRules(op CrudOperation) []relationFieldRule {
+	switch op {
+	case OpCreate, OpCreateBatch, OpImport:
+		return r.createWritable
+	case OpUpdate, OpUpdateBatch, OpPatch, OpUpdateByFilter:
+		return r.updateWritable
+	case OpUpsert, OpUpsertBatch:
+		return slices.Concat(r.createWritable, r.updateWritable)
+	default:
+		return nil
+	}
+}
+
+func (r resolvedFieldPolicy) writable(op CrudOperation, field string) bool {
+	for _, rule := range r.writableRules(op) {
+		if !rule.allows(field) {
+			return false
+		}
+	}
+	return true
+}
+
+// writableFields lists every JSON field of typ that op may write, or nil when
+// the policy does not restrict op.
+func (r resolvedFieldPolicy) writableFields(op CrudOperation, typ reflect.Type) []string {
+	if len(r.writableRules(op)) == 0 {
+		return nil
+	}
+	for typ != nil && typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return nil
+	}
+	fields := []string{}
+	for field := range writeCandidateFields(typ) {
+		if name := jsonFieldName(field); r.writable(op, name) {
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}
+
+// filterWritableFields checks the fields a filter update assigns. In strip
+// mode the fields that are not writable are dropped instead.
+func (r resolvedFieldPolicy) filterWritableFields(op CrudOperation, fields []string) ([]string, error) {
+	if len(r.writableRules(op)) == 0 {
+		return fields, nil
+	}
+	kept := make([]string, 0, len(fields))
+	var denied []string
+	for _, field := range fields {
+		if r.writable(op, field) {
+			kept = append(kept, field)
+		} else {
+			denied = append(denied, field)
+		}
+	}
+	if len(denied) > 0 && r.writeMode != WriteFieldStrip {
+		return nil, &ValidationError{&NotWritableError{Operation: op, Fields: denied}}
+	}
+	return kept, nil
+}
+
+// enforceWritableCreate enforces the writable rules of op on a new record: a
+// create writes the fields it sets to non-zero values other than those scope
+// assigns.
+func enforceWritableCreate[T any](decision resolvedFieldPolicy, scope ScopeFilter, op CrudOperation, record T) (T, error) {
+	if len(decision.writableRules(op)) == 0 {
+		return record, nil
+	}
+	return enforceWritable(decision, op, record, scopedBaseline[T](scope), true)
+}
+
+// enforceWritableUpdate enforces the writable rules of op on record, an
+// update of existing: an update writes the fields whose values differ from
+// the stored ones. Strip mode resets those fields to the stored values.
+func enforceWritableUpdate[T any](decision resolvedFieldPolicy, op CrudOperation, record, existing T) (T, error) {
+	return enforceWritable(decision, op, record, existing, false)
+}
+
+// enforceWritable treats the fields of record that differ from existing as
+// written; with skipZero, zero values are never writes.
+func enforceWritable[T any](decision resolvedFieldPolicy, op CrudOperation, record, existing T, skipZero bool) (T, error) {
+	if len(decision.writableRules(op)) == 0 || isNil(record) {
+		return record, nil
+	}
+	target := reflect.ValueOf(record)
+	if target.Kind() != reflect.Pointer {
+		ptr := reflect.New(target.Type())
+		ptr.Elem().Set(target)
+		target = ptr
+	}
+	rv := target.Elem()
+	if rv.Kind() != reflect.Struct {
+		return record, nil
+	}
+	stored := reflect.Zero(rv.Type())
+	if !isNil(existing) {
+		stored = reflect.Indirect(reflect.ValueOf(existing))
+	}
+
+	var denied []string
+	for field := range writeCandidateFields(rv.Type()) {
+		name := jsonFieldName(field)
+		if decision.writable(op, name) {
+			continue
+		}
+		current, previous := rv.FieldByIndex(field.Index), stored.FieldByIndex(field.Index)
+		if (skipZero && current.IsZero()) || reflect.DeepEqual(current.Interface(), previous.Interface()) {
+			continue
+		}
+		if decision.writeMode == WriteFieldStrip {
+			current.Set(previous)
+			continue
+		}
+		denied = append(denied, name)
+	}
+	if len(denied) > 0 {
+		return record, &ValidationError{&NotWritableError{Operation: op, Fields: denied}}
+	}
+	if reflect.ValueOf(record).Kind() != reflect.Pointer {
+		return target.Elem().Interface().(T), nil
+	}
+	return record, nil
+}
+
+// writeCandidateFields yields the exported fields a payload can set.
+func writeCandidateFields(typ reflect.Type) iter.Seq[reflect.StructField] {
+	return func(yield func(reflect.StructField) bool) {
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+			if !field.IsExported() || field.Anonymous || field.Tag.Get(TAG_CRUD) == "-" {
+				continue
+			}
+			if !yield(field) {
+				return
+			}
+		}
+	}
+}
+
+// annotateWritableFieldsInSchema lists the fields the requesting actor may
+// write under `x-writable-fields`, keyed by create and update, so form
+// builders can disable the rest. Operations the field policy does not
+// restrict are left out.
+func (c *Controller[T]) annotateWritableFieldsInSchema(ctx Context, doc map[string]any, schemaName string) {
+	if c.fieldPolicyProvider == nil || len(doc) == 0 || schemaName == "" {
+		return
+	}
+	writable := make(map[string][]string)
+	for _, op := range []CrudOperation{OpCreate, OpUpdate} {
+		meta, err := c.resolveGuardContext(ctx, op)
+		if err != nil {
+			continue
+		}
+		policy, err := c.resolveFieldPolicy(ctx, op, meta)
+		if err != nil {
+			continue
+		}
+		if fields := policy.writableFields(op, c.resourceType); fields != nil {
+			writable[string(op)] = fields
+		}
+	}
+	if len(writable) == 0 {
+		return
+	}
+	if _, schema := ensureSchemaProperties(doc, schemaName); schema != nil {
+		schema["x-writable-fields"] = writable
+	}
+}
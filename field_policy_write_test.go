@@ -0,0 +1,171 @@
+package crud
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writablePolicy(mode WriteFieldMode) FieldPolicyProvider[*TestUser] {
+	return func(req FieldPolicyRequest[*TestUser]) (FieldPolicy, error) {
+		return FieldPolicy{
+			Name:             "writable",
+			WritableDeny:     []string{"age"},
+			WritableOnUpdate: WritableFields{Deny: []string{"email"}},
+			WriteMode:        mode,
+		}, nil
+	}
+}
+
+func writeRequest(t *testing.T, app *fiber.App, method, path string, body any) (int, map[string]any) {
+	t.Helper()
+	payload, err := json.Marshal(body)
+	require.NoError(t, err)
+	req := httptest.NewRequest(method, path, bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req, -1)
+	require.NoError(t, err)
+
+	var out map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+	return resp.StatusCode, out
+}
+
+func notWritableFields(t *testing.T, payload map[string]any) []string {
+	t.Helper()
+	errPayload, ok := payload["error"].(map[string]any)
+	require.True(t, ok, "error payload missing: %v", payload)
+	entries, _ := errPayload["validation_errors"].([]any)
+	fields := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		fields = append(fields, entry.(map[string]any)["field"].(string))
+	}
+	return fields
+}
+
+func TestController_WritableFields_Reject(t *testing.T) {
+	app, db := setupApp(t, WithFieldPolicyProvider(writablePolicy(WriteFieldReject)))
+	defer db.Close()
+
+	status, payload := writeRequest(t, app, http.MethodPost, "/test-user", map[string]any{"name": "Ann", "email": "ann@example.com", "age": 30})
+	require.Equal(t, http.StatusUnprocessableEntity, status)
+	assert.Equal(t, []string{"age"}, notWritableFields(t, payload))
+	assert.Equal(t, 0, countTestUsers(t, db))
+
+	user := &TestUser{Name: "Ann", Email: "ann@example.com", Age: 30}
+	insertTestUsers(t, db, user)
+
+	status, payload = writeRequest(t, app, http.MethodPut, "/test-user/"+user.ID.String(), map[string]any{"email": "new@example.com", "age": 31})
+	require.Equal(t, http.StatusUnprocessableEntity, status)
+	assert.Equal(t, []string{"email", "age"}, notWritableFields(t, payload))
+
+	status, _ = writeRequest(t, app, http.MethodPut, "/test-user/"+user.ID.String(), map[string]any{"name": "Ann B", "email": "ann@example.com", "age": 30})
+	assert.Equal(t, http.StatusOK, status, "unchanged values are not writes")
+
+	status, _ = filterMutationRequest(t, app, http.MethodPatch, "/test-users?name=Ann%20B", map[string]any{"age": 40})
+	assert.Equal(t, http.StatusUnprocessableEntity, status)
+}
+
+func TestController_WritableFields_Strip(t *testing.T) {
+	app, db := setupApp(t, WithFieldPolicyProvider(writablePolicy(WriteFieldStrip)))
+	defer db.Close()
+
+	status, payload := writeRequest(t, app, http.MethodPost, "/test-user", map[string]any{"name": "Ann", "email": "ann@example.com", "age": 30})
+	require.Equal(t, http.StatusCreated, status)
+	assert.EqualValues(t, 0, payload["age"], "creates keep the zero value")
+
+	status, payload = writeRequest(t, app, http.MethodPut, "/test-user/"+payload["id"].(string), map[string]any{"name": "Ann B", "email": "new@example.com"})
+	require.Equal(t, http.StatusOK, status)
+	data := payload["data"].(map[string]any)
+	assert.Equal(t, "Ann B", data["name"])
+	assert.Equal(t, "ann@example.com", data["email"], "updates keep the stored value")
+}
+
+func TestController_Schema_PublishesWritableFields(t *testing.T) {
+	app, db := setupApp(t, WithFieldPolicyProvider(writablePolicy(WriteFieldReject)))
+	defer db.Close()
+
+	req := httptest.NewRequest(http.MethodGet, "/test-user/schema", nil)
+	resp, err := app.Test(req, -1)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var doc map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&doc))
+	schema := doc["components"].(map[string]any)["schemas"].(map[string]any)["test-user"].(map[string]any)
+	writable := schema["x-writable-fields"].(map[string]any)
+	assert.ElementsMatch(t, []any{"id", "name", "email", "created_at", "updated_at", "profiles"}, writable["create"])
+	assert.ElementsMatch(t, []any{"id", "name", "created_at", "updated_at", "profiles"}, writable["update"])
+}
+
+func TestNewService_FieldPolicyWritableFields(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+	require.NoError(t, createSchema(ctx, db))
+
+	svc := NewService(ServiceConfig[*TestUser]{
+		Repository:  newTestUserRepository(db),
+		FieldPolicy: writablePolicy(WriteFieldReject),
+	})
+	reqCtx := newMockContextWithQuery(nil)
+
+	_, err := svc.Create(reqCtx, &TestUser{Name: "Ann", Email: "ann@example.com", Age: 30})
+	var notWritable *NotWritableError
+	require.ErrorAs(t, err, &notWritable)
+	assert.Equal(t, []string{"age"}, notWritable.Fields)
+
+	user := &TestUser{Name: "Ann", Email: "ann@example.com", Age: 30}
+	insertTestUsers(t, db, user)
+	stored := *user
+
+	stored.Name = "Ann B"
+	_, err = svc.Update(reqCtx, &stored)
+	require.NoError(t, err, "fields matching the stored record are not writes")
+
+	stored.Email = "new@example.com"
+	_, err = svc.Update(reqCtx, &stored)
+	require.ErrorAs(t, err, &notWritable)
+	assert.Equal(t, []string{"email"}, notWritable.Fields)
+}
+
+func TestController_Import_HonoursWritableFields(t *testing.T) {
+	body := "name,email,age\nAnn,ann@example.com,30\nBob,bob@example.com,\n"
+
+	t.Run("reject", func(t *testing.T) {
+		app, db := setupApp(t, WithFieldPolicyProvider(writablePolicy(WriteFieldReject)), WithImportConfig[*TestUser](ImportConfig{ChunkSize: 1}))
+		defer db.Close()
+		status, summary := importRequest(t, app, "/test-user/import", "text/csv", body)
+		require.Equal(t, http.StatusMultiStatus, status)
+		assert.Equal(t, 1, summary.Created)
+		require.Len(t, summary.Errors, 1)
+		assert.Equal(t, 1, summary.Errors[0].Row, "rows setting fields that are not writable are rejected")
+		assert.Equal(t, 1, countTestUsers(t, db))
+	})
+
+	t.Run("strip", func(t *testing.T) {
+		app, db := setupApp(t, WithFieldPolicyProvider(writablePolicy(WriteFieldStrip)))
+		defer db.Close()
+		status, summary := importRequest(t, app, "/test-user/import", "text/csv", body)
+		require.Equal(t, http.StatusOK, status, "%+v", summary.Errors)
+		assert.Equal(t, 2, summary.Created)
+		var stored TestUser
+		require.NoError(t, db.NewSelect().Model(&stored).Where("email = ?", "ann@example.com").Scan(context.Background()))
+		assert.Zero(t, stored.Age, "strip mode drops the write")
+
+		insertTestUsers(t, db, &TestUser{Name: "Cid", Email: "cid@example.com", Age: 40})
+		status, summary = importRequest(t, app, "/test-user/import?upsert=true", "text/csv", "name,email,age\nCyd,cid@example.com,\n")
+		require.Equal(t, http.StatusOK, status, "%+v", summary.Errors)
+		assert.Equal(t, 1, summary.Updated)
+		stored = TestUser{}
+		require.NoError(t, db.NewSelect().Model(&stored).Where("email = ?", "cid@example.com").Scan(context.Background()))
+		assert.Equal(t, "Cyd", stored.Name)
+		assert.Equal(t, 40, stored.Age, "imported updates keep the stored values of fields they cannot write")
+	})
+}
@@ -0,0 +1,229 @@
+package crud
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+// fieldsetParamPrefix starts JSON:API style sparse fieldset params:
+// GET /users?include=profiles&fields[profiles]=id,avatar
+const fieldsetParamPrefix = "fields["
+
+func fieldsetParamPath(param string) (string, bool) {
+	if !strings.HasPrefix(param, fieldsetParamPrefix) || !strings.HasSuffix(param, "]") {
+		return "", false
+	}
+	path := strings.TrimSpace(param[len(fieldsetParamPrefix) : len(param)-1])
+	return path, path != ""
+}
+
+// fieldsetsFromQuery collects fields[relation]=a,b params by relation path.
+func fieldsetsFromQuery(params map[string]string) map[string][]string {
+	var out map[string][]string
+	for param, value := range params {
+		path, ok := fieldsetParamPath(param)
+		if !ok {
+			continue
+		}
+		if out == nil {
+			out = make(map[string][]string)
+		}
+		fields := []string{}
+		for field := range strings.SplitSeq(value, ",") {
+			if field = strings.TrimSpace(field); field != "" && !slices.Contains(fields, field) {
+				fields = append(fields, field)
+			}
+		}
+		out[path] = fields
+	}
+	return out
+}
+
+// applyIncludeFieldsets validates fieldsets (relation path to JSON field
+// names) and include filters against the included relations and field
+// policy, then sets the columns each include node loads. Relations covered by
+// dotted field policy entries load only the columns the policy allows, even
+// without a fieldset.
+func applyIncludeFieldsets(nodes map[string]*relationIncludeNode, meta *relationMetadata, fieldsets map[string][]string, policy resolvedFieldPolicy) error {
+	if len(fieldsets) == 0 && len(policy.relationRules) == 0 && len(policy.allowSet) == 0 && len(policy.denySet) == 0 {
+		return nil
+	}
+
+	rules := canonicalRelationRules(meta, policy)
+	if err := checkIncludeFilters(nodes, meta, "", "", "", policy, rules); err != nil {
+		return err
+	}
+	requested := make(map[string][]string, len(fieldsets))
+	for path, fields := range fieldsets {
+		relMeta, canonical, ok := resolveRelationPath(meta, path)
+		if !ok {
+			return &QueryValidationError{Code: QueryValidationInvalidFieldset, Field: path, Reason: "unknown relation"}
+		}
+		if !includesRelationPath(nodes, canonical) {
+			return &QueryValidationError{Code: QueryValidationInvalidFieldset, Field: path, Reason: "relation is not included"}
+		}
+		if root, _, _ := strings.Cut(path, "."); !policy.allowsField(meta.children[strings.ToLower(strings.TrimSpace(root))].jsonName) {
+			return &QueryValidationError{Code: QueryValidationFieldNotAllowed, Field: strings.TrimSpace(root)}
+		}
+		for _, field := range fields {
+			if !relationHasColumn(relMeta, field) {
+				return &QueryValidationError{Code: QueryValidationInvalidFieldset, Field: path, Reason: fmt.Sprintf("unknown field %q", field)}
+			}
+			if !rules[canonical].allows(field) {
+				return &QueryValidationError{Code: QueryValidationFieldNotAllowed, Field: path + "." + field}
+			}
+		}
+		requested[canonical] = fields
+	}
+
+	setIncludeColumns(nodes, meta, "", requested, rules)
+	return nil
+}
+
+// checkIncludeFilters rejects include filters, as in
+// include=profiles.bio=remote, on relations or fields the field policy denies.
+func checkIncludeFilters(nodes map[string]*relationIncludeNode, parent *relationMetadata, root, prefix, requestPrefix string, policy resolvedFieldPolicy, rules map[string]relationFieldRule) error {
+	for _, node := range nodes {
+		if node == nil {
+			continue
+		}
+		child := parent.children[strings.ToLower(node.name)]
+		if child == nil {
+			continue
+		}
+		path, requestPath, relRoot := strings.ToLower(node.name), node.requestName, root
+		if prefix != "" {
+			path, requestPath = prefix+"."+path, requestPrefix+"."+requestPath
+		} else {
+			relRoot = child.jsonName
+		}
+		for _, filter := range node.filters {
+			if !policy.allowsField(relRoot) || !rules[path].allows(filter.field) {
+				return &QueryValidationError{Code: QueryValidationFieldNotAllowed, Field: requestPath + "." + filter.field}
+			}
+		}
+		if err := checkIncludeFilters(node.children, child, relRoot, path, requestPath, policy, rules); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// canonicalRelationRules keys the policy's relation rules by canonical path.
+func canonicalRelationRules(meta *relationMetadata, policy resolvedFieldPolicy) map[string]relationFieldRule {
+	rules := make(map[string]relationFieldRule, len(policy.relationRules))
+	for path, rule := range policy.relationRules {
+		if _, canonical, ok := resolveRelationPath(meta, path); ok {
+			rules[canonical] = rule
+		}
+	}
+	return rules
+}
+
+// resolveRelationPath walks a dotted relation path, returning the relation
+// metadata and the path in lowercase relation names.
+func resolveRelationPath(meta *relationMetadata, path string) (*relationMetadata, string, bool) {
+	if meta == nil {
+		return nil, "", false
+	}
+	current := meta
+	var names []string
+	for segment := range strings.SplitSeq(path, ".") {
+		child, ok := current.children[strings.ToLower(strings.TrimSpace(segment))]
+		if !ok || child == nil {
+			return nil, "", false
+		}
+		names = append(names, strings.ToLower(child.relationName))
+		current = child
+	}
+	return current, strings.Join(names, "."), true
+}
+
+func includesRelationPath(nodes map[string]*relationIncludeNode, canonical string) bool {
+	for name := range strings.SplitSeq(canonical, ".") {
+		var next *relationIncludeNode
+		for _, node := range nodes {
+			if node != nil && strings.ToLower(node.name) == name {
+				next = node
+				break
+			}
+		}
+		if next == nil {
+			return false
+		}
+		nodes = next.children
+	}
+	return true
+}
+
+func relationHasColumn(meta *relationMetadata, field string) bool {
+	column, ok := meta.fields[field]
+	// Tag options such as table: (bun.BaseModel) and rel: are not columns.
+	if !ok || column == "" || strings.Contains(column, ":") {
+		return false
+	}
+	// Relation fields are listed in the field map but are not columns.
+	for _, child := range meta.children {
+		if child != nil && child.jsonName == field {
+			return false
+		}
+	}
+	return true
+}
+
+func setIncludeColumns(nodes map[string]*relationIncludeNode, parent *relationMetadata, prefix string, requested map[string][]string, rules map[string]relationFieldRule) {
+	for _, node := range nodes {
+		if node == nil {
+			continue
+		}
+		child := parent.children[strings.ToLower(node.name)]
+		if child == nil {
+			continue
+		}
+		path := strings.ToLower(node.name)
+		if prefix != "" {
+			path = prefix + "." + path
+		}
+
+		fields, ok := requested[path]
+		rule, ruled := rules[path]
+		if ok || ruled {
+			if !ok {
+				fields = sortedKeys(child.fields)
+			}
+			columns := []string{}
+			for _, field := range fields {
+				if relationHasColumn(child, field) && rule.allows(field) && !slices.Contains(columns, child.fields[field]) {
+					columns = append(columns, child.fields[field])
+				}
+			}
+			node.columns = columns
+			node.parent = parent
+		}
+		setIncludeColumns(node.children, child, path, requested, rules)
+	}
+}
+
+// relationSelectColumns adds the relation's key and join columns to the
+// node's sparse columns so bun can still attach the loaded rows.
+func relationSelectColumns(q *bun.SelectQuery, node *relationIncludeNode) []string {
+	columns := append([]string{}, node.columns...)
+	if node.parent == nil || node.parent.typ == nil {
+		return columns
+	}
+	rel, ok := q.DB().Table(node.parent.typ).Relations[node.name]
+	if !ok {
+		return columns
+	}
+	keys := append(append([]*schema.Field{}, rel.JoinPKs...), rel.JoinTable.PKs...)
+	for _, field := range keys {
+		if !slices.Contains(columns, field.Name) {
+			columns = append(columns, field.Name)
+		}
+	}
+	return columns
+}
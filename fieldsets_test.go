@@ -0,0 +1,145 @@
+package crud
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+)
+
+func insertTestProfile(t *testing.T, db *bun.DB, userID uuid.UUID, bio string) *TestUserProfile {
+	t.Helper()
+	now := time.Now().UTC()
+	profile := &TestUserProfile{ID: uuid.New(), UserID: userID, Bio: bio, CreatedAt: now, UpdatedAt: now}
+	_, err := db.NewInsert().Model(profile).Exec(context.Background())
+	require.NoError(t, err)
+	return profile
+}
+
+func TestController_Index_RelationFieldsets(t *testing.T) {
+	app, db := setupApp(t)
+	defer db.Close()
+
+	user := &TestUser{Name: "Ann"}
+	insertTestUsers(t, db, user)
+	profile := insertTestProfile(t, db, user.ID, "Remote")
+
+	status, payload := listRequest(t, app, "/test-users?include=profiles&fields%5Bprofiles%5D=bio")
+	require.Equal(t, http.StatusOK, status)
+	require.Len(t, payload.Data, 1)
+	require.Len(t, payload.Data[0].Profiles, 1)
+	loaded := payload.Data[0].Profiles[0]
+	assert.Equal(t, "Remote", loaded.Bio)
+	assert.Equal(t, profile.ID, loaded.ID, "key columns are always loaded")
+	assert.Equal(t, user.ID, loaded.UserID, "join columns are always loaded")
+	assert.True(t, loaded.CreatedAt.IsZero(), "columns outside the fieldset are not loaded")
+	assert.Equal(t, map[string][]string{"profiles": {"bio"}}, payload.Meta.Fieldsets)
+
+	status, payload = listRequest(t, app, "/test-users?include=profiles")
+	require.Equal(t, http.StatusOK, status)
+	require.Len(t, payload.Data[0].Profiles, 1)
+	assert.False(t, payload.Data[0].Profiles[0].CreatedAt.IsZero())
+	assert.Nil(t, payload.Meta.Fieldsets)
+
+	for _, path := range []string{
+		"/test-users?include=profiles&fields%5Bprofiles%5D=nickname",
+		"/test-users?fields%5Bprofiles%5D=bio",
+		"/test-users?include=profiles&fields%5Bcompany%5D=id",
+	} {
+		status, _ = listRequest(t, app, path)
+		assert.Equal(t, http.StatusBadRequest, status, path)
+	}
+}
+
+func TestController_Index_RelationFieldsetsHonourFieldPolicy(t *testing.T) {
+	provider := func(req FieldPolicyRequest[*TestUser]) (FieldPolicy, error) {
+		return FieldPolicy{Name: "list:no-bio", Deny: []string{"profiles.bio"}}, nil
+	}
+	app, db := setupApp(t, WithFieldPolicyProvider(provider))
+	defer db.Close()
+
+	user := &TestUser{Name: "Ann"}
+	insertTestUsers(t, db, user)
+	insertTestProfile(t, db, user.ID, "Remote")
+
+	status, payload := listRequest(t, app, "/test-users?include=profiles")
+	require.Equal(t, http.StatusOK, status)
+	require.Len(t, payload.Data[0].Profiles, 1)
+	assert.Empty(t, payload.Data[0].Profiles[0].Bio, "denied relation columns are not loaded")
+	assert.False(t, payload.Data[0].Profiles[0].CreatedAt.IsZero())
+
+	status, _ = listRequest(t, app, "/test-users?include=profiles&fields%5Bprofiles%5D=bio")
+	assert.Equal(t, http.StatusBadRequest, status)
+	status, _ = listRequest(t, app, "/test-users?include=profiles.bio=Remote")
+	assert.Equal(t, http.StatusBadRequest, status, "denied relation fields cannot filter includes")
+	status, _ = listRequest(t, app, "/test-users?include=profiles.created_at__isnull=false")
+	assert.Equal(t, http.StatusOK, status)
+}
+
+func TestController_Index_IncludeFiltersHonourFieldPolicy(t *testing.T) {
+	provider := func(req FieldPolicyRequest[*TestUser]) (FieldPolicy, error) {
+		return FieldPolicy{Name: "list:no-profiles", Deny: []string{"profiles"}}, nil
+	}
+	app, db := setupApp(t, WithFieldPolicyProvider(provider))
+	defer db.Close()
+
+	user := &TestUser{Name: "Ann"}
+	insertTestUsers(t, db, user)
+	insertTestProfile(t, db, user.ID, "Remote")
+
+	status, _ := listRequest(t, app, "/test-users?include=profiles.bio=Remote")
+	assert.Equal(t, http.StatusBadRequest, status, "denied relations cannot filter includes")
+}
+
+func TestBuildIncludeTree_RejectsUnreadableFilterFields(t *testing.T) {
+	meta := getRelationMetadataForType(reflect.TypeFor[fieldsetPost]())
+
+	_, err := buildIncludeTree("author.name=Ann", meta)
+	require.NoError(t, err)
+
+	meta.children["author"].exposed = map[string]struct{}{"name": {}}
+	defer func() { meta.children["author"].exposed = nil }()
+	_, err = buildIncludeTree("author.bio=Ann", meta)
+	assert.Error(t, err, "fields the relation descriptor does not expose cannot filter")
+}
+
+type fieldsetAuthor struct {
+	bun.BaseModel `bun:"table:fieldset_authors,alias:fa"`
+
+	ID   int64  `bun:"id,pk" json:"id"`
+	Name string `bun:"name" json:"name"`
+	Bio  string `bun:"bio" json:"bio"`
+}
+
+type fieldsetPost struct {
+	bun.BaseModel `bun:"table:fieldset_posts,alias:fp"`
+
+	ID       int64           `bun:"id,pk" json:"id"`
+	Title    string          `bun:"title" json:"title"`
+	AuthorID int64           `bun:"author_id" json:"author_id"`
+	Author   *fieldsetAuthor `bun:"rel:belongs-to,join:author_id=id" json:"author"`
+}
+
+func TestBuildListCriteriaFromOptions_JoinedRelationFieldset(t *testing.T) {
+	criteria, filters, err := BuildListCriteriaFromOptions[*fieldsetPost](ListQueryOptions{
+		Include:   []string{"author"},
+		Fieldsets: map[string][]string{"author": {"name"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{"author": {"name"}}, filters.Fieldsets)
+
+	query := setupTestDB(t).NewSelect().Model((*fieldsetPost)(nil))
+	for _, criterion := range criteria {
+		query = criterion(query)
+	}
+	sql := query.String()
+	assert.Contains(t, sql, `"author"."name" AS "author__name"`)
+	assert.Contains(t, sql, `"author"."id" AS "author__id"`)
+	assert.NotContains(t, sql, `"author__bio"`)
+}
@@ -0,0 +1,340 @@
+package crud
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"sort"
+	"time"
+
+	querybun "github.com/goliatone/go-crud/pkg/go-query-bun"
+	"github.com/goliatone/go-repository-bun"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+// FilterMutationDryRunQueryParam makes the update-by-filter and
+// delete-by-filter routes report how many rows match without writing.
+const FilterMutationDryRunQueryParam = "dry_run"
+
+// FilterMutationResult is returned by the update-by-filter and
+// delete-by-filter routes.
+type FilterMutationResult struct {
+	Affected int  `json:"affected"`
+	DryRun   bool `json:"dry_run,omitempty"`
+}
+
+// FilterMutationService is implemented by services that can update or delete
+// every row matching a set of criteria in one statement. The repository-backed
+// service and the NewService layers implement it.
+type FilterMutationService[T any] interface {
+	// UpdateWhere copies the named fields (JSON names) of values onto every
+	// matching row and reports how many rows changed. Versions and updated_at
+	// are advanced; record lifecycle hooks do not run.
+	UpdateWhere(ctx Context, values T, fields []string, criteria []repository.SelectCriteria) (int, error)
+	DeleteWhere(ctx Context, criteria []repository.SelectCriteria) (int, error)
+	// CountWhere reports how many rows match; it backs ?dry_run=true.
+	CountWhere(ctx Context, criteria []repository.SelectCriteria) (int, error)
+}
+
+func filterMutationServiceOf[T any](svc any, op CrudOperation) (FilterMutationService[T], error) {
+	if mut, ok := svc.(FilterMutationService[T]); ok && mut != nil {
+		return mut, nil
+	}
+	return nil, UnsupportedOperationError{Operation: op}
+}
+
+// filterMutationCriteria keeps the filter and search criteria of plan and
+// refuses to build an unbounded write.
+func filterMutationCriteria(plan querybun.Plan) ([]repository.SelectCriteria, error) {
+	if len(plan.Filters) == 0 && len(plan.Search) == 0 {
+		return nil, &QueryValidationError{Code: QueryValidationFilterRequired}
+	}
+	criteria := make([]querybun.Criteria, 0, len(plan.Joins)+len(plan.Filters)+len(plan.Search))
+	criteria = append(criteria, plan.Joins...)
+	criteria = append(criteria, plan.Filters...)
+	criteria = append(criteria, plan.Search...)
+	return adaptQueryBunCriteria(criteria), nil
+}
+
+// --- repository service ---
+
+// UpdateWhere issues UPDATE ... WHERE pk IN (SELECT pk ... WHERE criteria).
+func (s *repositoryService[T]) UpdateWhere(ctx Context, values T, fields []string, criteria []repository.SelectCriteria) (int, error) {
+	db, err := s.filterMutationDB(ctx, OpUpdateByFilter)
+	if err != nil {
+		return 0, err
+	}
+	table := db.Dialect().Tables().Get(reflect.Indirect(reflect.ValueOf(values)).Type())
+	columns, err := filterMutationColumns(table, fields)
+	if err != nil {
+		return 0, err
+	}
+	q := db.NewUpdate().
+		Model(values).
+		Column(columns...).
+		Where("?PKs IN (?)", s.filterMutationSubquery(db, criteria))
+	res, err := filterMutationStamp(q, table, columns).Exec(ctx.UserContext())
+	return rowsAffected(res, err)
+}
+
+// filterMutationStamp advances the version and updated_at of every matching
+// row: numeric versions are incremented in SQL and timestamp columns are set
+// to the current time. Assigned columns are left as given.
+func filterMutationStamp(q *bun.UpdateQuery, table *schema.Table, columns []string) *bun.UpdateQuery {
+	now := nextVersionTime(time.Time{})
+	stamped := slices.Clone(columns)
+	if field, ok := versionFieldFor(table.Type); ok && !slices.Contains(stamped, field.column) {
+		stamped = append(stamped, field.column)
+		if typ := table.Type.FieldByIndex(field.index).Type; isTimeType(typ) {
+			q = q.Set("? = ?", bun.Ident(field.column), now)
+		} else if field.explicit {
+			q = q.Set("? = ? + 1", bun.Ident(field.column), bun.Ident(field.column))
+		}
+	}
+	if field, ok := table.FieldMap[versionFallbackColumn]; ok && isTimeType(field.IndirectType) && !slices.Contains(stamped, field.Name) {
+		q = q.Set("? = ?", bun.Ident(field.Name), now)
+	}
+	return q
+}
+
+func isTimeType(typ reflect.Type) bool {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	return typ == reflect.TypeFor[time.Time]()
+}
+
+// DeleteWhere removes every matching row; soft-deletable models are soft
+// deleted.
+func (s *repositoryService[T]) DeleteWhere(ctx Context, criteria []repository.SelectCriteria) (int, error) {
+	db, err := s.filterMutationDB(ctx, OpDeleteByFilter)
+	if err != nil {
+		return 0, err
+	}
+	res, err := db.NewDelete().
+		Model(s.repo.Handlers().NewRecord()).
+		Where("?PKs IN (?)", s.filterMutationSubquery(db, criteria)).
+		Exec(ctx.UserContext())
+	return rowsAffected(res, err)
+}
+
+func (s *repositoryService[T]) CountWhere(ctx Context, criteria []repository.SelectCriteria) (int, error) {
+	db, err := s.filterMutationDB(ctx, OpList)
+	if err != nil {
+		return 0, err
+	}
+	q := db.NewSelect().Model(s.repo.Handlers().NewRecord())
+	for _, c := range criteria {
+		q = q.Apply(c)
+	}
+	return q.Count(ctx.UserContext())
+}
+
+func (s *repositoryService[T]) filterMutationDB(ctx Context, op CrudOperation) (bun.IDB, error) {
+	if tx, ok := TxFromContext(ctx.UserContext()); ok {
+		return tx, nil
+	}
+	if provider, ok := s.repo.(repository.DBProvider); ok && provider.DB() != nil {
+		return provider.DB(), nil
+	}
+	return nil, UnsupportedOperationError{Operation: op}
+}
+
+// filterMutationSubquery selects the primary keys of the matching rows. The
+// derived table keeps MySQL from rejecting a subquery on the updated table.
+func (s *repositoryService[T]) filterMutationSubquery(db bun.IDB, criteria []repository.SelectCriteria) *bun.SelectQuery {
+	q := db.NewSelect().Model(s.repo.Handlers().NewRecord()).ColumnExpr("?PKs")
+	for _, c := range criteria {
+		q = q.Apply(c)
+	}
+	return db.NewSelect().TableExpr("(?) AS filtered", q).ColumnExpr("*")
+}
+
+// filterMutationColumns maps JSON field names to writable columns. Primary
+// keys cannot be assigned.
+func filterMutationColumns(table *schema.Table, fields []string) ([]string, error) {
+	byJSON := make(map[string]*schema.Field, len(table.Fields))
+	for _, field := range table.Fields {
+		byJSON[jsonFieldName(field.StructField)] = field
+	}
+	columns := make([]string, 0, len(fields))
+	for _, name := range fields {
+		field, ok := byJSON[name]
+		if !ok || field.StructField.Tag.Get("json") == "-" {
+			return nil, &ValidationError{fmt.Errorf("unknown field %q", name)}
+		}
+		if field.IsPK {
+			return nil, &ValidationError{fmt.Errorf("field %q is a primary key and cannot be updated", name)}
+		}
+		columns = append(columns, field.Name)
+	}
+	return columns, nil
+}
+
+func rowsAffected(res interface{ RowsAffected() (int64, error) }, err error) (int, error) {
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// --- controller ---
+
+// UpdateByFilter applies the body's field assignments to every row matching
+// the query filters:
+// PATCH /users?status__eq=draft  {"status": "archived"}
+func (c *Controller[T]) UpdateByFilter(ctx Context) error {
+	ctx = c.applyContextFactory(ctx)
+	meta, policy, criteria, err := c.prepareFilterMutation(ctx, OpUpdateByFilter)
+	if err != nil {
+		return c.resp.OnError(ctx, err, OpUpdateByFilter)
+	}
+	mut, err := filterMutationServiceOf[T](c.resolvedWriteService(), OpUpdateByFilter)
+	if err != nil {
+		return c.resp.OnError(ctx, err, OpUpdateByFilter)
+	}
+
+	values, fields, err := c.decodeFilterAssignments(ctx, policy)
+	if err != nil {
+		return c.resp.OnError(ctx, err, OpUpdateByFilter)
+	}
+	if values, err = enforceScopeOnFields(meta.scope, OpUpdateByFilter, values, fields); err != nil {
+		return c.resp.OnError(ctx, err, OpUpdateByFilter)
+	}
+	if queryFlag(ctx, FilterMutationDryRunQueryParam) {
+		return c.respondFilterMutationCount(ctx, OpUpdateByFilter, mut, criteria)
+	}
+
+	affected, err := mut.UpdateWhere(ctx, values, fields, criteria)
+	c.emitFilterMutationActivity(ctx, OpUpdateByFilter, meta, affected, err)
+	if err != nil {
+		return c.resp.OnError(ctx, err, OpUpdateByFilter)
+	}
+	return writeResult(c.resp, ctx, http.StatusOK, APIResponse[FilterMutationResult]{
+		Success: true,
+		Data:    FilterMutationResult{Affected: affected},
+	}, OpUpdateByFilter)
+}
+
+// DeleteByFilter deletes every row matching the query filters:
+// DELETE /users?created_at__lt=2024-01-01
+func (c *Controller[T]) DeleteByFilter(ctx Context) error {
+	ctx = c.applyContextFactory(ctx)
+	meta, _, criteria, err := c.prepareFilterMutation(ctx, OpDeleteByFilter)
+	if err != nil {
+		return c.resp.OnError(ctx, err, OpDeleteByFilter)
+	}
+	mut, err := filterMutationServiceOf[T](c.resolvedWriteService(), OpDeleteByFilter)
+	if err != nil {
+		return c.resp.OnError(ctx, err, OpDeleteByFilter)
+	}
+	if queryFlag(ctx, FilterMutationDryRunQueryParam) {
+		return c.respondFilterMutationCount(ctx, OpDeleteByFilter, mut, criteria)
+	}
+
+	affected, err := mut.DeleteWhere(ctx, criteria)
+	c.emitFilterMutationActivity(ctx, OpDeleteByFilter, meta, affected, err)
+	if err != nil {
+		return c.resp.OnError(ctx, err, OpDeleteByFilter)
+	}
+	return writeResult(c.resp, ctx, http.StatusOK, APIResponse[FilterMutationResult]{
+		Success: true,
+		Data:    FilterMutationResult{Affected: affected},
+	}, OpDeleteByFilter)
+}
+
+// prepareFilterMutation resolves guard and policy and builds the WHERE
+// criteria from the list filters, always ANDing in the scope filter. Filter
+// mutations never load the rows they write, so controllers with an Authorizer
+// reject them with ForbiddenError.
+func (c *Controller[T]) prepareFilterMutation(ctx Context, op CrudOperation) (guardRequestContext, resolvedFieldPolicy, []repository.SelectCriteria, error) {
+	if c.authorizer != nil {
+		return guardRequestContext{}, resolvedFieldPolicy{}, nil, &ForbiddenError{Operation: op, Reason: "filter mutations cannot be checked by the record authorizer"}
+	}
+	meta, policy, err := c.prepareWriteOp(ctx, op)
+	if err != nil {
+		return meta, policy, nil, err
+	}
+	criteria, _, err := BuildQueryCriteriaWithLogger[T](ctx, op, c.logger, c.queryLoggingEnabled, c.policyQueryOptions(policy)...)
+	if err != nil {
+		return meta, policy, nil, err
+	}
+	criteria = c.applyScopeCriteria(criteria, meta.scope)
+	criteria = c.applyFieldPolicyCriteria(criteria, policy)
+	return meta, policy, criteria, nil
+}
+
+// decodeFilterAssignments decodes the body into a record and lists the JSON
+// fields it assigns. Fields hidden by the field policy cannot be assigned, and
+// fields it does not make writable are rejected or dropped.
+func (c *Controller[T]) decodeFilterAssignments(ctx Context, policy resolvedFieldPolicy) (T, []string, error) {
+	var assignments map[string]json.RawMessage
+	if err := json.Unmarshal(ctx.Body(), &assignments); err != nil {
+		var zero T
+		return zero, nil, &ValidationError{fmt.Errorf("body must be a JSON object of field assignments: %w", err)}
+	}
+	if len(assignments) == 0 {
+		var zero T
+		return zero, nil, &ValidationError{errors.New("no fields to update")}
+	}
+	fields := make([]string, 0, len(assignments))
+	for field := range assignments {
+		if !policy.allowsField(field) {
+			var zero T
+			return zero, nil, &ValidationError{fmt.Errorf("field %q cannot be updated", field)}
+		}
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	fields, err := policy.filterWritableFields(OpUpdateByFilter, fields)
+	if err != nil {
+		var zero T
+		return zero, nil, err
+	}
+	if len(fields) == 0 {
+		var zero T
+		return zero, nil, &ValidationError{errors.New("no writable fields to update")}
+	}
+
+	values, err := c.deserializer(OpUpdateByFilter, ctx)
+	if err != nil {
+		return values, nil, &ValidationError{err}
+	}
+	return values, fields, nil
+}
+
+func (c *Controller[T]) respondFilterMutationCount(ctx Context, op CrudOperation, mut FilterMutationService[T], criteria []repository.SelectCriteria) error {
+	count, err := mut.CountWhere(ctx, criteria)
+	if err != nil {
+		return c.resp.OnError(ctx, err, op)
+	}
+	return writeResult(c.resp, ctx, http.StatusOK, APIResponse[FilterMutationResult]{
+		Success: true,
+		Data:    FilterMutationResult{Affected: count, DryRun: true},
+	}, op)
+}
+
+// emitFilterMutationActivity emits one event carrying the affected row count
+// and the query filters.
+func (c *Controller[T]) emitFilterMutationActivity(ctx Context, op CrudOperation, meta guardRequestContext, affected int, err error) {
+	if c.activityEmitterHooks == nil || !c.activityEmitterHooks.Enabled() {
+		return
+	}
+	filter := map[string]string{}
+	for key, value := range ctx.Queries() {
+		if key != FilterMutationDryRunQueryParam {
+			filter[key] = value
+		}
+	}
+	hctx := c.newHookContext(ctx, op, meta)
+	for _, evt := range c.buildActivityEvents(hctx, op, nil, err) {
+		evt.Metadata["affected"] = affected
+		evt.Metadata["filter"] = filter
+		_ = c.activityEmitterHooks.Emit(hookUserContext(hctx), evt)
+	}
+}
@@ -0,0 +1,136 @@
+package crud
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/goliatone/go-crud/pkg/activity"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func filterMutationRequest(t *testing.T, app *fiber.App, method, path string, body any) (int, FilterMutationResult) {
+	t.Helper()
+	var payload []byte
+	if body != nil {
+		var err error
+		payload, err = json.Marshal(body)
+		require.NoError(t, err)
+	}
+	req := httptest.NewRequest(method, path, bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req, -1)
+	require.NoError(t, err)
+
+	var out APIResponse[FilterMutationResult]
+	if resp.StatusCode == http.StatusOK {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+	}
+	return resp.StatusCode, out.Data
+}
+
+func TestController_UpdateByFilter(t *testing.T) {
+	capture := &activity.CaptureHook{}
+	app, db := setupApp(t, WithActivityHooks[*TestUser](activity.Hooks{capture}, activity.Config{Enabled: true}))
+	defer db.Close()
+	insertTestUsers(t, db,
+		&TestUser{Name: "Ann", Email: "ann@example.com", Age: 20},
+		&TestUser{Name: "Bob", Email: "bob@example.com", Age: 25},
+		&TestUser{Name: "Cid", Email: "cid@example.com", Age: 40},
+	)
+
+	status, result := filterMutationRequest(t, app, http.MethodPatch, "/test-users?age__lt=30&dry_run=true", map[string]any{"name": "Young"})
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, FilterMutationResult{Affected: 2, DryRun: true}, result)
+	assert.Empty(t, capture.Events, "dry runs do not emit activity")
+
+	status, result = filterMutationRequest(t, app, http.MethodPatch, "/test-users?age__lt=30", map[string]any{"name": "Young"})
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, 2, result.Affected)
+
+	var names []string
+	require.NoError(t, db.NewSelect().Model((*TestUser)(nil)).Column("name").Order("age").Scan(context.Background(), &names))
+	assert.Equal(t, []string{"Young", "Young", "Cid"}, names)
+
+	require.Len(t, capture.Events, 1)
+	evt := capture.Events[0]
+	assert.Equal(t, "crud.test-user.update.filter", evt.Verb)
+	assert.Equal(t, 2, evt.Metadata["affected"])
+	assert.Equal(t, map[string]string{"age__lt": "30"}, evt.Metadata["filter"])
+}
+
+func TestController_UpdateByFilter_ValidatesAndStampsRows(t *testing.T) {
+	var validated []string
+	validator := func(_ Context, user *TestUser) error {
+		validated = append(validated, user.Name)
+		if user.Name == "" {
+			return &ValidationError{errors.New("name is required")}
+		}
+		return nil
+	}
+	handler := &resultRecordingHandler[*TestUser]{ResponseHandler: NewDefaultResponseHandler[*TestUser]()}
+	app, db := setupApp(t, WithValidator[*TestUser](validator), WithResponseHandler[*TestUser](handler))
+	defer db.Close()
+	stale := time.Now().UTC().Add(-time.Hour).Truncate(time.Microsecond)
+	insertTestUsers(t, db, &TestUser{Name: "Ann", Email: "ann@example.com", Age: 20, UpdatedAt: stale})
+
+	status, _ := filterMutationRequest(t, app, http.MethodPatch, "/test-users?age__lt=30", map[string]any{"name": ""})
+	assert.Equal(t, http.StatusUnprocessableEntity, status, "the assigned values are validated")
+
+	status, result := filterMutationRequest(t, app, http.MethodPatch, "/test-users?age__lt=30", map[string]any{"name": "Young"})
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, 1, result.Affected)
+	assert.Equal(t, []string{"", "Young"}, validated, "the validator runs once per request")
+	assert.Equal(t, []int{http.StatusOK}, handler.statuses, "the result goes through the response handler")
+
+	var stored TestUser
+	require.NoError(t, db.NewSelect().Model(&stored).Scan(context.Background()))
+	assert.Equal(t, "Young", stored.Name)
+	assert.True(t, stored.UpdatedAt.After(stale), "updated_at is advanced")
+}
+
+func TestController_DeleteByFilter(t *testing.T) {
+	app, db := setupApp(t)
+	defer db.Close()
+	insertTestUsers(t, db,
+		&TestUser{Name: "Ann", Email: "ann@example.com", Age: 20},
+		&TestUser{Name: "Bob", Email: "bob@example.com", Age: 40},
+	)
+
+	status, result := filterMutationRequest(t, app, http.MethodDelete, "/test-users?age__gte=30&dry_run=true", nil)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, FilterMutationResult{Affected: 1, DryRun: true}, result)
+	assert.Equal(t, 2, countTestUsers(t, db))
+
+	status, result = filterMutationRequest(t, app, http.MethodDelete, "/test-users?age__gte=30", nil)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, 1, result.Affected)
+	assert.Equal(t, 1, countTestUsers(t, db))
+}
+
+func TestController_FilterMutation_Guards(t *testing.T) {
+	app, db := setupApp(t)
+	defer db.Close()
+	insertTestUsers(t, db, &TestUser{Name: "Ann", Email: "ann@example.com", Age: 20})
+
+	status, _ := filterMutationRequest(t, app, http.MethodDelete, "/test-users", nil)
+	assert.Equal(t, http.StatusBadRequest, status, "a filter is required")
+	status, _ = filterMutationRequest(t, app, http.MethodDelete, "/test-users?dry_run=true", nil)
+	assert.Equal(t, http.StatusBadRequest, status, "dry_run is not a filter")
+	assert.Equal(t, 1, countTestUsers(t, db))
+
+	status, _ = filterMutationRequest(t, app, http.MethodPatch, "/test-users?age__lt=30", map[string]any{"id": uuid.New().String()})
+	assert.Equal(t, http.StatusUnprocessableEntity, status, "primary keys cannot be assigned")
+	status, _ = filterMutationRequest(t, app, http.MethodPatch, "/test-users?age__lt=30", map[string]any{"nickname": "x"})
+	assert.Equal(t, http.StatusUnprocessableEntity, status, "unknown fields are rejected")
+	status, _ = filterMutationRequest(t, app, http.MethodPatch, "/test-users?age__lt=30", map[string]any{})
+	assert.Equal(t, http.StatusUnprocessableEntity, status)
+}
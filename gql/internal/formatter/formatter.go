@@ -13,6 +13,7 @@ const (
 	unionMembersKey       = "x-gql-union-members"
 	unionDiscriminatorKey = "x-gql-union-discriminator-map"
 	unionOverridesKey     = "x-gql-union-type-map"
+	softDeleteKey         = "x-soft-delete"
 )
 
 // Document is the template ready representation of a list of schemas.
@@ -29,6 +30,9 @@ type Entity struct {
 	LabelField    string
 	Fields        []Field
 	Relationships []Relation
+	// SoftDelete is set when a property carries the x-soft-delete marker, which
+	// enables the restore and purge mutations.
+	SoftDelete bool
 }
 
 // Union represents a GraphQL union derived from oneOf schema definitions.
@@ -202,6 +206,9 @@ func formatSchema(schema router.SchemaMetadata, opts Options) (Entity, []Union)
 		if field.Relation != nil {
 			relations[propName] = *field.Relation
 		}
+		if isSoftDeleteProperty(prop) {
+			entity.SoftDelete = true
+		}
 		fields = append(fields, field)
 	}
 
@@ -314,6 +321,18 @@ func buildUnionTypeMap(prop router.PropertyInfo, opts Options) map[string]string
 	return out
 }
 
+func isSoftDeleteProperty(prop router.PropertyInfo) bool {
+	if marked, _ := prop.CustomTagData[softDeleteKey].(bool); marked {
+		return true
+	}
+	for _, part := range strings.Split(prop.AllTags["bun"], ",") {
+		if strings.TrimSpace(part) == "soft_delete" {
+			return true
+		}
+	}
+	return false
+}
+
 func unionMembers(prop router.PropertyInfo) []string {
 	if prop.CustomTagData == nil {
 		return nil
@@ -23,6 +23,7 @@ const (
 	unionMembersKey       = "x-gql-union-members"
 	unionDiscriminatorKey = "x-gql-union-discriminator-map"
 	unionOverridesKey     = "x-gql-union-type-map"
+	softDeleteKey         = "x-soft-delete"
 )
 
 // FromFile reads SchemaMetadata from a JSON file. The payload can be a single schema,
@@ -339,6 +340,9 @@ func propertyFromOpenAPI(name string, raw map[string]any) (router.PropertyInfo,
 	if members := unionMembersFromRaw(raw); len(members) > 0 {
 		setCustomTagData(&prop, unionMembersKey, members)
 	}
+	if boolValue(raw[softDeleteKey]) {
+		setCustomTagData(&prop, softDeleteKey, true)
+	}
 
 	if nested, ok := raw["properties"].(map[string]any); ok && len(nested) > 0 {
 		prop.Properties = make(map[string]router.PropertyInfo, len(nested))
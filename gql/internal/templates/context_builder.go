@@ -219,6 +219,27 @@ func buildDefaultOverlay(doc formatter.Document, subscriptionEvents []string) ov
 				},
 			},
 		)
+
+		if entity.SoftDelete {
+			mutations = append(mutations,
+				overlay.Operation{
+					Name:       "restore" + entity.Name,
+					ReturnType: entity.Name,
+					Required:   true,
+					Args: []overlay.Argument{
+						{Name: "id", Type: "UUID", Required: true},
+					},
+				},
+				overlay.Operation{
+					Name:       "purge" + entity.Name,
+					ReturnType: "Boolean",
+					Required:   true,
+					Args: []overlay.Argument{
+						{Name: "id", Type: "UUID", Required: true},
+					},
+				},
+			)
+		}
 	}
 
 	return overlay.Overlay{
@@ -768,7 +768,45 @@ func (r *Resolver) Delete{{ entity.Name }}(ctx context.Context, id string) (bool
 	}
 {% endif %}	return true, nil
 }
+{% if entity.SoftDelete %}
+func (r *Resolver) Restore{{ entity.Name }}(ctx context.Context, id string) (*model.{{ entity.Name }}, error) {
+	if err := r.guard(ctx, "{{ entity.Name }}", "restore"); err != nil {
+		return nil, err
+	}
+	restorer, ok := r.{{ entity.Name }}Service().(crud.SoftDeleteService[model.{{ entity.Name }}])
+	if !ok {
+		return nil, crud.UnsupportedOperationError{Operation: crud.OpRestore}
+	}
+	record, err := r.{{ entity.Name }}Service().Show(r.crudContext(ctx), id, []repository.SelectCriteria{repository.SelectDeletedOnly()})
+	if err != nil {
+		return nil, err
+	}
+	record, err = restorer.Restore(r.crudContext(ctx), record)
+	if err != nil {
+		return nil, err
+	}
+{% if Subscriptions %}	if err := r.publishEvent(ctx, "{{ entity.Name }}", "updated", record); err != nil {
+		return nil, err
+	}
+{% endif %}	return &record, nil
+}
 
+func (r *Resolver) Purge{{ entity.Name }}(ctx context.Context, id string) (bool, error) {
+	if err := r.guard(ctx, "{{ entity.Name }}", "purge"); err != nil {
+		return false, err
+	}
+	purger, ok := r.{{ entity.Name }}Service().(crud.SoftDeleteService[model.{{ entity.Name }}])
+	if !ok {
+		return false, crud.UnsupportedOperationError{Operation: crud.OpPurge}
+	}
+	var record model.{{ entity.Name }}
+	setID(&record, id)
+	if err := purger.Purge(r.crudContext(ctx), record); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+{% endif %}
 {% endfor %}
 {% if Subscriptions %}{% for sub in Subscriptions %}
 func (r *Resolver) {{ sub.MethodName }}(ctx context.Context{% for arg in sub.Args %}, {{ arg.Name }} any{% endfor %}) (<-chan {% if sub.List %}[]*model.{{ sub.ReturnType }}{% else %}*model.{{ sub.ReturnType }}{% endif %}, error) {
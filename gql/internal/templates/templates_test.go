@@ -181,6 +181,47 @@ func TestTemplates_RenderVersionedSchemas(t *testing.T) {
 	require.Contains(t, modelOut, "type ArticleV1_2_3 struct")
 }
 
+func TestTemplates_RenderSoftDeleteMutations(t *testing.T) {
+	renderer, err := NewRenderer()
+	require.NoError(t, err)
+
+	schemas := []router.SchemaMetadata{
+		{
+			Name: "note",
+			Properties: map[string]router.PropertyInfo{
+				"id":         {Type: "string"},
+				"deleted_at": {Type: "string", Format: "date-time", CustomTagData: map[string]any{"x-soft-delete": true}},
+			},
+		},
+		{
+			Name: "tag",
+			Properties: map[string]router.PropertyInfo{
+				"id": {Type: "string"},
+			},
+		},
+	}
+
+	doc, err := formatter.Format(schemas)
+	require.NoError(t, err)
+
+	ctx := BuildContext(doc, ContextOptions{
+		ConfigPath: "gqlgen.yml",
+		OutDir:     "graph",
+	})
+
+	schemaOut, err := renderer.Render(SchemaTemplate, ctx)
+	require.NoError(t, err)
+	require.Contains(t, schemaOut, "restoreNote(id: UUID!): Note!")
+	require.Contains(t, schemaOut, "purgeNote(id: UUID!): Boolean!")
+	require.NotContains(t, schemaOut, "restoreTag")
+
+	resolverOut, err := renderer.Render(ResolverGenTemplate, ctx)
+	require.NoError(t, err)
+	require.Contains(t, resolverOut, "func (r *Resolver) RestoreNote(")
+	require.Contains(t, resolverOut, "func (r *Resolver) PurgeNote(")
+	require.NotContains(t, resolverOut, "RestoreTag")
+}
+
 func TestBuildContext_OmitsMutationFields(t *testing.T) {
 	schemas, err := metadata.FromFile(filepath.Join("testdata", "metadata.json"))
 	require.NoError(t, err)
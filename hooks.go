@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/goliatone/go-crud/pkg/activity"
+	"github.com/uptrace/bun"
 )
 
 // HookMetadata carries operational attributes for lifecycle hooks.
@@ -13,6 +14,9 @@ type HookMetadata struct {
 	RouteName string
 	Method    string
 	Path      string
+	// UpsertAction is set for AfterUpsert hooks and reports whether the row
+	// was inserted or updated.
+	UpsertAction UpsertAction
 }
 
 // HookContext bundles the request context with hook metadata.
@@ -53,6 +57,14 @@ type LifecycleHooks[T any] struct {
 	AfterDelete       []HookFunc[T]
 	BeforeDeleteBatch []HookBatchFunc[T]
 	AfterDeleteBatch  []HookBatchFunc[T]
+
+	// Restore hooks run once per record, including for batch restores.
+	BeforeRestore []HookFunc[T]
+	AfterRestore  []HookFunc[T]
+
+	// Upsert hooks run once per record, including for batch upserts.
+	BeforeUpsert []HookFunc[T]
+	AfterUpsert  []HookFunc[T]
 }
 
 // ActivityHooks returns the v2 activity emitter constructed from pkg/activity.
@@ -70,6 +82,16 @@ func (h HookContext) NotificationEmitter() NotificationEmitter {
 	return h.notificationEmitter
 }
 
+// Tx returns the transaction wrapping the current operation, if any. Batch
+// endpoints run hooks and repository writes inside one transaction; hooks that
+// persist related data should use it so a failure rolls everything back.
+func (h HookContext) Tx() (bun.IDB, bool) {
+	if h.Context == nil {
+		return nil, false
+	}
+	return TxFromContext(h.Context.UserContext())
+}
+
 // HookFromContext adapts a legacy hook that only expected crud.Context into a
 // HookFunc that receives the enriched HookContext. Nil hooks return nil.
 func HookFromContext[T any](hook func(Context, T) error) HookFunc[T] {
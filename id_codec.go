@@ -0,0 +1,612 @@
+package crud
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ettle/strcase"
+	repository "github.com/goliatone/go-repository-bun"
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// CompositeIDSeparator joins the parts of composite keys in paths and payloads.
+const CompositeIDSeparator = ","
+
+// IDCodec parses, validates and formats primary key values exchanged through
+// routes, batch payloads and RPC requests.
+type IDCodec interface {
+	// Parse validates raw and returns the typed key value. Composite codecs
+	// return a []any with one entry per key column.
+	Parse(raw string) (any, error)
+	// Format renders a key value read from a record into its string form.
+	Format(value any) (string, error)
+	// Schema describes the route parameter in OpenAPI documents.
+	Schema() map[string]any
+}
+
+// UUIDCodec handles uuid.UUID keys. It is the default codec.
+type UUIDCodec struct{}
+
+func (UUIDCodec) Parse(raw string) (any, error) {
+	id, err := uuid.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return nil, fmt.Errorf("invalid uuid id %q: %w", raw, err)
+	}
+	return id, nil
+}
+
+func (UUIDCodec) Format(value any) (string, error) {
+	switch v := value.(type) {
+	case uuid.UUID:
+		return v.String(), nil
+	case [16]byte:
+		return uuid.UUID(v).String(), nil
+	case string:
+		id, err := uuid.Parse(v)
+		if err != nil {
+			return "", fmt.Errorf("invalid uuid id %q: %w", v, err)
+		}
+		return id.String(), nil
+	case fmt.Stringer:
+		return v.String(), nil
+	default:
+		return "", fmt.Errorf("unsupported uuid id type %T", value)
+	}
+}
+
+func (UUIDCodec) Schema() map[string]any {
+	return map[string]any{"type": "string", "format": "uuid"}
+}
+
+// Int64Codec handles integer serial keys.
+type Int64Codec struct{}
+
+func (Int64Codec) Parse(raw string) (any, error) {
+	id, err := strconv.ParseInt(strings.TrimSpace(raw), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid integer id %q", raw)
+	}
+	return id, nil
+}
+
+func (Int64Codec) Format(value any) (string, error) {
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), 10), nil
+	case reflect.String:
+		if _, err := strconv.ParseInt(rv.String(), 10, 64); err != nil {
+			return "", fmt.Errorf("invalid integer id %q", rv.String())
+		}
+		return rv.String(), nil
+	default:
+		return "", fmt.Errorf("unsupported integer id type %T", value)
+	}
+}
+
+func (Int64Codec) Schema() map[string]any {
+	return map[string]any{"type": "integer", "format": "int64"}
+}
+
+const ulidAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// bigIntDigits is the digit set used by big.Int for base 32, aligned with ulidAlphabet.
+const bigIntDigits = "0123456789abcdefghijklmnopqrstuv"
+
+// ULIDCodec handles ULID keys stored either as strings or as [16]byte values
+// (e.g. oklog/ulid.ULID). Parsing is case-insensitive and returns the canonical
+// upper-case form.
+type ULIDCodec struct{}
+
+func (ULIDCodec) Parse(raw string) (any, error) {
+	id := strings.ToUpper(strings.TrimSpace(raw))
+	if len(id) != 26 || id[0] > '7' {
+		return nil, fmt.Errorf("invalid ulid id %q", raw)
+	}
+	for i := 0; i < len(id); i++ {
+		if !strings.ContainsRune(ulidAlphabet, rune(id[i])) {
+			return nil, fmt.Errorf("invalid ulid id %q", raw)
+		}
+	}
+	return id, nil
+}
+
+func (c ULIDCodec) Format(value any) (string, error) {
+	rv := reflect.ValueOf(value)
+	switch {
+	case !rv.IsValid():
+		return "", fmt.Errorf("ulid id is empty")
+	case rv.Kind() == reflect.String:
+		parsed, err := c.Parse(rv.String())
+		if err != nil {
+			return "", err
+		}
+		return parsed.(string), nil
+	case isByteArray16(rv.Type()):
+		var raw [16]byte
+		reflect.Copy(reflect.ValueOf(&raw).Elem(), rv)
+		return encodeULID(raw), nil
+	default:
+		return "", fmt.Errorf("unsupported ulid id type %T", value)
+	}
+}
+
+func (ULIDCodec) Schema() map[string]any {
+	return map[string]any{"type": "string", "pattern": "^[0-7][0-9A-HJKMNP-TV-Za-hjkmnp-tv-z]{25}$"}
+}
+
+func (ULIDCodec) setField(field reflect.Value, value any) error {
+	id, ok := value.(string)
+	if !ok || !isByteArray16(field.Type()) {
+		return errIDFieldUnhandled
+	}
+	raw, err := decodeULID(id)
+	if err != nil {
+		return err
+	}
+	reflect.Copy(field, reflect.ValueOf(raw[:]))
+	return nil
+}
+
+func encodeULID(raw [16]byte) string {
+	digits := new(big.Int).SetBytes(raw[:]).Text(32)
+	var b strings.Builder
+	b.Grow(26)
+	for i := len(digits); i < 26; i++ {
+		b.WriteByte('0')
+	}
+	for i := 0; i < len(digits); i++ {
+		b.WriteByte(ulidAlphabet[strings.IndexByte(bigIntDigits, digits[i])])
+	}
+	return b.String()
+}
+
+func decodeULID(id string) ([16]byte, error) {
+	var raw [16]byte
+	digits := make([]byte, len(id))
+	for i := 0; i < len(id); i++ {
+		idx := strings.IndexByte(ulidAlphabet, id[i])
+		if idx < 0 {
+			return raw, fmt.Errorf("invalid ulid id %q", id)
+		}
+		digits[i] = bigIntDigits[idx]
+	}
+	value, ok := new(big.Int).SetString(string(digits), 32)
+	if !ok || value.BitLen() > 128 {
+		return raw, fmt.Errorf("invalid ulid id %q", id)
+	}
+	value.FillBytes(raw[:])
+	return raw, nil
+}
+
+func isByteArray16(typ reflect.Type) bool {
+	return typ != nil && typ.Kind() == reflect.Array && typ.Len() == 16 && typ.Elem().Kind() == reflect.Uint8
+}
+
+// StringCodec handles opaque string keys such as slugs.
+type StringCodec struct{}
+
+func (StringCodec) Parse(raw string) (any, error) {
+	id := strings.TrimSpace(raw)
+	if id == "" {
+		return nil, fmt.Errorf("empty id")
+	}
+	return id, nil
+}
+
+func (StringCodec) Format(value any) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case fmt.Stringer:
+		return v.String(), nil
+	default:
+		rv := reflect.ValueOf(value)
+		if rv.Kind() == reflect.String {
+			return rv.String(), nil
+		}
+		return "", fmt.Errorf("unsupported string id type %T", value)
+	}
+}
+
+func (StringCodec) Schema() map[string]any {
+	return map[string]any{"type": "string"}
+}
+
+// CompositeCodec handles multi-column keys encoded as "key1,key2". Parts are
+// matched to the model's primary key columns in struct field order.
+type CompositeCodec struct {
+	Parts []IDCodec
+}
+
+// NewCompositeCodec builds a CompositeCodec from per-column codecs.
+func NewCompositeCodec(parts ...IDCodec) CompositeCodec {
+	return CompositeCodec{Parts: parts}
+}
+
+func (c CompositeCodec) Parse(raw string) (any, error) {
+	segments := strings.Split(strings.TrimSpace(raw), CompositeIDSeparator)
+	if len(segments) != len(c.Parts) {
+		return nil, fmt.Errorf("invalid composite id %q: expected %d parts, got %d", raw, len(c.Parts), len(segments))
+	}
+	values := make([]any, len(segments))
+	for i, segment := range segments {
+		value, err := c.Parts[i].Parse(segment)
+		if err != nil {
+			return nil, fmt.Errorf("invalid composite id %q: part %d: %w", raw, i+1, err)
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+func (c CompositeCodec) Format(value any) (string, error) {
+	values, ok := value.([]any)
+	if !ok || len(values) != len(c.Parts) {
+		return "", fmt.Errorf("composite id requires %d values", len(c.Parts))
+	}
+	segments := make([]string, len(values))
+	for i, v := range values {
+		segment, err := c.Parts[i].Format(v)
+		if err != nil {
+			return "", err
+		}
+		if strings.Contains(segment, CompositeIDSeparator) {
+			return "", fmt.Errorf("composite id part %q contains separator %q", segment, CompositeIDSeparator)
+		}
+		segments[i] = segment
+	}
+	return strings.Join(segments, CompositeIDSeparator), nil
+}
+
+func (c CompositeCodec) Schema() map[string]any {
+	return map[string]any{
+		"type":        "string",
+		"description": fmt.Sprintf("Composite key with %d parts joined by %q", len(c.Parts), CompositeIDSeparator),
+	}
+}
+
+// idFieldSetter lets codecs adapt parsed values to key field types that plain
+// reflection conversion cannot handle.
+type idFieldSetter interface {
+	setField(field reflect.Value, value any) error
+}
+
+var errIDFieldUnhandled = errors.New("id field not handled by codec")
+
+// usesHandlerIDs reports whether IDs should go through the repository
+// ModelHandlers (uuid based), which keeps the pre-codec behavior intact.
+func usesHandlerIDs(codec IDCodec) bool {
+	if codec == nil {
+		return true
+	}
+	_, ok := codec.(UUIDCodec)
+	return ok
+}
+
+func resolveIDCodec(codec IDCodec) IDCodec {
+	if codec == nil {
+		return UUIDCodec{}
+	}
+	return codec
+}
+
+func codecParts(codec IDCodec) []IDCodec {
+	if composite, ok := codec.(CompositeCodec); ok {
+		return composite.Parts
+	}
+	return []IDCodec{codec}
+}
+
+type primaryKeyField struct {
+	index  []int
+	column string
+}
+
+var primaryKeyCache sync.Map
+
+// primaryKeyFields returns the bun primary key columns of typ in field order.
+func primaryKeyFields(typ reflect.Type) []primaryKeyField {
+	for typ != nil && typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return nil
+	}
+	if cached, ok := primaryKeyCache.Load(typ); ok {
+		return cached.([]primaryKeyField)
+	}
+	fields := collectPrimaryKeyFields(typ, nil)
+	primaryKeyCache.Store(typ, fields)
+	return fields
+}
+
+func collectPrimaryKeyFields(typ reflect.Type, parent []int) []primaryKeyField {
+	var fields []primaryKeyField
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		index := append(append([]int{}, parent...), i)
+		tag := field.Tag.Get(TAG_BUN)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && tag == "" {
+			fields = append(fields, collectPrimaryKeyFields(field.Type, index)...)
+			continue
+		}
+		if !field.IsExported() || tag == "" || tag == "-" {
+			continue
+		}
+		parts := strings.Split(tag, ",")
+		isPK := false
+		for _, opt := range parts[1:] {
+			if strings.TrimSpace(opt) == "pk" {
+				isPK = true
+				break
+			}
+		}
+		if !isPK {
+			continue
+		}
+		column := strings.TrimSpace(parts[0])
+		if column == "" {
+			column = strcase.ToSnake(field.Name)
+		}
+		fields = append(fields, primaryKeyField{index: index, column: column})
+	}
+	return fields
+}
+
+func recordStructValue(record any) (reflect.Value, bool) {
+	rv := reflect.ValueOf(record)
+	for rv.IsValid() && rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return reflect.Value{}, false
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() || rv.Kind() != reflect.Struct {
+		return reflect.Value{}, false
+	}
+	return rv, true
+}
+
+// keyFieldsFor resolves the primary key fields matching the codec arity.
+func keyFieldsFor(typ reflect.Type, codec IDCodec) ([]primaryKeyField, error) {
+	fields := primaryKeyFields(typ)
+	parts := codecParts(codec)
+	if len(fields) == 0 && len(parts) == 1 {
+		return []primaryKeyField{{column: "id"}}, nil
+	}
+	if len(fields) != len(parts) {
+		return nil, fmt.Errorf("id codec expects %d key columns, model defines %d", len(parts), len(fields))
+	}
+	return fields, nil
+}
+
+func keyFieldValue(rv reflect.Value, field primaryKeyField) (reflect.Value, bool) {
+	if field.index == nil {
+		fv := rv.FieldByName("ID")
+		return fv, fv.IsValid()
+	}
+	fv := rv.FieldByIndex(field.index)
+	return fv, fv.IsValid()
+}
+
+// assignIDValue stores a parsed key value into a record field, converting
+// between compatible kinds (e.g. int64 into int32 columns).
+func assignIDValue(codec IDCodec, field reflect.Value, value any) error {
+	if !field.CanSet() {
+		return fmt.Errorf("id field is not settable")
+	}
+	if field.Kind() == reflect.Pointer {
+		elem := reflect.New(field.Type().Elem())
+		if err := assignIDValue(codec, elem.Elem(), value); err != nil {
+			return err
+		}
+		field.Set(elem)
+		return nil
+	}
+	if setter, ok := codec.(idFieldSetter); ok {
+		if err := setter.setField(field, value); err != errIDFieldUnhandled {
+			return err
+		}
+	}
+	rv := reflect.ValueOf(value)
+	if !rv.IsValid() {
+		return fmt.Errorf("id value is empty")
+	}
+	if rv.Type().AssignableTo(field.Type()) {
+		field.Set(rv)
+		return nil
+	}
+	if isNumericKind(rv.Kind()) && field.Kind() == reflect.String {
+		return fmt.Errorf("cannot assign %s id to %s field", rv.Type(), field.Type())
+	}
+	if rv.Type().ConvertibleTo(field.Type()) {
+		field.Set(rv.Convert(field.Type()))
+		return nil
+	}
+	return fmt.Errorf("cannot assign %s id to %s field", rv.Type(), field.Type())
+}
+
+func isNumericKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	default:
+		return false
+	}
+}
+
+// setRecordID assigns a parsed key value to record.
+func setRecordID[T any](handlers repository.ModelHandlers[T], codec IDCodec, record T, value any) error {
+	if usesHandlerIDs(codec) && handlers.SetID != nil {
+		if id, ok := value.(uuid.UUID); ok {
+			handlers.SetID(record, id)
+			return nil
+		}
+	}
+
+	codec = resolveIDCodec(codec)
+	rv, ok := recordStructValue(record)
+	if !ok {
+		return fmt.Errorf("cannot set id on %T", record)
+	}
+	fields, err := keyFieldsFor(rv.Type(), codec)
+	if err != nil {
+		return err
+	}
+	values := []any{value}
+	if len(fields) > 1 {
+		composite, ok := value.([]any)
+		if !ok || len(composite) != len(fields) {
+			return fmt.Errorf("composite id requires %d values", len(fields))
+		}
+		values = composite
+	}
+	parts := codecParts(codec)
+	for i, field := range fields {
+		fv, ok := keyFieldValue(rv, field)
+		if !ok {
+			return fmt.Errorf("missing id field on %T", record)
+		}
+		if err := assignIDValue(parts[i], fv, values[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordKeyValues returns the raw key field values of record in column order.
+func recordKeyValues(codec IDCodec, record any) ([]primaryKeyField, []any, error) {
+	rv, ok := recordStructValue(record)
+	if !ok {
+		return nil, nil, fmt.Errorf("cannot read id from %T", record)
+	}
+	fields, err := keyFieldsFor(rv.Type(), resolveIDCodec(codec))
+	if err != nil {
+		return nil, nil, err
+	}
+	values := make([]any, len(fields))
+	for i, field := range fields {
+		fv, ok := keyFieldValue(rv, field)
+		if !ok {
+			return nil, nil, fmt.Errorf("missing id field on %T", record)
+		}
+		for fv.Kind() == reflect.Pointer {
+			if fv.IsNil() {
+				return nil, nil, fmt.Errorf("id field is nil on %T", record)
+			}
+			fv = fv.Elem()
+		}
+		values[i] = fv.Interface()
+	}
+	return fields, values, nil
+}
+
+// formatRecordID renders the key of record using the codec.
+func formatRecordID[T any](handlers repository.ModelHandlers[T], codec IDCodec, record T) (string, error) {
+	if usesHandlerIDs(codec) && handlers.GetID != nil {
+		return handlers.GetID(record).String(), nil
+	}
+	codec = resolveIDCodec(codec)
+	fields, values, err := recordKeyValues(codec, record)
+	if err != nil {
+		return "", err
+	}
+	if len(fields) > 1 {
+		return codec.Format(values)
+	}
+	return codec.Format(values[0])
+}
+
+// copyRecordID copies the key of src onto dst.
+func copyRecordID[T any](handlers repository.ModelHandlers[T], codec IDCodec, dst, src T) error {
+	if usesHandlerIDs(codec) && handlers.GetID != nil && handlers.SetID != nil {
+		handlers.SetID(dst, handlers.GetID(src))
+		return nil
+	}
+	raw, err := formatRecordID(handlers, codec, src)
+	if err != nil {
+		return err
+	}
+	value, err := resolveIDCodec(codec).Parse(raw)
+	if err != nil {
+		return err
+	}
+	return setRecordID(handlers, codec, dst, value)
+}
+
+// typedKeyValues parses raw and converts each part into the key column's Go
+// type so query arguments bind the same way record fields do.
+func typedKeyValues(typ reflect.Type, codec IDCodec, raw string) ([]primaryKeyField, []any, error) {
+	codec = resolveIDCodec(codec)
+	for typ != nil && typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	fields, err := keyFieldsFor(typ, codec)
+	if err != nil {
+		return nil, nil, err
+	}
+	parsed, err := codec.Parse(raw)
+	if err != nil {
+		return nil, nil, err
+	}
+	values := []any{parsed}
+	if len(fields) > 1 {
+		values = parsed.([]any)
+	}
+	parts := codecParts(codec)
+	for i, field := range fields {
+		if field.index == nil || typ == nil {
+			continue
+		}
+		holder := reflect.New(typ.FieldByIndex(field.index).Type).Elem()
+		if err := assignIDValue(parts[i], holder, values[i]); err != nil {
+			return nil, nil, err
+		}
+		values[i] = holder.Interface()
+	}
+	return fields, values, nil
+}
+
+// selectByKey builds select criteria matching the given key columns.
+func selectByKey(fields []primaryKeyField, values []any) repository.SelectCriteria {
+	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		for i, field := range fields {
+			q = q.Where("?TableAlias.? = ?", bun.Ident(field.column), values[i])
+		}
+		return q
+	}
+}
+
+// deleteByKeys builds delete criteria matching any of the given keys.
+func deleteByKeys(fields []primaryKeyField, keys [][]any) repository.DeleteCriteria {
+	if len(fields) == 1 {
+		values := make([]any, len(keys))
+		for i, key := range keys {
+			values[i] = key[0]
+		}
+		return repository.DeleteColumnIn(fields[0].column, values)
+	}
+	return func(q *bun.DeleteQuery) *bun.DeleteQuery {
+		return q.WhereGroup(" AND ", func(q *bun.DeleteQuery) *bun.DeleteQuery {
+			for _, key := range keys {
+				q = q.WhereGroup(" OR ", func(q *bun.DeleteQuery) *bun.DeleteQuery {
+					for i, field := range fields {
+						q = q.Where("?TableAlias.? = ?", bun.Ident(field.column), key[i])
+					}
+					return q
+				})
+			}
+			return q
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package crud
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+
+	"github.com/goliatone/go-repository-bun"
+)
+
+type serialWidget struct {
+	bun.BaseModel `bun:"table:serial_widgets,alias:sw"`
+
+	ID   int64  `bun:"id,pk,autoincrement" json:"id"`
+	Name string `bun:"name" json:"name"`
+}
+
+type membershipRecord struct {
+	bun.BaseModel `bun:"table:membership_records,alias:mr"`
+
+	OrgID  int64  `bun:"org_id,pk" json:"org_id"`
+	UserID string `bun:"user_id,pk" json:"user_id"`
+	Role   string `bun:"role" json:"role"`
+}
+
+func newCodecTestDB(t *testing.T, models ...any) *bun.DB {
+	t.Helper()
+	sqldb, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	sqldb.SetMaxOpenConns(1)
+	db := bun.NewDB(sqldb, sqlitedialect.New())
+	t.Cleanup(func() { _ = db.Close() })
+	for _, model := range models {
+		_, err := db.NewCreateTable().Model(model).IfNotExists().Exec(context.Background())
+		require.NoError(t, err)
+	}
+	return db
+}
+
+func setupSerialWidgetApp(t *testing.T) (*fiber.App, *bun.DB, *Controller[*serialWidget]) {
+	t.Helper()
+	db := newCodecTestDB(t, (*serialWidget)(nil))
+	repo := repository.NewRepository(db, repository.ModelHandlers[*serialWidget]{
+		NewRecord:     func() *serialWidget { return &serialWidget{} },
+		GetID:         func(*serialWidget) uuid.UUID { return uuid.Nil },
+		SetID:         func(*serialWidget, uuid.UUID) {},
+		GetIdentifier: func() string { return "Name" },
+	})
+	controller := NewController(repo, WithIDCodec[*serialWidget](Int64Codec{}))
+	app := fiber.New()
+	controller.RegisterRoutes(NewFiberAdapter(app))
+	return app, db, controller
+}
+
+func TestIDCodec_BuiltinsRoundTrip(t *testing.T) {
+	parsed, err := Int64Codec{}.Parse(" 42 ")
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), parsed)
+	formatted, err := Int64Codec{}.Format(int32(7))
+	require.NoError(t, err)
+	assert.Equal(t, "7", formatted)
+	_, err = Int64Codec{}.Parse("abc")
+	assert.Error(t, err)
+
+	ulid := "01ARZ3NDEKTSV4RRFFQ69G5FAV"
+	parsed, err = ULIDCodec{}.Parse(" 01arz3ndektsv4rrffq69g5fav")
+	require.NoError(t, err)
+	assert.Equal(t, ulid, parsed)
+	raw, err := decodeULID(ulid)
+	require.NoError(t, err)
+	formatted, err = ULIDCodec{}.Format(raw)
+	require.NoError(t, err)
+	assert.Equal(t, ulid, formatted)
+	_, err = ULIDCodec{}.Parse("81ARZ3NDEKTSV4RRFFQ69G5FAV")
+	assert.Error(t, err, "ulid values above 2^128 are rejected")
+
+	_, err = StringCodec{}.Parse("  ")
+	assert.Error(t, err)
+
+	composite := NewCompositeCodec(Int64Codec{}, StringCodec{})
+	parsed, err = composite.Parse("10,alice")
+	require.NoError(t, err)
+	assert.Equal(t, []any{int64(10), "alice"}, parsed)
+	formatted, err = composite.Format([]any{int64(10), "alice"})
+	require.NoError(t, err)
+	assert.Equal(t, "10,alice", formatted)
+	_, err = composite.Parse("10")
+	assert.Error(t, err)
+}
+
+func TestIDCodec_SetAndFormatCompositeRecord(t *testing.T) {
+	codec := NewCompositeCodec(Int64Codec{}, StringCodec{})
+	handlers := repository.ModelHandlers[*membershipRecord]{}
+	record := &membershipRecord{}
+
+	value, err := codec.Parse("3,bob")
+	require.NoError(t, err)
+	require.NoError(t, setRecordID(handlers, codec, record, value))
+	assert.Equal(t, int64(3), record.OrgID)
+	assert.Equal(t, "bob", record.UserID)
+
+	id, err := formatRecordID(handlers, codec, record)
+	require.NoError(t, err)
+	assert.Equal(t, "3,bob", id)
+}
+
+func TestController_Int64IDCodec_UpdateShowDelete(t *testing.T) {
+	app, db, _ := setupSerialWidgetApp(t)
+	ctx := context.Background()
+
+	widgets := []*serialWidget{{Name: "one"}, {Name: "two"}, {Name: "three"}}
+	for _, widget := range widgets {
+		_, err := db.NewInsert().Model(widget).Exec(ctx)
+		require.NoError(t, err)
+	}
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, fmt.Sprintf("/serial-widget/%d", widgets[0].ID), nil), -1)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	body, _ := json.Marshal(map[string]any{"name": "uno"})
+	req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/serial-widget/%d", widgets[0].ID), bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err = app.Test(req, -1)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	stored := &serialWidget{}
+	require.NoError(t, db.NewSelect().Model(stored).Where("id = ?", widgets[0].ID).Scan(ctx))
+	assert.Equal(t, "uno", stored.Name)
+
+	req = httptest.NewRequest(http.MethodPut, "/serial-widget/not-a-number", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err = app.Test(req, -1)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+
+	deleteBody, _ := json.Marshal([]int64{widgets[1].ID, widgets[2].ID})
+	req = httptest.NewRequest(http.MethodDelete, "/serial-widget/batch", bytes.NewReader(deleteBody))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err = app.Test(req, -1)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	count, err := db.NewSelect().Model((*serialWidget)(nil)).Count(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestController_CompositeIDCodec_ShowAndUpdate(t *testing.T) {
+	db := newCodecTestDB(t, (*membershipRecord)(nil))
+	ctx := context.Background()
+	repo := repository.NewRepository(db, repository.ModelHandlers[*membershipRecord]{
+		NewRecord:     func() *membershipRecord { return &membershipRecord{} },
+		GetIdentifier: func() string { return "UserID" },
+	})
+	controller := NewController(repo, WithIDCodec[*membershipRecord](NewCompositeCodec(Int64Codec{}, StringCodec{})))
+	app := fiber.New()
+	controller.RegisterRoutes(NewFiberAdapter(app))
+
+	records := []*membershipRecord{
+		{OrgID: 1, UserID: "alice", Role: "admin"},
+		{OrgID: 1, UserID: "bob", Role: "member"},
+	}
+	_, err := db.NewInsert().Model(&records).Exec(ctx)
+	require.NoError(t, err)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/membership-record/1,bob", nil), -1)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var shown APIResponse[membershipRecord]
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&shown))
+	assert.Equal(t, "member", shown.Data.Role)
+
+	body, _ := json.Marshal(map[string]any{"role": "owner"})
+	req := httptest.NewRequest(http.MethodPut, "/membership-record/1,alice", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err = app.Test(req, -1)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	stored := &membershipRecord{}
+	require.NoError(t, db.NewSelect().Model(stored).Where("org_id = 1 AND user_id = 'alice'").Scan(ctx))
+	assert.Equal(t, "owner", stored.Role)
+}
+
+func TestController_IDCodecSchemaInMetadata(t *testing.T) {
+	_, _, controller := setupSerialWidgetApp(t)
+
+	found := false
+	for _, route := range controller.GetMetadata().Routes {
+		for _, param := range route.Parameters {
+			if param.In == "path" && param.Name == "id" {
+				found = true
+				assert.Equal(t, "integer", param.Schema["type"], "route %s", route.Name)
+			}
+		}
+	}
+	assert.True(t, found)
+}
@@ -0,0 +1,387 @@
+package crud
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// IdempotencyKeyHeader carries the client supplied idempotency key.
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotencyReplayedHeader is set on responses served from the cache.
+	IdempotencyReplayedHeader = "Idempotent-Replayed"
+
+	defaultIdempotencyTTL = 24 * time.Hour
+)
+
+// ErrIdempotencyInFlight is returned by IdempotencyStore.Reserve while another
+// request holds the key.
+var ErrIdempotencyInFlight = errors.New("crud: idempotency key is in use by a request in flight")
+
+// IdempotencyConflictError reports a duplicate request that arrived while the
+// original was still running. It maps to 409.
+type IdempotencyConflictError struct{ error }
+
+// IdempotencyRecord is the cached outcome of a mutation.
+type IdempotencyRecord struct {
+	// Fingerprint identifies the request the key was first used with.
+	Fingerprint string
+	Status      int
+	Body        []byte
+	ContentType string
+	// Headers holds the response headers set by the handler, such as ETag.
+	Headers map[string]string
+}
+
+// IdempotencyStore persists idempotency keys and cached responses. Keys passed
+// to the store are already scoped by tenant and actor.
+type IdempotencyStore interface {
+	// Reserve claims key for a new request. It returns the cached record when
+	// key already completed, ErrIdempotencyInFlight while another request holds
+	// it, and (nil, nil) once the caller owns the key. ttl bounds how long both
+	// the reservation and the cached record are kept.
+	Reserve(ctx context.Context, key, fingerprint string, ttl time.Duration) (*IdempotencyRecord, error)
+	// Complete stores the response for a reserved key.
+	Complete(ctx context.Context, key string, record IdempotencyRecord) error
+	// Release drops a reservation so the request can be retried.
+	Release(ctx context.Context, key string) error
+}
+
+// IdempotencyConfig tunes WithIdempotency.
+type IdempotencyConfig struct {
+	// TTL defaults to 24h.
+	TTL time.Duration
+	// Header defaults to IdempotencyKeyHeader.
+	Header string
+}
+
+type idempotencyPolicy struct {
+	store  IdempotencyStore
+	ttl    time.Duration
+	header string
+}
+
+func newIdempotencyPolicy(store IdempotencyStore, cfg IdempotencyConfig) *idempotencyPolicy {
+	if store == nil {
+		return nil
+	}
+	policy := &idempotencyPolicy{store: store, ttl: cfg.TTL, header: strings.TrimSpace(cfg.Header)}
+	if policy.ttl <= 0 {
+		policy.ttl = defaultIdempotencyTTL
+	}
+	if policy.header == "" {
+		policy.header = IdempotencyKeyHeader
+	}
+	return policy
+}
+
+// reserve claims the scoped key for ctx. A non-nil record means the request
+// was already served.
+func (p *idempotencyPolicy) reserve(ctx Context, key, fingerprint string) (*IdempotencyRecord, error) {
+	record, err := p.store.Reserve(ctx.UserContext(), key, fingerprint, p.ttl)
+	if errors.Is(err, ErrIdempotencyInFlight) {
+		return nil, &IdempotencyConflictError{err}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if record != nil && record.Fingerprint != fingerprint {
+		return nil, &ValidationError{fmt.Errorf("idempotency key %q was used with a different request", requestHeader(ctx, p.header))}
+	}
+	return record, nil
+}
+
+// requestKey returns the idempotency key sent with ctx, if any.
+func (p *idempotencyPolicy) requestKey(ctx Context) string {
+	if p == nil {
+		return ""
+	}
+	return requestHeader(ctx, p.header)
+}
+
+// scopedIdempotencyKey namespaces key by tenant, actor and operation so two
+// callers never share cached responses. tenant is the tenant resolved for the
+// request, which may come from a trusted header rather than the actor.
+func scopedIdempotencyKey(tenant string, actor ActorContext, resource string, op CrudOperation, key string) string {
+	return strings.Join([]string{tenant, actor.ActorID, resource, string(op), key}, "|")
+}
+
+func idempotencyFingerprint(parts ...[]byte) string {
+	hash := sha256.New()
+	for _, part := range parts {
+		hash.Write(part)
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// idempotencyScope resolves the tenant and actor used to scope keys. Scope
+// guards run here so keys are partitioned by the same tenant and actor the
+// handler will see.
+func (c *Controller[T]) idempotencyScope(ctx Context, op CrudOperation) (string, ActorContext, error) {
+	ctx = c.applyContextFactory(ctx)
+	meta, err := c.resolveGuardContext(ctx, op)
+	if err != nil {
+		return "", ActorContext{}, err
+	}
+	return TenantFromContext(ctx.UserContext()), meta.actor, nil
+}
+
+// idempotent wraps a mutating route handler. Requests carrying an idempotency
+// key are served at most once; replays return the cached status and body.
+func (c *Controller[T]) idempotent(op CrudOperation, handler func(Context) error) func(Context) error {
+	if c.idempotency == nil {
+		return handler
+	}
+	policy := c.idempotency
+	return func(ctx Context) error {
+		key := policy.requestKey(ctx)
+		if key == "" {
+			return handler(ctx)
+		}
+		tenant, actor, err := c.idempotencyScope(ctx, op)
+		if err != nil {
+			return handler(ctx)
+		}
+
+		scoped := scopedIdempotencyKey(tenant, actor, c.resource, op, key)
+		fingerprint := idempotencyFingerprint([]byte(ctx.Params("id")), []byte(ctx.Query("atomic")), ctx.Body())
+		cached, err := policy.reserve(ctx, scoped, fingerprint)
+		if err != nil {
+			return c.resp.OnError(ctx, err, op)
+		}
+		if cached != nil {
+			return replayIdempotentResponse(ctx, cached)
+		}
+
+		recorder := &idempotencyRecorder{Context: ctx, status: http.StatusOK}
+		if err := handler(recorder); err != nil || !recorder.captured || recorder.status < 200 || recorder.status > 299 {
+			// Only successful responses the recorder could capture are
+			// cached; anything else frees the key so the client can retry.
+			_ = policy.store.Release(ctx.UserContext(), scoped)
+			return err
+		}
+		if err := policy.store.Complete(ctx.UserContext(), scoped, IdempotencyRecord{
+			Fingerprint: fingerprint,
+			Status:      recorder.status,
+			Body:        recorder.body,
+			ContentType: recorder.contentType,
+			Headers:     recorder.headers,
+		}); err != nil {
+			c.logger.Error("idempotency: failed to store response for key %q: %v", key, err)
+		}
+		return nil
+	}
+}
+
+func replayIdempotentResponse(ctx Context, record *IdempotencyRecord) error {
+	for key, value := range record.Headers {
+		setResponseHeader(ctx, key, value)
+	}
+	setResponseHeader(ctx, IdempotencyReplayedHeader, "true")
+	if len(record.Body) == 0 {
+		return ctx.SendStatus(record.Status)
+	}
+	if record.ContentType != "" {
+		return ctx.Status(record.Status).JSON(json.RawMessage(record.Body), record.ContentType)
+	}
+	return ctx.Status(record.Status).JSON(json.RawMessage(record.Body))
+}
+
+// RunIdempotent runs fn at most once per idempotency key for callers that do
+// not go through HTTP routes, such as the rpc package. The key is read from
+// the controller's idempotency header on ctx; request identifies the payload.
+// Replays decode the cached result into R. Failed calls are not cached.
+func RunIdempotent[T, R any](ctx Context, c *Controller[T], op CrudOperation, request any, fn func() (R, error)) (R, error) {
+	var zero R
+	policy := c.idempotency
+	key := policy.requestKey(ctx)
+	if key == "" {
+		return fn()
+	}
+	tenant, actor, err := c.idempotencyScope(ctx, op)
+	if err != nil {
+		return zero, err
+	}
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return zero, err
+	}
+
+	scoped := scopedIdempotencyKey(tenant, actor, c.resource, op, key)
+	fingerprint := idempotencyFingerprint(payload)
+	cached, err := policy.reserve(ctx, scoped, fingerprint)
+	if err != nil {
+		return zero, err
+	}
+	if cached != nil {
+		var out R
+		if err := json.Unmarshal(cached.Body, &out); err != nil {
+			return zero, err
+		}
+		return out, nil
+	}
+
+	result, err := fn()
+	if err != nil {
+		_ = policy.store.Release(ctx.UserContext(), scoped)
+		return result, err
+	}
+	body, err := json.Marshal(result)
+	if err == nil {
+		err = policy.store.Complete(ctx.UserContext(), scoped, IdempotencyRecord{
+			Fingerprint: fingerprint,
+			Status:      http.StatusOK,
+			Body:        body,
+			ContentType: "application/json",
+		})
+	}
+	if err != nil {
+		c.logger.Error("idempotency: failed to store response for key %q: %v", key, err)
+	}
+	return result, nil
+}
+
+// idempotencyRecorder captures the status, headers and JSON body written by a
+// handler. captured reports whether the response went through JSON or
+// SendStatus; bodies sent any other way cannot be replayed.
+type idempotencyRecorder struct {
+	Context
+	status      int
+	body        []byte
+	contentType string
+	headers     map[string]string
+	captured    bool
+}
+
+func (r *idempotencyRecorder) Status(status int) Response {
+	r.status = status
+	r.Context.Status(status)
+	return r
+}
+
+// JSON encodes data once and sends the same bytes it caches.
+func (r *idempotencyRecorder) JSON(data any, ctype ...string) error {
+	if len(ctype) > 0 {
+		r.contentType = ctype[0]
+	}
+	body, err := json.Marshal(data)
+	if err != nil {
+		return r.Context.JSON(data, ctype...)
+	}
+	r.body = body
+	r.captured = true
+	return r.Context.JSON(json.RawMessage(body), ctype...)
+}
+
+func (r *idempotencyRecorder) SendStatus(status int) error {
+	r.status = status
+	r.body = nil
+	r.captured = true
+	return r.Context.SendStatus(status)
+}
+
+func (r *idempotencyRecorder) SetUserContext(ctx context.Context) {
+	if setter, ok := r.Context.(userContextSetter); ok {
+		setter.SetUserContext(ctx)
+	}
+}
+
+func (r *idempotencyRecorder) Header(key string) string {
+	return requestHeader(r.Context, key)
+}
+
+func (r *idempotencyRecorder) SetHeader(key, value string) {
+	if r.headers == nil {
+		r.headers = make(map[string]string)
+	}
+	r.headers[key] = value
+	setResponseHeader(r.Context, key, value)
+}
+
+// memoryIdempotencySweepInterval is how often Reserve drops expired entries
+// from a MemoryIdempotencyStore.
+const memoryIdempotencySweepInterval = time.Minute
+
+// MemoryIdempotencyStore is an in-process IdempotencyStore. Entries do not
+// survive restarts and are not shared between instances. Expired entries are
+// swept by Reserve at most once a minute.
+type MemoryIdempotencyStore struct {
+	mu        sync.Mutex
+	entries   map[string]memoryIdempotencyEntry
+	now       func() time.Time
+	nextSweep time.Time
+}
+
+type memoryIdempotencyEntry struct {
+	record    *IdempotencyRecord
+	expiresAt time.Time
+}
+
+// NewMemoryIdempotencyStore returns an empty in-memory store.
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{
+		entries: make(map[string]memoryIdempotencyEntry),
+		now:     time.Now,
+	}
+}
+
+func (s *MemoryIdempotencyStore) Reserve(_ context.Context, key, _ string, ttl time.Duration) (*IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+	if entry, ok := s.entries[key]; ok && now.Before(entry.expiresAt) {
+		if entry.record == nil {
+			return nil, ErrIdempotencyInFlight
+		}
+		record := *entry.record
+		return &record, nil
+	}
+	s.entries[key] = memoryIdempotencyEntry{expiresAt: now.Add(ttl)}
+	return nil, nil
+}
+
+// sweep drops expired entries once the sweep interval has passed. Callers
+// hold s.mu.
+func (s *MemoryIdempotencyStore) sweep(now time.Time) {
+	if now.Before(s.nextSweep) {
+		return
+	}
+	maps.DeleteFunc(s.entries, func(_ string, entry memoryIdempotencyEntry) bool {
+		return !now.Before(entry.expiresAt)
+	})
+	s.nextSweep = now.Add(memoryIdempotencySweepInterval)
+}
+
+func (s *MemoryIdempotencyStore) Complete(_ context.Context, key string, record IdempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[key]
+	if !ok {
+		return fmt.Errorf("crud: idempotency key %q is not reserved", key)
+	}
+	record.Body = append([]byte(nil), record.Body...)
+	record.Headers = maps.Clone(record.Headers)
+	entry.record = &record
+	s.entries[key] = entry
+	return nil
+}
+
+func (s *MemoryIdempotencyStore) Release(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	return nil
+}
@@ -0,0 +1,112 @@
+package crud
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// IdempotencyEntry is the row stored by BunIdempotencyStore.
+type IdempotencyEntry struct {
+	bun.BaseModel `bun:"table:crud_idempotency_keys,alias:cik"`
+
+	Key         string            `bun:"idempotency_key,pk"`
+	Fingerprint string            `bun:"fingerprint,notnull"`
+	Completed   bool              `bun:"completed,notnull"`
+	Status      int               `bun:"status"`
+	Body        []byte            `bun:"body"`
+	ContentType string            `bun:"content_type"`
+	Headers     map[string]string `bun:"headers"`
+	ExpiresAt   time.Time         `bun:"expires_at,notnull"`
+}
+
+// BunIdempotencyStore keeps idempotency keys in a database table so replays
+// work across instances. Reservations rely on INSERT ... ON CONFLICT DO
+// NOTHING (PostgreSQL, SQLite).
+type BunIdempotencyStore struct {
+	db  bun.IDB
+	now func() time.Time
+}
+
+// NewBunIdempotencyStore returns a store backed by the crud_idempotency_keys table.
+func NewBunIdempotencyStore(db bun.IDB) *BunIdempotencyStore {
+	return &BunIdempotencyStore{db: db, now: time.Now}
+}
+
+// CreateTable creates the backing table when it does not exist.
+func (s *BunIdempotencyStore) CreateTable(ctx context.Context) error {
+	_, err := s.db.NewCreateTable().Model((*IdempotencyEntry)(nil)).IfNotExists().Exec(ctx)
+	return err
+}
+
+func (s *BunIdempotencyStore) Reserve(ctx context.Context, key, fingerprint string, ttl time.Duration) (*IdempotencyRecord, error) {
+	now := s.now().UTC()
+	// Expired keys are dropped first so they can be claimed again.
+	if _, err := s.db.NewDelete().
+		Model((*IdempotencyEntry)(nil)).
+		Where("?TableAlias.idempotency_key = ?", key).
+		Where("?TableAlias.expires_at <= ?", now).
+		Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	entry := &IdempotencyEntry{Key: key, Fingerprint: fingerprint, ExpiresAt: now.Add(ttl)}
+	// The conflicting row can be released between the insert and the lookup;
+	// the claim is then retried once before reporting the key as in flight.
+	for range 2 {
+		res, err := s.db.NewInsert().Model(entry).On("CONFLICT DO NOTHING").Exec(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if inserted, err := res.RowsAffected(); err == nil && inserted == 1 {
+			return nil, nil
+		}
+
+		existing := new(IdempotencyEntry)
+		err = s.db.NewSelect().
+			Model(existing).
+			Where("?TableAlias.idempotency_key = ?", key).
+			Scan(ctx)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !existing.Completed {
+			return nil, ErrIdempotencyInFlight
+		}
+		return &IdempotencyRecord{
+			Fingerprint: existing.Fingerprint,
+			Status:      existing.Status,
+			Body:        existing.Body,
+			ContentType: existing.ContentType,
+			Headers:     existing.Headers,
+		}, nil
+	}
+	return nil, ErrIdempotencyInFlight
+}
+
+func (s *BunIdempotencyStore) Complete(ctx context.Context, key string, record IdempotencyRecord) error {
+	_, err := s.db.NewUpdate().
+		Model((*IdempotencyEntry)(nil)).
+		Set("completed = ?", true).
+		Set("status = ?", record.Status).
+		Set("body = ?", record.Body).
+		Set("content_type = ?", record.ContentType).
+		Set("headers = ?", record.Headers).
+		Where("?TableAlias.idempotency_key = ?", key).
+		Exec(ctx)
+	return err
+}
+
+func (s *BunIdempotencyStore) Release(ctx context.Context, key string) error {
+	_, err := s.db.NewDelete().
+		Model((*IdempotencyEntry)(nil)).
+		Where("?TableAlias.idempotency_key = ?", key).
+		Exec(ctx)
+	return err
+}
@@ -0,0 +1,177 @@
+package crud
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestController_Create_IdempotencyKeyReplaysResponse(t *testing.T) {
+	store := NewMemoryIdempotencyStore()
+	app, db := setupApp(t, WithIdempotency[*TestUser](store))
+	defer db.Close()
+
+	send := func(body map[string]any) *http.Response {
+		req := batchRequest(http.MethodPost, "/test-user", body)
+		req.Header.Set(IdempotencyKeyHeader, "create-1")
+		resp, err := app.Test(req, -1)
+		require.NoError(t, err)
+		return resp
+	}
+
+	payload := map[string]any{"name": "Once", "email": "once@example.com"}
+	first := send(payload)
+	require.Equal(t, http.StatusCreated, first.StatusCode)
+	firstBody, err := io.ReadAll(first.Body)
+	require.NoError(t, err)
+	assert.Empty(t, first.Header.Get(IdempotencyReplayedHeader))
+
+	second := send(payload)
+	require.Equal(t, http.StatusCreated, second.StatusCode)
+	secondBody, err := io.ReadAll(second.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "true", second.Header.Get(IdempotencyReplayedHeader))
+	assert.JSONEq(t, string(firstBody), string(secondBody))
+	assert.Equal(t, 1, countTestUsers(t, db))
+
+	mismatch := send(map[string]any{"name": "Other", "email": "other@example.com"})
+	assert.Equal(t, http.StatusUnprocessableEntity, mismatch.StatusCode)
+	assert.Equal(t, 1, countTestUsers(t, db))
+}
+
+func TestController_Create_IdempotencyKeyInFlight(t *testing.T) {
+	store := NewMemoryIdempotencyStore()
+	app, db := setupApp(t, WithIdempotency[*TestUser](store))
+	defer db.Close()
+
+	scoped := scopedIdempotencyKey("", ActorContext{}, "test-user", OpCreate, "busy")
+	_, err := store.Reserve(context.Background(), scoped, "", time.Minute)
+	require.NoError(t, err)
+
+	req := batchRequest(http.MethodPost, "/test-user", map[string]any{"name": "Busy", "email": "busy@example.com"})
+	req.Header.Set(IdempotencyKeyHeader, "busy")
+	resp, err := app.Test(req, -1)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	assert.Zero(t, countTestUsers(t, db))
+
+	require.NoError(t, store.Release(context.Background(), scoped))
+	resp, err = app.Test(req.Clone(context.Background()), -1)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+}
+
+func TestController_Update_IdempotencyKeyReplaysOnlySuccess(t *testing.T) {
+	app, db := setupApp(t, WithIdempotency[*TestUser](NewMemoryIdempotencyStore()))
+	defer db.Close()
+
+	id := uuid.New()
+	send := func() *http.Response {
+		req := batchRequest(http.MethodPut, "/test-user/"+id.String(), map[string]any{"name": "Renamed", "updated_at": "2024-05-06T07:08:09Z"})
+		req.Header.Set(IdempotencyKeyHeader, "update-1")
+		resp, err := app.Test(req, -1)
+		require.NoError(t, err)
+		return resp
+	}
+
+	missing := send()
+	require.Equal(t, http.StatusNotFound, missing.StatusCode)
+
+	insertTestUsers(t, db, &TestUser{ID: id, Name: "Original", Email: "original@example.com"})
+	first := send()
+	require.Equal(t, http.StatusOK, first.StatusCode, "error responses are not cached")
+	assert.Empty(t, first.Header.Get(IdempotencyReplayedHeader))
+	require.NotEmpty(t, first.Header.Get("ETag"))
+
+	second := send()
+	require.Equal(t, http.StatusOK, second.StatusCode)
+	assert.Equal(t, "true", second.Header.Get(IdempotencyReplayedHeader))
+	assert.Equal(t, first.Header.Get("ETag"), second.Header.Get("ETag"), "replays carry the original headers")
+}
+
+func TestBunIdempotencyStore_RoundTrip(t *testing.T) {
+	db := newCodecTestDB(t)
+	ctx := context.Background()
+	store := NewBunIdempotencyStore(db)
+	require.NoError(t, store.CreateTable(ctx))
+
+	record, err := store.Reserve(ctx, "k", "fp", time.Minute)
+	require.NoError(t, err)
+	assert.Nil(t, record)
+
+	_, err = store.Reserve(ctx, "k", "fp", time.Minute)
+	assert.ErrorIs(t, err, ErrIdempotencyInFlight)
+
+	body, _ := json.Marshal(map[string]string{"id": "1"})
+	require.NoError(t, store.Complete(ctx, "k", IdempotencyRecord{Fingerprint: "fp", Status: http.StatusCreated, Body: body, Headers: map[string]string{"ETag": `"1"`}}))
+
+	record, err = store.Reserve(ctx, "k", "fp", time.Minute)
+	require.NoError(t, err)
+	require.NotNil(t, record)
+	assert.Equal(t, http.StatusCreated, record.Status)
+	assert.JSONEq(t, string(body), string(record.Body))
+	assert.Equal(t, map[string]string{"ETag": `"1"`}, record.Headers)
+
+	store.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	record, err = store.Reserve(ctx, "k", "fp", time.Minute)
+	require.NoError(t, err)
+	assert.Nil(t, record, "expired keys can be claimed again")
+}
+
+func TestController_Action_IdempotencyKeySkipsUncapturedResponses(t *testing.T) {
+	var calls int
+	action := Action[*TestUser]{
+		Name:   "Ping",
+		Method: http.MethodPost,
+		Target: ActionTargetCollection,
+		Handler: func(actx ActionContext[*TestUser]) error {
+			calls++
+			// The status is set but the body is not sent through JSON or
+			// SendStatus, so there is nothing to replay.
+			actx.Status(http.StatusAccepted)
+			return nil
+		},
+	}
+	app, db := setupApp(t, WithActions(action), WithIdempotency[*TestUser](NewMemoryIdempotencyStore()))
+	defer db.Close()
+
+	for range 2 {
+		req := batchRequest(http.MethodPost, "/test-users/actions/ping", nil)
+		req.Header.Set(IdempotencyKeyHeader, "ping-1")
+		resp, err := app.Test(req, -1)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+		assert.Empty(t, resp.Header.Get(IdempotencyReplayedHeader))
+	}
+	assert.Equal(t, 2, calls, "uncaptured responses release the key")
+}
+
+func TestMemoryIdempotencyStore_SweepsExpiredEntries(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryIdempotencyStore()
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	for _, key := range []string{"a", "b", "c"} {
+		_, err := store.Reserve(ctx, key, "", time.Second)
+		require.NoError(t, err)
+	}
+	require.NoError(t, store.Complete(ctx, "a", IdempotencyRecord{Status: http.StatusOK}))
+	_, err := store.Reserve(ctx, "live", "", time.Hour)
+	require.NoError(t, err)
+	assert.Len(t, store.entries, 4)
+
+	now = now.Add(memoryIdempotencySweepInterval)
+	_, err = store.Reserve(ctx, "d", "", time.Second)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"live", "d"}, slices.Collect(maps.Keys(store.entries)), "expired keys are dropped by the next sweep")
+}
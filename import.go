@@ -0,0 +1,582 @@
+package crud
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"encoding"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/ettle/strcase"
+	goerrors "github.com/goliatone/go-errors"
+	"github.com/goliatone/go-repository-bun"
+)
+
+// Query parameters read by the import route.
+const (
+	// ImportFormatQueryParam selects csv or ndjson; it defaults to the request
+	// Content-Type and then to csv.
+	ImportFormatQueryParam = "format"
+	// ImportDryRunQueryParam validates every row without writing.
+	ImportDryRunQueryParam = "dry_run"
+	// ImportUpsertQueryParam updates rows whose identifier matches a stored
+	// record instead of creating them.
+	ImportUpsertQueryParam = "upsert"
+	// ImportHeaderMapQueryParam maps source columns to JSON field names, e.g.
+	// `header_map=E-mail:email,Full Name:name`.
+	ImportHeaderMapQueryParam = "header_map"
+
+	DefaultImportChunkSize = 500
+)
+
+// ImportConfig tunes the import route.
+type ImportConfig struct {
+	// ChunkSize is the number of rows written per transaction. Defaults to
+	// DefaultImportChunkSize.
+	ChunkSize int
+	// HeaderMap maps source columns (CSV headers or NDJSON keys) to JSON field
+	// names. Entries from the header_map query parameter take precedence.
+	HeaderMap map[string]string
+}
+
+func (cfg ImportConfig) normalized() ImportConfig {
+	if cfg.ChunkSize <= 0 {
+		cfg.ChunkSize = DefaultImportChunkSize
+	}
+	return cfg
+}
+
+// ImportRowError reports why a row was not imported. Row is the 1-based data
+// row, not counting the CSV header line.
+type ImportRowError struct {
+	Row    int             `json:"row"`
+	Status int             `json:"status"`
+	Error  *goerrors.Error `json:"error"`
+}
+
+// ImportChunkResult is the progress entry for one chunk of rows. Failed counts
+// the rows reported in ImportSummary.Errors; RolledBack counts the valid rows
+// that were not written because another row failed the chunk transaction.
+type ImportChunkResult struct {
+	Index      int  `json:"index"`
+	FirstRow   int  `json:"first_row"`
+	Rows       int  `json:"rows"`
+	Created    int  `json:"created"`
+	Updated    int  `json:"updated"`
+	Failed     int  `json:"failed"`
+	RolledBack int  `json:"rolled_back"`
+	Committed  bool `json:"committed"`
+}
+
+// ImportSummary is returned by the import route. In dry runs Created and
+// Updated count the rows that would have been written.
+type ImportSummary struct {
+	Format     string              `json:"format"`
+	DryRun     bool                `json:"dry_run"`
+	Upsert     bool                `json:"upsert"`
+	Total      int                 `json:"total"`
+	Created    int                 `json:"created"`
+	Updated    int                 `json:"updated"`
+	Failed     int                 `json:"failed"`
+	RolledBack int                 `json:"rolled_back"`
+	Chunks     []ImportChunkResult `json:"chunks"`
+	Errors     []ImportRowError    `json:"errors,omitempty"`
+}
+
+func (s *ImportSummary) addChunk(chunk ImportChunkResult) {
+	s.Total += chunk.Rows
+	s.Created += chunk.Created
+	s.Updated += chunk.Updated
+	s.Failed += chunk.Failed
+	s.RolledBack += chunk.RolledBack
+	s.Chunks = append(s.Chunks, chunk)
+}
+
+func (s *ImportSummary) complete() bool {
+	return s.Failed == 0 && s.RolledBack == 0
+}
+
+// importRowReader yields one decoded row per call and io.EOF at the end. Row
+// level decode errors are returned as *importRowDecodeError so reading can go on.
+type importRowReader interface {
+	Next() (map[string]any, error)
+}
+
+type importRowDecodeError struct{ error }
+
+func newImportRowReader(format string, body io.Reader, headerMap map[string]string) importRowReader {
+	if format == ExportFormatNDJSON {
+		scanner := bufio.NewScanner(body)
+		scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+		return &ndjsonImportReader{scanner: scanner, headerMap: headerMap}
+	}
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	return &csvImportReader{reader: reader, headerMap: headerMap}
+}
+
+type csvImportReader struct {
+	reader    *csv.Reader
+	headerMap map[string]string
+	header    []string
+}
+
+func (r *csvImportReader) Next() (map[string]any, error) {
+	if r.header == nil {
+		header, err := r.reader.Read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, io.EOF
+			}
+			return nil, &ValidationError{fmt.Errorf("invalid csv header: %w", err)}
+		}
+		r.header = make([]string, len(header))
+		for i, column := range header {
+			r.header[i] = mapImportColumn(strings.TrimPrefix(column, "\ufeff"), r.headerMap)
+		}
+	}
+	record, err := r.reader.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return nil, &importRowDecodeError{err}
+		}
+		return nil, err
+	}
+	if len(record) > len(r.header) {
+		return nil, &importRowDecodeError{fmt.Errorf("row has %d columns, header has %d", len(record), len(r.header))}
+	}
+	row := make(map[string]any, len(record))
+	for i, value := range record {
+		if value == "" {
+			continue
+		}
+		row[r.header[i]] = value
+	}
+	return row, nil
+}
+
+type ndjsonImportReader struct {
+	scanner   *bufio.Scanner
+	headerMap map[string]string
+}
+
+func (r *ndjsonImportReader) Next() (map[string]any, error) {
+	for r.scanner.Scan() {
+		line := bytes.TrimSpace(r.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var raw map[string]json.RawMessage
+		if err := json.Unmarshal(line, &raw); err != nil {
+			return nil, &importRowDecodeError{err}
+		}
+		row := make(map[string]any, len(raw))
+		for key, value := range raw {
+			row[mapImportColumn(key, r.headerMap)] = value
+		}
+		return row, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return nil, &ValidationError{err}
+	}
+	return nil, io.EOF
+}
+
+func mapImportColumn(column string, headerMap map[string]string) string {
+	column = strings.TrimSpace(column)
+	if mapped, ok := headerMap[column]; ok && mapped != "" {
+		return mapped
+	}
+	return column
+}
+
+// parseImportHeaderMap parses `Source:field,Other:field` pairs.
+func parseImportHeaderMap(raw string, base map[string]string) map[string]string {
+	out := make(map[string]string, len(base))
+	for source, field := range base {
+		out[strings.TrimSpace(source)] = strings.TrimSpace(field)
+	}
+	for pair := range strings.SplitSeq(raw, ",") {
+		source, field, ok := strings.Cut(pair, ":")
+		if !ok {
+			continue
+		}
+		out[strings.TrimSpace(source)] = strings.TrimSpace(field)
+	}
+	return out
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// importFieldTypes maps JSON field names of typ to their Go types.
+func importFieldTypes(typ reflect.Type) map[string]reflect.Type {
+	for typ != nil && typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	out := map[string]reflect.Type{}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return out
+	}
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && field.Tag.Get("json") == "" {
+			for name, fieldType := range importFieldTypes(field.Type) {
+				out[name] = fieldType
+			}
+			continue
+		}
+		if !field.IsExported() || field.Tag.Get("json") == "-" {
+			continue
+		}
+		out[jsonFieldName(field)] = field.Type
+	}
+	return out
+}
+
+// importValue converts a CSV cell to the JSON value expected by a field of
+// typ: strings stay strings for string and text types, anything else is
+// passed through as JSON when it parses as JSON.
+func importValue(value any, typ reflect.Type) any {
+	cell, ok := value.(string)
+	if !ok {
+		return value
+	}
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ.Kind() == reflect.String || reflect.PointerTo(typ).Implements(textUnmarshalerType) {
+		return cell
+	}
+	if json.Valid([]byte(cell)) {
+		return json.RawMessage(cell)
+	}
+	return cell
+}
+
+type importRow[T any] struct {
+	row    int
+	record T
+}
+
+// --- controller ---
+
+// Import creates (or, with upsert=true, updates) records from a CSV or NDJSON
+// request body, writing each chunk of rows in its own transaction:
+// POST /user/import?format=csv&dry_run=true&header_map=E-mail:email
+func (c *Controller[T]) Import(ctx Context) error {
+	ctx = c.applyContextFactory(ctx)
+	summary, err := c.importRows(ctx)
+	if err != nil {
+		return c.resp.OnError(ctx, err, OpImport)
+	}
+	status := http.StatusOK
+	if !summary.complete() {
+		status = http.StatusMultiStatus
+	}
+	return writeResult(c.resp, ctx, status, APIResponse[ImportSummary]{Success: summary.complete(), Data: summary}, OpImport)
+}
+
+func (c *Controller[T]) importRows(ctx Context) (ImportSummary, error) {
+	format := importFormat(ctx)
+	if format != ExportFormatCSV && format != ExportFormatNDJSON {
+		return ImportSummary{}, &ValidationError{fmt.Errorf("unsupported import format %q", format)}
+	}
+	summary := ImportSummary{
+		Format: format,
+		DryRun: queryFlag(ctx, ImportDryRunQueryParam),
+		Upsert: queryFlag(ctx, ImportUpsertQueryParam),
+		Chunks: []ImportChunkResult{},
+	}
+	if summary.Upsert && !c.supportsIdentifierLookup() {
+		return summary, &ValidationError{fmt.Errorf("upsert requires GetIdentifier or GetIdentifierValue model handlers")}
+	}
+
+	meta, policy, err := c.prepareWriteOp(ctx, OpImport)
+	if err != nil {
+		return summary, err
+	}
+
+	cfg := c.importConfig.normalized()
+	reader := newImportRowReader(format, importBody(ctx), parseImportHeaderMap(ctx.Query(ImportHeaderMapQueryParam), cfg.HeaderMap))
+	fieldTypes := importFieldTypes(c.resourceType)
+	lookupCriteria := c.applyFieldPolicyCriteria(c.applyScopeCriteria(nil, meta.scope), policy)
+
+	rowNumber := 0
+	for done := false; !done; {
+		chunk := ImportChunkResult{Index: len(summary.Chunks), FirstRow: rowNumber + 1}
+		var pending []importRow[T]
+		for chunk.Rows < cfg.ChunkSize {
+			row, err := reader.Next()
+			if errors.Is(err, io.EOF) {
+				done = true
+				break
+			}
+			var decodeErr *importRowDecodeError
+			if err != nil && !errors.As(err, &decodeErr) {
+				return summary, err
+			}
+			rowNumber++
+			chunk.Rows++
+			if err == nil {
+				var record T
+				record, err = c.importRecord(row, fieldTypes, policy, meta.scope)
+				if err == nil {
+					pending = append(pending, importRow[T]{row: rowNumber, record: record})
+					continue
+				}
+			} else {
+				err = &ValidationError{decodeErr}
+			}
+			chunk.Failed++
+			summary.Errors = append(summary.Errors, c.importRowError(ctx, rowNumber, err))
+		}
+		if chunk.Rows == 0 {
+			break
+		}
+
+		if summary.DryRun {
+			c.dryRunImportChunk(ctx, meta, policy, lookupCriteria, pending, &chunk, &summary)
+		} else {
+			c.writeImportChunk(ctx, meta, policy, lookupCriteria, pending, &chunk, &summary)
+		}
+		summary.addChunk(chunk)
+	}
+	// Decode errors are reported while reading, write errors per chunk.
+	slices.SortStableFunc(summary.Errors, func(a, b ImportRowError) int { return cmp.Compare(a.Row, b.Row) })
+	return summary, nil
+}
+
+// importRecord decodes row into a new record and enforces the create writable
+// fields of policy and scope on it; rows matching a stored record are checked
+// against the update rules once resolved. The validator runs once, in dry runs
+// or in the write service.
+func (c *Controller[T]) importRecord(row map[string]any, fieldTypes map[string]reflect.Type, policy resolvedFieldPolicy, scope ScopeFilter) (T, error) {
+	var zero T
+	values := make(map[string]any, len(row))
+	for field, value := range row {
+		typ, ok := fieldTypes[field]
+		if !ok {
+			continue
+		}
+		values[field] = importValue(value, typ)
+	}
+	raw, err := json.Marshal(values)
+	if err != nil {
+		return zero, &ValidationError{err}
+	}
+	record := c.Repo.Handlers().NewRecord()
+	if err := json.Unmarshal(raw, record); err != nil {
+		return zero, &ValidationError{err}
+	}
+	if record, err = enforceCreateWrite(policy, scope, OpImport, record); err != nil {
+		return zero, err
+	}
+	return record, nil
+}
+
+// enforceImportWrite applies the writable fields of policy and scope to a
+// resolved import row: rows matching a stored record follow the update rules,
+// new rows the create rules.
+func enforceImportWrite[T any](policy resolvedFieldPolicy, scope ScopeFilter, record, stored T, existing bool) (T, error) {
+	if existing {
+		return enforceUpdateWrite(policy, scope, OpUpdate, record, stored)
+	}
+	return enforceCreateWrite(policy, scope, OpImport, record)
+}
+
+// dryRunImportChunk resolves upsert matches and runs the validator and the
+// before hooks without writing.
+func (c *Controller[T]) dryRunImportChunk(ctx Context, meta guardRequestContext, policy resolvedFieldPolicy, criteria []repository.SelectCriteria, pending []importRow[T], chunk *ImportChunkResult, summary *ImportSummary) {
+	for _, item := range pending {
+		op, hooks := OpCreate, c.hooks.BeforeCreate
+		record, stored, existing, err := c.resolveImportRecord(ctx, criteria, item.record, summary.Upsert)
+		if err == nil && existing {
+			op, hooks = OpUpdate, c.hooks.BeforeUpdate
+			err = c.authorizeRecord(ctx, OpImport, stored)
+		}
+		if err == nil {
+			record, err = enforceImportWrite(policy, meta.scope, record, stored, existing)
+		}
+		if err == nil && c.validator != nil {
+			err = c.validator(ctx, record)
+		}
+		if err == nil {
+			err = c.runHooks(ctx, op, hooks, record, meta)
+		}
+		switch {
+		case err != nil:
+			chunk.Failed++
+			summary.Errors = append(summary.Errors, c.importRowError(ctx, item.row, err))
+		case existing:
+			chunk.Updated++
+		default:
+			chunk.Created++
+		}
+	}
+}
+
+// writeImportChunk writes pending in one transaction. The first failing row
+// is reported and rolls the chunk back; the other pending rows count as
+// rolled back.
+func (c *Controller[T]) writeImportChunk(ctx Context, meta guardRequestContext, policy resolvedFieldPolicy, criteria []repository.SelectCriteria, pending []importRow[T], chunk *ImportChunkResult, summary *ImportSummary) {
+	if len(pending) == 0 {
+		return
+	}
+	svc := c.resolvedWriteService()
+	written := make([]T, 0, len(pending))
+	created, updated := 0, 0
+	failedRow := 0
+	err := c.runInTx(ctx, func() error {
+		for _, item := range pending {
+			failedRow = item.row
+			record, stored, existing, err := c.resolveImportRecord(ctx, criteria, item.record, summary.Upsert)
+			if err != nil {
+				return err
+			}
+			if existing {
+				if err := c.authorizeRecord(ctx, OpImport, stored); err != nil {
+					return err
+				}
+			}
+			if record, err = enforceImportWrite(policy, meta.scope, record, stored, existing); err != nil {
+				return err
+			}
+			if existing {
+				record, err = svc.Update(ctx, record)
+				updated++
+			} else {
+				record, err = svc.Create(ctx, record)
+				created++
+			}
+			if err != nil {
+				return err
+			}
+			written = append(written, record)
+		}
+		return nil
+	})
+	if err != nil {
+		chunk.Failed++
+		chunk.RolledBack += len(pending) - 1
+		summary.Errors = append(summary.Errors, c.importRowError(ctx, failedRow, err))
+		c.emitActivityEvents(ctx, OpImport, meta, nil, err)
+		return
+	}
+	chunk.Created, chunk.Updated, chunk.Committed = created, updated, true
+	c.emitActivityEvents(ctx, OpImport, meta, written, nil)
+}
+
+// resolveImportRecord merges record over the stored record sharing its
+// identifier when upsert is set, returning the stored record too.
+func (c *Controller[T]) resolveImportRecord(ctx Context, criteria []repository.SelectCriteria, record T, upsert bool) (T, T, bool, error) {
+	var stored T
+	if !upsert {
+		return record, stored, false, nil
+	}
+	identifier := c.importIdentifierValue(record)
+	if identifier == "" {
+		return record, stored, false, nil
+	}
+	var existing T
+	var err error
+	if tx, ok := TxFromContext(ctx.UserContext()); ok {
+		existing, err = c.Repo.GetByIdentifierTx(ctx.UserContext(), tx, identifier, criteria...)
+	} else {
+		existing, err = c.Repo.GetByIdentifier(ctx.UserContext(), identifier, criteria...)
+	}
+	if err != nil {
+		if repository.IsRecordNotFound(err) {
+			return record, stored, false, nil
+		}
+		return record, stored, false, err
+	}
+	merged, err := mergeRecordWithExisting(record, existing)
+	if err != nil {
+		return record, stored, false, err
+	}
+	copyPrimaryKeys(merged, existing)
+	return merged, existing, true, nil
+}
+
+// copyPrimaryKeys sets the primary key fields of dst from src; merging leaves
+// array keys such as uuid.UUID untouched.
+func copyPrimaryKeys(dst, src any) {
+	dv, ok := recordStructValue(dst)
+	if !ok || !dv.CanSet() {
+		return
+	}
+	sv, ok := recordStructValue(src)
+	if !ok {
+		return
+	}
+	for _, field := range primaryKeyFields(dv.Type()) {
+		target, err := dv.FieldByIndexErr(field.index)
+		if err != nil || !target.CanSet() {
+			continue
+		}
+		if value, err := sv.FieldByIndexErr(field.index); err == nil {
+			target.Set(value)
+		}
+	}
+}
+
+func (c *Controller[T]) supportsIdentifierLookup() bool {
+	handlers := c.Repo.Handlers()
+	return handlers.GetIdentifierValue != nil || handlers.GetIdentifier != nil
+}
+
+// importIdentifierValue reads the identifier of record through
+// GetIdentifierValue, or the field named by GetIdentifier.
+func (c *Controller[T]) importIdentifierValue(record T) string {
+	handlers := c.Repo.Handlers()
+	if handlers.GetIdentifierValue != nil {
+		return strings.TrimSpace(handlers.GetIdentifierValue(record))
+	}
+	if handlers.GetIdentifier == nil {
+		return ""
+	}
+	name := strings.TrimSpace(handlers.GetIdentifier())
+	value, ok := recordColumnValue(record, name)
+	if !ok {
+		value, ok = recordColumnValue(record, strcase.ToSnake(name))
+	}
+	if !ok || reflect.ValueOf(value).IsZero() {
+		return ""
+	}
+	return strings.TrimSpace(fmt.Sprint(value))
+}
+
+func importFormat(ctx Context) string {
+	if format := strings.ToLower(strings.TrimSpace(ctx.Query(ImportFormatQueryParam))); format != "" {
+		return format
+	}
+	contentType := strings.ToLower(requestHeader(ctx, "Content-Type"))
+	if strings.Contains(contentType, "ndjson") || strings.Contains(contentType, "jsonl") {
+		return ExportFormatNDJSON
+	}
+	return ExportFormatCSV
+}
+
+// importBody streams the request body when the adapter supports it.
+func importBody(ctx Context) io.Reader {
+	if streamer, ok := ctx.(requestBodyStreamer); ok {
+		if body := streamer.BodyStream(); body != nil {
+			return body
+		}
+	}
+	return bytes.NewReader(ctx.Body())
+}
+
+func (c *Controller[T]) importRowError(ctx Context, row int, err error) ImportRowError {
+	mapped, status := encodeErrorBody(ctx, c.resp.OnError, err, OpImport)
+	return ImportRowError{Row: row, Status: status, Error: mapped}
+}
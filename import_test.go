@@ -0,0 +1,161 @@
+package crud
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	goerrors "github.com/goliatone/go-errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func importRequest(t *testing.T, app *fiber.App, path, contentType, body string) (int, ImportSummary) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	resp, err := app.Test(req, -1)
+	require.NoError(t, err)
+	var payload APIResponse[ImportSummary]
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&payload))
+	return resp.StatusCode, payload.Data
+}
+
+func TestController_Import_CSVChunksAndHeaderMap(t *testing.T) {
+	app, db := setupApp(t, WithImportConfig[*TestUser](ImportConfig{
+		ChunkSize: 2,
+		HeaderMap: map[string]string{"E-mail": "email"},
+	}))
+	defer db.Close()
+
+	body := "Full Name,E-mail,age\n" +
+		"Ann,ann@example.com,20\n" +
+		"Bob,bob@example.com,30\n" +
+		"Cid,cid@example.com,40\n"
+	status, summary := importRequest(t, app, "/test-user/import?header_map=Full%20Name:name", "text/csv", body)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, 3, summary.Total)
+	assert.Equal(t, 3, summary.Created)
+	require.Len(t, summary.Chunks, 2)
+	assert.Equal(t, 2, summary.Chunks[0].Rows)
+	assert.Equal(t, 3, summary.Chunks[1].FirstRow)
+	assert.True(t, summary.Chunks[1].Committed)
+
+	var stored TestUser
+	require.NoError(t, db.NewSelect().Model(&stored).Where("email = ?", "cid@example.com").Scan(context.Background()))
+	assert.Equal(t, "Cid", stored.Name)
+	assert.Equal(t, 40, stored.Age)
+}
+
+func TestController_Import_DryRunReportsRowErrors(t *testing.T) {
+	validator := func(_ Context, user *TestUser) error {
+		if user.Email == "" {
+			return &ValidationError{errors.New("email is required")}
+		}
+		return nil
+	}
+	var hooked []string
+	app, db := setupApp(t,
+		WithValidator[*TestUser](validator),
+		WithLifecycleHooks(LifecycleHooks[*TestUser]{
+			BeforeCreate: []HookFunc[*TestUser]{
+				func(_ HookContext, user *TestUser) error {
+					hooked = append(hooked, user.Name)
+					return nil
+				},
+			},
+		}),
+	)
+	defer db.Close()
+
+	body := `{"name":"Ann","email":"ann@example.com"}
+{"name":"Bob"}
+not json
+`
+	status, summary := importRequest(t, app, "/test-user/import?dry_run=true", "application/x-ndjson", body)
+	require.Equal(t, http.StatusMultiStatus, status)
+	assert.True(t, summary.DryRun)
+	assert.Equal(t, 3, summary.Total)
+	assert.Equal(t, 1, summary.Created)
+	assert.Equal(t, 2, summary.Failed)
+	require.Len(t, summary.Errors, 2)
+	assert.Equal(t, 2, summary.Errors[0].Row)
+	assert.Equal(t, http.StatusUnprocessableEntity, summary.Errors[0].Status)
+	assert.Equal(t, 3, summary.Errors[1].Row)
+	assert.Equal(t, []string{"Ann"}, hooked)
+	assert.Zero(t, countTestUsers(t, db), "dry runs do not write")
+}
+
+func TestController_Import_UpsertByIdentifier(t *testing.T) {
+	app, db := setupApp(t)
+	defer db.Close()
+	insertTestUsers(t, db, &TestUser{Name: "Ann", Email: "ann@example.com", Age: 20})
+
+	body := "name,email,age\nAnnie,ann@example.com,21\nBob,bob@example.com,30\n"
+	status, summary := importRequest(t, app, "/test-user/import?upsert=true", "text/csv", body)
+	require.Equal(t, http.StatusOK, status, "%+v", summary.Errors)
+	assert.Equal(t, 1, summary.Updated)
+	assert.Equal(t, 1, summary.Created)
+	assert.Equal(t, 2, countTestUsers(t, db))
+
+	var stored TestUser
+	require.NoError(t, db.NewSelect().Model(&stored).Where("email = ?", "ann@example.com").Scan(context.Background()))
+	assert.Equal(t, "Annie", stored.Name)
+	assert.Equal(t, 21, stored.Age)
+}
+
+func TestController_Import_FailedChunkRollsBack(t *testing.T) {
+	app, db := setupApp(t, WithImportConfig[*TestUser](ImportConfig{ChunkSize: 2}))
+	defer db.Close()
+
+	body := "name,email\nAnn,ann@example.com\nDup,ann@example.com\nCid,cid@example.com\n"
+	status, summary := importRequest(t, app, "/test-user/import", "text/csv", body)
+	require.Equal(t, http.StatusMultiStatus, status)
+	require.Len(t, summary.Chunks, 2)
+	assert.False(t, summary.Chunks[0].Committed)
+	assert.Equal(t, 1, summary.Chunks[0].Failed)
+	assert.Equal(t, 1, summary.Chunks[0].RolledBack)
+	assert.Equal(t, 1, summary.Failed)
+	assert.Equal(t, 1, summary.RolledBack)
+	assert.True(t, summary.Chunks[1].Committed)
+	require.Len(t, summary.Errors, 1)
+	assert.Equal(t, 2, summary.Errors[0].Row)
+	assert.Equal(t, 1, countTestUsers(t, db))
+}
+
+func TestController_Import_ValidatesOnceAndUsesControllerResponses(t *testing.T) {
+	validated := 0
+	validator := func(_ Context, user *TestUser) error {
+		validated++
+		if user.Email == "" {
+			return &ValidationError{errors.New("email is required")}
+		}
+		return nil
+	}
+	handler := &resultRecordingHandler[*TestUser]{ResponseHandler: NewDefaultResponseHandler[*TestUser]()}
+	app, db := setupApp(t,
+		WithValidator[*TestUser](validator),
+		WithResponseHandler[*TestUser](handler),
+		WithErrorEncoder[*TestUser](ProblemJSONErrorEncoder(WithProblemJSONStatusResolver(func(*goerrors.Error, CrudOperation) int {
+			return http.StatusTeapot
+		}))),
+		WithImportConfig[*TestUser](ImportConfig{ChunkSize: 1}),
+	)
+	defer db.Close()
+
+	body := "name,email\nAnn,ann@example.com\nBob,\n"
+	status, summary := importRequest(t, app, "/test-user/import", "text/csv", body)
+	require.Equal(t, http.StatusMultiStatus, status)
+	assert.Equal(t, []int{http.StatusMultiStatus}, handler.statuses, "the summary goes through the response handler")
+	assert.Equal(t, 2, validated, "each row is validated once")
+	assert.Equal(t, 1, summary.Created)
+	require.Len(t, summary.Errors, 1)
+	assert.Equal(t, 2, summary.Errors[0].Row)
+	assert.Equal(t, http.StatusTeapot, summary.Errors[0].Status, "row errors follow the controller's error encoder")
+	assert.Equal(t, 1, countTestUsers(t, db))
+}
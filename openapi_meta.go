@@ -1,6 +1,14 @@
 package crud
 
-import "github.com/goliatone/go-router"
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	querybun "github.com/goliatone/go-crud/pkg/go-query-bun"
+	"github.com/goliatone/go-router"
+)
 
 var _ router.MetadataProvider = (*Controller[any])(nil)
 
@@ -20,9 +28,462 @@ func (c *Controller[T]) GetMetadata() router.ResourceMetadata {
 	copyMeta := *metadata
 	if len(copyMeta.Routes) > 0 {
 		copyMeta.Routes = append([]router.RouteDefinition{}, copyMeta.Routes...)
+		copyMeta.Routes = appendFilterOperatorParameters(copyMeta.Routes)
+		copyMeta.Routes = appendPatchRouteDefinition(copyMeta.Routes)
+		copyMeta.Routes = appendAggregateRouteDefinition(copyMeta.Routes)
+		copyMeta.Routes = appendExportRouteDefinition(copyMeta.Routes)
+		copyMeta.Routes = appendImportRouteDefinition(copyMeta.Routes)
+		copyMeta.Routes = appendUpsertRouteDefinitions(copyMeta.Routes)
+		copyMeta.Routes = appendFilterMutationRouteDefinitions(copyMeta.Routes)
+		if c.SupportsSoftDelete() {
+			copyMeta.Routes = appendSoftDeleteRouteDefinitions(copyMeta.Routes)
+		}
+		if c.idCodec != nil {
+			copyMeta.Routes = applyIDParameterSchema(copyMeta.Routes, c.idCodec.Schema())
+		}
 	}
 	if len(c.actionRouteDefs) > 0 {
 		copyMeta.Routes = append(copyMeta.Routes, c.actionRouteDefs...)
 	}
 	return copyMeta
 }
+
+// appendFilterOperatorParameters advertises the filter expression parameter
+// on the generated list route. OpenAPI has no parameters with templated
+// names, so the {field}__{operator} filters are described as a pattern in
+// the route description instead. Routes derived from the list route inherit
+// both.
+func appendFilterOperatorParameters(routes []router.RouteDefinition) []router.RouteDefinition {
+	for i, def := range routes {
+		if def.Method != "GET" || !strings.HasSuffix(def.Name, ":"+string(OpList)) {
+			continue
+		}
+		if !slices.ContainsFunc(def.Parameters, func(param router.Parameter) bool {
+			return param.Name == querybun.FilterExprParam
+		}) {
+			routes[i].Parameters = append(append([]router.Parameter{}, def.Parameters...), router.Parameter{
+				Name:        querybun.FilterExprParam,
+				In:          "query",
+				Description: "Filter expression with AND/OR/NOT grouping, or a JSON filter tree",
+				Schema:      map[string]any{"type": "string"},
+			})
+		}
+		routes[i].Description = strings.TrimSpace(def.Description + "\n\n" + filterOperatorPattern())
+	}
+	return routes
+}
+
+// filterOperatorPattern describes the {field}__{operator} query parameters.
+func filterOperatorPattern() string {
+	operators := FilterOperators()
+	names := make([]string, len(operators))
+	for i, op := range operators {
+		names[i] = "`" + op.Name + "`"
+	}
+	return "Any field can be filtered with `{field}__{operator}={value}` query parameters, " +
+		"for example `age__gte=21`. Operators: " + strings.Join(names, ", ") +
+		". The resource schema lists them with their arity under `x-filter-operators`."
+}
+
+// annotateFilterOperatorsInSchema lists the filter operators and their arity
+// under `x-filter-operators` so UIs can build filter widgets.
+func annotateFilterOperatorsInSchema(doc map[string]any, schemaName string) {
+	if len(doc) == 0 || schemaName == "" {
+		return
+	}
+	if _, schema := ensureSchemaProperties(doc, schemaName); schema != nil {
+		schema["x-filter-operators"] = FilterOperators()
+	}
+}
+
+// appendPatchRouteDefinition derives the PATCH /resource/:id definition from
+// the generated update route, advertising the patch media types.
+func appendPatchRouteDefinition(routes []router.RouteDefinition) []router.RouteDefinition {
+	for _, def := range routes {
+		if def.Method != "PUT" || !strings.HasSuffix(def.Name, ":"+string(OpUpdate)) {
+			continue
+		}
+		resource := strings.TrimSuffix(def.Name, ":"+string(OpUpdate))
+		patch := router.RouteDefinition{
+			Method:      "PATCH",
+			Path:        def.Path,
+			Name:        fmt.Sprintf("%s:%s", resource, OpPatch),
+			Summary:     strings.Replace(def.Summary, "Update", "Patch", 1),
+			Description: "Applies a JSON Merge Patch (RFC 7396) or JSON Patch (RFC 6902) document to an existing record",
+			Tags:        append([]string{}, def.Tags...),
+			Parameters:  append([]router.Parameter{}, def.Parameters...),
+			Responses:   append([]router.Response{}, def.Responses...),
+			RequestBody: &router.RequestBody{
+				Description: "Patch document",
+				Required:    true,
+				Content: map[string]any{
+					MergePatchMediaType: map[string]any{
+						"schema": map[string]any{"type": "object"},
+					},
+					JSONPatchMediaType: map[string]any{
+						"schema": map[string]any{
+							"type": "array",
+							"items": map[string]any{
+								"type":     "object",
+								"required": []string{"op", "path"},
+								"properties": map[string]any{
+									"op": map[string]any{
+										"type": "string",
+										"enum": []string{"add", "remove", "replace", "move", "copy", "test"},
+									},
+									"path":  map[string]any{"type": "string"},
+									"from":  map[string]any{"type": "string"},
+									"value": map[string]any{},
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+		return append(routes, patch)
+	}
+	return routes
+}
+
+// appendAggregateRouteDefinition derives GET /resources/aggregate from the
+// list route, keeping its filter parameters.
+func appendAggregateRouteDefinition(routes []router.RouteDefinition) []router.RouteDefinition {
+	for _, def := range routes {
+		if def.Method != "GET" || !strings.HasSuffix(def.Name, ":"+string(OpList)) {
+			continue
+		}
+		resource := strings.TrimSuffix(def.Name, ":"+string(OpList))
+		params := make([]router.Parameter, 0, len(def.Parameters)+len(aggregateQueryParams))
+		for _, param := range aggregateQueryParams {
+			params = append(params, router.Parameter{
+				Name:        param,
+				In:          "query",
+				Description: "Comma separated field names",
+				Schema:      map[string]any{"type": "string"},
+			})
+		}
+		for _, param := range def.Parameters {
+			if param.Ref == "" {
+				params = append(params, param)
+			}
+		}
+		aggregate := router.RouteDefinition{
+			Method:      "GET",
+			Path:        def.Path + "/aggregate",
+			Name:        fmt.Sprintf("%s:%s", resource, OpAggregate),
+			Summary:     strings.Replace(def.Summary, "List", "Aggregate", 1),
+			Description: "Computes count, sum, avg, min and max, optionally grouped, over the filtered rows",
+			Tags:        append([]string{}, def.Tags...),
+			Parameters:  params,
+			Responses: []router.Response{
+				{
+					Code:        200,
+					Description: "Aggregate result",
+					Content: map[string]any{
+						"application/json": map[string]any{
+							"schema": map[string]any{
+								"type": "object",
+								"properties": map[string]any{
+									"group_by": map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+									"groups": map[string]any{
+										"type": "array",
+										"items": map[string]any{
+											"type": "object",
+											"properties": map[string]any{
+												"key":   map[string]any{"type": "object"},
+												"count": map[string]any{"type": "integer"},
+												"sum":   map[string]any{"type": "object"},
+												"avg":   map[string]any{"type": "object"},
+												"min":   map[string]any{"type": "object"},
+												"max":   map[string]any{"type": "object"},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+		return append(routes, aggregate)
+	}
+	return routes
+}
+
+// appendExportRouteDefinition derives GET /resources/export from the list
+// route, keeping its filter parameters.
+func appendExportRouteDefinition(routes []router.RouteDefinition) []router.RouteDefinition {
+	for _, def := range routes {
+		if def.Method != "GET" || !strings.HasSuffix(def.Name, ":"+string(OpList)) {
+			continue
+		}
+		resource := strings.TrimSuffix(def.Name, ":"+string(OpList))
+		params := []router.Parameter{{
+			Name:        ExportFormatQueryParam,
+			In:          "query",
+			Description: "Export format",
+			Schema: map[string]any{
+				"type":    "string",
+				"enum":    []string{ExportFormatCSV, ExportFormatNDJSON, ExportFormatXLSX},
+				"default": ExportFormatCSV,
+			},
+		}}
+		for _, param := range def.Parameters {
+			if param.Ref == "" {
+				params = append(params, param)
+			}
+		}
+		content := map[string]any{}
+		for _, contentType := range exportContentTypes {
+			content[contentType] = map[string]any{
+				"schema": map[string]any{"type": "string", "format": "binary"},
+			}
+		}
+		export := router.RouteDefinition{
+			Method:      "GET",
+			Path:        def.Path + "/export",
+			Name:        fmt.Sprintf("%s:%s", resource, OpExport),
+			Summary:     strings.Replace(def.Summary, "List", "Export", 1),
+			Description: "Streams the filtered rows as CSV, NDJSON or XLSX",
+			Tags:        append([]string{}, def.Tags...),
+			Parameters:  params,
+			Responses: []router.Response{
+				{
+					Code:        200,
+					Description: "Export file",
+					Content:     content,
+				},
+			},
+		}
+		return append(routes, export)
+	}
+	return routes
+}
+
+// appendImportRouteDefinition derives POST /resource/import from the create
+// route.
+func appendImportRouteDefinition(routes []router.RouteDefinition) []router.RouteDefinition {
+	for _, def := range routes {
+		if def.Method != "POST" || !strings.HasSuffix(def.Name, ":"+string(OpCreate)) {
+			continue
+		}
+		resource := strings.TrimSuffix(def.Name, ":"+string(OpCreate))
+		flag := func(name, description string) router.Parameter {
+			return router.Parameter{
+				Name:        name,
+				In:          "query",
+				Description: description,
+				Schema:      map[string]any{"type": "boolean"},
+			}
+		}
+		upload := map[string]any{"schema": map[string]any{"type": "string", "format": "binary"}}
+		imp := router.RouteDefinition{
+			Method:      "POST",
+			Path:        def.Path + "/import",
+			Name:        fmt.Sprintf("%s:%s", resource, OpImport),
+			Summary:     strings.Replace(def.Summary, "Create", "Import", 1),
+			Description: "Creates or upserts records from a CSV or NDJSON upload, one transaction per chunk",
+			Tags:        append([]string{}, def.Tags...),
+			Parameters: []router.Parameter{
+				{
+					Name:        ImportFormatQueryParam,
+					In:          "query",
+					Description: "Upload format, defaults to the Content-Type",
+					Schema:      map[string]any{"type": "string", "enum": []string{ExportFormatCSV, ExportFormatNDJSON}},
+				},
+				flag(ImportDryRunQueryParam, "Validate rows without writing"),
+				flag(ImportUpsertQueryParam, "Update records matching the resource identifier"),
+				{
+					Name:        ImportHeaderMapQueryParam,
+					In:          "query",
+					Description: "Comma separated source:field column mappings",
+					Schema:      map[string]any{"type": "string"},
+				},
+			},
+			RequestBody: &router.RequestBody{
+				Description: "CSV or NDJSON rows",
+				Required:    true,
+				Content: map[string]any{
+					"text/csv":             upload,
+					"application/x-ndjson": upload,
+				},
+			},
+			Responses: []router.Response{
+				{Code: 200, Description: "Import summary"},
+				{Code: 207, Description: "Import summary with row errors"},
+			},
+		}
+		return append(routes, imp)
+	}
+	return routes
+}
+
+// appendUpsertRouteDefinitions derives PUT /resource and PUT
+// /resource/batch/upsert from the generated create routes.
+func appendUpsertRouteDefinitions(routes []router.RouteDefinition) []router.RouteDefinition {
+	var upserts []router.RouteDefinition
+	for _, def := range routes {
+		if def.Method != "POST" {
+			continue
+		}
+		switch {
+		case strings.HasSuffix(def.Name, ":"+string(OpCreate)):
+			resource := strings.TrimSuffix(def.Name, ":"+string(OpCreate))
+			upsert := def
+			upsert.Method = "PUT"
+			upsert.Name = fmt.Sprintf("%s:%s", resource, OpUpsert)
+			upsert.Summary = strings.Replace(def.Summary, "Create", "Upsert", 1)
+			upsert.Description = "Inserts a record or updates the one matching its conflict columns"
+			upsert.Tags = append([]string{}, def.Tags...)
+			upsert.Parameters = append([]router.Parameter{}, def.Parameters...)
+			upsert.Responses = append([]router.Response{}, def.Responses...)
+			for i := range upsert.Responses {
+				if upsert.Responses[i].Code == 201 {
+					upsert.Responses[i].Code = 200
+				}
+			}
+			upserts = append(upserts, upsert)
+		case strings.HasSuffix(def.Name, ":"+string(OpCreateBatch)):
+			resource := strings.TrimSuffix(def.Name, ":"+string(OpCreateBatch))
+			upsert := def
+			upsert.Method = "PUT"
+			upsert.Path = def.Path + "/upsert"
+			upsert.Name = fmt.Sprintf("%s:%s", resource, OpUpsertBatch)
+			upsert.Summary = strings.Replace(def.Summary, "Create", "Upsert", 1)
+			upsert.Description = "Inserts or updates records matching their conflict columns"
+			upsert.Tags = append([]string{}, def.Tags...)
+			upsert.Parameters = append([]router.Parameter{}, def.Parameters...)
+			upsert.Responses = append([]router.Response{}, def.Responses...)
+			upserts = append(upserts, upsert)
+		}
+	}
+	return append(routes, upserts...)
+}
+
+// appendFilterMutationRouteDefinitions derives PATCH and DELETE /resources
+// from the list route, keeping its filter parameters.
+func appendFilterMutationRouteDefinitions(routes []router.RouteDefinition) []router.RouteDefinition {
+	for _, def := range routes {
+		if def.Method != "GET" || !strings.HasSuffix(def.Name, ":"+string(OpList)) {
+			continue
+		}
+		resource := strings.TrimSuffix(def.Name, ":"+string(OpList))
+		params := []router.Parameter{{
+			Name:        FilterMutationDryRunQueryParam,
+			In:          "query",
+			Description: "Report the number of matching rows without writing",
+			Schema:      map[string]any{"type": "boolean"},
+		}}
+		for _, param := range def.Parameters {
+			if param.Ref == "" {
+				params = append(params, param)
+			}
+		}
+		responses := []router.Response{
+			{
+				Code:        200,
+				Description: "Affected rows",
+				Content: map[string]any{
+					"application/json": map[string]any{
+						"schema": map[string]any{
+							"type": "object",
+							"properties": map[string]any{
+								"success": map[string]any{"type": "boolean"},
+								"data": map[string]any{
+									"type": "object",
+									"properties": map[string]any{
+										"affected": map[string]any{"type": "integer"},
+										"dry_run":  map[string]any{"type": "boolean"},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			{Code: 400, Description: "At least one filter is required"},
+		}
+		update := router.RouteDefinition{
+			Method:      "PATCH",
+			Path:        def.Path,
+			Name:        fmt.Sprintf("%s:%s", resource, OpUpdateByFilter),
+			Summary:     strings.Replace(def.Summary, "List", "Update matching", 1),
+			Description: "Assigns the body's fields to every record matching the filters",
+			Tags:        append([]string{}, def.Tags...),
+			Parameters:  params,
+			Responses:   responses,
+			RequestBody: &router.RequestBody{
+				Description: "Field assignments",
+				Required:    true,
+				Content: map[string]any{
+					"application/json": map[string]any{
+						"schema": map[string]any{"type": "object"},
+					},
+				},
+			},
+		}
+		remove := update
+		remove.Method = "DELETE"
+		remove.Name = fmt.Sprintf("%s:%s", resource, OpDeleteByFilter)
+		remove.Summary = strings.Replace(def.Summary, "List", "Delete matching", 1)
+		remove.Description = "Deletes every record matching the filters"
+		remove.Tags = append([]string{}, def.Tags...)
+		remove.RequestBody = nil
+		return append(routes, update, remove)
+	}
+	return routes
+}
+
+// appendSoftDeleteRouteDefinitions derives the restore and purge routes from
+// the generated delete route of a soft-deletable resource.
+func appendSoftDeleteRouteDefinitions(routes []router.RouteDefinition) []router.RouteDefinition {
+	for _, def := range routes {
+		if def.Method != "DELETE" || !strings.HasSuffix(def.Name, ":"+string(OpDelete)) {
+			continue
+		}
+		resource := strings.TrimSuffix(def.Name, ":"+string(OpDelete))
+		restore := router.RouteDefinition{
+			Method:      "POST",
+			Path:        def.Path + "/restore",
+			Name:        fmt.Sprintf("%s:%s", resource, OpRestore),
+			Summary:     strings.Replace(def.Summary, "Delete", "Restore", 1),
+			Description: "Restores a soft-deleted record",
+			Tags:        append([]string{}, def.Tags...),
+			Parameters:  append([]router.Parameter{}, def.Parameters...),
+		}
+		purge := router.RouteDefinition{
+			Method:      "DELETE",
+			Path:        def.Path + "/purge",
+			Name:        fmt.Sprintf("%s:%s", resource, OpPurge),
+			Summary:     strings.Replace(def.Summary, "Delete", "Purge", 1),
+			Description: "Permanently removes a record, including soft-deleted ones",
+			Tags:        append([]string{}, def.Tags...),
+			Parameters:  append([]router.Parameter{}, def.Parameters...),
+			Responses:   append([]router.Response{}, def.Responses...),
+		}
+		return append(routes, restore, purge)
+	}
+	return routes
+}
+
+// applyIDParameterSchema rewrites the ":id" path parameter schema so generated
+// documents reflect the configured IDCodec.
+func applyIDParameterSchema(routes []router.RouteDefinition, schema map[string]any) []router.RouteDefinition {
+	if len(schema) == 0 {
+		return routes
+	}
+	for i, def := range routes {
+		for j, param := range def.Parameters {
+			if param.In != "path" || param.Name != "id" {
+				continue
+			}
+			params := append([]router.Parameter{}, def.Parameters...)
+			params[j].Schema = maps.Clone(schema)
+			routes[i].Parameters = params
+			break
+		}
+	}
+	return routes
+}
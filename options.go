@@ -4,6 +4,7 @@ import (
 	"maps"
 	"net/http"
 	"reflect"
+	"slices"
 	"strings"
 
 	"github.com/goliatone/go-crud/pkg/activity"
@@ -29,10 +30,17 @@ type RouteOptions struct {
 	Method  string
 }
 
+// RouteConfig enables, disables or remaps the routes RegisterRoutes adds.
+// Routes are enabled by default, except OpUpdateByFilter, OpDeleteByFilter,
+// OpImport, OpPurge and OpPurgeBatch, which need Enabled set to true.
 type RouteConfig struct {
 	Operations map[CrudOperation]RouteOptions
 }
 
+// optInOperations lists the routes that bulk write or hard delete rows, so
+// existing controllers do not gain them on upgrade.
+var optInOperations = []CrudOperation{OpUpdateByFilter, OpDeleteByFilter, OpImport, OpPurge, OpPurgeBatch}
+
 func DefaultRouteConfig() RouteConfig {
 	return RouteConfig{}
 }
@@ -76,7 +84,7 @@ func cloneRouteOptionsMap(in map[CrudOperation]RouteOptions) map[CrudOperation]R
 
 func (rc RouteConfig) resolve(op CrudOperation, defaultMethod string) (bool, string) {
 	method := defaultMethod
-	enabled := true
+	enabled := !slices.Contains(optInOperations, op)
 
 	if len(method) == 0 {
 		method = http.MethodGet
@@ -242,6 +250,14 @@ func WithBatchRouteSegment[T any](segment string) Option[T] {
 	}
 }
 
+// WithIDCodec configures how primary keys are parsed, validated and formatted
+// (e.g. Int64Codec{}, ULIDCodec{}, NewCompositeCodec(...)). Defaults to UUIDCodec.
+func WithIDCodec[T any](codec IDCodec) Option[T] {
+	return func(c *Controller[T]) {
+		c.idCodec = codec
+	}
+}
+
 // WithBatchReturnOrderByID enables ordered batch returns for CreateBatch/UpdateBatch.
 func WithBatchReturnOrderByID[T any](enabled bool) Option[T] {
 	return func(c *Controller[T]) {
@@ -274,6 +290,12 @@ func mergeLifecycleHooks[T any](base, add LifecycleHooks[T]) LifecycleHooks[T] {
 	base.BeforeDeleteBatch = append(base.BeforeDeleteBatch, add.BeforeDeleteBatch...)
 	base.AfterDeleteBatch = append(base.AfterDeleteBatch, add.AfterDeleteBatch...)
 
+	base.BeforeRestore = append(base.BeforeRestore, add.BeforeRestore...)
+	base.AfterRestore = append(base.AfterRestore, add.AfterRestore...)
+
+	base.BeforeUpsert = append(base.BeforeUpsert, add.BeforeUpsert...)
+	base.AfterUpsert = append(base.AfterUpsert, add.AfterUpsert...)
+
 	return base
 }
 
@@ -301,6 +323,51 @@ func WithNotificationEmitter[T any](emitter NotificationEmitter) Option[T] {
 	}
 }
 
+// WithIdempotency caches the responses of Create, CreateBatch, Update, Patch
+// and mutating actions per Idempotency-Key header so client retries are served
+// from the store instead of running twice.
+func WithIdempotency[T any](store IdempotencyStore, cfg ...IdempotencyConfig) Option[T] {
+	return func(c *Controller[T]) {
+		var config IdempotencyConfig
+		if len(cfg) > 0 {
+			config = cfg[0]
+		}
+		c.idempotency = newIdempotencyPolicy(store, config)
+	}
+}
+
+// WithExportConfig sets the row limit and page size of the export route.
+func WithExportConfig[T any](cfg ExportConfig) Option[T] {
+	return func(c *Controller[T]) {
+		c.exportConfig = cfg
+	}
+}
+
+// WithImportConfig sets the chunk size and default header mapping of the
+// import route.
+func WithImportConfig[T any](cfg ImportConfig) Option[T] {
+	return func(c *Controller[T]) {
+		c.importConfig = cfg
+	}
+}
+
+// WithValidator runs validator before records are created or updated,
+// including rows of an import dry run.
+func WithValidator[T any](validator ValidatorFunc[T]) Option[T] {
+	return func(c *Controller[T]) {
+		c.validator = validator
+	}
+}
+
+// WithUpsertConflictColumns sets the ON CONFLICT target of the upsert routes.
+// Columns may be given as column or Go field names; by default the repository
+// identifier (ModelHandlers.GetIdentifier) is used.
+func WithUpsertConflictColumns[T any](columns ...string) Option[T] {
+	return func(c *Controller[T]) {
+		c.upsertConflictColumns = append([]string(nil), columns...)
+	}
+}
+
 func WithActions[T any](actions ...Action[T]) Option[T] {
 	return func(c *Controller[T]) {
 		if len(actions) == 0 {
@@ -356,6 +423,39 @@ func WithFieldPolicyProvider[T any](provider FieldPolicyProvider[T]) Option[T] {
 	}
 }
 
+// WithAuthorizer consults authorizer on every record Show, Update, Patch,
+// Delete, Restore and Purge load (including batches), on the stored rows
+// upserts update, and on each page of Index and Export. Denied single records
+// fail with ForbiddenError; denied list rows are dropped unless cfg selects
+// DeniedRowsRedact. Update and delete by filter are rejected with
+// ForbiddenError, since they write rows without loading them.
+func WithAuthorizer[T any](authorizer Authorizer[T], cfg ...AuthorizerConfig) Option[T] {
+	return func(c *Controller[T]) {
+		if authorizer == nil {
+			c.authorizer = nil
+			return
+		}
+		var config AuthorizerConfig
+		if len(cfg) > 0 {
+			config = cfg[0]
+		}
+		c.authorizer = &recordAuthorizer[T]{authorizer: authorizer, deniedRows: config.DeniedRows}
+	}
+}
+
+// WithTenancy scopes every operation to the request tenant. See TenancyConfig
+// for how the tenant and its column are resolved. It composes with
+// WithScopeGuard; actors with the BypassTenancy capability are not scoped.
+func WithTenancy[T any](cfg ...TenancyConfig) Option[T] {
+	return func(c *Controller[T]) {
+		var config TenancyConfig
+		if len(cfg) > 0 {
+			config = cfg[0]
+		}
+		c.tenancy = &config
+	}
+}
+
 // DefaultDeserializer provides a generic deserializer.
 func DefaultDeserializer[T any](op CrudOperation, ctx Context) (T, error) {
 	var record T
@@ -1,5 +1,18 @@
 package crud
 
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ettle/strcase"
+	querybun "github.com/goliatone/go-crud/pkg/go-query-bun"
+)
+
 func normalizePagination(filters *Filters, count int) bool {
 	if filters == nil {
 		return false
@@ -41,3 +54,157 @@ func normalizePagination(filters *Filters, count int) bool {
 
 	return adjusted
 }
+
+// ErrCursorSigningKeyRequired is returned when keyset pagination is requested
+// before a cursor signing key is configured.
+var ErrCursorSigningKeyRequired = errors.New("crud: keyset pagination requires a cursor signing key; call SetCursorSigningKey or pass WithCursorSigningKey")
+
+var cursorSigningKey atomic.Pointer[[]byte]
+
+// SetCursorSigningKey sets the global key used to sign keyset pagination
+// cursors. There is no default: keyset requests fail with
+// ErrCursorSigningKeyRequired until a key is set here or passed with
+// WithCursorSigningKey. Every instance serving the same API must use the same
+// key, and cursors stay valid across restarts for as long as it is unchanged.
+func SetCursorSigningKey(key []byte) {
+	if len(key) == 0 {
+		return
+	}
+	stored := append([]byte{}, key...)
+	cursorSigningKey.Store(&stored)
+}
+
+func globalCursorSigningKey() []byte {
+	if key := cursorSigningKey.Load(); key != nil {
+		return *key
+	}
+	return nil
+}
+
+func (cfg queryBuilderConfig) resolvedCursorSigningKey() []byte {
+	if len(cfg.cursorSigningKey) > 0 {
+		return cfg.cursorSigningKey
+	}
+	return globalCursorSigningKey()
+}
+
+// ApplyCursorPage finalizes a keyset page built from cursor query options.
+// It restores the requested order for pages read backwards and sets
+// NextCursor/PrevCursor on filters. count is the total reported by the
+// list call, which in keyset mode counts rows from the cursor onward.
+// Offset-paginated filters are returned unchanged.
+func ApplyCursorPage[T any](filters *Filters, records []T, count int) ([]T, error) {
+	if filters == nil || filters.keyset == nil {
+		return records, nil
+	}
+	keyset := filters.keyset
+	if keyset.Direction == querybun.CursorPrev {
+		slices.Reverse(records)
+	}
+	filters.Count = count
+	filters.NextCursor = ""
+	filters.PrevCursor = ""
+	if len(records) == 0 {
+		return records, nil
+	}
+
+	hasMore := count > len(records)
+	hasNext, hasPrev := hasMore, keyset.HasCursor
+	if keyset.Direction == querybun.CursorPrev {
+		hasNext, hasPrev = true, hasMore
+	}
+
+	var err error
+	if hasNext {
+		if filters.NextCursor, err = encodeRecordCursor(querybun.CursorNext, keyset.Order, records[len(records)-1], filters.cursorKey); err != nil {
+			return nil, err
+		}
+	}
+	if hasPrev {
+		if filters.PrevCursor, err = encodeRecordCursor(querybun.CursorPrev, keyset.Order, records[0], filters.cursorKey); err != nil {
+			return nil, err
+		}
+	}
+	return records, nil
+}
+
+func encodeRecordCursor(direction querybun.CursorDirection, orders []querybun.Order, record any, key []byte) (string, error) {
+	values := make([]any, len(orders))
+	for i, order := range orders {
+		value, ok := recordColumnValue(record, order.Field)
+		if !ok {
+			return "", fmt.Errorf("cursor column %q not found on record", order.Field)
+		}
+		values[i] = value
+	}
+	if len(key) == 0 {
+		key = globalCursorSigningKey()
+	}
+	return querybun.EncodeCursor(querybun.Cursor{Direction: direction, Order: orders, Values: values}, key)
+}
+
+var columnIndexCache sync.Map // map[reflect.Type]map[string][]int
+
+// recordColumnValue reads the struct field mapped to a bun column.
+func recordColumnValue(record any, column string) (any, bool) {
+	rv, ok := recordStructValue(record)
+	if !ok {
+		return nil, false
+	}
+	if idx := strings.LastIndex(column, "."); idx >= 0 {
+		column = column[idx+1:]
+	}
+	index, ok := columnIndexes(rv.Type())[column]
+	if !ok {
+		return nil, false
+	}
+	field, err := rv.FieldByIndexErr(index)
+	if err != nil {
+		return nil, false
+	}
+	return field.Interface(), true
+}
+
+func columnIndexes(typ reflect.Type) map[string][]int {
+	if cached, ok := columnIndexCache.Load(typ); ok {
+		return cached.(map[string][]int)
+	}
+	indexes := make(map[string][]int)
+	collectColumnIndexes(typ, nil, indexes)
+	columnIndexCache.Store(typ, indexes)
+	return indexes
+}
+
+func collectColumnIndexes(typ reflect.Type, parent []int, out map[string][]int) {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		index := append(append([]int{}, parent...), i)
+		tag := field.Tag.Get(TAG_BUN)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && tag == "" {
+			collectColumnIndexes(field.Type, index, out)
+			continue
+		}
+		if !field.IsExported() || tag == "-" || strings.Contains(tag, "rel:") || strings.Contains(tag, "m2m:") {
+			continue
+		}
+		column := strings.TrimSpace(strings.Split(tag, ",")[0])
+		if column == "" {
+			column = strcase.ToSnake(field.Name)
+		}
+		if _, exists := out[column]; !exists {
+			out[column] = index
+		}
+	}
+}
+
+func keyColumnsForType(typ reflect.Type) []string {
+	fields := primaryKeyFields(typ)
+	if len(fields) == 0 {
+		return nil
+	}
+	columns := make([]string, len(fields))
+	for i, field := range fields {
+		columns[i] = field.column
+	}
+	return columns
+}
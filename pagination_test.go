@@ -0,0 +1,118 @@
+package crud
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// useCursorSigningKey sets a global cursor signing key for the test.
+func useCursorSigningKey(t *testing.T) {
+	t.Helper()
+	SetCursorSigningKey([]byte("pagination-test-key"))
+	t.Cleanup(func() { cursorSigningKey.Store(nil) })
+}
+
+func TestController_Index_KeysetPagination(t *testing.T) {
+	useCursorSigningKey(t)
+	app, db := setupApp(t)
+	defer db.Close()
+
+	ages := []int{30, 20, 30, 40, 10}
+	for i, age := range ages {
+		insertTestUsers(t, db, &TestUser{
+			ID:    uuid.New(),
+			Name:  fmt.Sprintf("Keyset %d", i),
+			Email: fmt.Sprintf("keyset-%d@example.com", i),
+			Age:   age,
+		})
+	}
+
+	fetch := func(cursor string) APIListResponse[TestUser] {
+		query := url.Values{"limit": {"2"}, "order": {"age asc"}, "cursor": {cursor}}
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/test-users?"+query.Encode(), nil), -1)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var out APIListResponse[TestUser]
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+		return out
+	}
+	agesOf := func(users []TestUser) []int {
+		out := make([]int, len(users))
+		for i, user := range users {
+			out[i] = user.Age
+		}
+		return out
+	}
+
+	first := fetch("")
+	assert.Equal(t, []int{10, 20}, agesOf(first.Data))
+	assert.NotEmpty(t, first.Meta.NextCursor)
+	assert.Empty(t, first.Meta.PrevCursor)
+	assert.Zero(t, first.Meta.Offset)
+
+	second := fetch(first.Meta.NextCursor)
+	assert.Equal(t, []int{30, 30}, agesOf(second.Data))
+	assert.NotEmpty(t, second.Meta.NextCursor)
+	assert.NotEmpty(t, second.Meta.PrevCursor)
+
+	last := fetch(second.Meta.NextCursor)
+	assert.Equal(t, []int{40}, agesOf(last.Data))
+	assert.Empty(t, last.Meta.NextCursor)
+
+	back := fetch(second.Meta.PrevCursor)
+	assert.Equal(t, []int{10, 20}, agesOf(back.Data))
+	assert.Empty(t, back.Meta.PrevCursor)
+	assert.Equal(t, second.Data[0].ID, fetch(back.Meta.NextCursor).Data[0].ID)
+}
+
+func TestController_Index_InvalidCursor(t *testing.T) {
+	useCursorSigningKey(t)
+	app, db := setupApp(t)
+	defer db.Close()
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/test-users?cursor=tampered.value", nil), -1)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestController_Index_KeysetRequiresSigningKey(t *testing.T) {
+	app, db := setupApp(t)
+	defer db.Close()
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/test-users?cursor=", nil), -1)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+
+	_, _, err = BuildListCriteriaFromOptions[*TestUser](ListQueryOptions{Keyset: true})
+	assert.ErrorIs(t, err, ErrCursorSigningKeyRequired)
+
+	_, _, err = BuildListCriteriaFromOptions[*TestUser](ListQueryOptions{Keyset: true}, WithCursorSigningKey([]byte("per-call")))
+	assert.NoError(t, err)
+}
+
+func TestBuildListCriteriaFromOptions_Keyset(t *testing.T) {
+	useCursorSigningKey(t)
+	_, filters, err := BuildListCriteriaFromOptions[*TestUser](ListQueryOptions{Keyset: true, Limit: 1, Order: "name desc"})
+	require.NoError(t, err)
+	require.NotNil(t, filters.keyset)
+	assert.Equal(t, []Order{{Field: "name", Dir: "DESC"}, {Field: "id", Dir: "ASC"}}, filters.Order)
+
+	users := []*TestUser{{ID: uuid.New(), Name: "Zed"}}
+	page, err := ApplyCursorPage(filters, users, 3)
+	require.NoError(t, err)
+	assert.Len(t, page, 1)
+	assert.NotEmpty(t, filters.NextCursor)
+
+	_, next, err := BuildListCriteriaFromOptions[*TestUser](ListQueryOptions{Cursor: filters.NextCursor, Limit: 1})
+	require.NoError(t, err)
+	assert.True(t, next.keyset.HasCursor)
+	assert.Equal(t, filters.Order, next.Order, "order is recovered from the cursor")
+}
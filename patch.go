@@ -0,0 +1,615 @@
+package crud
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const (
+	// MergePatchMediaType identifies RFC 7396 JSON Merge Patch documents.
+	MergePatchMediaType = "application/merge-patch+json"
+	// JSONPatchMediaType identifies RFC 6902 JSON Patch documents.
+	JSONPatchMediaType = "application/json-patch+json"
+)
+
+// JSONPatchOperation is a single RFC 6902 operation.
+type JSONPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// resolvePatchMediaType normalizes the request Content-Type. Plain JSON bodies
+// are treated as merge patches so clients without custom media types still work.
+func resolvePatchMediaType(contentType string) (string, error) {
+	contentType = strings.TrimSpace(contentType)
+	if contentType == "" {
+		return MergePatchMediaType, nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", fmt.Errorf("invalid patch content type %q: %w", contentType, err)
+	}
+	switch strings.ToLower(mediaType) {
+	case MergePatchMediaType, "application/json":
+		return MergePatchMediaType, nil
+	case JSONPatchMediaType:
+		return JSONPatchMediaType, nil
+	default:
+		return "", fmt.Errorf("unsupported patch content type %q", mediaType)
+	}
+}
+
+// patchStored backs Patch and PatchRecord: it loads the record identified by
+// id, applies document to it and persists the result through Update. Scope
+// guards, field policy providers and the Authorizer see OpUpdate, so rules
+// written for updates also cover patches; hooks and activity events report
+// OpPatch. The returned record has not had the field policy applied yet.
+func (c *Controller[T]) patchStored(ctx Context, id, mediaType string, document []byte) (T, resolvedFieldPolicy, error) {
+	var zero T
+	svc := c.resolvedWriteService()
+	meta, err := c.resolveGuardContext(ctx, OpUpdate)
+	if err != nil {
+		return zero, resolvedFieldPolicy{}, err
+	}
+
+	policy, err := c.resolveFieldPolicy(ctx, OpUpdate, meta)
+	if err != nil {
+		return zero, policy, err
+	}
+	c.logFieldPolicyDecision(policy)
+	c.attachHookContext(ctx, OpPatch)
+
+	fail := func(records []T, err error) (T, resolvedFieldPolicy, error) {
+		c.emitActivityEvents(ctx, OpPatch, meta, records, err)
+		return zero, policy, err
+	}
+
+	resolvedType, err := resolvePatchMediaType(mediaType)
+	if err != nil {
+		return fail(nil, &ValidationError{err})
+	}
+
+	criteria := c.applyScopeCriteria(nil, meta.scope)
+	criteria = c.applyFieldPolicyCriteria(criteria, policy)
+	existingRecord, err := svc.Show(ctx, strings.TrimSpace(id), criteria)
+	if err != nil {
+		return fail(nil, &NotFoundError{err})
+	}
+	if err := c.authorizeRecord(ctx, OpUpdate, existingRecord); err != nil {
+		return fail([]T{existingRecord}, err)
+	}
+	if err := c.resolvePrecondition(ctx, existingRecord, nil); err != nil {
+		return fail([]T{existingRecord}, err)
+	}
+
+	record, err := c.applyPatchToRecord(existingRecord, resolvedType, document)
+	if err != nil {
+		return fail([]T{existingRecord}, &ValidationError{err})
+	}
+	// The path identifies the record; patches cannot move it to another ID.
+	if err := copyRecordID(c.Repo.Handlers(), c.idCodec, record, existingRecord); err != nil {
+		return fail([]T{existingRecord}, err)
+	}
+	if record, err = enforceUpdateWrite(policy, meta.scope, OpPatch, record, existingRecord); err != nil {
+		return fail([]T{existingRecord}, err)
+	}
+
+	updatedRecord, err := svc.Update(ctx, record)
+	if err != nil {
+		return fail([]T{record}, err)
+	}
+
+	c.emitActivityEvents(ctx, OpPatch, meta, []T{updatedRecord}, nil)
+	return updatedRecord, policy, nil
+}
+
+// applyPatchToRecord renders existing as JSON, applies the patch document and
+// decodes the result into a copy of existing. Fields hidden from JSON keep
+// their stored values; JSON-visible fields take the patched document's values,
+// so removed members end up zeroed.
+func (c *Controller[T]) applyPatchToRecord(existing T, mediaType string, body []byte) (T, error) {
+	var zero T
+	if len(bytes.TrimSpace(body)) == 0 {
+		return zero, fmt.Errorf("patch document is empty")
+	}
+
+	raw, err := json.Marshal(existing)
+	if err != nil {
+		return zero, err
+	}
+	doc, err := decodeJSONDocument(raw)
+	if err != nil {
+		return zero, err
+	}
+
+	switch mediaType {
+	case JSONPatchMediaType:
+		var ops []JSONPatchOperation
+		if err := json.Unmarshal(body, &ops); err != nil {
+			return zero, fmt.Errorf("invalid json patch document: %w", err)
+		}
+		doc, err = applyJSONPatch(doc, ops)
+		if err != nil {
+			return zero, err
+		}
+	default:
+		patch, err := decodeJSONDocument(body)
+		if err != nil {
+			return zero, fmt.Errorf("invalid merge patch document: %w", err)
+		}
+		doc, err = applyMergePatchDocument(doc, patch, c.mergePatchStrategies(), c.mergePolicy.DeleteWithNull)
+		if err != nil {
+			return zero, err
+		}
+	}
+
+	if _, ok := doc.(map[string]any); !ok {
+		return zero, fmt.Errorf("patch result must be a JSON object")
+	}
+	patched, err := json.Marshal(doc)
+	if err != nil {
+		return zero, err
+	}
+
+	target, ok := cloneRecordForPatch(existing)
+	if !ok {
+		return zero, fmt.Errorf("patch requires a pointer to struct record")
+	}
+	if err := json.Unmarshal(patched, target); err != nil {
+		return zero, err
+	}
+	return target, nil
+}
+
+// mergePatchStrategies maps the JSON name of virtual source maps to the merge
+// strategy configured via struct tags or MergePolicy.FieldMergeStrategy.
+func (c *Controller[T]) mergePatchStrategies() map[string]string {
+	if len(c.virtualFieldDefs) == 0 {
+		return nil
+	}
+	typ := c.resourceType
+	for typ != nil && typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return nil
+	}
+
+	strategies := make(map[string]string)
+	for _, def := range c.virtualFieldDefs {
+		field, ok := typ.FieldByName(def.SourceField)
+		if !ok {
+			continue
+		}
+		name := encodedJSONName(field)
+		if name == "" {
+			continue
+		}
+		strategy := def.MergeStrategy
+		if strategy == "" && c.mergePolicy.FieldMergeStrategy != nil {
+			strategy = c.mergePolicy.FieldMergeStrategy[def.SourceField]
+		}
+		if strategy == "" {
+			strategy = "deep"
+		}
+		strategies[name] = strategy
+	}
+	return strategies
+}
+
+// encodedJSONName mirrors encoding/json naming, returning "" for skipped fields.
+func encodedJSONName(field reflect.StructField) string {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return ""
+	}
+	if name := strings.Split(tag, ",")[0]; name != "" {
+		return name
+	}
+	return field.Name
+}
+
+func decodeJSONDocument(raw []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// cloneRecordForPatch copies the struct behind record and resets every
+// JSON-visible field so decoding the patched document fully defines them.
+func cloneRecordForPatch[T any](record T) (T, bool) {
+	var zero T
+	rv := reflect.ValueOf(record)
+	if !rv.IsValid() || rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return zero, false
+	}
+	clone := reflect.New(rv.Elem().Type())
+	clone.Elem().Set(rv.Elem())
+	resetJSONVisibleFields(clone.Elem())
+	out, ok := clone.Interface().(T)
+	return out, ok
+}
+
+func resetJSONVisibleFields(val reflect.Value) {
+	typ := val.Type()
+	for i := 0; i < val.NumField(); i++ {
+		field := typ.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		if field.Anonymous && strings.Split(tag, ",")[0] == "" && field.Type.Kind() == reflect.Struct {
+			resetJSONVisibleFields(val.Field(i))
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if fv := val.Field(i); fv.CanSet() {
+			fv.Set(reflect.Zero(field.Type))
+		}
+	}
+}
+
+// applyMergePatchDocument applies an RFC 7396 merge patch. Top-level members
+// that back virtual fields follow their merge strategy: "replace" swaps the
+// whole object, "shallow" only merges first-level keys, "deep" follows the RFC.
+// Nested nulls inside those members honour deleteWithNull.
+func applyMergePatchDocument(target, patch any, strategies map[string]string, deleteWithNull bool) (any, error) {
+	patchObj, ok := patch.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("merge patch document must be a JSON object")
+	}
+	targetObj, ok := target.(map[string]any)
+	if !ok {
+		targetObj = map[string]any{}
+	}
+
+	for key, value := range patchObj {
+		if value == nil {
+			delete(targetObj, key)
+			continue
+		}
+		strategy, virtual := strategies[key]
+		if !virtual {
+			targetObj[key] = mergePatchValue(targetObj[key], value, true)
+			continue
+		}
+		switch strategy {
+		case "replace":
+			targetObj[key] = stripPatchNulls(value, deleteWithNull)
+		case "shallow":
+			targetObj[key] = shallowMergePatch(targetObj[key], value, deleteWithNull)
+		default:
+			targetObj[key] = mergePatchValue(targetObj[key], value, deleteWithNull)
+		}
+	}
+	return targetObj, nil
+}
+
+func mergePatchValue(target, patch any, deleteWithNull bool) any {
+	patchObj, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	targetObj, ok := target.(map[string]any)
+	if !ok {
+		targetObj = map[string]any{}
+	}
+	for key, value := range patchObj {
+		if value == nil {
+			if deleteWithNull {
+				delete(targetObj, key)
+			} else {
+				targetObj[key] = nil
+			}
+			continue
+		}
+		targetObj[key] = mergePatchValue(targetObj[key], value, deleteWithNull)
+	}
+	return targetObj
+}
+
+func shallowMergePatch(target, patch any, deleteWithNull bool) any {
+	patchObj, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	targetObj, ok := target.(map[string]any)
+	if !ok {
+		targetObj = map[string]any{}
+	}
+	for key, value := range patchObj {
+		if value == nil && deleteWithNull {
+			delete(targetObj, key)
+			continue
+		}
+		targetObj[key] = value
+	}
+	return targetObj
+}
+
+func stripPatchNulls(value any, deleteWithNull bool) any {
+	obj, ok := value.(map[string]any)
+	if !ok || !deleteWithNull {
+		return value
+	}
+	for key, item := range obj {
+		if item == nil {
+			delete(obj, key)
+		}
+	}
+	return obj
+}
+
+// applyJSONPatch applies RFC 6902 operations in order. Any failure aborts the
+// whole patch so the record is never partially modified.
+func applyJSONPatch(doc any, ops []JSONPatchOperation) (any, error) {
+	for i, op := range ops {
+		path, err := parseJSONPointer(op.Path)
+		if err != nil {
+			return nil, fmt.Errorf("json patch operation %d: %w", i, err)
+		}
+
+		switch op.Op {
+		case "add", "replace", "test":
+			if len(op.Value) == 0 {
+				return nil, fmt.Errorf("json patch operation %d: %q requires a value", i, op.Op)
+			}
+			var value any
+			if value, err = decodeJSONDocument(op.Value); err != nil {
+				return nil, fmt.Errorf("json patch operation %d: invalid value: %w", i, err)
+			}
+			switch op.Op {
+			case "add":
+				doc, err = jsonPointerAdd(doc, path, value)
+			case "replace":
+				if _, err = jsonPointerGet(doc, path); err == nil {
+					doc, err = jsonPointerSet(doc, path, value)
+				}
+			case "test":
+				var current any
+				if current, err = jsonPointerGet(doc, path); err == nil && !reflect.DeepEqual(current, value) {
+					err = fmt.Errorf("test failed for path %q", op.Path)
+				}
+			}
+		case "remove":
+			doc, err = jsonPointerRemove(doc, path)
+		case "move", "copy":
+			from, perr := parseJSONPointer(op.From)
+			if perr != nil {
+				return nil, fmt.Errorf("json patch operation %d: %w", i, perr)
+			}
+			if op.Op == "move" && isJSONPointerPrefix(from, path) && len(from) < len(path) {
+				return nil, fmt.Errorf("json patch operation %d: cannot move %q into one of its children", i, op.From)
+			}
+			var value any
+			if value, err = jsonPointerGet(doc, from); err != nil {
+				break
+			}
+			if op.Op == "move" {
+				if doc, err = jsonPointerRemove(doc, from); err != nil {
+					break
+				}
+			} else {
+				value = cloneJSONValue(value)
+			}
+			doc, err = jsonPointerAdd(doc, path, value)
+		default:
+			return nil, fmt.Errorf("json patch operation %d: unsupported op %q", i, op.Op)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("json patch operation %d (%s %s): %w", i, op.Op, op.Path, err)
+		}
+	}
+	return doc, nil
+}
+
+func parseJSONPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid json pointer %q", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		token = strings.ReplaceAll(token, "~1", "/")
+		tokens[i] = strings.ReplaceAll(token, "~0", "~")
+	}
+	return tokens, nil
+}
+
+func isJSONPointerPrefix(prefix, path []string) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+	for i := range prefix {
+		if prefix[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func jsonPointerGet(doc any, path []string) (any, error) {
+	current := doc
+	for _, token := range path {
+		switch node := current.(type) {
+		case map[string]any:
+			value, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("path member %q not found", token)
+			}
+			current = value
+		case []any:
+			idx, err := jsonArrayIndex(token, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			current = node[idx]
+		default:
+			return nil, fmt.Errorf("path member %q not found", token)
+		}
+	}
+	return current, nil
+}
+
+// jsonPointerUpdate walks to the parent of path and lets apply mutate the
+// container, writing rebuilt slices back up the tree.
+func jsonPointerUpdate(doc any, path []string, apply func(parent any, token string) (any, error)) (any, error) {
+	if len(path) == 1 {
+		return apply(doc, path[0])
+	}
+	token := path[0]
+	switch node := doc.(type) {
+	case map[string]any:
+		child, ok := node[token]
+		if !ok {
+			return nil, fmt.Errorf("path member %q not found", token)
+		}
+		updated, err := jsonPointerUpdate(child, path[1:], apply)
+		if err != nil {
+			return nil, err
+		}
+		node[token] = updated
+		return node, nil
+	case []any:
+		idx, err := jsonArrayIndex(token, len(node), false)
+		if err != nil {
+			return nil, err
+		}
+		updated, err := jsonPointerUpdate(node[idx], path[1:], apply)
+		if err != nil {
+			return nil, err
+		}
+		node[idx] = updated
+		return node, nil
+	default:
+		return nil, fmt.Errorf("path member %q not found", token)
+	}
+}
+
+func jsonPointerAdd(doc any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	return jsonPointerUpdate(doc, path, func(parent any, token string) (any, error) {
+		switch node := parent.(type) {
+		case map[string]any:
+			node[token] = value
+			return node, nil
+		case []any:
+			idx, err := jsonArrayIndex(token, len(node), true)
+			if err != nil {
+				return nil, err
+			}
+			node = append(node, nil)
+			copy(node[idx+1:], node[idx:])
+			node[idx] = value
+			return node, nil
+		default:
+			return nil, fmt.Errorf("cannot add member %q to a scalar value", token)
+		}
+	})
+}
+
+func jsonPointerSet(doc any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	return jsonPointerUpdate(doc, path, func(parent any, token string) (any, error) {
+		switch node := parent.(type) {
+		case map[string]any:
+			node[token] = value
+			return node, nil
+		case []any:
+			idx, err := jsonArrayIndex(token, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			node[idx] = value
+			return node, nil
+		default:
+			return nil, fmt.Errorf("path member %q not found", token)
+		}
+	})
+}
+
+func jsonPointerRemove(doc any, path []string) (any, error) {
+	if len(path) == 0 {
+		return nil, fmt.Errorf("cannot remove the document root")
+	}
+	return jsonPointerUpdate(doc, path, func(parent any, token string) (any, error) {
+		switch node := parent.(type) {
+		case map[string]any:
+			if _, ok := node[token]; !ok {
+				return nil, fmt.Errorf("path member %q not found", token)
+			}
+			delete(node, token)
+			return node, nil
+		case []any:
+			idx, err := jsonArrayIndex(token, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			return append(node[:idx], node[idx+1:]...), nil
+		default:
+			return nil, fmt.Errorf("path member %q not found", token)
+		}
+	})
+}
+
+func jsonArrayIndex(token string, length int, allowEnd bool) (int, error) {
+	if token == "-" {
+		if allowEnd {
+			return length, nil
+		}
+		return 0, fmt.Errorf("array index %q out of range", token)
+	}
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	idx, err := strconv.Atoi(token)
+	if err != nil || idx < 0 {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	limit := length - 1
+	if allowEnd {
+		limit = length
+	}
+	if idx > limit {
+		return 0, fmt.Errorf("array index %q out of range", token)
+	}
+	return idx, nil
+}
+
+func cloneJSONValue(value any) any {
+	switch node := value.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(node))
+		for key, item := range node {
+			out[key] = cloneJSONValue(item)
+		}
+		return out
+	case []any:
+		out := make([]any, len(node))
+		for i, item := range node {
+			out[i] = cloneJSONValue(item)
+		}
+		return out
+	default:
+		return value
+	}
+}
@@ -0,0 +1,188 @@
+package crud
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestController_PatchUser_MergePatch(t *testing.T) {
+	var beforeUpdateOp CrudOperation
+	var guardOps []CrudOperation
+	app, db := setupApp(t, WithLifecycleHooks(LifecycleHooks[*TestUser]{
+		BeforeUpdate: []HookFunc[*TestUser]{
+			func(hctx HookContext, _ *TestUser) error {
+				beforeUpdateOp = hctx.Metadata.Operation
+				return nil
+			},
+		},
+	}), WithScopeGuard[*TestUser](func(ctx Context, op CrudOperation) (ActorContext, ScopeFilter, error) {
+		guardOps = append(guardOps, op)
+		return ActorContext{}, ScopeFilter{}, nil
+	}))
+	defer db.Close()
+
+	user := &TestUser{
+		ID:       uuid.New(),
+		Name:     "Patch Me",
+		Email:    "merge-patch@example.com",
+		Age:      41,
+		Password: "secret",
+	}
+	insertTestUsers(t, db, user)
+
+	body := []byte(`{"name":"Patched","age":null}`)
+	req := httptest.NewRequest(http.MethodPatch, fmt.Sprintf("/test-user/%s", user.ID), bytes.NewReader(body))
+	req.Header.Set("Content-Type", MergePatchMediaType)
+
+	resp, err := app.Test(req, -1)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var response APIResponse[TestUser]
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+	assert.Equal(t, "Patched", response.Data.Name)
+	assert.Equal(t, 0, response.Data.Age)
+	assert.Equal(t, user.Email, response.Data.Email)
+	assert.Equal(t, user.ID, response.Data.ID)
+	assert.Equal(t, OpPatch, beforeUpdateOp)
+	assert.Contains(t, guardOps, OpUpdate, "guards see patches as updates")
+	assert.NotContains(t, guardOps, OpPatch)
+
+	stored, err := newTestUserRepository(db).GetByID(context.Background(), user.ID.String())
+	require.NoError(t, err)
+	assert.Equal(t, "Patched", stored.Name)
+	assert.Equal(t, 0, stored.Age)
+	assert.Equal(t, "secret", stored.Password, "fields hidden from JSON keep their stored value")
+}
+
+func TestController_PatchUser_JSONPatch(t *testing.T) {
+	app, db := setupApp(t)
+	defer db.Close()
+
+	user := &TestUser{
+		ID:    uuid.New(),
+		Name:  "Json Patch",
+		Email: "json-patch@example.com",
+		Age:   30,
+	}
+	insertTestUsers(t, db, user)
+
+	body := []byte(`[
+		{"op":"test","path":"/age","value":30},
+		{"op":"replace","path":"/age","value":31},
+		{"op":"copy","from":"/email","path":"/name"}
+	]`)
+	req := httptest.NewRequest(http.MethodPatch, fmt.Sprintf("/test-user/%s", user.ID), bytes.NewReader(body))
+	req.Header.Set("Content-Type", JSONPatchMediaType)
+
+	resp, err := app.Test(req, -1)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	stored, err := newTestUserRepository(db).GetByID(context.Background(), user.ID.String())
+	require.NoError(t, err)
+	assert.Equal(t, 31, stored.Age)
+	assert.Equal(t, user.Email, stored.Name)
+}
+
+func TestController_PatchUser_FailedTestLeavesRecordUntouched(t *testing.T) {
+	app, db := setupApp(t)
+	defer db.Close()
+
+	user := &TestUser{
+		ID:        uuid.New(),
+		Name:      "Guarded",
+		Email:     "patch-test-op@example.com",
+		Age:       20,
+		CreatedAt: time.Now().UTC(),
+	}
+	insertTestUsers(t, db, user)
+
+	body := []byte(`[{"op":"replace","path":"/name","value":"Changed"},{"op":"test","path":"/age","value":99}]`)
+	req := httptest.NewRequest(http.MethodPatch, fmt.Sprintf("/test-user/%s", user.ID), bytes.NewReader(body))
+	req.Header.Set("Content-Type", JSONPatchMediaType)
+
+	resp, err := app.Test(req, -1)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+
+	stored, err := newTestUserRepository(db).GetByID(context.Background(), user.ID.String())
+	require.NoError(t, err)
+	assert.Equal(t, "Guarded", stored.Name)
+}
+
+func TestController_PatchUser_UnsupportedMediaType(t *testing.T) {
+	app, db := setupApp(t)
+	defer db.Close()
+
+	user := &TestUser{ID: uuid.New(), Name: "Media", Email: "patch-media@example.com"}
+	insertTestUsers(t, db, user)
+
+	req := httptest.NewRequest(http.MethodPatch, fmt.Sprintf("/test-user/%s", user.ID), bytes.NewReader([]byte(`name=x`)))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := app.Test(req, -1)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+}
+
+func TestApplyMergePatchDocument_VirtualStrategies(t *testing.T) {
+	decode := func(raw string) any {
+		doc, err := decodeJSONDocument([]byte(raw))
+		require.NoError(t, err)
+		return doc
+	}
+	target := `{"name":"a","metadata":{"a":1,"nested":{"x":1,"y":2}},"extra":{"keep":true,"drop":1}}`
+	patch := `{"metadata":{"nested":{"y":null},"b":2},"extra":{"drop":null}}`
+
+	deep, err := applyMergePatchDocument(decode(target), decode(patch), map[string]string{"metadata": "deep"}, true)
+	require.NoError(t, err)
+	assert.Equal(t, decode(`{"name":"a","metadata":{"a":1,"b":2,"nested":{"x":1}},"extra":{"keep":true}}`), deep)
+
+	shallow, err := applyMergePatchDocument(decode(target), decode(patch), map[string]string{"metadata": "shallow"}, true)
+	require.NoError(t, err)
+	assert.Equal(t, decode(`{"name":"a","metadata":{"a":1,"b":2,"nested":{"y":null}},"extra":{"keep":true}}`), shallow)
+
+	replaced, err := applyMergePatchDocument(decode(target), decode(patch), map[string]string{"metadata": "replace"}, true)
+	require.NoError(t, err)
+	assert.Equal(t, decode(`{"name":"a","metadata":{"nested":{"y":null},"b":2},"extra":{"keep":true}}`), replaced)
+
+	keepNulls, err := applyMergePatchDocument(decode(target), decode(patch), map[string]string{"metadata": "deep"}, false)
+	require.NoError(t, err)
+	assert.Equal(t, decode(`{"name":"a","metadata":{"a":1,"b":2,"nested":{"x":1,"y":null}},"extra":{"keep":true}}`), keepNulls)
+}
+
+func TestApplyJSONPatch_Operations(t *testing.T) {
+	doc, err := decodeJSONDocument([]byte(`{"a":{"b":[1,2,3]},"c~d":"x"}`))
+	require.NoError(t, err)
+
+	ops := []JSONPatchOperation{
+		{Op: "add", Path: "/a/b/1", Value: json.RawMessage(`9`)},
+		{Op: "add", Path: "/a/b/-", Value: json.RawMessage(`4`)},
+		{Op: "remove", Path: "/a/b/0"},
+		{Op: "move", From: "/c~0d", Path: "/e"},
+		{Op: "test", Path: "/e", Value: json.RawMessage(`"x"`)},
+	}
+	out, err := applyJSONPatch(doc, ops)
+	require.NoError(t, err)
+
+	expected, err := decodeJSONDocument([]byte(`{"a":{"b":[9,2,3,4]},"e":"x"}`))
+	require.NoError(t, err)
+	assert.Equal(t, expected, out)
+
+	_, err = applyJSONPatch(expected, []JSONPatchOperation{{Op: "remove", Path: "/missing"}})
+	assert.Error(t, err)
+
+	_, err = applyJSONPatch(expected, []JSONPatchOperation{{Op: "move", From: "/a", Path: "/a/child"}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,185 @@
+package querybun
+
+import (
+	"database/sql/driver"
+	"encoding"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnumValuer is implemented by string field types that only accept a fixed
+// set of values. Filter values on such fields must be one of EnumValues.
+type EnumValuer interface {
+	EnumValues() []string
+}
+
+var (
+	timeType            = reflect.TypeFor[time.Time]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+	driverValuerType    = reflect.TypeFor[driver.Valuer]()
+)
+
+// dateOnlyLayouts are tried after RFC 3339 for time fields.
+var dateOnlyLayouts = []string{time.DateOnly, "2006-01-02T15:04:05", time.DateTime}
+
+// coerceFilterValues parses raw filter values into the Go type of field so
+// the database compares like with like. Pattern operators keep strings,
+// isnull/notnull parse their flag, and the JSON array operators use the
+// element type of slice fields. Fields without a known type keep their raw
+// strings.
+//
+// A value that does not parse fails with ValidationInvalidValue when
+// cfg.StrictValidation is set; otherwise the raw strings are used as before.
+func coerceFilterValues(field string, op Operator, values []string, cfg Config) ([]any, *ValidationError) {
+	raw := make([]any, len(values))
+	for i, value := range values {
+		raw[i] = value
+	}
+
+	typ := cfg.fieldType(field)
+	switch op.Canonical {
+	case "like", "ilike", "startswith", "endswith", "contains":
+		return raw, nil
+	case "isnull", "notnull":
+		typ = reflect.TypeFor[bool]()
+	case "arraycontains", "hasany":
+		typ = indirectFieldType(typ)
+		if typ == nil || (typ.Kind() != reflect.Slice && typ.Kind() != reflect.Array) || typ.Elem().Kind() == reflect.Uint8 {
+			return raw, nil
+		}
+		typ = typ.Elem()
+	}
+	typ = indirectFieldType(typ)
+	if typ == nil {
+		return raw, nil
+	}
+
+	now := time.Now
+	if cfg.Now != nil {
+		now = cfg.Now
+	}
+	out := make([]any, len(values))
+	for i, value := range values {
+		coerced, expected, ok := coerceFilterValue(typ, value, now)
+		if !ok {
+			if !cfg.StrictValidation {
+				return raw, nil
+			}
+			return nil, &ValidationError{
+				Code:     ValidationInvalidValue,
+				Field:    field,
+				Operator: op.Token,
+				Reason:   fmt.Sprintf("invalid value %q, expected %s", value, expected),
+			}
+		}
+		out[i] = coerced
+	}
+	return out, nil
+}
+
+// coerceFilterValue parses value as typ, returning a description of the
+// expected input when it does not parse.
+func coerceFilterValue(typ reflect.Type, value string, now func() time.Time) (any, string, bool) {
+	if enum, ok := reflect.New(typ).Interface().(EnumValuer); ok {
+		allowed := enum.EnumValues()
+		if !slices.Contains(allowed, value) {
+			return nil, "one of " + strings.Join(allowed, ", "), false
+		}
+		return value, "", true
+	}
+	if typ == timeType {
+		parsed, ok := parseFilterTime(value, now)
+		return parsed, "an RFC 3339 time, a date or a relative time such as now-7d", ok
+	}
+	if reflect.PointerTo(typ).Implements(textUnmarshalerType) {
+		target := reflect.New(typ)
+		if err := target.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value)); err != nil {
+			return nil, "a valid " + typ.String(), false
+		}
+		if typ.Implements(driverValuerType) {
+			return target.Elem().Interface(), "", true
+		}
+		return value, "", true
+	}
+
+	switch typ.Kind() {
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(value)
+		return parsed, "a boolean", err == nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parsed, err := strconv.ParseInt(value, 10, typ.Bits())
+		return parsed, "an integer", err == nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		parsed, err := strconv.ParseUint(value, 10, typ.Bits())
+		return parsed, "a non-negative integer", err == nil
+	case reflect.Float32, reflect.Float64:
+		parsed, err := strconv.ParseFloat(value, typ.Bits())
+		return parsed, "a number", err == nil
+	default:
+		return value, "", true
+	}
+}
+
+// parseFilterTime accepts RFC 3339 times, dates, and times relative to now
+// or today such as now-7d, now+2h or today-1w.
+func parseFilterTime(value string, now func() time.Time) (time.Time, bool) {
+	if parsed, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return parsed, true
+	}
+	for _, layout := range dateOnlyLayouts {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed, true
+		}
+	}
+
+	lower := strings.ToLower(value)
+	base := now()
+	switch {
+	case strings.HasPrefix(lower, "now"):
+		lower = lower[len("now"):]
+	case strings.HasPrefix(lower, "today"):
+		year, month, day := base.Date()
+		base = time.Date(year, month, day, 0, 0, 0, 0, base.Location())
+		lower = lower[len("today"):]
+	default:
+		return time.Time{}, false
+	}
+	if lower == "" {
+		return base, true
+	}
+	if len(lower) < 3 || (lower[0] != '+' && lower[0] != '-') {
+		return time.Time{}, false
+	}
+	amount, err := strconv.Atoi(lower[1 : len(lower)-1])
+	if err != nil || amount < 0 {
+		return time.Time{}, false
+	}
+	if lower[0] == '-' {
+		amount = -amount
+	}
+	switch lower[len(lower)-1] {
+	case 's':
+		return base.Add(time.Duration(amount) * time.Second), true
+	case 'm':
+		return base.Add(time.Duration(amount) * time.Minute), true
+	case 'h':
+		return base.Add(time.Duration(amount) * time.Hour), true
+	case 'd':
+		return base.AddDate(0, 0, amount), true
+	case 'w':
+		return base.AddDate(0, 0, 7*amount), true
+	default:
+		return time.Time{}, false
+	}
+}
+
+func indirectFieldType(typ reflect.Type) reflect.Type {
+	for typ != nil && typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	return typ
+}
@@ -0,0 +1,110 @@
+package querybun
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type filterStatus string
+
+func (filterStatus) EnumValues() []string {
+	return []string{"active", "inactive", "pending"}
+}
+
+func TestCoerceFilterValues(t *testing.T) {
+	now := time.Date(2024, 3, 10, 15, 30, 0, 0, time.UTC)
+	id := uuid.MustParse("6ba7b810-9dad-11d1-80b4-00c04fd430c8")
+	cfg := Config{
+		StrictValidation: true,
+		Now:              func() time.Time { return now },
+		FieldTypes: map[string]reflect.Type{
+			"age":        reflect.TypeFor[int](),
+			"score":      reflect.TypeFor[*float64](),
+			"active":     reflect.TypeFor[bool](),
+			"created_at": reflect.TypeFor[time.Time](),
+			"id":         reflect.TypeFor[uuid.UUID](),
+			"status":     reflect.TypeFor[filterStatus](),
+			"tags":       reflect.TypeFor[[]int](),
+		},
+	}
+	eq := Operator{Token: "eq", Canonical: "eq"}
+
+	cases := []struct {
+		field  string
+		op     Operator
+		values []string
+		want   []any
+	}{
+		{"age", eq, []string{"42"}, []any{int64(42)}},
+		{"score", eq, []string{"1.5"}, []any{1.5}},
+		{"active", eq, []string{"true"}, []any{true}},
+		{"created_at", eq, []string{"2024-01-02T03:04:05Z"}, []any{time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)}},
+		{"created_at", eq, []string{"2024-01-02"}, []any{time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)}},
+		{"created_at", eq, []string{"now-7d"}, []any{now.AddDate(0, 0, -7)}},
+		{"created_at", eq, []string{"today+2h"}, []any{time.Date(2024, 3, 10, 2, 0, 0, 0, time.UTC)}},
+		{"id", eq, []string{id.String()}, []any{id}},
+		{"status", eq, []string{"active"}, []any{"active"}},
+		{"tags", Operator{Canonical: "arraycontains"}, []string{"1", "2"}, []any{int64(1), int64(2)}},
+		{"age", Operator{Canonical: "contains"}, []string{"4"}, []any{"4"}},
+		{"age", Operator{Canonical: "isnull"}, []string{"false"}, []any{false}},
+		{"name", eq, []string{"Alice"}, []any{"Alice"}},
+	}
+	for _, tc := range cases {
+		got, err := coerceFilterValues(tc.field, tc.op, tc.values, cfg)
+		require.Nil(t, err, "%s %v", tc.field, tc.values)
+		assert.Equal(t, tc.want, got, "%s %v", tc.field, tc.values)
+	}
+
+	for field, value := range map[string]string{
+		"age":        "4x",
+		"active":     "maybe",
+		"created_at": "now-7y",
+		"id":         "not-a-uuid",
+		"status":     "archived",
+	} {
+		_, err := coerceFilterValues(field, eq, []string{value}, cfg)
+		require.NotNil(t, err, field)
+		assert.Equal(t, ValidationInvalidValue, err.Code, field)
+	}
+
+	cfg.StrictValidation = false
+	got, err := coerceFilterValues("age", eq, []string{"4x"}, cfg)
+	require.Nil(t, err)
+	assert.Equal(t, []any{"4x"}, got)
+}
+
+func TestBuildFilterCriteria_TypedValues(t *testing.T) {
+	db := setupQueryBunDB(t)
+	seedFilterUsers(t, db)
+
+	cfg := Config{
+		AllowedFields: filterAllowedFields(),
+		FieldTypes: map[string]reflect.Type{
+			"age":    reflect.TypeFor[int](),
+			"status": reflect.TypeFor[filterStatus](),
+		},
+	}
+	criteria, _, err := BuildFilterCriteriaFromPredicates([]Predicate{{Field: "age", Operator: "gt", Values: []string{"9"}}}, cfg)
+	require.NoError(t, err)
+	assert.Len(t, executeFilterUserCriteria(t, db, criteria), 3)
+
+	cfg.StrictValidation = true
+	_, unsupported, err := BuildFilterCriteriaFromPredicates([]Predicate{{Field: "status", Values: []string{"archived"}, RawKey: "status"}}, cfg)
+	var validationErr *ValidationError
+	require.True(t, errors.As(err, &validationErr))
+	assert.Equal(t, `invalid value for field "status": invalid value "archived", expected one of active, inactive, pending`, err.Error())
+	assert.Equal(t, []UnsupportedPredicate{{Field: "status", Operator: "eq", RawKey: "status", Reason: UnsupportedValueShape}}, unsupported)
+
+	expr, err := ParseFilterExpr("status = active AND age > 'old'")
+	require.NoError(t, err)
+	_, _, err = BuildFilterExprCriteria(expr, cfg)
+	require.True(t, errors.As(err, &validationErr))
+	assert.Equal(t, ValidationInvalidValue, validationErr.Code)
+	assert.Equal(t, 21, validationErr.Position)
+}
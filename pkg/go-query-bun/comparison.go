@@ -0,0 +1,190 @@
+package querybun
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+// OperatorInfo describes a canonical filter operator and the number of values
+// it takes, so clients can build filter widgets.
+type OperatorInfo struct {
+	Name        string `json:"name"`
+	MinValues   int    `json:"min_values"`
+	MaxValues   int    `json:"max_values,omitempty"` // zero means unbounded
+	Description string `json:"description"`
+}
+
+var filterOperators = []OperatorInfo{
+	{Name: "eq", MinValues: 1, MaxValues: 1, Description: "Equals the value"},
+	{Name: "ne", MinValues: 1, MaxValues: 1, Description: "Does not equal the value"},
+	{Name: "gt", MinValues: 1, MaxValues: 1, Description: "Greater than the value"},
+	{Name: "gte", MinValues: 1, MaxValues: 1, Description: "Greater than or equal to the value"},
+	{Name: "lt", MinValues: 1, MaxValues: 1, Description: "Less than the value"},
+	{Name: "lte", MinValues: 1, MaxValues: 1, Description: "Less than or equal to the value"},
+	{Name: "between", MinValues: 2, MaxValues: 2, Description: "Between two values, inclusive"},
+	{Name: "in", MinValues: 1, Description: "Equals any of the values"},
+	{Name: "nin", MinValues: 1, Description: "Equals none of the values"},
+	{Name: "isnull", MaxValues: 1, Description: "Is null; a false value tests for not null"},
+	{Name: "notnull", MaxValues: 1, Description: "Is not null; a false value tests for null"},
+	{Name: "like", MinValues: 1, MaxValues: 1, Description: "Matches a SQL LIKE pattern"},
+	{Name: "ilike", MinValues: 1, MaxValues: 1, Description: "Matches a SQL LIKE pattern, case insensitive"},
+	{Name: "startswith", MinValues: 1, MaxValues: 1, Description: "Starts with the value"},
+	{Name: "endswith", MinValues: 1, MaxValues: 1, Description: "Ends with the value"},
+	{Name: "contains", MinValues: 1, MaxValues: 1, Description: "Contains the value"},
+	{Name: "arraycontains", MinValues: 1, Description: "JSON array contains all of the values"},
+	{Name: "hasany", MinValues: 1, Description: "JSON array contains any of the values"},
+	{Name: "and", MinValues: 1, Description: "Equals every value (comma separated)"},
+	{Name: "or", MinValues: 1, Description: "Equals any value (comma separated)"},
+}
+
+// FilterOperators lists the canonical filter operators with their arity.
+func FilterOperators() []OperatorInfo {
+	return append([]OperatorInfo{}, filterOperators...)
+}
+
+func filterOperatorInfo(canonical string) (OperatorInfo, bool) {
+	for _, info := range filterOperators {
+		if info.Name == canonical {
+			return info, true
+		}
+	}
+	return OperatorInfo{}, false
+}
+
+// checkOperatorArity validates the number of values given to op. When repeat
+// is set, single-value operators accept several values, each applied as its
+// own ANDed condition, as flat field__operator filters always have.
+func checkOperatorArity(op Operator, field string, values []string, repeat bool) *ValidationError {
+	info, ok := filterOperatorInfo(op.Canonical)
+	if !ok {
+		return nil
+	}
+	maxValues := info.MaxValues
+	if repeat && maxValues == 1 && info.MinValues == 1 {
+		maxValues = 0
+	}
+	count := len(values)
+	if count >= info.MinValues && (maxValues == 0 || count <= maxValues) {
+		return nil
+	}
+
+	var want string
+	switch {
+	case info.MinValues == info.MaxValues:
+		want = fmt.Sprintf("exactly %d", info.MinValues)
+	case info.MaxValues == 0:
+		want = fmt.Sprintf("at least %d", info.MinValues)
+	default:
+		want = fmt.Sprintf("%d to %d", info.MinValues, info.MaxValues)
+	}
+	return &ValidationError{
+		Code:     ValidationInvalidArity,
+		Field:    field,
+		Operator: op.Token,
+		Reason:   fmt.Sprintf("takes %s value(s), got %d", want, count),
+	}
+}
+
+// ComparisonCondition renders the WHERE condition and arguments comparing
+// column with values under op. Null checks, pattern and JSON containment
+// operators produce dialect-specific SQL; the rest use op.SQL. Values must
+// already satisfy the operator arity, and single-value operators read only
+// the first value.
+//
+// Pattern operators escape %, _ and the escape character itself, so values
+// match literally. JSON containment targets jsonb columns on Postgres and
+// JSON text columns elsewhere.
+func ComparisonCondition(name dialect.Name, column string, op Operator, values []any) (string, []any) {
+	switch op.Canonical {
+	case "in":
+		return fmt.Sprintf("%s IN (?)", column), []any{bun.In(values)}
+	case "nin":
+		return fmt.Sprintf("%s NOT IN (?)", column), []any{bun.In(values)}
+	case "between":
+		return fmt.Sprintf("%s BETWEEN ? AND ?", column), []any{values[0], values[1]}
+	case "isnull", "notnull":
+		isNull := op.Canonical == "isnull"
+		if len(values) > 0 && !nullCheckFlag(values[0]) {
+			isNull = !isNull
+		}
+		if isNull {
+			return fmt.Sprintf("%s IS NULL", column), nil
+		}
+		return fmt.Sprintf("%s IS NOT NULL", column), nil
+	case "startswith", "endswith", "contains":
+		pattern := escapeLikePattern(fmt.Sprint(values[0]))
+		if op.Canonical != "startswith" {
+			pattern = "%" + pattern
+		}
+		if op.Canonical != "endswith" {
+			pattern += "%"
+		}
+		escape := `'\'`
+		if name == dialect.MySQL {
+			escape = `'\\'`
+		}
+		return fmt.Sprintf("%s LIKE ? ESCAPE %s", column, escape), []any{pattern}
+	case "arraycontains":
+		switch name {
+		case dialect.PG:
+			return fmt.Sprintf("%s @> ?::jsonb", column), []any{jsonArray(values)}
+		case dialect.MySQL:
+			return fmt.Sprintf("JSON_CONTAINS(%s, ?)", column), []any{jsonArray(values)}
+		default:
+			distinct := uniqueValues(values)
+			return fmt.Sprintf("(SELECT COUNT(DISTINCT value) FROM json_each(%s) WHERE value IN (?)) = %d", column, len(distinct)), []any{bun.In(distinct)}
+		}
+	case "hasany":
+		switch name {
+		case dialect.PG:
+			return fmt.Sprintf(`%s \?| ARRAY[?]`, column), []any{bun.In(values)}
+		case dialect.MySQL:
+			return fmt.Sprintf("JSON_OVERLAPS(%s, ?)", column), []any{jsonArray(values)}
+		default:
+			return fmt.Sprintf("EXISTS (SELECT 1 FROM json_each(%s) WHERE value IN (?))", column), []any{bun.In(values)}
+		}
+	default:
+		return fmt.Sprintf("%s %s ?", column, op.SQL), []any{values[0]}
+	}
+}
+
+func escapeLikePattern(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
+
+// nullCheckFlag reads the optional isnull/notnull value; anything but a
+// false boolean keeps the operator's meaning.
+func nullCheckFlag(value any) bool {
+	switch typed := value.(type) {
+	case bool:
+		return typed
+	case string:
+		flag, err := strconv.ParseBool(typed)
+		return err != nil || flag
+	default:
+		return true
+	}
+}
+
+func jsonArray(values []any) string {
+	encoded, _ := json.Marshal(values)
+	return string(encoded)
+}
+
+func uniqueValues(values []any) []any {
+	seen := make(map[any]struct{}, len(values))
+	out := make([]any, 0, len(values))
+	for _, value := range values {
+		if _, ok := seen[value]; ok {
+			continue
+		}
+		seen[value] = struct{}{}
+		out = append(out, value)
+	}
+	return out
+}
@@ -0,0 +1,171 @@
+package querybun
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+type operatorItem struct {
+	bun.BaseModel `bun:"table:operator_items,alias:i"`
+
+	ID    int     `bun:"id,pk"`
+	Name  string  `bun:"name"`
+	Score int     `bun:"score"`
+	Note  *string `bun:"note"`
+	Tags  string  `bun:"tags"`
+}
+
+func seedOperatorItems(t *testing.T, db *bun.DB) {
+	t.Helper()
+
+	ctx := context.Background()
+	require.NoError(t, db.ResetModel(ctx, (*operatorItem)(nil)))
+
+	note := "reviewed"
+	items := []operatorItem{
+		{ID: 1, Name: "100%_pure", Score: 10, Note: &note, Tags: `["go","sql"]`},
+		{ID: 2, Name: "100 pure", Score: 20, Tags: `["go"]`},
+		{ID: 3, Name: "pure_gold", Score: 30, Tags: `["rust"]`},
+	}
+	for _, item := range items {
+		_, err := db.NewInsert().Model(&item).Exec(ctx)
+		require.NoError(t, err)
+	}
+}
+
+func operatorItemIDs(t *testing.T, db *bun.DB, predicates ...Predicate) []int {
+	t.Helper()
+
+	criteria, unsupported, err := BuildFilterCriteriaFromPredicates(predicates, Config{
+		AllowedFields:    map[string]string{"name": "name", "score": "score", "note": "note", "tags": "tags"},
+		StrictValidation: true,
+	})
+	require.NoError(t, err)
+	require.Empty(t, unsupported)
+
+	var rows []operatorItem
+	query := db.NewSelect().Model(&rows)
+	for _, criterion := range criteria {
+		query = criterion(query)
+	}
+	require.NoError(t, query.Order("id ASC").Scan(context.Background()))
+	ids := make([]int, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row.ID)
+	}
+	return ids
+}
+
+func TestBuildFilterCriteriaFromPredicates_RichOperators(t *testing.T) {
+	db := setupQueryBunDB(t)
+	seedOperatorItems(t, db)
+
+	cases := []struct {
+		name      string
+		predicate Predicate
+		want      []int
+	}{
+		{"between", Predicate{Field: "score", Operator: "between", Values: []string{"15", "30"}}, []int{2, 3}},
+		{"nin", Predicate{Field: "score", Operator: "nin", Values: []string{"10", "30"}}, []int{2}},
+		{"isnull", Predicate{Field: "note", Operator: "isnull"}, []int{2, 3}},
+		{"isnull false", Predicate{Field: "note", Operator: "isnull", Values: []string{"false"}}, []int{1}},
+		{"notnull", Predicate{Field: "note", Operator: "notnull", Values: []string{"true"}}, []int{1}},
+		{"startswith escapes", Predicate{Field: "name", Operator: "startswith", Values: []string{"100%"}}, []int{1}},
+		{"endswith", Predicate{Field: "name", Operator: "endswith", Values: []string{"pure"}}, []int{1, 2}},
+		{"contains escapes", Predicate{Field: "name", Operator: "contains", Values: []string{"_"}}, []int{1, 3}},
+		{"arraycontains", Predicate{Field: "tags", Operator: "arraycontains", Values: []string{"go", "sql"}}, []int{1}},
+		{"hasany", Predicate{Field: "tags", Operator: "hasany", Values: []string{"sql", "rust"}}, []int{1, 3}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, operatorItemIDs(t, db, tc.predicate))
+		})
+	}
+}
+
+func TestBuildFilterCriteriaFromPredicates_Arity(t *testing.T) {
+	predicate := Predicate{Field: "age", Operator: "between", Values: []string{"20"}, RawKey: "age__between"}
+
+	criteria, unsupported, err := BuildFilterCriteriaFromPredicates([]Predicate{predicate}, Config{AllowedFields: filterAllowedFields()})
+	require.NoError(t, err)
+	assert.Empty(t, criteria)
+	assert.Equal(t, []UnsupportedPredicate{
+		{Field: "age", Operator: "between", RawKey: "age__between", Reason: UnsupportedArity},
+	}, unsupported)
+
+	_, _, err = BuildFilterCriteriaFromPredicates([]Predicate{predicate}, Config{AllowedFields: filterAllowedFields(), StrictValidation: true})
+	var validationErr *ValidationError
+	require.True(t, errors.As(err, &validationErr))
+	assert.Equal(t, ValidationInvalidArity, validationErr.Code)
+	assert.Equal(t, `operator "between" on field "age" takes exactly 2 value(s), got 1`, err.Error())
+}
+
+func TestBuildFilterExprCriteria_RichOperators(t *testing.T) {
+	db := setupQueryBunDB(t)
+	seedFilterUsers(t, db)
+
+	expr, err := ParseFilterExpr("age between (26, 35) OR NOT name startswith C")
+	require.NoError(t, err)
+	criteria, _, err := BuildFilterExprCriteria(expr, Config{AllowedFields: filterAllowedFields()})
+	require.NoError(t, err)
+
+	var names []string
+	for _, row := range executeFilterUserCriteria(t, db, criteria) {
+		names = append(names, row.Name)
+	}
+	assert.Equal(t, []string{"Alice", "Bob"}, names)
+
+	expr, err = ParseFilterExpr("status isnull AND name = 'x'")
+	require.NoError(t, err)
+	assert.Empty(t, expr.And[0].Values)
+
+	expr, err = ParseFilterExpr("name = 'x' OR age between 3")
+	require.NoError(t, err)
+	_, _, err = BuildFilterExprCriteria(expr, Config{AllowedFields: filterAllowedFields()})
+	var validationErr *ValidationError
+	require.True(t, errors.As(err, &validationErr))
+	assert.Equal(t, ValidationInvalidArity, validationErr.Code)
+	assert.Equal(t, 15, validationErr.Position)
+}
+
+func TestComparisonCondition_Dialects(t *testing.T) {
+	tags := Operator{Canonical: "arraycontains"}
+	cond, args := ComparisonCondition(dialect.PG, "tags", tags, []any{"a", "b"})
+	assert.Equal(t, "tags @> ?::jsonb", cond)
+	assert.Equal(t, []any{`["a","b"]`}, args)
+
+	cond, _ = ComparisonCondition(dialect.PG, "tags", Operator{Canonical: "hasany"}, []any{"a"})
+	assert.Equal(t, `tags \?| ARRAY[?]`, cond)
+
+	cond, _ = ComparisonCondition(dialect.MySQL, "tags", tags, []any{"a"})
+	assert.Equal(t, "JSON_CONTAINS(tags, ?)", cond)
+
+	cond, args = ComparisonCondition(dialect.MySQL, "name", Operator{Canonical: "contains"}, []any{`a\b`})
+	assert.Equal(t, `name LIKE ? ESCAPE '\\'`, cond)
+	assert.Equal(t, []any{`%a\\b%`}, args)
+}
+
+func TestBuildQueryPlan_NullCheckWithoutValue(t *testing.T) {
+	db := setupQueryBunDB(t)
+	seedOperatorItems(t, db)
+
+	plan, err := BuildQueryPlan(ListOptions{
+		Filters: map[string]any{"note__isnull": "", "name__eq": " "},
+	}, Config{AllowedFields: map[string]string{"name": "name", "note": "note"}})
+	require.NoError(t, err)
+
+	var rows []operatorItem
+	query := db.NewSelect().Model(&rows)
+	for _, criterion := range plan.Filters {
+		query = criterion(query)
+	}
+	require.NoError(t, query.Order("id ASC").Scan(context.Background()))
+	require.Len(t, rows, 2)
+	assert.Equal(t, 2, rows[0].ID)
+}
@@ -0,0 +1,387 @@
+package querybun
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+// CursorDirection identifies which side of a cursor a keyset page reads.
+type CursorDirection string
+
+const (
+	CursorNext CursorDirection = "next"
+	CursorPrev CursorDirection = "prev"
+)
+
+// DefaultKeyColumn is the keyset tiebreaker used when Config.KeyColumns is empty.
+const DefaultKeyColumn = "id"
+
+// Cursor is a decoded keyset position: the full order tuple, including primary
+// key tiebreakers, and the boundary row values for each order column.
+type Cursor struct {
+	Direction CursorDirection
+	Order     []Order
+	Values    []any
+}
+
+// KeysetMetadata describes the keyset pagination state of a plan.
+// Order is the effective order in the requested sort direction, including
+// primary key tiebreakers. When Direction is CursorPrev the query reads rows
+// in reverse order and callers must reverse the page before returning it.
+type KeysetMetadata struct {
+	Direction CursorDirection
+	Order     []Order
+	HasCursor bool
+}
+
+// ErrInvalidCursor is returned when a cursor cannot be decoded or verified.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ErrCursorSecretRequired is returned for keyset plans built without
+// Config.CursorSecret.
+var ErrCursorSecretRequired = errors.New("cursor secret is not configured")
+
+type cursorPayload struct {
+	Direction CursorDirection `json:"d"`
+	Order     [][2]string     `json:"o"`
+	Values    []cursorValue   `json:"v"`
+}
+
+type cursorValue struct {
+	Kind  string `json:"k"`
+	Value string `json:"v,omitempty"`
+}
+
+// EncodeCursor serializes and signs a cursor with HMAC-SHA256.
+func EncodeCursor(cursor Cursor, secret []byte) (string, error) {
+	if len(secret) == 0 {
+		return "", fmt.Errorf("%w: signing key is required", ErrInvalidCursor)
+	}
+	if len(cursor.Values) != len(cursor.Order) {
+		return "", fmt.Errorf("%w: expected %d values, got %d", ErrInvalidCursor, len(cursor.Order), len(cursor.Values))
+	}
+	payload := cursorPayload{
+		Direction: cursor.Direction,
+		Order:     make([][2]string, len(cursor.Order)),
+		Values:    make([]cursorValue, len(cursor.Values)),
+	}
+	if payload.Direction == "" {
+		payload.Direction = CursorNext
+	}
+	for i, order := range cursor.Order {
+		payload.Order[i] = [2]string{order.Field, order.Dir}
+	}
+	for i, value := range cursor.Values {
+		encoded, err := encodeCursorValue(value)
+		if err != nil {
+			return "", err
+		}
+		payload.Values[i] = encoded
+	}
+
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	body := base64.RawURLEncoding.EncodeToString(raw)
+	return body + "." + base64.RawURLEncoding.EncodeToString(signCursor(body, secret)), nil
+}
+
+// DecodeCursor verifies and decodes a cursor produced by EncodeCursor.
+func DecodeCursor(token string, secret []byte) (Cursor, error) {
+	if len(secret) == 0 {
+		return Cursor{}, fmt.Errorf("%w: signing key is required", ErrInvalidCursor)
+	}
+	body, sig, ok := strings.Cut(strings.TrimSpace(token), ".")
+	if !ok || body == "" || sig == "" {
+		return Cursor{}, ErrInvalidCursor
+	}
+	decodedSig, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(decodedSig, signCursor(body, secret)) {
+		return Cursor{}, fmt.Errorf("%w: signature mismatch", ErrInvalidCursor)
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(body)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	var payload cursorPayload
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	if payload.Direction != CursorNext && payload.Direction != CursorPrev {
+		return Cursor{}, fmt.Errorf("%w: unknown direction %q", ErrInvalidCursor, payload.Direction)
+	}
+	if len(payload.Order) == 0 || len(payload.Order) != len(payload.Values) {
+		return Cursor{}, fmt.Errorf("%w: order and values mismatch", ErrInvalidCursor)
+	}
+
+	cursor := Cursor{
+		Direction: payload.Direction,
+		Order:     make([]Order, len(payload.Order)),
+		Values:    make([]any, len(payload.Values)),
+	}
+	for i, order := range payload.Order {
+		cursor.Order[i] = Order{Field: order[0], Dir: normalizeDirection(order[1])}
+	}
+	for i, value := range payload.Values {
+		decoded, err := decodeCursorValue(value)
+		if err != nil {
+			return Cursor{}, err
+		}
+		cursor.Values[i] = decoded
+	}
+	return cursor, nil
+}
+
+func signCursor(body string, secret []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(body))
+	return mac.Sum(nil)
+}
+
+func encodeCursorValue(value any) (cursorValue, error) {
+	if value == nil {
+		return cursorValue{Kind: "n"}, nil
+	}
+	switch v := value.(type) {
+	case time.Time:
+		return cursorValue{Kind: "t", Value: v.UTC().Format(time.RFC3339Nano)}, nil
+	case encoding.TextMarshaler:
+		text, err := v.MarshalText()
+		if err != nil {
+			return cursorValue{}, err
+		}
+		return cursorValue{Kind: "s", Value: string(text)}, nil
+	}
+
+	rv := reflect.ValueOf(value)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return cursorValue{Kind: "n"}, nil
+		}
+		return encodeCursorValue(rv.Elem().Interface())
+	}
+	switch rv.Kind() {
+	case reflect.String:
+		return cursorValue{Kind: "s", Value: rv.String()}, nil
+	case reflect.Bool:
+		return cursorValue{Kind: "b", Value: strconv.FormatBool(rv.Bool())}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return cursorValue{Kind: "i", Value: strconv.FormatInt(rv.Int(), 10)}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return cursorValue{Kind: "u", Value: strconv.FormatUint(rv.Uint(), 10)}, nil
+	case reflect.Float32, reflect.Float64:
+		return cursorValue{Kind: "f", Value: strconv.FormatFloat(rv.Float(), 'g', -1, 64)}, nil
+	default:
+		return cursorValue{}, fmt.Errorf("%w: unsupported value type %T", ErrInvalidCursor, value)
+	}
+}
+
+func decodeCursorValue(value cursorValue) (any, error) {
+	var (
+		out any
+		err error
+	)
+	switch value.Kind {
+	case "n":
+		return nil, nil
+	case "s":
+		return value.Value, nil
+	case "b":
+		out, err = strconv.ParseBool(value.Value)
+	case "i":
+		out, err = strconv.ParseInt(value.Value, 10, 64)
+	case "u":
+		out, err = strconv.ParseUint(value.Value, 10, 64)
+	case "f":
+		out, err = strconv.ParseFloat(value.Value, 64)
+	case "t":
+		out, err = time.Parse(time.RFC3339Nano, value.Value)
+	default:
+		return nil, fmt.Errorf("%w: unknown value kind %q", ErrInvalidCursor, value.Kind)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	return out, nil
+}
+
+// KeysetOrder appends primary key tiebreakers to orders so every row has a
+// unique position. Key columns already present in orders are not repeated.
+func KeysetOrder(orders []Order, keyColumns []string) []Order {
+	if len(keyColumns) == 0 {
+		keyColumns = []string{DefaultKeyColumn}
+	}
+	out := append([]Order{}, orders...)
+	for _, column := range keyColumns {
+		column = strings.TrimSpace(column)
+		if column == "" {
+			continue
+		}
+		found := false
+		for _, order := range orders {
+			if order.Field == column {
+				found = true
+				break
+			}
+		}
+		if !found {
+			out = append(out, Order{Field: column, Dir: "ASC"})
+		}
+	}
+	return out
+}
+
+// BuildKeysetCriteria returns order, seek, and limit criteria for a keyset page.
+// Rows are read after (or, for CursorPrev, before) cursor in the given order.
+func BuildKeysetCriteria(orders []Order, cursor *Cursor, limit int) []Criteria {
+	reverse := cursor != nil && cursor.Direction == CursorPrev
+	criteria := []Criteria{func(q *bun.SelectQuery) *bun.SelectQuery {
+		for _, order := range orders {
+			dir := order.Dir
+			if reverse {
+				dir = flipDirection(dir)
+			}
+			q = q.OrderExpr(fmt.Sprintf("%s %s", keysetColumn(order.Field), dir))
+		}
+		if limit > 0 {
+			q = q.Limit(limit)
+		}
+		return q
+	}}
+	if cursor == nil || len(cursor.Values) != len(orders) {
+		return criteria
+	}
+
+	values := append([]any{}, cursor.Values...)
+	criteria = append(criteria, func(q *bun.SelectQuery) *bun.SelectQuery {
+		nullsLargest := nullsSortLargest(q)
+		return q.WhereGroup(" AND ", func(q *bun.SelectQuery) *bun.SelectQuery {
+			branches := 0
+			for i := range orders {
+				parts := make([]string, 0, i+1)
+				args := make([]any, 0, i+1)
+				for j := range i {
+					column := keysetColumn(orders[j].Field)
+					if values[j] == nil {
+						parts = append(parts, column+" IS NULL")
+						continue
+					}
+					parts = append(parts, column+" = ?")
+					args = append(args, values[j])
+				}
+				after, ok := keysetAfter(keysetColumn(orders[i].Field), values[i], (orders[i].Dir == "DESC") != reverse, nullsLargest)
+				if !ok {
+					continue
+				}
+				parts = append(parts, after)
+				if values[i] != nil {
+					args = append(args, values[i])
+				}
+				q = q.WhereOr("("+strings.Join(parts, " AND ")+")", args...)
+				branches++
+			}
+			if branches == 0 {
+				q = q.Where("1 = 0")
+			}
+			return q
+		})
+	})
+	return criteria
+}
+
+// keysetAfter returns the condition for rows strictly after value in a column
+// read in descending (desc) or ascending order. NULLs are placed the way the
+// dialect orders them by default, so pages line up with ORDER BY; ok is false
+// when no row can follow value.
+func keysetAfter(column string, value any, desc, nullsLargest bool) (string, bool) {
+	switch {
+	case value == nil && desc == nullsLargest:
+		return column + " IS NOT NULL", true
+	case value == nil:
+		return "", false
+	case !desc && nullsLargest:
+		return "(" + column + " > ? OR " + column + " IS NULL)", true
+	case !desc:
+		return column + " > ?", true
+	case nullsLargest:
+		return column + " < ?", true
+	default:
+		return "(" + column + " < ? OR " + column + " IS NULL)", true
+	}
+}
+
+// nullsSortLargest reports whether the query dialect sorts NULLs after every
+// other value in ascending order, as PostgreSQL does. SQLite, MySQL and SQL
+// Server sort them first.
+func nullsSortLargest(q *bun.SelectQuery) bool {
+	return q != nil && q.Dialect() != nil && q.Dialect().Name() == dialect.PG
+}
+
+func buildKeysetPlan(opts ListOptions, cfg Config, limit int, orders []Order) ([]Criteria, []Order, *KeysetMetadata, error) {
+	if len(cfg.CursorSecret) == 0 {
+		return nil, nil, nil, ErrCursorSecretRequired
+	}
+	effective := KeysetOrder(orders, cfg.KeyColumns)
+	meta := &KeysetMetadata{Direction: CursorNext}
+
+	token := strings.TrimSpace(opts.Cursor)
+	if token == "" {
+		meta.Order = effective
+		return BuildKeysetCriteria(effective, nil, limit), effective, meta, nil
+	}
+
+	cursor, err := DecodeCursor(token, cfg.CursorSecret)
+	if err != nil {
+		return nil, nil, nil, &ValidationError{Code: ValidationInvalidCursor, Reason: err.Error()}
+	}
+	if len(orders) == 0 {
+		effective = cursor.Order
+	} else if !sameOrder(effective, cursor.Order) {
+		return nil, nil, nil, &ValidationError{Code: ValidationInvalidCursor, Reason: "cursor does not match requested order"}
+	}
+
+	meta.Direction = cursor.Direction
+	meta.Order = effective
+	meta.HasCursor = true
+	return BuildKeysetCriteria(effective, &cursor, limit), effective, meta, nil
+}
+
+func sameOrder(a, b []Order) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Field != b[i].Field || a[i].Dir != b[i].Dir {
+			return false
+		}
+	}
+	return true
+}
+
+func keysetColumn(field string) string {
+	if strings.Contains(field, ".") {
+		return field
+	}
+	return "?TableAlias." + field
+}
+
+func flipDirection(dir string) string {
+	if dir == "DESC" {
+		return "ASC"
+	}
+	return "DESC"
+}
@@ -0,0 +1,155 @@
+package querybun
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+)
+
+var testCursorSecret = []byte("cursor-test-secret")
+
+func TestCursor_EncodeDecodeRoundTrip(t *testing.T) {
+	at := time.Date(2024, 5, 6, 7, 8, 9, 123, time.UTC)
+	cursor := Cursor{
+		Direction: CursorPrev,
+		Order:     []Order{{Field: "created_at", Dir: "DESC"}, {Field: "age", Dir: "ASC"}, {Field: "name", Dir: "ASC"}, {Field: "id", Dir: "ASC"}},
+		Values:    []any{at, 30, "Alice", int64(7)},
+	}
+
+	token, err := EncodeCursor(cursor, testCursorSecret)
+	require.NoError(t, err)
+
+	decoded, err := DecodeCursor(token, testCursorSecret)
+	require.NoError(t, err)
+	assert.Equal(t, CursorPrev, decoded.Direction)
+	assert.Equal(t, cursor.Order, decoded.Order)
+	assert.Equal(t, []any{at, int64(30), "Alice", int64(7)}, decoded.Values)
+
+	_, err = DecodeCursor(token, []byte("other-secret"))
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+
+	_, err = DecodeCursor("x"+token, testCursorSecret)
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+func TestBuildQueryPlan_KeysetPagesForwardAndBack(t *testing.T) {
+	db := setupQueryBunDB(t)
+	seedFilterUsers(t, db)
+	ctx := context.Background()
+	_, err := db.NewInsert().Model(&filterUser{ID: 4, Name: "Dave", Age: 30, Status: "active"}).Exec(ctx)
+	require.NoError(t, err)
+
+	cfg := Config{AllowedFields: filterAllowedFields(), CursorSecret: testCursorSecret}
+	page := func(cursor string) ([]filterUser, *KeysetMetadata) {
+		plan, err := BuildQueryPlan(ListOptions{Limit: 2, Order: "age asc", Cursor: cursor, CursorSet: true}, cfg)
+		require.NoError(t, err)
+		var rows []filterUser
+		query := db.NewSelect().Model(&rows)
+		for _, criterion := range plan.ListCriteria() {
+			query = criterion(query)
+		}
+		require.NoError(t, query.Scan(ctx))
+		return rows, plan.Metadata.Keyset
+	}
+	cursorAt := func(direction CursorDirection, meta *KeysetMetadata, row filterUser) string {
+		token, err := EncodeCursor(Cursor{Direction: direction, Order: meta.Order, Values: []any{row.Age, row.ID}}, testCursorSecret)
+		require.NoError(t, err)
+		return token
+	}
+
+	first, meta := page("")
+	require.NotNil(t, meta)
+	assert.False(t, meta.HasCursor)
+	assert.Equal(t, []Order{{Field: "age", Dir: "ASC"}, {Field: "id", Dir: "ASC"}}, meta.Order)
+	assert.Equal(t, []int64{2, 1}, filterUserIDs(first))
+
+	second, meta := page(cursorAt(CursorNext, meta, first[len(first)-1]))
+	assert.True(t, meta.HasCursor)
+	assert.Equal(t, []int64{4, 3}, filterUserIDs(second), "ties on age are broken by id")
+
+	back, meta := page(cursorAt(CursorPrev, meta, second[0]))
+	assert.Equal(t, CursorPrev, meta.Direction)
+	assert.Equal(t, []int64{1, 2}, filterUserIDs(back), "prev pages are read in reverse order")
+}
+
+type keysetRankedUser struct {
+	bun.BaseModel `bun:"table:keyset_ranked_users,alias:u"`
+
+	ID   int  `bun:"id,pk"`
+	Rank *int `bun:"rank"`
+}
+
+func TestBuildQueryPlan_KeysetPagesThroughNullValues(t *testing.T) {
+	db := setupQueryBunDB(t)
+	ctx := context.Background()
+	require.NoError(t, db.ResetModel(ctx, (*keysetRankedUser)(nil)))
+	rank := func(v int) *int { return &v }
+	rows := []keysetRankedUser{{ID: 1}, {ID: 2, Rank: rank(5)}, {ID: 3}, {ID: 4, Rank: rank(1)}, {ID: 5, Rank: rank(5)}}
+	_, err := db.NewInsert().Model(&rows).Exec(ctx)
+	require.NoError(t, err)
+
+	allowed := map[string]string{"id": "id", "rank": "rank"}
+	cfg := Config{AllowedFields: allowed, CursorSecret: testCursorSecret}
+	for _, order := range []string{"rank asc", "rank desc"} {
+		t.Run(order, func(t *testing.T) {
+			var expected []keysetRankedUser
+			require.NoError(t, db.NewSelect().Model(&expected).OrderExpr(order+", id asc").Scan(ctx))
+
+			var seen []int
+			cursor := ""
+			for range rows {
+				plan, err := BuildQueryPlan(ListOptions{Limit: 1, Order: order, Cursor: cursor, CursorSet: true}, cfg)
+				require.NoError(t, err)
+				var page []keysetRankedUser
+				query := db.NewSelect().Model(&page)
+				for _, criterion := range plan.ListCriteria() {
+					query = criterion(query)
+				}
+				require.NoError(t, query.Scan(ctx))
+				require.Len(t, page, 1, "no page may come back empty before the last row")
+				seen = append(seen, page[0].ID)
+
+				var value any
+				if page[0].Rank != nil {
+					value = *page[0].Rank
+				}
+				cursor, err = EncodeCursor(Cursor{Direction: CursorNext, Order: plan.Metadata.Keyset.Order, Values: []any{value, page[0].ID}}, testCursorSecret)
+				require.NoError(t, err)
+			}
+
+			want := make([]int, len(expected))
+			for i, row := range expected {
+				want[i] = row.ID
+			}
+			assert.Equal(t, want, seen)
+		})
+	}
+}
+
+func TestBuildQueryPlan_KeysetRejectsMismatchedCursor(t *testing.T) {
+	cfg := Config{AllowedFields: filterAllowedFields(), CursorSecret: testCursorSecret}
+	token, err := EncodeCursor(Cursor{Order: []Order{{Field: "id", Dir: "ASC"}}, Values: []any{1}}, testCursorSecret)
+	require.NoError(t, err)
+
+	_, err = BuildQueryPlan(ListOptions{Order: "name desc", Cursor: token}, cfg)
+	var validationErr *ValidationError
+	require.True(t, errors.As(err, &validationErr))
+	assert.Equal(t, ValidationInvalidCursor, validationErr.Code)
+
+	_, err = BuildQueryPlan(ListOptions{Cursor: "garbage"}, cfg)
+	require.True(t, errors.As(err, &validationErr))
+	assert.Equal(t, ValidationInvalidCursor, validationErr.Code)
+}
+
+func filterUserIDs(rows []filterUser) []int64 {
+	ids := make([]int64, len(rows))
+	for i, row := range rows {
+		ids[i] = int64(row.ID)
+	}
+	return ids
+}
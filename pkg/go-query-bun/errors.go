@@ -9,6 +9,13 @@ const (
 	ValidationUnsupportedOperator   ValidationErrorCode = "unsupported_operator"
 	ValidationSearchColumnsRequired ValidationErrorCode = "search_columns_required"
 	ValidationFieldNotAllowed       ValidationErrorCode = "field_not_allowed"
+	ValidationInvalidCursor         ValidationErrorCode = "invalid_cursor"
+	ValidationInvalidFilter         ValidationErrorCode = "invalid_filter"
+	ValidationInvalidArity          ValidationErrorCode = "invalid_arity"
+	ValidationInvalidValue          ValidationErrorCode = "invalid_value"
+	ValidationTooManyFilters        ValidationErrorCode = "too_many_filters"
+	ValidationSearchTooLong         ValidationErrorCode = "search_too_long"
+	ValidationRelationTooDeep       ValidationErrorCode = "relation_too_deep"
 )
 
 // ValidationError provides typed strict-mode query validation failures.
@@ -17,13 +24,22 @@ type ValidationError struct {
 	Field    string
 	Operator string
 	Search   string
+	Reason   string
+	// Position is the 1-based position in a filter expression, or zero.
+	Position int
 }
 
 func (e *ValidationError) Error() string {
 	if e == nil {
 		return "query validation error"
 	}
+	if e.Position > 0 {
+		return fmt.Sprintf("%s at position %d", e.message(), e.Position)
+	}
+	return e.message()
+}
 
+func (e *ValidationError) message() string {
 	switch e.Code {
 	case ValidationUnsupportedOperator:
 		if e.Field != "" {
@@ -37,6 +53,50 @@ func (e *ValidationError) Error() string {
 			return fmt.Sprintf("field %q is not allowed", e.Field)
 		}
 		return "field is not allowed"
+	case ValidationInvalidCursor:
+		if e.Reason != "" {
+			return e.Reason
+		}
+		return "invalid cursor"
+	case ValidationInvalidFilter:
+		if e.Reason != "" {
+			return "invalid filter: " + e.Reason
+		}
+		return "invalid filter"
+	case ValidationInvalidArity:
+		message := fmt.Sprintf("operator %q", e.Operator)
+		if e.Field != "" {
+			message += fmt.Sprintf(" on field %q", e.Field)
+		}
+		if e.Reason != "" {
+			return message + " " + e.Reason
+		}
+		return message + " has the wrong number of values"
+	case ValidationInvalidValue:
+		message := "invalid value"
+		if e.Field != "" {
+			message += fmt.Sprintf(" for field %q", e.Field)
+		}
+		if e.Reason != "" {
+			return message + ": " + e.Reason
+		}
+		return message
+	case ValidationTooManyFilters:
+		if e.Reason != "" {
+			return "too many filters: " + e.Reason
+		}
+		return "too many filters"
+	case ValidationSearchTooLong:
+		if e.Reason != "" {
+			return "search term is too long: " + e.Reason
+		}
+		return "search term is too long"
+	case ValidationRelationTooDeep:
+		message := fmt.Sprintf("relation field %q is too deep", e.Field)
+		if e.Reason != "" {
+			return message + ": " + e.Reason
+		}
+		return message
 	default:
 		return "query validation error"
 	}
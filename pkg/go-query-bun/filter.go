@@ -31,8 +31,8 @@ func BuildFilterCriteriaFromPredicates(predicates []Predicate, cfg Config) ([]Cr
 			continue
 		}
 
-		columnName, ok := allowedFields[field]
-		if !ok || strings.TrimSpace(columnName) == "" {
+		columnName, rel, ok := cfg.filterColumn(field)
+		if !ok {
 			reason := UnsupportedDisallowedField
 			if len(allowedFields) == 0 {
 				reason = UnsupportedUnknownField
@@ -49,7 +49,7 @@ func BuildFilterCriteriaFromPredicates(predicates []Predicate, cfg Config) ([]Cr
 		}
 
 		cleaned := normalizeStringValues(predicate.Values)
-		if len(cleaned) == 0 {
+		if len(cleaned) == 0 && !nullCheckOperator(operatorToken, cfg) {
 			unsupported = append(unsupported, unsupportedFromPredicate(predicate, UnsupportedEmptyValue))
 			continue
 		}
@@ -63,20 +63,30 @@ func BuildFilterCriteriaFromPredicates(predicates []Predicate, cfg Config) ([]Cr
 			unsupported = append(unsupported, unsupportedFromPredicate(predicate, UnsupportedOperator))
 			continue
 		}
+		if err := checkOperatorArity(operator, field, cleaned, true); err != nil {
+			unsupported = append(unsupported, unsupportedFromPredicate(predicate, UnsupportedArity))
+			if cfg.StrictValidation {
+				return nil, unsupported, err
+			}
+			continue
+		}
+		values, valueErr := coerceFilterValues(field, operator, cleaned, cfg)
+		if valueErr != nil {
+			unsupported = append(unsupported, unsupportedFromPredicate(predicate, UnsupportedValueShape))
+			return nil, unsupported, valueErr
+		}
 
 		switch operator.Canonical {
 		case "and":
-			values := append([]string{}, cleaned...)
 			column := columnName
 			andConditions = append(andConditions, func(q *bun.SelectQuery) *bun.SelectQuery {
 				eqOperator := resolveSQLOperator("eq", cfg)
 				for _, value := range values {
-					q = q.Where(fmt.Sprintf("%s %s ?", column, eqOperator), value)
+					q = q.Where(relationCondition(q, rel, fmt.Sprintf("%s %s ?", column, eqOperator)), value)
 				}
 				return q
 			})
 		case "or":
-			values := append([]string{}, cleaned...)
 			column := columnName
 			orGroups = append(orGroups, func(q *bun.SelectQuery) *bun.SelectQuery {
 				orComparisonOp := resolveSQLOperator("eq", cfg)
@@ -84,26 +94,27 @@ func BuildFilterCriteriaFromPredicates(predicates []Predicate, cfg Config) ([]Cr
 					orComparisonOp = "="
 				}
 				for i, value := range values {
+					cond := relationCondition(q, rel, fmt.Sprintf("%s %s ?", column, orComparisonOp))
 					if i == 0 {
-						q = q.Where(fmt.Sprintf("%s %s ?", column, orComparisonOp), value)
+						q = q.Where(cond, value)
 					} else {
-						q = q.WhereOr(fmt.Sprintf("%s %s ?", column, orComparisonOp), value)
+						q = q.WhereOr(cond, value)
 					}
 				}
 				return q
 			})
 		default:
-			values := append([]string{}, cleaned...)
 			column := columnName
-			sqlOp := operator.SQL
-			canonical := operator.Canonical
+			op := operator
 			andConditions = append(andConditions, func(q *bun.SelectQuery) *bun.SelectQuery {
-				if canonical == "in" {
-					q = q.Where(fmt.Sprintf("%s IN (?)", column), bun.In(values))
-					return q
+				name := q.Dialect().Name()
+				if info, _ := filterOperatorInfo(op.Canonical); info.MinValues != 1 || info.MaxValues != 1 {
+					cond, args := ComparisonCondition(name, column, op, values)
+					return q.Where(relationCondition(q, rel, cond), args...)
 				}
 				for _, value := range values {
-					q = q.Where(fmt.Sprintf("%s %s ?", column, sqlOp), value)
+					cond, args := ComparisonCondition(name, column, op, []any{value})
+					q = q.Where(relationCondition(q, rel, cond), args...)
 				}
 				return q
 			})
@@ -150,12 +161,22 @@ func resolveFilterOperator(token, field string, cfg Config) (Operator, bool, err
 	return Operator{}, false, nil
 }
 
+// nullCheckOperator reports whether token resolves to isnull or notnull,
+// which need no value, as in deleted_at__isnull=.
+func nullCheckOperator(token string, cfg Config) bool {
+	operator, err := ResolveOperator(token, "", Config{OperatorMap: cfg.OperatorMap})
+	if err != nil {
+		return false
+	}
+	return operator.Canonical == "isnull" || operator.Canonical == "notnull"
+}
+
 func operatorTokenSupported(token string, operators map[string]string) bool {
 	normalized := normalizeOperatorToken(token)
 	if normalized == "" {
 		normalized = "eq"
 	}
-	if _, ok := canonicalOperatorSQL[normalized]; ok {
+	if _, ok := canonicalOperatorSQL[normalized]; ok || isComparisonOperator(normalized) {
 		return true
 	}
 	effective := effectiveOperatorMap(operators)
@@ -163,7 +184,7 @@ func operatorTokenSupported(token string, operators map[string]string) bool {
 	if !ok || strings.TrimSpace(mapped) == "" {
 		return false
 	}
-	return canonicalOperatorForSQL(mapped) != ""
+	return canonicalOperatorForSQL(mapped) != "" || isComparisonOperator(normalizeOperatorToken(mapped))
 }
 
 func resolveSQLOperator(op string, cfg Config) string {
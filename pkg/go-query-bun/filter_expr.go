@@ -0,0 +1,603 @@
+package querybun
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/uptrace/bun"
+)
+
+// FilterExprParam is the query parameter carrying a filter expression.
+const FilterExprParam = "filter"
+
+// maxFilterExprDepth bounds nesting so hostile input cannot exhaust the stack.
+const maxFilterExprDepth = 32
+
+// FilterExpr is a boolean tree of field predicates. Exactly one of And, Or,
+// Not or Field is set on each node. The JSON form is
+//
+//	{"or": [{"and": [{"field": "status", "op": "eq", "value": "a"},
+//	                 {"field": "age", "op": "gt", "value": 3}]},
+//	        {"field": "owner_id", "op": "eq", "value": "me"}]}
+//
+// Leaves accept either "value" (scalar or array) or "values".
+type FilterExpr struct {
+	And []FilterExpr `json:"and,omitempty"`
+	Or  []FilterExpr `json:"or,omitempty"`
+	Not *FilterExpr  `json:"not,omitempty"`
+
+	Field    string   `json:"field,omitempty"`
+	Operator string   `json:"op,omitempty"`
+	Values   []string `json:"values,omitempty"`
+
+	// Pos is the 1-based byte position of the node in the parsed expression,
+	// reported by validation errors. It is zero for trees built in code.
+	Pos int `json:"-"`
+}
+
+// UnmarshalJSON decodes a filter tree, accepting "value" as an alias of
+// "values" for leaves.
+func (e *FilterExpr) UnmarshalJSON(data []byte) error {
+	type node FilterExpr
+	var raw struct {
+		node
+		Value json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*e = FilterExpr(raw.node)
+	if len(raw.Value) == 0 {
+		return nil
+	}
+	values, err := filterExprJSONValues(raw.Value)
+	if err != nil {
+		return err
+	}
+	e.Values = append(e.Values, values...)
+	return nil
+}
+
+func filterExprJSONValues(raw json.RawMessage) ([]string, error) {
+	raw = bytes.TrimSpace(raw)
+	switch {
+	case bytes.Equal(raw, []byte("null")):
+		return nil, nil
+	case len(raw) > 0 && raw[0] == '[':
+		var items []json.RawMessage
+		if err := json.Unmarshal(raw, &items); err != nil {
+			return nil, err
+		}
+		out := make([]string, 0, len(items))
+		for _, item := range items {
+			values, err := filterExprJSONValues(item)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, values...)
+		}
+		return out, nil
+	case len(raw) > 0 && raw[0] == '"':
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil, err
+		}
+		return []string{s}, nil
+	case len(raw) > 0 && raw[0] == '{':
+		return nil, errors.New("filter value must be a scalar or an array of scalars")
+	default:
+		// Numbers and booleans keep their literal text.
+		return []string{string(raw)}, nil
+	}
+}
+
+// ParseFilterExpr parses a filter expression or, when input starts with "{",
+// a JSON filter tree. The expression grammar is
+//
+//	expr      = term { OR term }
+//	term      = factor { AND factor }
+//	factor    = NOT factor | "(" expr ")" | predicate
+//	predicate = field operator [ value | "(" value { "," value } ")" ]
+//
+// Operators are =, !=, <>, >, >=, <, <= or an operator name such as ilike,
+// between or isnull. The value may be omitted for operators that take none,
+// as in "deleted_at isnull"; symbol operators always need one. Values are quoted strings ('...' or "...", doubling the quote to escape
+// it), numbers or bare words. Keywords are case-insensitive. Blank input
+// returns nil. Syntax errors are ValidationInvalidFilter errors carrying the
+// position of the offending token.
+func ParseFilterExpr(input string) (*FilterExpr, error) {
+	trimmed := strings.TrimSpace(input)
+	if trimmed == "" {
+		return nil, nil
+	}
+	if strings.HasPrefix(trimmed, "{") {
+		var expr FilterExpr
+		if err := json.Unmarshal([]byte(trimmed), &expr); err != nil {
+			invalid := &ValidationError{Code: ValidationInvalidFilter, Reason: err.Error()}
+			var syntaxErr *json.SyntaxError
+			if errors.As(err, &syntaxErr) {
+				lead := len(input) - len(strings.TrimLeft(input, " \t\r\n"))
+				invalid.Position = lead + int(syntaxErr.Offset)
+			}
+			return nil, invalid
+		}
+		return &expr, nil
+	}
+
+	tokens, err := lexFilterExpr(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &filterExprParser{tokens: tokens}
+	expr, err := p.parseOr(0)
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != filterTokenEOF {
+		return nil, filterSyntaxError(tok.pos, "unexpected %q", tok.text)
+	}
+	return expr, nil
+}
+
+func filterSyntaxError(pos int, format string, args ...any) error {
+	return &ValidationError{
+		Code:     ValidationInvalidFilter,
+		Position: pos,
+		Reason:   fmt.Sprintf(format, args...),
+	}
+}
+
+// --- lexer ---
+
+type filterTokenKind int
+
+const (
+	filterTokenEOF filterTokenKind = iota
+	filterTokenIdent
+	filterTokenString
+	filterTokenNumber
+	filterTokenOperator
+	filterTokenLParen
+	filterTokenRParen
+	filterTokenComma
+)
+
+type filterToken struct {
+	kind filterTokenKind
+	text string
+	pos  int
+}
+
+var filterSymbolOperators = map[string]string{
+	"=":  "eq",
+	"==": "eq",
+	"!=": "ne",
+	"<>": "ne",
+	">":  "gt",
+	">=": "gte",
+	"<":  "lt",
+	"<=": "lte",
+}
+
+func lexFilterExpr(input string) ([]filterToken, error) {
+	var tokens []filterToken
+	for i := 0; i < len(input); {
+		c := input[i]
+		pos := i + 1
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, filterToken{kind: filterTokenLParen, text: "(", pos: pos})
+			i++
+		case c == ')':
+			tokens = append(tokens, filterToken{kind: filterTokenRParen, text: ")", pos: pos})
+			i++
+		case c == ',':
+			tokens = append(tokens, filterToken{kind: filterTokenComma, text: ",", pos: pos})
+			i++
+		case c == '\'' || c == '"':
+			var b strings.Builder
+			j := i + 1
+			for {
+				if j >= len(input) {
+					return nil, filterSyntaxError(pos, "unterminated string")
+				}
+				if input[j] == c {
+					if j+1 < len(input) && input[j+1] == c {
+						b.WriteByte(c)
+						j += 2
+						continue
+					}
+					break
+				}
+				b.WriteByte(input[j])
+				j++
+			}
+			tokens = append(tokens, filterToken{kind: filterTokenString, text: b.String(), pos: pos})
+			i = j + 1
+		case strings.ContainsRune("=!<>", rune(c)):
+			j := i + 1
+			if j < len(input) && strings.ContainsRune("=>", rune(input[j])) {
+				j++
+			}
+			symbol := input[i:j]
+			if _, ok := filterSymbolOperators[symbol]; !ok {
+				return nil, filterSyntaxError(pos, "unknown operator %q", symbol)
+			}
+			tokens = append(tokens, filterToken{kind: filterTokenOperator, text: symbol, pos: pos})
+			i = j
+		case isFilterDigit(c) || (c == '-' && i+1 < len(input) && isFilterDigit(input[i+1])):
+			j := i + 1
+			for j < len(input) && (isFilterDigit(input[j]) || input[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, filterToken{kind: filterTokenNumber, text: input[i:j], pos: pos})
+			i = j
+		case isFilterIdentStart(c):
+			j := i + 1
+			for j < len(input) && (isFilterIdentStart(input[j]) || isFilterDigit(input[j]) || input[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, filterToken{kind: filterTokenIdent, text: input[i:j], pos: pos})
+			i = j
+		default:
+			return nil, filterSyntaxError(pos, "unexpected character %q", c)
+		}
+	}
+	return append(tokens, filterToken{kind: filterTokenEOF, text: "end of input", pos: len(input) + 1}), nil
+}
+
+func isFilterDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isFilterIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// --- parser ---
+
+type filterExprParser struct {
+	tokens []filterToken
+	next   int
+}
+
+func (p *filterExprParser) peek() filterToken {
+	return p.tokens[p.next]
+}
+
+func (p *filterExprParser) advance() filterToken {
+	tok := p.tokens[p.next]
+	if tok.kind != filterTokenEOF {
+		p.next++
+	}
+	return tok
+}
+
+func (p *filterExprParser) keyword(word string) bool {
+	tok := p.peek()
+	return tok.kind == filterTokenIdent && strings.EqualFold(tok.text, word)
+}
+
+func isFilterKeyword(tok filterToken) bool {
+	if tok.kind != filterTokenIdent {
+		return false
+	}
+	switch strings.ToUpper(tok.text) {
+	case "AND", "OR", "NOT":
+		return true
+	}
+	return false
+}
+
+func (p *filterExprParser) parseOr(depth int) (*FilterExpr, error) {
+	if depth > maxFilterExprDepth {
+		return nil, filterSyntaxError(p.peek().pos, "filter is nested too deeply")
+	}
+	first, err := p.parseAnd(depth)
+	if err != nil {
+		return nil, err
+	}
+	if !p.keyword("OR") {
+		return first, nil
+	}
+	expr := &FilterExpr{Or: []FilterExpr{*first}, Pos: first.Pos}
+	for p.keyword("OR") {
+		p.advance()
+		next, err := p.parseAnd(depth)
+		if err != nil {
+			return nil, err
+		}
+		expr.Or = append(expr.Or, *next)
+	}
+	return expr, nil
+}
+
+func (p *filterExprParser) parseAnd(depth int) (*FilterExpr, error) {
+	first, err := p.parseUnary(depth)
+	if err != nil {
+		return nil, err
+	}
+	if !p.keyword("AND") {
+		return first, nil
+	}
+	expr := &FilterExpr{And: []FilterExpr{*first}, Pos: first.Pos}
+	for p.keyword("AND") {
+		p.advance()
+		next, err := p.parseUnary(depth)
+		if err != nil {
+			return nil, err
+		}
+		expr.And = append(expr.And, *next)
+	}
+	return expr, nil
+}
+
+func (p *filterExprParser) parseUnary(depth int) (*FilterExpr, error) {
+	tok := p.peek()
+	switch {
+	case p.keyword("NOT"):
+		p.advance()
+		if depth+1 > maxFilterExprDepth {
+			return nil, filterSyntaxError(tok.pos, "filter is nested too deeply")
+		}
+		child, err := p.parseUnary(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return &FilterExpr{Not: child, Pos: tok.pos}, nil
+	case tok.kind == filterTokenLParen:
+		p.advance()
+		expr, err := p.parseOr(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.advance(); closing.kind != filterTokenRParen {
+			return nil, filterSyntaxError(closing.pos, "expected \")\", got %q", closing.text)
+		}
+		return expr, nil
+	default:
+		return p.parsePredicate()
+	}
+}
+
+func (p *filterExprParser) parsePredicate() (*FilterExpr, error) {
+	field := p.advance()
+	if field.kind != filterTokenIdent || isFilterKeyword(field) {
+		return nil, filterSyntaxError(field.pos, "expected field, got %q", field.text)
+	}
+
+	var operator string
+	named := false
+	switch tok := p.advance(); {
+	case tok.kind == filterTokenOperator:
+		operator = filterSymbolOperators[tok.text]
+	case tok.kind == filterTokenIdent && !isFilterKeyword(tok):
+		operator = strings.ToLower(tok.text)
+		named = true
+	default:
+		return nil, filterSyntaxError(tok.pos, "expected operator after %q, got %q", field.text, tok.text)
+	}
+
+	var values []string
+	if next := p.peek(); named && (next.kind == filterTokenEOF || next.kind == filterTokenRParen || p.keyword("AND") || p.keyword("OR")) {
+		// No value; compileLeaf checks the operator arity.
+	} else if next.kind == filterTokenLParen {
+		p.advance()
+		for {
+			value, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+			tok := p.advance()
+			if tok.kind == filterTokenRParen {
+				break
+			}
+			if tok.kind != filterTokenComma {
+				return nil, filterSyntaxError(tok.pos, "expected \",\" or \")\", got %q", tok.text)
+			}
+		}
+	} else {
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		values = []string{value}
+	}
+
+	return &FilterExpr{Field: field.text, Operator: operator, Values: values, Pos: field.pos}, nil
+}
+
+func (p *filterExprParser) parseValue() (string, error) {
+	tok := p.advance()
+	switch {
+	case tok.kind == filterTokenString, tok.kind == filterTokenNumber:
+		return tok.text, nil
+	case tok.kind == filterTokenIdent && !isFilterKeyword(tok):
+		return tok.text, nil
+	default:
+		return "", filterSyntaxError(tok.pos, "expected value, got %q", tok.text)
+	}
+}
+
+// --- compiler ---
+
+// BuildFilterExprCriteria compiles expr into a criterion of nested WhereGroup
+// calls ANDed with the rest of the query. Fields resolve through
+// cfg.AllowedFields, so virtual field expressions work as they do for flat
+// filters. NOT is pushed down to the leaves (De Morgan), since Bun drops the
+// separator of a leading group.
+//
+// Unknown fields and unsupported operators are reported as unsupported and
+// their predicates dropped, unless cfg.StrictValidation or cfg.StrictFields
+// is set, in which case the error carries the predicate position. Malformed
+// trees always fail with ValidationInvalidFilter.
+func BuildFilterExprCriteria(expr *FilterExpr, cfg Config) ([]Criteria, []UnsupportedPredicate, error) {
+	if expr == nil {
+		return nil, nil, nil
+	}
+	c := &filterExprCompiler{cfg: cfg, allowed: cloneStringMap(cfg.AllowedFields)}
+	node, err := c.compile(expr, false, 0)
+	if err != nil {
+		return nil, c.unsupported, err
+	}
+	if node == nil {
+		return nil, c.unsupported, nil
+	}
+	return []Criteria{func(q *bun.SelectQuery) *bun.SelectQuery {
+		return node.apply(q, " AND ")
+	}}, c.unsupported, nil
+}
+
+type filterExprCompiler struct {
+	cfg         Config
+	allowed     map[string]string
+	unsupported []UnsupportedPredicate
+}
+
+// filterExprNode is a compiled group (sep set) or leaf comparison, rendered
+// when applied so the SQL can follow the query dialect.
+type filterExprNode struct {
+	sep      string
+	children []*filterExprNode
+	column   string
+	relation *RelationField
+	operator Operator
+	values   []any
+	negate   bool
+}
+
+func (n *filterExprNode) apply(q *bun.SelectQuery, sep string) *bun.SelectQuery {
+	if n.sep == "" {
+		cond, args := ComparisonCondition(q.Dialect().Name(), n.column, n.operator, n.values)
+		cond = relationCondition(q, n.relation, cond)
+		if n.negate {
+			cond = "NOT (" + cond + ")"
+		}
+		if sep == " OR " {
+			return q.WhereOr(cond, args...)
+		}
+		return q.Where(cond, args...)
+	}
+	return q.WhereGroup(sep, func(q *bun.SelectQuery) *bun.SelectQuery {
+		for _, child := range n.children {
+			q = child.apply(q, n.sep)
+		}
+		return q
+	})
+}
+
+func (c *filterExprCompiler) compile(expr *FilterExpr, negate bool, depth int) (*filterExprNode, error) {
+	if depth > maxFilterExprDepth {
+		return nil, filterSyntaxError(expr.Pos, "filter is nested too deeply")
+	}
+
+	shapes := 0
+	for _, set := range []bool{len(expr.And) > 0, len(expr.Or) > 0, expr.Not != nil, strings.TrimSpace(expr.Field) != ""} {
+		if set {
+			shapes++
+		}
+	}
+	if shapes != 1 {
+		return nil, filterSyntaxError(expr.Pos, "filter node must set exactly one of and, or, not or field")
+	}
+
+	switch {
+	case expr.Not != nil:
+		return c.compile(expr.Not, !negate, depth+1)
+	case len(expr.And) > 0:
+		return c.compileGroup(expr.And, negate, !negate, depth)
+	case len(expr.Or) > 0:
+		return c.compileGroup(expr.Or, negate, negate, depth)
+	default:
+		return c.compileLeaf(expr, negate)
+	}
+}
+
+func (c *filterExprCompiler) compileGroup(children []FilterExpr, negate, conjunction bool, depth int) (*filterExprNode, error) {
+	group := &filterExprNode{sep: " OR "}
+	if conjunction {
+		group.sep = " AND "
+	}
+	for i := range children {
+		child, err := c.compile(&children[i], negate, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		if child != nil {
+			group.children = append(group.children, child)
+		}
+	}
+	switch len(group.children) {
+	case 0:
+		return nil, nil
+	case 1:
+		return group.children[0], nil
+	default:
+		return group, nil
+	}
+}
+
+func (c *filterExprCompiler) compileLeaf(expr *FilterExpr, negate bool) (*filterExprNode, error) {
+	field := strings.TrimSpace(expr.Field)
+	token := normalizeOperatorToken(expr.Operator)
+	if token == "" {
+		token = "eq"
+	}
+	predicate := Predicate{Field: field, Operator: token, Values: expr.Values, RawKey: FilterExprParam}
+	strict := c.cfg.StrictValidation || c.cfg.StrictFields
+
+	column, rel, ok := c.cfg.filterColumn(field)
+	if !ok {
+		reason := UnsupportedDisallowedField
+		if len(c.allowed) == 0 {
+			reason = UnsupportedUnknownField
+		}
+		c.unsupported = append(c.unsupported, unsupportedFromPredicate(predicate, reason))
+		if strict {
+			return nil, &ValidationError{Code: ValidationFieldNotAllowed, Field: field, Position: expr.Pos}
+		}
+		return nil, nil
+	}
+	operator, operatorOK, err := resolveFilterOperator(token, field, c.cfg)
+	if err != nil {
+		c.unsupported = append(c.unsupported, unsupportedFromPredicate(predicate, UnsupportedOperator))
+		var validationErr *ValidationError
+		if errors.As(err, &validationErr) {
+			validationErr.Position = expr.Pos
+		}
+		return nil, err
+	}
+	if !operatorOK {
+		c.unsupported = append(c.unsupported, unsupportedFromPredicate(predicate, UnsupportedOperator))
+		return nil, nil
+	}
+
+	if operator.Canonical == "and" || operator.Canonical == "or" {
+		return nil, &ValidationError{Code: ValidationInvalidFilter, Field: field, Operator: token, Position: expr.Pos, Reason: fmt.Sprintf("operator %q is not a comparison", token)}
+	}
+	if err := checkOperatorArity(operator, field, expr.Values, false); err != nil {
+		c.unsupported = append(c.unsupported, unsupportedFromPredicate(predicate, UnsupportedArity))
+		err.Position = expr.Pos
+		return nil, err
+	}
+	values, valueErr := coerceFilterValues(field, operator, expr.Values, c.cfg)
+	if valueErr != nil {
+		c.unsupported = append(c.unsupported, unsupportedFromPredicate(predicate, UnsupportedValueShape))
+		valueErr.Position = expr.Pos
+		return nil, valueErr
+	}
+
+	return &filterExprNode{
+		column:   column,
+		relation: rel,
+		operator: operator,
+		values:   values,
+		negate:   negate,
+	}, nil
+}
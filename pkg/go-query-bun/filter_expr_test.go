@@ -0,0 +1,145 @@
+package querybun
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFilterExpr_Grammar(t *testing.T) {
+	expr, err := ParseFilterExpr(`(status = 'active' AND age > 3) or NOT name in ("Bob", 'O''Neil')`)
+	require.NoError(t, err)
+
+	assert.Equal(t, &FilterExpr{Pos: 2, Or: []FilterExpr{
+		{Pos: 2, And: []FilterExpr{
+			{Field: "status", Operator: "eq", Values: []string{"active"}, Pos: 2},
+			{Field: "age", Operator: "gt", Values: []string{"3"}, Pos: 24},
+		}},
+		{Pos: 36, Not: &FilterExpr{Field: "name", Operator: "in", Values: []string{"Bob", "O'Neil"}, Pos: 40}},
+	}}, expr)
+
+	expr, err = ParseFilterExpr("   ")
+	require.NoError(t, err)
+	assert.Nil(t, expr)
+}
+
+func TestParseFilterExpr_JSONTree(t *testing.T) {
+	expr, err := ParseFilterExpr(`{"or": [{"field": "age", "op": "gte", "value": 40}, {"not": {"field": "status", "value": ["active", "pending"], "op": "in"}}]}`)
+	require.NoError(t, err)
+	assert.Equal(t, &FilterExpr{Or: []FilterExpr{
+		{Field: "age", Operator: "gte", Values: []string{"40"}},
+		{Not: &FilterExpr{Field: "status", Operator: "in", Values: []string{"active", "pending"}}},
+	}}, expr)
+
+	var decoded FilterExpr
+	require.NoError(t, json.Unmarshal([]byte(`{"field": "name", "values": ["Alice"]}`), &decoded))
+	assert.Equal(t, FilterExpr{Field: "name", Values: []string{"Alice"}}, decoded)
+}
+
+func TestParseFilterExpr_SyntaxErrorPositions(t *testing.T) {
+	cases := []struct {
+		input    string
+		position int
+	}{
+		{"status = ", 10},
+		{"status 'a'", 8},
+		{"(status = 'a'", 14},
+		{"status = 'a' AND", 17},
+		{"status = 'a", 10},
+		{"status ! 'a'", 8},
+		{"status = a b", 12},
+		{"status = $", 10},
+	}
+	for _, tc := range cases {
+		t.Run(tc.input, func(t *testing.T) {
+			_, err := ParseFilterExpr(tc.input)
+			var validationErr *ValidationError
+			require.True(t, errors.As(err, &validationErr), "got %v", err)
+			assert.Equal(t, ValidationInvalidFilter, validationErr.Code)
+			assert.Equal(t, tc.position, validationErr.Position, validationErr.Error())
+		})
+	}
+}
+
+func TestBuildFilterExprCriteria_NestedGroups(t *testing.T) {
+	db := setupQueryBunDB(t)
+	seedFilterUsers(t, db)
+
+	run := func(input string) []string {
+		t.Helper()
+		expr, err := ParseFilterExpr(input)
+		require.NoError(t, err)
+		criteria, unsupported, err := BuildFilterExprCriteria(expr, Config{AllowedFields: filterAllowedFields()})
+		require.NoError(t, err)
+		assert.Empty(t, unsupported)
+		var names []string
+		for _, row := range executeFilterUserCriteria(t, db, criteria) {
+			names = append(names, row.Name)
+		}
+		return names
+	}
+
+	assert.Equal(t, []string{"Alice", "Bob"}, run("(status = 'active' AND age >= 30) OR name = 'Bob'"))
+	assert.Equal(t, []string{"Bob", "Carol"}, run("NOT (status = 'active' AND age >= 30)"))
+	assert.Equal(t, []string{"Alice"}, run("NOT (status = 'pending' OR age < 30)"))
+	assert.Equal(t, []string{"Carol"}, run("status in ('pending', 'inactive') AND NOT name = Bob"))
+}
+
+func TestBuildFilterExprCriteria_UnknownFields(t *testing.T) {
+	expr, err := ParseFilterExpr("name = 'Alice' OR secret = 'x'")
+	require.NoError(t, err)
+
+	criteria, unsupported, err := BuildFilterExprCriteria(expr, Config{AllowedFields: filterAllowedFields()})
+	require.NoError(t, err)
+	assert.Len(t, criteria, 1)
+	assert.Equal(t, []UnsupportedPredicate{
+		{Field: "secret", Operator: "eq", RawKey: FilterExprParam, Reason: UnsupportedDisallowedField},
+	}, unsupported)
+
+	_, _, err = BuildFilterExprCriteria(expr, Config{AllowedFields: filterAllowedFields(), StrictValidation: true})
+	var validationErr *ValidationError
+	require.True(t, errors.As(err, &validationErr))
+	assert.Equal(t, ValidationFieldNotAllowed, validationErr.Code)
+	assert.Equal(t, 19, validationErr.Position)
+	assert.Equal(t, `field "secret" is not allowed at position 19`, err.Error())
+
+	expr, err = ParseFilterExpr("name = 'Alice' AND age nope 3")
+	require.NoError(t, err)
+	_, _, err = BuildFilterExprCriteria(expr, Config{AllowedFields: filterAllowedFields(), StrictValidation: true})
+	require.True(t, errors.As(err, &validationErr))
+	assert.Equal(t, ValidationUnsupportedOperator, validationErr.Code)
+	assert.Equal(t, 20, validationErr.Position)
+}
+
+func TestBuildFilterExprCriteria_MalformedTree(t *testing.T) {
+	_, _, err := BuildFilterExprCriteria(&FilterExpr{Field: "name", Values: []string{"a"}, Or: []FilterExpr{{Field: "age", Values: []string{"1"}}}}, Config{AllowedFields: filterAllowedFields()})
+	var validationErr *ValidationError
+	require.True(t, errors.As(err, &validationErr))
+	assert.Equal(t, ValidationInvalidFilter, validationErr.Code)
+
+	_, _, err = BuildFilterExprCriteria(&FilterExpr{Field: "age", Operator: "gt", Values: []string{"1", "2"}}, Config{AllowedFields: filterAllowedFields()})
+	require.True(t, errors.As(err, &validationErr))
+	assert.Equal(t, ValidationInvalidArity, validationErr.Code)
+}
+
+func TestBuildQueryPlan_FilterExpr(t *testing.T) {
+	db := setupQueryBunDB(t)
+	seedFilterUsers(t, db)
+
+	plan, err := BuildQueryPlan(ListOptions{
+		Filters:    map[string]any{"age__gte": "25"},
+		Filter:     "status = 'active' OR status = 'inactive'",
+		FilterTree: &FilterExpr{Not: &FilterExpr{Field: "name", Values: []string{"Alice"}}},
+	}, Config{AllowedFields: filterAllowedFields()})
+	require.NoError(t, err)
+
+	rows := executeFilterUserCriteria(t, db, plan.Filters)
+	require.Len(t, rows, 1)
+	assert.Equal(t, "Bob", rows[0].Name)
+
+	_, err = BuildQueryPlan(ListOptions{Filter: "status ="}, Config{AllowedFields: filterAllowedFields()})
+	require.Error(t, err)
+}
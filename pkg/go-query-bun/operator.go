@@ -20,6 +20,21 @@ var canonicalOperatorSQL = map[string]string{
 	"or":    "or",
 }
 
+// comparisonOperators are canonical operators rendered by
+// ComparisonCondition instead of a single SQL operator, so they carry no SQL
+// fragment. Alias maps point at them by name, as in {"notin": "nin"}.
+var comparisonOperators = map[string]struct{}{
+	"nin":           {},
+	"between":       {},
+	"isnull":        {},
+	"notnull":       {},
+	"startswith":    {},
+	"endswith":      {},
+	"contains":      {},
+	"arraycontains": {},
+	"hasany":        {},
+}
+
 var canonicalOperatorBySQL = map[string]string{
 	"=":     "eq",
 	"<>":    "ne",
@@ -85,6 +100,13 @@ func ResolveOperator(token, field string, cfg Config) (Operator, error) {
 		}, nil
 	}
 
+	if _, ok := comparisonOperators[normalized]; ok {
+		return Operator{
+			Token:     normalized,
+			Canonical: normalized,
+		}, nil
+	}
+
 	if mapped, ok := operators[normalized]; ok {
 		if trimmed := strings.TrimSpace(mapped); trimmed != "" {
 			canonical := canonicalOperatorForSQL(trimmed)
@@ -95,6 +117,12 @@ func ResolveOperator(token, field string, cfg Config) (Operator, error) {
 					SQL:       trimmed,
 				}, nil
 			}
+			if name := normalizeOperatorToken(trimmed); isComparisonOperator(name) {
+				return Operator{
+					Token:     normalized,
+					Canonical: name,
+				}, nil
+			}
 		}
 	}
 
@@ -129,6 +157,11 @@ func effectiveOperatorMap(operators map[string]string) map[string]string {
 	return effective
 }
 
+func isComparisonOperator(name string) bool {
+	_, ok := comparisonOperators[name]
+	return ok
+}
+
 func canonicalOperatorForSQL(sql string) string {
 	if canonical, ok := canonicalOperatorBySQL[normalizeSQLOperator(sql)]; ok {
 		return canonical
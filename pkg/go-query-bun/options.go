@@ -1,5 +1,10 @@
 package querybun
 
+import (
+	"reflect"
+	"time"
+)
+
 const (
 	DefaultLimit  = 25
 	DefaultOffset = 0
@@ -19,8 +24,17 @@ type ListOptions struct {
 	Search     string
 	Filters    map[string]any
 	Predicates []Predicate
+	// Filter is a filter expression or JSON filter tree (see
+	// ParseFilterExpr). FilterTree is the pre-parsed equivalent; when both
+	// are set they are ANDed. Both are ANDed with Filters/Predicates.
+	Filter     string
+	FilterTree *FilterExpr
 	Select     []string
 	Include    []string
+	// Cursor is an opaque keyset cursor from a previous page. Keyset
+	// pagination is used when Cursor is set or CursorSet is true.
+	Cursor    string
+	CursorSet bool
 }
 
 // Config controls field resolution, operator aliases, validation, and defaults.
@@ -34,4 +48,34 @@ type Config struct {
 	FallbackUnsupportedOperators bool
 	DefaultLimit                 int
 	DefaultOffset                int
+	// MaxLimit clamps the page size, including unbounded (zero or negative)
+	// limits. Zero leaves the limit unbounded.
+	MaxLimit int
+	// MaxPredicates caps the filter predicates, counting field params and
+	// filter expression comparisons. Zero is unlimited.
+	MaxPredicates int
+	// MaxSearchLength caps the _search term in characters. Zero is unlimited.
+	MaxSearchLength int
+	// MaxRelationDepth caps the relations a dotted filter or order field goes
+	// through; company.address.city is two deep. Zero is unlimited.
+	MaxRelationDepth int
+	// CursorSecret signs and verifies keyset cursors.
+	CursorSecret []byte
+	// KeyColumns are the primary key columns appended to keyset orders as
+	// tiebreakers. Defaults to DefaultKeyColumn.
+	KeyColumns []string
+	// FieldTypes maps AllowedFields keys to their Go types. Filter values on
+	// typed fields are parsed before they reach the database.
+	FieldTypes map[string]reflect.Type
+	// Now anchors relative time values such as now-7d. Defaults to time.Now.
+	Now func() time.Time
+	// SearchStrategy resolves _search over SearchColumns. Defaults to
+	// LikeSearch.
+	SearchStrategy SearchStrategy
+	// RelationFields resolves dotted fields such as "company.name" that are
+	// not in AllowedFields, for filters and ordering across relations.
+	RelationFields RelationFieldResolver
+
+	// qualifyColumns is set by BuildQueryPlan when the plan joins relations.
+	qualifyColumns bool
 }
@@ -45,6 +45,12 @@ func BuildOrderCriteria(order string, cfg Config) ([]Criteria, []Order) {
 			direction = normalizeDirection(parts[1])
 		}
 		columnName, ok := allowedFields[field]
+		if !ok {
+			// Fields behind to-many relations have no single value to order by.
+			if rel, relOK := cfg.resolveRelationField(field); relOK && rel.Exists == nil {
+				columnName, ok = rel.Column, true
+			}
+		}
 		if !ok || strings.TrimSpace(columnName) == "" {
 			continue
 		}
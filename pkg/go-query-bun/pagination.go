@@ -3,6 +3,7 @@ package querybun
 import "github.com/uptrace/bun"
 
 // NormalizePagination returns the effective limit, offset, and one-based page.
+// Limits are clamped to cfg.MaxLimit when set.
 func NormalizePagination(opts ListOptions, cfg Config) (int, int, int) {
 	limit := cfg.DefaultLimit
 	if limit == 0 {
@@ -14,6 +15,7 @@ func NormalizePagination(opts ListOptions, cfg Config) (int, int, int) {
 		if opts.LimitSet {
 			limit = opts.Limit
 		}
+		limit = clampLimit(limit, cfg)
 		if opts.OffsetSet {
 			offset = opts.Offset
 		}
@@ -24,6 +26,7 @@ func NormalizePagination(opts ListOptions, cfg Config) (int, int, int) {
 		if opts.Limit > 0 {
 			limit = opts.Limit
 		}
+		limit = clampLimit(limit, cfg)
 		offset = opts.Offset
 		return limit, offset, pageFor(limit, offset)
 	}
@@ -33,13 +36,24 @@ func NormalizePagination(opts ListOptions, cfg Config) (int, int, int) {
 		if perPage <= 0 {
 			perPage = limit
 		}
+		perPage = clampLimit(perPage, cfg)
 		page := max(opts.Page, 1)
 		return perPage, (page - 1) * perPage, page
 	}
 
+	limit = clampLimit(limit, cfg)
 	return limit, offset, pageFor(limit, offset)
 }
 
+// clampLimit caps limit at cfg.MaxLimit. Non-positive limits mean no limit
+// and are clamped too.
+func clampLimit(limit int, cfg Config) int {
+	if cfg.MaxLimit > 0 && (limit <= 0 || limit > cfg.MaxLimit) {
+		return cfg.MaxLimit
+	}
+	return limit
+}
+
 // BuildPaginationCriteria returns limit/offset criteria plus normalized values.
 func BuildPaginationCriteria(opts ListOptions, cfg Config) ([]Criteria, int, int, int) {
 	limit, offset, page := NormalizePagination(opts, cfg)
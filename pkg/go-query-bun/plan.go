@@ -1,9 +1,16 @@
 package querybun
 
-import "strings"
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
 
 // Plan contains independently applicable criteria groups for a list query.
 type Plan struct {
+	// Joins add the to-one relations used by relation fields in filters and
+	// order. They must precede Filters and Order.
+	Joins       []Criteria
 	Filters     []Criteria
 	Search      []Criteria
 	Order       []Criteria
@@ -15,9 +22,10 @@ type Plan struct {
 }
 
 // ListCriteria returns the legacy combined criteria order used by go-crud
-// list operations: pagination, order, filters/search, select.
+// list operations: joins, pagination, order, filters/search, select.
 func (p Plan) ListCriteria() []Criteria {
-	out := make([]Criteria, 0, len(p.Pagination)+len(p.Order)+len(p.Filters)+len(p.Search)+len(p.Select))
+	out := make([]Criteria, 0, len(p.Joins)+len(p.Pagination)+len(p.Order)+len(p.Filters)+len(p.Search)+len(p.Select))
+	out = append(out, p.Joins...)
 	out = append(out, p.Pagination...)
 	out = append(out, p.Order...)
 	out = append(out, p.Filters...)
@@ -35,13 +43,20 @@ func (p Plan) ReadCriteria() []Criteria {
 
 // Metadata contains normalized query values for response adapters and callers.
 type Metadata struct {
-	Limit   int
-	Offset  int
-	Page    int
-	Search  string
-	Order   []Order
-	Fields  []string
-	Include []string
+	Limit  int
+	Offset int
+	Page   int
+	// LimitClamped reports that the requested limit, kept in RequestedLimit,
+	// exceeded Config.MaxLimit and Limit was lowered to it.
+	LimitClamped   bool
+	RequestedLimit int
+	Search         string
+	// SearchStrategy names the strategy that resolved Search, if any.
+	SearchStrategy string
+	Order          []Order
+	Fields         []string
+	Include        []string
+	Keyset         *KeysetMetadata
 }
 
 // BuildQueryPlan builds a separated, reusable query plan from list options.
@@ -57,14 +72,57 @@ func BuildQueryPlan(opts ListOptions, cfg Config) (Plan, error) {
 	plan.Metadata.Limit = limit
 	plan.Metadata.Offset = offset
 	plan.Metadata.Page = page
+	if cfg.MaxLimit > 0 {
+		unbounded := cfg
+		unbounded.MaxLimit = 0
+		if requested, _, _ := NormalizePagination(opts, unbounded); requested != limit {
+			plan.Metadata.LimitClamped = true
+			plan.Metadata.RequestedLimit = requested
+		}
+	}
 
-	orderCriteria, orders := BuildOrderCriteria(NormalizeOrder(opts), cfg)
+	order := NormalizeOrder(opts)
+	orderCriteria, orders := BuildOrderCriteria(order, cfg)
 	plan.Order = orderCriteria
 	plan.Metadata.Order = orders
 
 	predicates, unsupported := NormalizePredicatesWithUnsupported(opts)
 	plan.Unsupported = append(plan.Unsupported, unsupported...)
 
+	tree, err := filterTreeFromOptions(opts)
+	if err != nil {
+		return plan, err
+	}
+
+	if err := checkQueryLimits(opts, order, predicates, tree, cfg); err != nil {
+		return plan, err
+	}
+
+	relationFields := append(orderFields(order), predicatesFields(predicates)...)
+	plan.Joins = relationJoins(filterExprFields(tree, relationFields), cfg)
+	if len(plan.Joins) > 0 {
+		cfg.qualifyColumns = true
+	}
+
+	if opts.CursorSet || strings.TrimSpace(opts.Cursor) != "" {
+		// Cursors hold the row's own values, so they cannot order by relations.
+		for _, field := range orderFields(order) {
+			if _, ok := cfg.resolveRelationField(field); ok {
+				return plan, &ValidationError{Code: ValidationInvalidCursor, Field: field, Reason: "cursor pagination cannot order by relation fields"}
+			}
+		}
+		keysetCriteria, keysetOrders, keyset, err := buildKeysetPlan(opts, cfg, limit, orders)
+		if err != nil {
+			return plan, err
+		}
+		plan.Pagination = keysetCriteria
+		plan.Order = nil
+		plan.Metadata.Offset = 0
+		plan.Metadata.Page = 0
+		plan.Metadata.Order = keysetOrders
+		plan.Metadata.Keyset = keyset
+	}
+
 	filterCriteria, filterUnsupported, err := BuildFilterCriteriaFromPredicates(predicates, cfg)
 	plan.Filters = filterCriteria
 	plan.Unsupported = append(plan.Unsupported, filterUnsupported...)
@@ -72,12 +130,25 @@ func BuildQueryPlan(opts ListOptions, cfg Config) (Plan, error) {
 		return plan, err
 	}
 
+	treeCriteria, treeUnsupported, err := BuildFilterExprCriteria(tree, cfg)
+	plan.Filters = append(plan.Filters, treeCriteria...)
+	plan.Unsupported = append(plan.Unsupported, treeUnsupported...)
+	if err != nil {
+		return plan, err
+	}
+
 	searchCriteria, search, err := BuildSearchCriteria(opts.Search, cfg)
 	plan.Search = searchCriteria
 	plan.Metadata.Search = search
 	if err != nil {
 		return plan, err
 	}
+	if len(searchCriteria) > 0 {
+		plan.Metadata.SearchStrategy = searchStrategy(cfg).Name()
+		if len(plan.Order) == 0 && plan.Metadata.Keyset == nil {
+			plan.Order = buildSearchRankCriteria(search, cfg)
+		}
+	}
 
 	selectCriteria, selected := BuildSelectCriteria(opts.Select, cfg)
 	plan.Select = selectCriteria
@@ -94,6 +165,56 @@ func BuildQueryPlan(opts ListOptions, cfg Config) (Plan, error) {
 	return plan, nil
 }
 
+// checkQueryLimits enforces Config.MaxPredicates and Config.MaxSearchLength.
+func checkQueryLimits(opts ListOptions, order string, predicates []Predicate, tree *FilterExpr, cfg Config) error {
+	if cfg.MaxPredicates > 0 {
+		if count := len(filterExprFields(tree, predicatesFields(predicates))); count > cfg.MaxPredicates {
+			return &ValidationError{Code: ValidationTooManyFilters, Reason: fmt.Sprintf("%d filters exceed the limit of %d", count, cfg.MaxPredicates)}
+		}
+	}
+	if cfg.MaxSearchLength > 0 {
+		search := strings.TrimSpace(opts.Search)
+		if length := utf8.RuneCountInString(search); length > cfg.MaxSearchLength {
+			return &ValidationError{Code: ValidationSearchTooLong, Search: search, Reason: fmt.Sprintf("%d characters exceed the limit of %d", length, cfg.MaxSearchLength)}
+		}
+	}
+	if cfg.MaxRelationDepth > 0 {
+		for _, field := range filterExprFields(tree, append(orderFields(order), predicatesFields(predicates)...)) {
+			if _, ok := cfg.resolveRelationField(field); !ok {
+				continue
+			}
+			if depth := strings.Count(field, "."); depth > cfg.MaxRelationDepth {
+				return &ValidationError{Code: ValidationRelationTooDeep, Field: field, Reason: fmt.Sprintf("%d relations deep exceeds the limit of %d", depth, cfg.MaxRelationDepth)}
+			}
+		}
+	}
+	return nil
+}
+
+func predicatesFields(predicates []Predicate) []string {
+	fields := make([]string, len(predicates))
+	for i, predicate := range predicates {
+		fields[i] = strings.TrimSpace(predicate.Field)
+	}
+	return fields
+}
+
+// filterTreeFromOptions parses opts.Filter and ANDs it with opts.FilterTree.
+func filterTreeFromOptions(opts ListOptions) (*FilterExpr, error) {
+	parsed, err := ParseFilterExpr(opts.Filter)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case parsed == nil:
+		return opts.FilterTree, nil
+	case opts.FilterTree == nil:
+		return parsed, nil
+	default:
+		return &FilterExpr{And: []FilterExpr{*parsed, *opts.FilterTree}}, nil
+	}
+}
+
 func normalizeSearchOptions(opts ListOptions) (ListOptions, []UnsupportedPredicate) {
 	search := strings.TrimSpace(opts.Search)
 
@@ -194,6 +315,7 @@ func NormalizeIncludes(includes []string) []IncludeRequest {
 func normalizeConfig(cfg Config) Config {
 	cfg.AllowedFields = cloneStringMap(cfg.AllowedFields)
 	cfg.SearchColumns = append([]string{}, cfg.SearchColumns...)
+	cfg.KeyColumns = append([]string{}, cfg.KeyColumns...)
 	if cfg.OperatorMap != nil {
 		cfg.OperatorMap = cloneOperatorMap(cfg.OperatorMap)
 	}
@@ -179,3 +179,46 @@ func assertSameCriteria(t *testing.T, expected, actual Criteria) {
 	t.Helper()
 	assert.Equal(t, reflect.ValueOf(expected).Pointer(), reflect.ValueOf(actual).Pointer())
 }
+
+func TestBuildQueryPlan_Limits(t *testing.T) {
+	cfg := Config{
+		AllowedFields:   filterAllowedFields(),
+		SearchColumns:   []string{"name"},
+		MaxLimit:        50,
+		MaxPredicates:   2,
+		MaxSearchLength: 5,
+	}
+
+	plan, err := BuildQueryPlan(ListOptions{Limit: 500, LimitSet: true, Offset: 100, OffsetSet: true}, cfg)
+	require.NoError(t, err)
+	assert.Equal(t, 50, plan.Metadata.Limit)
+	assert.Equal(t, 3, plan.Metadata.Page)
+	assert.True(t, plan.Metadata.LimitClamped)
+	assert.Equal(t, 500, plan.Metadata.RequestedLimit)
+
+	plan, err = BuildQueryPlan(ListOptions{Limit: 0, LimitSet: true}, cfg)
+	require.NoError(t, err)
+	assert.Equal(t, 50, plan.Metadata.Limit, "unbounded limits are clamped")
+	assert.True(t, plan.Metadata.LimitClamped)
+
+	plan, err = BuildQueryPlan(ListOptions{Limit: 20, LimitSet: true}, cfg)
+	require.NoError(t, err)
+	assert.Equal(t, 20, plan.Metadata.Limit)
+	assert.False(t, plan.Metadata.LimitClamped)
+
+	_, err = BuildQueryPlan(ListOptions{
+		Filters: map[string]any{"name": "Alice", "age__gte": "20"},
+		Filter:  "status = 'active'",
+	}, cfg)
+	var validationErr *ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, ValidationTooManyFilters, validationErr.Code)
+	assert.Equal(t, "too many filters: 3 filters exceed the limit of 2", validationErr.Error())
+
+	_, err = BuildQueryPlan(ListOptions{Search: "alicia"}, cfg)
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, ValidationSearchTooLong, validationErr.Code)
+
+	_, err = BuildQueryPlan(ListOptions{Search: "alice", Filters: map[string]any{"name": "Alice"}}, cfg)
+	require.NoError(t, err)
+}
@@ -57,7 +57,7 @@ func NormalizePredicatesWithUnsupported(opts ListOptions) ([]Predicate, []Unsupp
 				})
 				continue
 			}
-			if len(values) == 0 {
+			if len(values) == 0 && !nullCheckOperator(predicate.Operator, Config{}) {
 				unsupported = append(unsupported, UnsupportedPredicate{
 					Field:    field,
 					Operator: predicate.Operator,
@@ -108,7 +108,7 @@ func NormalizePredicatesWithUnsupported(opts ListOptions) ([]Predicate, []Unsupp
 			})
 			continue
 		}
-		if len(values) == 0 {
+		if len(values) == 0 && !nullCheckOperator(operator, Config{}) {
 			unsupported = append(unsupported, UnsupportedPredicate{
 				Field:    field,
 				Operator: operator,
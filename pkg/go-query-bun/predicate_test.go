@@ -80,6 +80,22 @@ func TestResolveOperator(t *testing.T) {
 		assert.Equal(t, Operator{Token: "$like", Canonical: "like", SQL: "LIKE"}, operator)
 	})
 
+	t.Run("comparison operators carry no SQL fragment", func(t *testing.T) {
+		operator, err := ResolveOperator("startswith", "name", Config{})
+		require.NoError(t, err)
+		assert.Equal(t, Operator{Token: "startswith", Canonical: "startswith"}, operator)
+		assert.NotContains(t, CanonicalOperatorMap(), "startswith")
+	})
+
+	t.Run("custom alias maps to comparison operator by name", func(t *testing.T) {
+		operator, err := ResolveOperator("notin", "status", Config{
+			OperatorMap:      map[string]string{"notin": "nin"},
+			StrictValidation: true,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, Operator{Token: "notin", Canonical: "nin"}, operator)
+	})
+
 	t.Run("strict unsupported operator includes field and operator", func(t *testing.T) {
 		_, err := ResolveOperator("unknown", "name", Config{StrictValidation: true})
 		require.Error(t, err)
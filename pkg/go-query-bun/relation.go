@@ -0,0 +1,147 @@
+package querybun
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/uptrace/bun"
+)
+
+// RelationField is a column of a related model addressed by a dotted path
+// such as "company.name". Fields reached only through to-one relations are
+// joined; fields behind a to-many relation are matched with EXISTS.
+type RelationField struct {
+	// Column is the column expression, qualified with the relation alias.
+	Column string
+	// Type is the Go type of the field, used to parse filter values.
+	Type reflect.Type
+	// Joins add the to-one relations leading to Column. BuildQueryPlan adds
+	// each join once, keyed by RelationJoin.Key, ahead of the filters.
+	Joins []RelationJoin
+	// Exists wraps a condition on Column in an EXISTS subquery. It is set
+	// for fields behind a to-many relation, which cannot be ordered by.
+	Exists func(q *bun.SelectQuery, cond string) string
+}
+
+// RelationJoin is a join shared by the relation fields using it.
+type RelationJoin struct {
+	Key   string
+	Apply Criteria
+}
+
+// RelationFieldResolver resolves a dotted field path to a relation field.
+type RelationFieldResolver func(field string) (RelationField, bool)
+
+// resolveRelationField resolves dotted fields missing from AllowedFields.
+func (cfg Config) resolveRelationField(field string) (RelationField, bool) {
+	if cfg.RelationFields == nil || !strings.Contains(field, ".") {
+		return RelationField{}, false
+	}
+	if _, ok := cfg.AllowedFields[field]; ok {
+		return RelationField{}, false
+	}
+	rel, ok := cfg.RelationFields(field)
+	if !ok || strings.TrimSpace(rel.Column) == "" {
+		return RelationField{}, false
+	}
+	return rel, true
+}
+
+// filterColumn resolves a filter field to its column and, for relation
+// fields, the relation it goes through.
+func (cfg Config) filterColumn(field string) (string, *RelationField, bool) {
+	if column, ok := cfg.AllowedFields[field]; ok {
+		if strings.TrimSpace(column) == "" {
+			return "", nil, false
+		}
+		if cfg.qualifyColumns {
+			column = qualifyColumn(column)
+		}
+		return column, nil, true
+	}
+	rel, ok := cfg.resolveRelationField(field)
+	if !ok {
+		return "", nil, false
+	}
+	return rel.Column, &rel, true
+}
+
+func (cfg Config) fieldType(field string) reflect.Type {
+	if typ, ok := cfg.FieldTypes[field]; ok {
+		return typ
+	}
+	if rel, ok := cfg.resolveRelationField(field); ok {
+		return rel.Type
+	}
+	return nil
+}
+
+var plainColumnPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// qualifyColumn prefixes bare column names with the model alias so they
+// stay unambiguous next to joined relations.
+func qualifyColumn(column string) string {
+	column = strings.TrimSpace(column)
+	if !plainColumnPattern.MatchString(column) {
+		return column
+	}
+	return "?TableAlias." + column
+}
+
+// relationCondition wraps cond for relation fields behind a to-many relation.
+func relationCondition(q *bun.SelectQuery, rel *RelationField, cond string) string {
+	if rel == nil || rel.Exists == nil {
+		return cond
+	}
+	return rel.Exists(q, cond)
+}
+
+// relationJoins returns the joins needed by the relation fields among
+// fields, each join once.
+func relationJoins(fields []string, cfg Config) []Criteria {
+	seen := make(map[string]struct{})
+	var out []Criteria
+	for _, field := range fields {
+		rel, ok := cfg.resolveRelationField(field)
+		if !ok || rel.Exists != nil {
+			continue
+		}
+		for _, join := range rel.Joins {
+			if _, ok := seen[join.Key]; ok || join.Apply == nil {
+				continue
+			}
+			seen[join.Key] = struct{}{}
+			out = append(out, join.Apply)
+		}
+	}
+	return out
+}
+
+// orderFields returns the field names of an order string.
+func orderFields(order string) []string {
+	var out []string
+	for raw := range strings.SplitSeq(order, ",") {
+		if parts := strings.Fields(raw); len(parts) > 0 {
+			out = append(out, parts[0])
+		}
+	}
+	return out
+}
+
+// filterExprFields appends the leaf fields of expr to out.
+func filterExprFields(expr *FilterExpr, out []string) []string {
+	if expr == nil {
+		return out
+	}
+	if field := strings.TrimSpace(expr.Field); field != "" {
+		out = append(out, field)
+	}
+	for i := range expr.And {
+		out = filterExprFields(&expr.And[i], out)
+	}
+	for i := range expr.Or {
+		out = filterExprFields(&expr.Or[i], out)
+	}
+	return filterExprFields(expr.Not, out)
+}
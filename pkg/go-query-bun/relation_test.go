@@ -0,0 +1,121 @@
+package querybun
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+)
+
+func filterUserRelationFields(field string) (RelationField, bool) {
+	switch field {
+	case "team.name":
+		return RelationField{
+			Column: "team.name",
+			Joins: []RelationJoin{{Key: "team", Apply: func(q *bun.SelectQuery) *bun.SelectQuery {
+				return q.Join("LEFT JOIN filter_teams AS team ON team.id = ?TableAlias.team_id")
+			}}},
+		}, true
+	case "pets.name":
+		return RelationField{
+			Column: "pets.name",
+			Exists: func(q *bun.SelectQuery, cond string) string {
+				return "EXISTS (SELECT 1 FROM filter_pets AS pets WHERE pets.owner_id = ?TableAlias.id AND (" + cond + "))"
+			},
+		}, true
+	}
+	return RelationField{}, false
+}
+
+func TestBuildQueryPlan_RelationFields(t *testing.T) {
+	cfg := Config{
+		AllowedFields:  filterAllowedFields(),
+		RelationFields: filterUserRelationFields,
+	}
+	plan, err := BuildQueryPlan(ListOptions{
+		Filters: map[string]any{
+			"team.name__ilike": "core%",
+			"name":             "Alice",
+		},
+		Filter: "pets.name = 'Rex'",
+		Order:  "team.name desc,pets.name asc",
+	}, cfg)
+	require.NoError(t, err)
+	require.Len(t, plan.Joins, 1, "joins are added once per relation")
+	assert.Empty(t, plan.Unsupported)
+	assert.Equal(t, []Order{{Field: "team.name", Dir: "DESC"}}, plan.Metadata.Order, "to-many fields cannot be ordered by")
+
+	sql := renderFilterUserQuery(t, plan.ListCriteria())
+	assert.Contains(t, sql, `LEFT JOIN filter_teams AS team ON team.id = "u".team_id`)
+	assert.Contains(t, sql, `"u".name = 'Alice'`, "root columns are qualified next to joins")
+	assert.Contains(t, sql, `team.name ILIKE 'core%'`)
+	assert.Contains(t, sql, `EXISTS (SELECT 1 FROM filter_pets AS pets WHERE pets.owner_id = "u".id AND (pets.name = 'Rex'))`)
+	assert.Contains(t, sql, `ORDER BY team.name DESC`)
+}
+
+func TestBuildQueryPlan_RelationFieldsWithoutJoinsKeepBareColumns(t *testing.T) {
+	plan, err := BuildQueryPlan(ListOptions{
+		Filters: map[string]any{"name": "Alice", "pets.name": "Rex"},
+	}, Config{AllowedFields: filterAllowedFields(), RelationFields: filterUserRelationFields})
+	require.NoError(t, err)
+	assert.Empty(t, plan.Joins)
+
+	sql := renderFilterUserQuery(t, plan.ListCriteria())
+	assert.Contains(t, sql, `(name = 'Alice')`)
+	assert.Contains(t, sql, `pets.name = 'Rex'`)
+}
+
+func TestBuildQueryPlan_RelationFieldsUnknownPath(t *testing.T) {
+	cfg := Config{AllowedFields: filterAllowedFields(), RelationFields: filterUserRelationFields}
+	plan, err := BuildQueryPlan(ListOptions{Filters: map[string]any{"team.owner": "x"}}, cfg)
+	require.NoError(t, err)
+	assert.Empty(t, plan.Filters)
+	require.Len(t, plan.Unsupported, 1)
+	assert.Equal(t, UnsupportedDisallowedField, plan.Unsupported[0].Reason)
+
+	cfg.StrictFields = true
+	_, err = BuildQueryPlan(ListOptions{Filters: map[string]any{"team.owner": "x"}}, cfg)
+	var validationErr *ValidationError
+	require.True(t, errors.As(err, &validationErr))
+	assert.Equal(t, ValidationFieldNotAllowed, validationErr.Code)
+}
+
+func TestBuildQueryPlan_KeysetRejectsRelationOrder(t *testing.T) {
+	_, err := BuildQueryPlan(ListOptions{Order: "team.name asc", CursorSet: true}, Config{
+		AllowedFields:  filterAllowedFields(),
+		RelationFields: filterUserRelationFields,
+	})
+	var validationErr *ValidationError
+	require.True(t, errors.As(err, &validationErr))
+	assert.Equal(t, ValidationInvalidCursor, validationErr.Code)
+}
+
+func TestBuildQueryPlan_MaxRelationDepth(t *testing.T) {
+	cfg := Config{
+		AllowedFields: filterAllowedFields(),
+		RelationFields: func(field string) (RelationField, bool) {
+			if field == "team.lead.name" {
+				return RelationField{Column: "lead.name"}, true
+			}
+			return filterUserRelationFields(field)
+		},
+		MaxRelationDepth: 1,
+	}
+
+	_, err := BuildQueryPlan(ListOptions{Filters: map[string]any{"team.name": "core"}, Order: "team.name asc"}, cfg)
+	require.NoError(t, err)
+
+	for _, opts := range []ListOptions{
+		{Filters: map[string]any{"team.lead.name": "Ann"}},
+		{Filter: "team.lead.name = 'Ann'"},
+		{Order: "team.lead.name asc"},
+	} {
+		_, err = BuildQueryPlan(opts, cfg)
+		var validationErr *ValidationError
+		require.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, ValidationRelationTooDeep, validationErr.Code)
+		assert.Equal(t, "team.lead.name", validationErr.Field)
+	}
+}
@@ -8,14 +8,68 @@ import (
 	"github.com/uptrace/bun/dialect"
 )
 
-// BuildSearchCriteria returns dialect-aware search criteria for _search.
+// SearchColumn is a resolved search column with an optional weight from "A"
+// (highest) to "D", configured as "field:A" in Config.SearchColumns.
+type SearchColumn struct {
+	Column string
+	Weight string
+}
+
+// SearchStrategy turns a _search term into a WHERE criterion over the
+// resolved search columns.
+type SearchStrategy interface {
+	// Name identifies the strategy in response metadata.
+	Name() string
+	Match(term string, columns []SearchColumn, cfg Config) Criteria
+}
+
+// SearchRanker is implemented by strategies that can order rows by
+// relevance. The rank order applies when the request sets no order and does
+// not use keyset pagination.
+type SearchRanker interface {
+	Rank(term string, columns []SearchColumn, cfg Config) Criteria
+}
+
+// LikeSearch ORs a case-insensitive substring match across the columns. It
+// is the default strategy; it needs no index and ignores weights.
+type LikeSearch struct{}
+
+// Name implements SearchStrategy.
+func (LikeSearch) Name() string { return "like" }
+
+// Match implements SearchStrategy.
+func (LikeSearch) Match(term string, columns []SearchColumn, cfg Config) Criteria {
+	pattern := "%" + term + "%"
+	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.WhereGroup(" AND ", func(q *bun.SelectQuery) *bun.SelectQuery {
+			for i, column := range columns {
+				if i == 0 {
+					q = applySearchWhere(q, column.Column, pattern, cfg)
+				} else {
+					q = applySearchWhereOr(q, column.Column, pattern, cfg)
+				}
+			}
+			return q
+		})
+	}
+}
+
+func searchStrategy(cfg Config) SearchStrategy {
+	if cfg.SearchStrategy != nil {
+		return cfg.SearchStrategy
+	}
+	return LikeSearch{}
+}
+
+// BuildSearchCriteria returns search criteria for _search using
+// cfg.SearchStrategy, or LikeSearch when unset.
 func BuildSearchCriteria(search string, cfg Config) ([]Criteria, string, error) {
 	searchTerm := strings.TrimSpace(search)
 	if searchTerm == "" {
 		return nil, "", nil
 	}
 
-	searchColumns := ResolveSearchColumns(cfg.SearchColumns, cfg.AllowedFields)
+	searchColumns := resolveSearchColumns(cfg)
 	if len(searchColumns) == 0 {
 		if cfg.StrictValidation && cfg.StrictSearchColumns {
 			return nil, searchTerm, &ValidationError{
@@ -26,23 +80,52 @@ func BuildSearchCriteria(search string, cfg Config) ([]Criteria, string, error)
 		return nil, searchTerm, nil
 	}
 
-	pattern := "%" + searchTerm + "%"
-	return []Criteria{func(q *bun.SelectQuery) *bun.SelectQuery {
-		return q.WhereGroup(" AND ", func(q *bun.SelectQuery) *bun.SelectQuery {
-			for i, column := range searchColumns {
-				if i == 0 {
-					q = applySearchWhere(q, column, pattern, cfg)
-				} else {
-					q = applySearchWhereOr(q, column, pattern, cfg)
-				}
-			}
-			return q
-		})
-	}}, searchTerm, nil
+	return []Criteria{searchStrategy(cfg).Match(searchTerm, searchColumns, cfg)}, searchTerm, nil
+}
+
+// buildSearchRankCriteria returns the relevance order for search, if the
+// strategy ranks.
+func buildSearchRankCriteria(search string, cfg Config) []Criteria {
+	ranker, ok := searchStrategy(cfg).(SearchRanker)
+	searchTerm := strings.TrimSpace(search)
+	if !ok || searchTerm == "" {
+		return nil
+	}
+	searchColumns := resolveSearchColumns(cfg)
+	if len(searchColumns) == 0 {
+		return nil
+	}
+	return []Criteria{ranker.Rank(searchTerm, searchColumns, cfg)}
+}
+
+// resolveSearchColumns resolves cfg.SearchColumns, qualifying bare columns
+// when the plan joins relations.
+func resolveSearchColumns(cfg Config) []SearchColumn {
+	columns := ResolveWeightedSearchColumns(cfg.SearchColumns, cfg.AllowedFields)
+	if cfg.qualifyColumns {
+		for i := range columns {
+			columns[i].Column = qualifyColumn(columns[i].Column)
+		}
+	}
+	return columns
 }
 
 // ResolveSearchColumns maps configured search fields or trusted columns to SQL columns.
 func ResolveSearchColumns(configured []string, allowedFields map[string]string) []string {
+	resolved := ResolveWeightedSearchColumns(configured, allowedFields)
+	if len(resolved) == 0 {
+		return nil
+	}
+	out := make([]string, len(resolved))
+	for i, column := range resolved {
+		out[i] = column.Column
+	}
+	return out
+}
+
+// ResolveWeightedSearchColumns maps configured search fields or trusted
+// columns, optionally suffixed with a weight ("title:A"), to SQL columns.
+func ResolveWeightedSearchColumns(configured []string, allowedFields map[string]string) []SearchColumn {
 	if len(configured) == 0 {
 		return nil
 	}
@@ -56,10 +139,10 @@ func ResolveSearchColumns(configured []string, allowedFields map[string]string)
 	}
 
 	dedup := make(map[string]struct{}, len(configured))
-	out := make([]string, 0, len(configured))
+	out := make([]SearchColumn, 0, len(configured))
 
 	for _, raw := range configured {
-		candidate := strings.TrimSpace(raw)
+		candidate, weight := splitSearchWeight(strings.TrimSpace(raw))
 		if candidate == "" {
 			continue
 		}
@@ -79,12 +162,26 @@ func ResolveSearchColumns(configured []string, allowedFields map[string]string)
 			continue
 		}
 		dedup[key] = struct{}{}
-		out = append(out, resolved)
+		out = append(out, SearchColumn{Column: resolved, Weight: weight})
 	}
 
 	return out
 }
 
+// splitSearchWeight splits a trailing ":A" to ":D" weight off a configured
+// search column.
+func splitSearchWeight(raw string) (string, string) {
+	idx := strings.LastIndex(raw, ":")
+	if idx <= 0 || idx != len(raw)-2 {
+		return raw, ""
+	}
+	weight := strings.ToUpper(raw[idx+1:])
+	if weight < "A" || weight > "D" {
+		return raw, ""
+	}
+	return strings.TrimSpace(raw[:idx]), weight
+}
+
 func applySearchWhere(q *bun.SelectQuery, column, pattern string, cfg Config) *bun.SelectQuery {
 	if supportsILike(q) {
 		op := resolveSQLOperator("ilike", cfg)
@@ -0,0 +1,138 @@
+package querybun
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/uptrace/bun"
+)
+
+// searchWeightRanks mirrors the Postgres ts_rank defaults for weights A-D;
+// unweighted columns rank as D.
+var searchWeightRanks = map[string]string{"A": "1.0", "B": "0.4", "C": "0.2", "D": "0.1"}
+
+var searchIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// PostgresFullTextSearch matches _search with websearch_to_tsquery, so terms
+// support quotes, "or" and "-" exclusions, and ranks rows by ts_rank.
+//
+// The document is VectorColumn when set, which lets a GIN index serve the
+// match; otherwise it is built from the search columns with setweight, using
+// each column's weight.
+type PostgresFullTextSearch struct {
+	// Config is the text search configuration, such as "english". Defaults
+	// to "simple".
+	Config string
+	// VectorColumn is a stored or generated tsvector column.
+	VectorColumn string
+}
+
+// Name implements SearchStrategy.
+func (PostgresFullTextSearch) Name() string { return "postgres_fts" }
+
+// Match implements SearchStrategy.
+func (s PostgresFullTextSearch) Match(term string, columns []SearchColumn, _ Config) Criteria {
+	cond := fmt.Sprintf("%s @@ %s", s.document(columns), s.query())
+	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.Where(cond, term)
+	}
+}
+
+// Rank implements SearchRanker.
+func (s PostgresFullTextSearch) Rank(term string, columns []SearchColumn, _ Config) Criteria {
+	order := fmt.Sprintf("ts_rank(%s, %s) DESC", s.document(columns), s.query())
+	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.OrderExpr(order, term)
+	}
+}
+
+func (s PostgresFullTextSearch) config() string {
+	if searchIdentifier.MatchString(s.Config) {
+		return s.Config
+	}
+	return "simple"
+}
+
+func (s PostgresFullTextSearch) query() string {
+	return fmt.Sprintf("websearch_to_tsquery('%s', ?)", s.config())
+}
+
+func (s PostgresFullTextSearch) document(columns []SearchColumn) string {
+	if s.VectorColumn != "" {
+		return s.VectorColumn
+	}
+	parts := make([]string, len(columns))
+	for i, column := range columns {
+		vector := fmt.Sprintf("to_tsvector('%s', coalesce(%s::text, ''))", s.config(), column.Column)
+		if column.Weight != "" {
+			vector = fmt.Sprintf("setweight(%s, '%s')", vector, column.Weight)
+		}
+		parts[i] = vector
+	}
+	return "(" + strings.Join(parts, " || ") + ")"
+}
+
+// SQLiteFTS5Search matches _search against an FTS5 virtual table and ranks
+// rows by bm25. The table's rowid must equal KeyColumn of the model table,
+// as with an external content table (content_rowid), and its columns must
+// be declared in the same order as the search columns, whose weights feed
+// bm25.
+//
+// Each word of the term is quoted, so FTS5 query syntax in user input is
+// matched literally and every word must appear.
+type SQLiteFTS5Search struct {
+	// Table is the FTS5 virtual table.
+	Table string
+	// KeyColumn is the model column matching the FTS rowid. Defaults to
+	// rowid.
+	KeyColumn string
+}
+
+// Name implements SearchStrategy.
+func (SQLiteFTS5Search) Name() string { return "sqlite_fts5" }
+
+// Match implements SearchStrategy.
+func (s SQLiteFTS5Search) Match(term string, _ []SearchColumn, _ Config) Criteria {
+	cond := fmt.Sprintf("?TableAlias.%s IN (SELECT rowid FROM %s WHERE %s MATCH ?)", s.keyColumn(), s.Table, s.Table)
+	match := fts5MatchQuery(term)
+	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.Where(cond, match)
+	}
+}
+
+// Rank implements SearchRanker.
+func (s SQLiteFTS5Search) Rank(term string, columns []SearchColumn, _ Config) Criteria {
+	weights := make([]string, len(columns))
+	for i, column := range columns {
+		weight, ok := searchWeightRanks[column.Weight]
+		if !ok {
+			weight = searchWeightRanks["D"]
+		}
+		weights[i] = weight
+	}
+	bm25 := s.Table
+	if len(weights) > 0 {
+		bm25 += ", " + strings.Join(weights, ", ")
+	}
+	order := fmt.Sprintf("(SELECT bm25(%s) FROM %s WHERE %s.rowid = ?TableAlias.%s AND %s MATCH ?) ASC", bm25, s.Table, s.Table, s.keyColumn(), s.Table)
+	match := fts5MatchQuery(term)
+	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.OrderExpr(order, match)
+	}
+}
+
+func (s SQLiteFTS5Search) keyColumn() string {
+	if s.KeyColumn != "" {
+		return s.KeyColumn
+	}
+	return "rowid"
+}
+
+func fts5MatchQuery(term string) string {
+	words := strings.Fields(term)
+	for i, word := range words {
+		words[i] = `"` + strings.ReplaceAll(word, `"`, `""`) + `"`
+	}
+	return strings.Join(words, " ")
+}
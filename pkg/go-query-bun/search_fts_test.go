@@ -0,0 +1,82 @@
+package querybun
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func renderFilterUserQuery(t *testing.T, criteria ...[]Criteria) string {
+	t.Helper()
+
+	db := setupQueryBunDB(t)
+	query := db.NewSelect().Model((*filterUser)(nil))
+	for _, group := range criteria {
+		for _, criterion := range group {
+			query = criterion(query)
+		}
+	}
+	return query.String()
+}
+
+func TestResolveWeightedSearchColumns(t *testing.T) {
+	columns := ResolveWeightedSearchColumns([]string{"name:A", "status:b", "age", "name:C", "missing:A"}, filterAllowedFields())
+	assert.Equal(t, []SearchColumn{
+		{Column: "name", Weight: "A"},
+		{Column: "status", Weight: "B"},
+		{Column: "age"},
+	}, columns)
+	assert.Equal(t, []string{"name", "status", "age"}, ResolveSearchColumns([]string{"name:A", "status:b", "age"}, filterAllowedFields()))
+}
+
+func TestBuildQueryPlan_PostgresFullTextSearch(t *testing.T) {
+	cfg := Config{
+		AllowedFields:  filterAllowedFields(),
+		SearchColumns:  []string{"name:A", "status"},
+		SearchStrategy: PostgresFullTextSearch{Config: "english"},
+	}
+	plan, err := BuildQueryPlan(ListOptions{Search: "ali -bob"}, cfg)
+	require.NoError(t, err)
+	assert.Equal(t, "postgres_fts", plan.Metadata.SearchStrategy)
+
+	doc := `(setweight(to_tsvector('english', coalesce(name::text, '')), 'A') || to_tsvector('english', coalesce(status::text, '')))`
+	sql := renderFilterUserQuery(t, plan.Search, plan.Order)
+	assert.Contains(t, sql, "WHERE ("+doc+" @@ websearch_to_tsquery('english', 'ali -bob'))")
+	assert.Contains(t, sql, "ORDER BY ts_rank("+doc+", websearch_to_tsquery('english', 'ali -bob')) DESC")
+
+	plan, err = BuildQueryPlan(ListOptions{Search: "ali", Order: "age desc"}, Config{
+		AllowedFields:  filterAllowedFields(),
+		SearchColumns:  []string{"name"},
+		SearchStrategy: PostgresFullTextSearch{Config: "bad'config", VectorColumn: "search_vector"},
+	})
+	require.NoError(t, err)
+	sql = renderFilterUserQuery(t, plan.Search, plan.Order)
+	assert.Contains(t, sql, "WHERE (search_vector @@ websearch_to_tsquery('simple', 'ali'))")
+	assert.NotContains(t, sql, "ts_rank", "an explicit order replaces the rank order")
+}
+
+func TestBuildQueryPlan_SQLiteFTS5Search(t *testing.T) {
+	plan, err := BuildQueryPlan(ListOptions{Search: `ali "b`}, Config{
+		AllowedFields:  filterAllowedFields(),
+		SearchColumns:  []string{"name:A", "status"},
+		SearchStrategy: SQLiteFTS5Search{Table: "filter_users_fts", KeyColumn: "id"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "sqlite_fts5", plan.Metadata.SearchStrategy)
+
+	sql := renderFilterUserQuery(t, plan.Search, plan.Order)
+	assert.Contains(t, sql, `WHERE ("u".id IN (SELECT rowid FROM filter_users_fts WHERE filter_users_fts MATCH '"ali" """b"'))`)
+	assert.Contains(t, sql, `ORDER BY (SELECT bm25(filter_users_fts, 1.0, 0.1) FROM filter_users_fts WHERE filter_users_fts.rowid = "u".id AND filter_users_fts MATCH '"ali" """b"') ASC`)
+}
+
+func TestBuildQueryPlan_LikeSearchStrategyName(t *testing.T) {
+	plan, err := BuildQueryPlan(ListOptions{Search: "ali"}, Config{AllowedFields: filterAllowedFields(), SearchColumns: []string{"name:A"}})
+	require.NoError(t, err)
+	assert.Equal(t, "like", plan.Metadata.SearchStrategy)
+	assert.Empty(t, plan.Order)
+
+	plan, err = BuildQueryPlan(ListOptions{Search: "ali"}, Config{AllowedFields: filterAllowedFields()})
+	require.NoError(t, err)
+	assert.Empty(t, plan.Metadata.SearchStrategy, "no columns, no search")
+}
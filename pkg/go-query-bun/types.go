@@ -43,6 +43,7 @@ const (
 	UnsupportedOperator        UnsupportedReason = "unsupported_operator"
 	UnsupportedEmptyValue      UnsupportedReason = "empty_value"
 	UnsupportedValueShape      UnsupportedReason = "unsupported_value_shape"
+	UnsupportedArity           UnsupportedReason = "invalid_arity"
 )
 
 // UnsupportedPredicate records a dropped or rejected query predicate.
@@ -0,0 +1,83 @@
+package policy
+
+import (
+	"slices"
+
+	crud "github.com/goliatone/go-crud"
+)
+
+// Builder assembles a Rule in Go:
+//
+//	policy.Allow("editors-own-articles").
+//		Roles("editor").
+//		Resources("article").
+//		Operations(crud.OpUpdate).
+//		Where("tenant_id", "=", "{{actor.tenant_id}}").
+//		Where("owner_id", "=", "{{actor.id}}").
+//		Rule()
+type Builder struct {
+	rule Rule
+}
+
+// Allow starts an allowing rule.
+func Allow(name string) *Builder {
+	return &Builder{rule: Rule{Name: name, Effect: EffectAllow}}
+}
+
+// Deny starts a denying rule.
+func Deny(name string) *Builder {
+	return &Builder{rule: Rule{Name: name, Effect: EffectDeny}}
+}
+
+// Roles adds the roles the rule matches.
+func (b *Builder) Roles(roles ...string) *Builder {
+	b.rule.Roles = append(b.rule.Roles, roles...)
+	return b
+}
+
+// Resources adds the resources the rule matches.
+func (b *Builder) Resources(resources ...string) *Builder {
+	b.rule.Resources = append(b.rule.Resources, resources...)
+	return b
+}
+
+// Operations adds the operations the rule matches.
+func (b *Builder) Operations(ops ...crud.CrudOperation) *Builder {
+	b.rule.Operations = append(b.rule.Operations, ops...)
+	return b
+}
+
+// When adds an attribute condition.
+func (b *Builder) When(attribute, operator string, values ...string) *Builder {
+	b.rule.When = append(b.rule.When, Condition{Attribute: attribute, Operator: operator, Values: values})
+	return b
+}
+
+// Where adds a row filter.
+func (b *Builder) Where(column, operator string, values ...string) *Builder {
+	b.rule.Where = append(b.rule.Where, Filter{Column: column, Operator: operator, Values: values})
+	return b
+}
+
+// Fields sets the field policy of allowed requests.
+func (b *Builder) Fields(fields Fields) *Builder {
+	b.rule.Fields = &fields
+	return b
+}
+
+// Bypass skips scope filtering for allowed requests.
+func (b *Builder) Bypass() *Builder {
+	b.rule.Bypass = true
+	return b
+}
+
+// Rule returns a copy of the assembled rule.
+func (b *Builder) Rule() Rule {
+	rule := b.rule
+	rule.Roles = slices.Clone(rule.Roles)
+	rule.Resources = slices.Clone(rule.Resources)
+	rule.Operations = slices.Clone(rule.Operations)
+	rule.When = slices.Clone(rule.When)
+	rule.Where = slices.Clone(rule.Where)
+	return rule
+}
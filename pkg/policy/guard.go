@@ -0,0 +1,49 @@
+package policy
+
+import (
+	"net/http"
+	"reflect"
+
+	crud "github.com/goliatone/go-crud"
+	goerrors "github.com/goliatone/go-errors"
+)
+
+// DeniedError returns the error reported for a denied decision: a 403
+// authorization error naming the deciding rule, if any.
+func DeniedError(decision Decision) error {
+	message := "access denied by policy"
+	if decision.Rule != "" {
+		message = "access denied by policy rule " + decision.Rule
+	}
+	return goerrors.New(message, goerrors.CategoryAuthz).
+		WithCode(http.StatusForbidden).
+		WithTextCode("POLICY_DENIED").
+		WithMetadata(map[string]any{"rule": decision.Rule})
+}
+
+// ScopeGuard adapts engine to a scope guard for the resource of T. The actor
+// is read from the request context; allowed requests are scoped by the Where
+// filters of the deciding rule and denied requests fail with DeniedError.
+func ScopeGuard[T any](engine *Engine) crud.ScopeGuardFunc[T] {
+	resource, _ := crud.GetResourceName(reflect.TypeFor[T]())
+	return func(ctx crud.Context, op crud.CrudOperation) (crud.ActorContext, crud.ScopeFilter, error) {
+		actor := crud.ActorFromContext(ctx.UserContext())
+		decision := engine.Explain(Request{Actor: actor, Resource: resource, Operation: op})
+		if !decision.Allowed {
+			return actor, crud.ScopeFilter{}, DeniedError(decision)
+		}
+		return actor, decision.Scope, nil
+	}
+}
+
+// FieldPolicyProvider adapts engine to a field policy provider returning the
+// Fields of the deciding rule. Denied requests fail with DeniedError.
+func FieldPolicyProvider[T any](engine *Engine) crud.FieldPolicyProvider[T] {
+	return func(req crud.FieldPolicyRequest[T]) (crud.FieldPolicy, error) {
+		decision := engine.Explain(Request{Actor: req.Actor, Resource: req.Resource, Operation: req.Operation})
+		if !decision.Allowed {
+			return crud.FieldPolicy{}, DeniedError(decision)
+		}
+		return decision.Fields, nil
+	}
+}
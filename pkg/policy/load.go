@@ -0,0 +1,42 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Document is the file form of a rule set:
+//
+//	rules:
+//	  - name: editors-own-articles
+//	    roles: [editor]
+//	    resources: [article]
+//	    operations: [update]
+//	    where:
+//	      - column: tenant_id
+//	        values: ["{{actor.tenant_id}}"]
+//	      - column: owner_id
+//	        values: ["{{actor.id}}"]
+type Document struct {
+	Rules []Rule `json:"rules" yaml:"rules"`
+}
+
+// FromJSON compiles the rules of a JSON document.
+func FromJSON(data []byte) (*Engine, error) {
+	var doc Document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("policy: decode json: %w", err)
+	}
+	return New(doc.Rules...)
+}
+
+// FromYAML compiles the rules of a YAML document.
+func FromYAML(data []byte) (*Engine, error) {
+	var doc Document
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("policy: decode yaml: %w", err)
+	}
+	return New(doc.Rules...)
+}
@@ -0,0 +1,475 @@
+// Package policy compiles declarative RBAC/ABAC rules into the scope guards
+// and field policy providers used by go-crud controllers and services.
+//
+// Rules are evaluated in order and the first rule that applies decides the
+// request; when no rule applies the request is denied. A rule applies when
+// its resources, operations, roles and When conditions match the request and
+// every template in its Where filters resolves. Templates such as
+// {{actor.tenant_id}} read the actor attributes listed in Attributes.
+package policy
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	crud "github.com/goliatone/go-crud"
+)
+
+// Effect is the outcome of a rule that applies.
+type Effect string
+
+const (
+	// EffectAllow grants the request, scoped by the rule's Where filters. It
+	// is the default.
+	EffectAllow Effect = "allow"
+	// EffectDeny refuses the request.
+	EffectDeny Effect = "deny"
+)
+
+// Any matches every role, resource or operation.
+const Any = "*"
+
+// Condition compares an actor attribute with Values. Operator is one of eq
+// (the default), ne, in, not_in, exists and not_exists; eq with several
+// values behaves as in. Values may be templates.
+type Condition struct {
+	Attribute string   `json:"attribute" yaml:"attribute"`
+	Operator  string   `json:"operator,omitempty" yaml:"operator,omitempty"`
+	Values    []string `json:"values,omitempty" yaml:"values,omitempty"`
+}
+
+// Filter restricts the rows a rule grants access to. Operator is a scope
+// filter operator (=, !=, <, <=, >, >=, IN, NOT IN) and defaults to =.
+// Values may be templates.
+type Filter struct {
+	Column   string   `json:"column" yaml:"column"`
+	Operator string   `json:"operator,omitempty" yaml:"operator,omitempty"`
+	Values   []string `json:"values" yaml:"values"`
+}
+
+// Fields maps onto the field policy of the requests a rule allows.
+type Fields struct {
+	Allow         []string `json:"allow,omitempty" yaml:"allow,omitempty"`
+	Deny          []string `json:"deny,omitempty" yaml:"deny,omitempty"`
+	WritableAllow []string `json:"writable_allow,omitempty" yaml:"writable_allow,omitempty"`
+	WritableDeny  []string `json:"writable_deny,omitempty" yaml:"writable_deny,omitempty"`
+	// WriteMode is "reject" (the default) or "strip".
+	WriteMode crud.WriteFieldMode `json:"write_mode,omitempty" yaml:"write_mode,omitempty"`
+}
+
+// Rule grants or denies the actors holding one of Roles an operation on a
+// resource.
+type Rule struct {
+	// Name identifies the rule in decisions, scope labels and audit logs.
+	Name   string `json:"name" yaml:"name"`
+	Effect Effect `json:"effect,omitempty" yaml:"effect,omitempty"`
+	// Roles matches the actor role or its role on the resource
+	// (ActorContext.ResourceRoles), case-sensitively. Empty or "*" matches
+	// every actor.
+	Roles []string `json:"roles,omitempty" yaml:"roles,omitempty"`
+	// Resources lists resource names such as "article", matched
+	// case-sensitively. Empty or "*" matches every resource.
+	Resources []string `json:"resources,omitempty" yaml:"resources,omitempty"`
+	// Operations lists CRUD operations. "update" also matches the
+	// "update:batch" and "update:filter" variants and "patch"; "create"
+	// matches "import". Allow rules match upserts when they list "upsert" or
+	// both "create" and "update"; deny rules when they list any of them.
+	// Empty or "*" matches every operation.
+	Operations []crud.CrudOperation `json:"operations,omitempty" yaml:"operations,omitempty"`
+	// When holds attribute conditions that must all hold.
+	When []Condition `json:"when,omitempty" yaml:"when,omitempty"`
+	// Where holds the row filters of allowed requests.
+	Where []Filter `json:"where,omitempty" yaml:"where,omitempty"`
+	// Fields sets the field policy of allowed requests.
+	Fields *Fields `json:"fields,omitempty" yaml:"fields,omitempty"`
+	// Bypass skips scope filtering for allowed requests.
+	Bypass bool `json:"bypass,omitempty" yaml:"bypass,omitempty"`
+}
+
+// Attributes lists the actor attributes rules can read. Metadata entries are
+// read as actor.metadata.<key>.
+var Attributes = []string{
+	"actor.id",
+	"actor.subject",
+	"actor.role",
+	"actor.resource_role",
+	"actor.tenant_id",
+	"actor.organization_id",
+	"actor.impersonator_id",
+	"actor.impersonated",
+}
+
+var (
+	templatePattern = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_.]+)\s*\}\}`)
+
+	conditionOperators = []string{"eq", "ne", "in", "not_in", "exists", "not_exists"}
+	filterOperators    = []string{"=", "!=", "<", "<=", ">", ">=", "IN", "NOT IN"}
+)
+
+// Engine evaluates a compiled rule set. It is safe for concurrent use.
+type Engine struct {
+	rules []Rule
+}
+
+// New validates and compiles rules.
+func New(rules ...Rule) (*Engine, error) {
+	seen := make(map[string]struct{}, len(rules))
+	compiled := make([]Rule, len(rules))
+	for i, rule := range rules {
+		rule, err := compileRule(rule)
+		if err != nil {
+			return nil, err
+		}
+		if _, dup := seen[rule.Name]; dup {
+			return nil, fmt.Errorf("policy: duplicate rule %q", rule.Name)
+		}
+		seen[rule.Name] = struct{}{}
+		compiled[i] = rule
+	}
+	return &Engine{rules: compiled}, nil
+}
+
+// Rules returns the compiled rules in evaluation order.
+func (e *Engine) Rules() []Rule {
+	if e == nil {
+		return nil
+	}
+	return slices.Clone(e.rules)
+}
+
+func compileRule(rule Rule) (Rule, error) {
+	rule.Name = strings.TrimSpace(rule.Name)
+	if rule.Name == "" {
+		return rule, fmt.Errorf("policy: rule name is required")
+	}
+	fail := func(format string, args ...any) (Rule, error) {
+		return rule, fmt.Errorf("policy: rule %q: %s", rule.Name, fmt.Sprintf(format, args...))
+	}
+
+	switch rule.Effect {
+	case "":
+		rule.Effect = EffectAllow
+	case EffectAllow, EffectDeny:
+	default:
+		return fail("unknown effect %q", rule.Effect)
+	}
+
+	rule.Roles = trimNames(rule.Roles)
+	rule.Resources = trimNames(rule.Resources)
+
+	rule.When = slices.Clone(rule.When)
+	for i, cond := range rule.When {
+		cond.Attribute = strings.TrimSpace(cond.Attribute)
+		cond.Operator = strings.ToLower(strings.TrimSpace(cond.Operator))
+		if cond.Operator == "" {
+			cond.Operator = "eq"
+		}
+		if !knownAttribute(cond.Attribute) {
+			return fail("unknown attribute %q", cond.Attribute)
+		}
+		if !slices.Contains(conditionOperators, cond.Operator) {
+			return fail("unknown condition operator %q", cond.Operator)
+		}
+		if len(cond.Values) == 0 && cond.Operator != "exists" && cond.Operator != "not_exists" {
+			return fail("condition on %q needs values", cond.Attribute)
+		}
+		if err := checkTemplates(cond.Values); err != nil {
+			return fail("%v", err)
+		}
+		rule.When[i] = cond
+	}
+
+	rule.Where = slices.Clone(rule.Where)
+	for i, filter := range rule.Where {
+		filter.Column = strings.TrimSpace(filter.Column)
+		filter.Operator = strings.ToUpper(strings.TrimSpace(filter.Operator))
+		if filter.Operator == "" {
+			filter.Operator = "="
+		}
+		if filter.Column == "" {
+			return fail("filter column is required")
+		}
+		if !slices.Contains(filterOperators, filter.Operator) {
+			return fail("unknown filter operator %q", filter.Operator)
+		}
+		if len(filter.Values) == 0 {
+			return fail("filter on %q needs values", filter.Column)
+		}
+		if err := checkTemplates(filter.Values); err != nil {
+			return fail("%v", err)
+		}
+		rule.Where[i] = filter
+	}
+
+	if rule.Fields != nil {
+		switch rule.Fields.WriteMode {
+		case "", crud.WriteFieldReject, crud.WriteFieldStrip:
+		default:
+			return fail("unknown write mode %q", rule.Fields.WriteMode)
+		}
+	}
+	return rule, nil
+}
+
+func trimNames(names []string) []string {
+	if names == nil {
+		return nil
+	}
+	trimmed := make([]string, len(names))
+	for i, name := range names {
+		trimmed[i] = strings.TrimSpace(name)
+	}
+	return trimmed
+}
+
+func knownAttribute(path string) bool {
+	if key, ok := strings.CutPrefix(path, "actor.metadata."); ok {
+		return key != ""
+	}
+	return slices.Contains(Attributes, path)
+}
+
+func checkTemplates(values []string) error {
+	for _, value := range values {
+		for _, match := range templatePattern.FindAllStringSubmatch(value, -1) {
+			if !knownAttribute(match[1]) {
+				return fmt.Errorf("unknown template attribute %q", match[1])
+			}
+		}
+	}
+	return nil
+}
+
+// Request is the input of one evaluation.
+type Request struct {
+	Actor     crud.ActorContext
+	Resource  string
+	Operation crud.CrudOperation
+}
+
+// Step records why one rule did or did not apply.
+type Step struct {
+	Rule    string
+	Applied bool
+	Reason  string
+}
+
+// Decision is the outcome of evaluating a request.
+type Decision struct {
+	Allowed bool
+	// Rule names the rule that decided the request; empty when none applied.
+	Rule string
+	// Scope holds the resolved Where filters of an allowing rule, labelled
+	// with the rule name.
+	Scope crud.ScopeFilter
+	// Fields holds the field policy of an allowing rule.
+	Fields crud.FieldPolicy
+	// Steps traces the rules evaluated, in order, up to the deciding one.
+	Steps []Step
+}
+
+// Explain evaluates req without side effects and reports which rule decided
+// it and why the rules before it did not apply. Use it for audits and dry
+// runs.
+func (e *Engine) Explain(req Request) Decision {
+	decision := Decision{}
+	if e == nil {
+		decision.Steps = append(decision.Steps, Step{Reason: "no policy engine"})
+		return decision
+	}
+	attrs := actorAttributes(req.Actor, req.Resource)
+	for _, rule := range e.rules {
+		scope, reason := evaluateRule(rule, req, attrs)
+		step := Step{Rule: rule.Name, Applied: reason == "", Reason: reason}
+		if !step.Applied {
+			decision.Steps = append(decision.Steps, step)
+			continue
+		}
+		decision.Rule = rule.Name
+		decision.Allowed = rule.Effect == EffectAllow
+		if decision.Allowed {
+			step.Reason = "allowed"
+			decision.Scope = scope
+			decision.Fields = fieldPolicy(rule)
+		} else {
+			step.Reason = "denied"
+		}
+		decision.Steps = append(decision.Steps, step)
+		return decision
+	}
+	decision.Steps = append(decision.Steps, Step{Reason: "no rule applied"})
+	return decision
+}
+
+// evaluateRule returns the resolved scope of rule, or the reason it does not
+// apply to req.
+func evaluateRule(rule Rule, req Request, attrs map[string]string) (crud.ScopeFilter, string) {
+	scope := crud.ScopeFilter{}
+	if !matchesName(rule.Resources, req.Resource) {
+		return scope, fmt.Sprintf("resource %q not listed", req.Resource)
+	}
+	if !matchesOperation(rule.Operations, req.Operation, rule.Effect) {
+		return scope, fmt.Sprintf("operation %q not listed", req.Operation)
+	}
+	if !matchesRole(rule.Roles, attrs) {
+		return scope, "actor role not listed"
+	}
+	for _, cond := range rule.When {
+		if ok, reason := evaluateCondition(cond, attrs); !ok {
+			return scope, reason
+		}
+	}
+	if rule.Effect == EffectDeny {
+		return scope, ""
+	}
+
+	scope.Bypass = rule.Bypass
+	scope.Labels = map[string]string{"policy_rule": rule.Name}
+	for _, filter := range rule.Where {
+		values := make([]string, len(filter.Values))
+		for i, value := range filter.Values {
+			resolved, missing := resolveTemplate(value, attrs)
+			if missing != "" {
+				return crud.ScopeFilter{}, fmt.Sprintf("%s is empty for filter on %q", missing, filter.Column)
+			}
+			values[i] = resolved
+		}
+		scope.AddColumnFilter(filter.Column, filter.Operator, values...)
+	}
+	return scope, ""
+}
+
+func evaluateCondition(cond Condition, attrs map[string]string) (bool, string) {
+	value := attrs[cond.Attribute]
+	switch cond.Operator {
+	case "exists":
+		return value != "", fmt.Sprintf("%s is empty", cond.Attribute)
+	case "not_exists":
+		return value == "", fmt.Sprintf("%s is set", cond.Attribute)
+	}
+
+	expected := make([]string, 0, len(cond.Values))
+	for _, raw := range cond.Values {
+		resolved, missing := resolveTemplate(raw, attrs)
+		if missing != "" {
+			return false, fmt.Sprintf("%s is empty for condition on %s", missing, cond.Attribute)
+		}
+		expected = append(expected, resolved)
+	}
+	found := value != "" && slices.Contains(expected, value)
+	switch cond.Operator {
+	case "ne", "not_in":
+		return !found, fmt.Sprintf("%s is %q", cond.Attribute, value)
+	default:
+		return found, fmt.Sprintf("%s is %q, want %s", cond.Attribute, value, strings.Join(expected, " or "))
+	}
+}
+
+// resolveTemplate replaces the templates of value. It returns the first
+// template attribute that resolved empty, if any.
+func resolveTemplate(value string, attrs map[string]string) (string, string) {
+	missing := ""
+	resolved := templatePattern.ReplaceAllStringFunc(value, func(match string) string {
+		name := templatePattern.FindStringSubmatch(match)[1]
+		attr := attrs[name]
+		if attr == "" && missing == "" {
+			missing = name
+		}
+		return attr
+	})
+	return resolved, missing
+}
+
+func actorAttributes(actor crud.ActorContext, resource string) map[string]string {
+	attrs := map[string]string{
+		"actor.id":              actor.ActorID,
+		"actor.subject":         actor.Subject,
+		"actor.role":            actor.Role,
+		"actor.resource_role":   actor.ResourceRoles[resource],
+		"actor.tenant_id":       actor.TenantID,
+		"actor.organization_id": actor.OrganizationID,
+		"actor.impersonator_id": actor.ImpersonatorID,
+		"actor.impersonated":    strconv.FormatBool(actor.IsImpersonated),
+	}
+	for key, value := range actor.Metadata {
+		if value != nil {
+			attrs["actor.metadata."+key] = fmt.Sprint(value)
+		}
+	}
+	return attrs
+}
+
+func matchesName(names []string, name string) bool {
+	return len(names) == 0 || slices.Contains(names, Any) || slices.Contains(names, name)
+}
+
+// impliedOperations lists, for operations a rule does not name, the
+// operations that cover them: patch is an update, import creates and upsert
+// may create or update.
+var impliedOperations = map[crud.CrudOperation][]crud.CrudOperation{
+	crud.OpPatch:  {crud.OpUpdate},
+	crud.OpImport: {crud.OpCreate},
+	crud.OpUpsert: {crud.OpCreate, crud.OpUpdate},
+}
+
+// matchesOperation reports whether ops covers op. An operation also matches
+// its ":" variants. Operations in impliedOperations match allow rules listing
+// every operation that covers them, and deny rules listing any of them.
+func matchesOperation(ops []crud.CrudOperation, op crud.CrudOperation, effect Effect) bool {
+	if len(ops) == 0 {
+		return true
+	}
+	listed := func(target crud.CrudOperation) bool {
+		return slices.ContainsFunc(ops, func(candidate crud.CrudOperation) bool {
+			return candidate == Any || candidate == target || strings.HasPrefix(string(target), string(candidate)+":")
+		})
+	}
+	if listed(op) {
+		return true
+	}
+	base, _, _ := strings.Cut(string(op), ":")
+	implied := impliedOperations[crud.CrudOperation(base)]
+	if len(implied) == 0 {
+		return false
+	}
+	if effect == EffectDeny {
+		return slices.ContainsFunc(implied, listed)
+	}
+	for _, covering := range implied {
+		if !listed(covering) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchesRole(roles []string, attrs map[string]string) bool {
+	if len(roles) == 0 || slices.Contains(roles, Any) {
+		return true
+	}
+	for _, held := range []string{attrs["actor.role"], attrs["actor.resource_role"]} {
+		if held != "" && slices.Contains(roles, held) {
+			return true
+		}
+	}
+	return false
+}
+
+func fieldPolicy(rule Rule) crud.FieldPolicy {
+	policy := crud.FieldPolicy{
+		Name:   rule.Name,
+		Labels: map[string]string{"policy_rule": rule.Name},
+	}
+	if rule.Fields == nil {
+		return policy
+	}
+	policy.Allow = slices.Clone(rule.Fields.Allow)
+	policy.Deny = slices.Clone(rule.Fields.Deny)
+	policy.WritableAllow = slices.Clone(rule.Fields.WritableAllow)
+	policy.WritableDeny = slices.Clone(rule.Fields.WritableDeny)
+	policy.WriteMode = rule.Fields.WriteMode
+	return policy
+}
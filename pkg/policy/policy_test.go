@@ -0,0 +1,190 @@
+package policy
+
+import (
+	"context"
+	"testing"
+
+	crud "github.com/goliatone/go-crud"
+	goerrors "github.com/goliatone/go-errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type article struct {
+	ID       string `json:"id"`
+	TenantID string `json:"tenant_id"`
+	OwnerID  string `json:"owner_id"`
+}
+
+type requestContext struct {
+	crud.Context
+	ctx context.Context
+}
+
+func (r requestContext) UserContext() context.Context { return r.ctx }
+
+func articleEngine(t *testing.T) *Engine {
+	t.Helper()
+	engine, err := New(
+		Deny("suspended").When("actor.metadata.suspended", "eq", "true").Rule(),
+		Allow("admins").Roles("admin").Bypass().Rule(),
+		Allow("editors-own-articles").
+			Roles("editor").
+			Resources("article").
+			Operations(crud.OpUpdate, crud.OpRead).
+			Where("tenant_id", "=", "{{actor.tenant_id}}").
+			Where("owner_id", "=", "{{actor.id}}").
+			Fields(Fields{WritableDeny: []string{"owner_id"}}).
+			Rule(),
+	)
+	require.NoError(t, err)
+	return engine
+}
+
+func TestEngine_Explain(t *testing.T) {
+	engine := articleEngine(t)
+	editor := crud.ActorContext{ActorID: "u1", Role: "editor", TenantID: "t1"}
+
+	decision := engine.Explain(Request{Actor: editor, Resource: "article", Operation: crud.OpUpdateBatch})
+	require.True(t, decision.Allowed, "update also covers update:batch")
+	assert.Equal(t, "editors-own-articles", decision.Rule)
+	assert.Equal(t, []crud.ScopeColumnFilter{
+		{Column: "tenant_id", Operator: "=", Values: []string{"t1"}},
+		{Column: "owner_id", Operator: "=", Values: []string{"u1"}},
+	}, decision.Scope.ColumnFilters)
+	assert.Equal(t, "editors-own-articles", decision.Scope.Labels["policy_rule"])
+	assert.Equal(t, []string{"owner_id"}, decision.Fields.WritableDeny)
+	assert.Equal(t, []Step{
+		{Rule: "suspended", Reason: `actor.metadata.suspended is "", want true`},
+		{Rule: "admins", Reason: "actor role not listed"},
+		{Rule: "editors-own-articles", Applied: true, Reason: "allowed"},
+	}, decision.Steps)
+
+	decision = engine.Explain(Request{Actor: editor, Resource: "article", Operation: crud.OpDelete})
+	assert.False(t, decision.Allowed)
+	assert.Empty(t, decision.Rule)
+	assert.Equal(t, `operation "delete" not listed`, decision.Steps[2].Reason)
+
+	noTenant := crud.ActorContext{ActorID: "u1", Role: "editor"}
+	decision = engine.Explain(Request{Actor: noTenant, Resource: "article", Operation: crud.OpRead})
+	assert.False(t, decision.Allowed, "rules whose templates resolve empty do not apply")
+	assert.Equal(t, `actor.tenant_id is empty for filter on "tenant_id"`, decision.Steps[2].Reason)
+
+	resourceEditor := crud.ActorContext{ActorID: "u2", TenantID: "t1", ResourceRoles: map[string]string{"article": "editor"}}
+	assert.True(t, engine.Explain(Request{Actor: resourceEditor, Resource: "article", Operation: crud.OpRead}).Allowed)
+
+	suspended := crud.ActorContext{Role: "admin", Metadata: map[string]any{"suspended": true}}
+	decision = engine.Explain(Request{Actor: suspended, Resource: "article", Operation: crud.OpRead})
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, "suspended", decision.Rule)
+
+	decision = engine.Explain(Request{Actor: crud.ActorContext{Role: "admin"}, Resource: "invoice", Operation: crud.OpDelete})
+	assert.True(t, decision.Allowed)
+	assert.True(t, decision.Scope.Bypass)
+}
+
+func TestEngine_OperationVariantsAndCase(t *testing.T) {
+	engine, err := New(
+		Deny("no-imports").Roles("intern").Operations(crud.OpCreate).Rule(),
+		Allow("editors").Roles("editor").Resources("article").Operations(crud.OpUpdate).Rule(),
+		Allow("writers").Roles("writer", "intern").Resources(" article ").Operations(crud.OpCreate, crud.OpUpdate).Rule(),
+	)
+	require.NoError(t, err)
+	allowed := func(role, resource string, op crud.CrudOperation) bool {
+		return engine.Explain(Request{Actor: crud.ActorContext{Role: role}, Resource: resource, Operation: op}).Allowed
+	}
+
+	assert.True(t, allowed("editor", "article", crud.OpPatch), "update covers patch")
+	assert.False(t, allowed("editor", "article", crud.OpUpsert), "upserts may create")
+	assert.False(t, allowed("editor", "article", crud.OpImport))
+	assert.True(t, allowed("writer", "article", crud.OpUpsert), "create and update cover upsert")
+	assert.True(t, allowed("writer", "article", crud.OpUpsertBatch))
+	assert.True(t, allowed("writer", "article", crud.OpImport), "create covers import")
+	assert.False(t, allowed("intern", "article", crud.OpImport), "deny rules on create cover import")
+	assert.False(t, allowed("intern", "article", crud.OpUpsert), "deny rules on create cover upsert")
+	assert.True(t, allowed("intern", "article", crud.OpUpdate))
+
+	assert.False(t, allowed("editor", "Article", crud.OpUpdate), "resources are case-sensitive")
+	assert.False(t, allowed("Editor", "article", crud.OpUpdate), "roles are case-sensitive")
+}
+
+func TestNew_RejectsInvalidRules(t *testing.T) {
+	cases := map[string]Rule{
+		`policy: rule name is required`:                            {},
+		`policy: rule "r": unknown effect "maybe"`:                 {Name: "r", Effect: "maybe"},
+		`policy: rule "r": unknown attribute "actor.email"`:        {Name: "r", When: []Condition{{Attribute: "actor.email", Values: []string{"x"}}}},
+		`policy: rule "r": unknown filter operator "LIKE"`:         {Name: "r", Where: []Filter{{Column: "name", Operator: "like", Values: []string{"x"}}}},
+		`policy: rule "r": unknown template attribute "user.id"`:   {Name: "r", Where: []Filter{{Column: "owner_id", Values: []string{"{{user.id}}"}}}},
+		`policy: rule "r": condition on "actor.role" needs values`: {Name: "r", When: []Condition{{Attribute: "actor.role"}}},
+	}
+	for want, rule := range cases {
+		_, err := New(rule)
+		assert.EqualError(t, err, want)
+	}
+
+	_, err := New(Rule{Name: "r"}, Rule{Name: "r"})
+	assert.EqualError(t, err, `policy: duplicate rule "r"`)
+}
+
+func TestFromYAMLAndJSON(t *testing.T) {
+	yamlEngine, err := FromYAML([]byte(`
+rules:
+  - name: editors-own-articles
+    roles: [editor]
+    resources: [article]
+    operations: [update]
+    where:
+      - column: tenant_id
+        values: ["{{actor.tenant_id}}"]
+    fields:
+      writable_deny: [owner_id]
+      write_mode: strip
+`))
+	require.NoError(t, err)
+
+	jsonEngine, err := FromJSON([]byte(`{"rules": [{
+		"name": "editors-own-articles",
+		"roles": ["editor"],
+		"resources": ["article"],
+		"operations": ["update"],
+		"where": [{"column": "tenant_id", "values": ["{{actor.tenant_id}}"]}],
+		"fields": {"writable_deny": ["owner_id"], "write_mode": "strip"}
+	}]}`))
+	require.NoError(t, err)
+	assert.Equal(t, yamlEngine.Rules(), jsonEngine.Rules())
+
+	decision := yamlEngine.Explain(Request{
+		Actor:     crud.ActorContext{Role: "editor", TenantID: "t1"},
+		Resource:  "article",
+		Operation: crud.OpUpdate,
+	})
+	require.True(t, decision.Allowed)
+	assert.Equal(t, crud.WriteFieldStrip, decision.Fields.WriteMode)
+
+	_, err = FromYAML([]byte("rules: [{name: r, effect: sometimes}]"))
+	assert.EqualError(t, err, `policy: rule "r": unknown effect "sometimes"`)
+}
+
+func TestScopeGuardAndFieldPolicyProvider(t *testing.T) {
+	engine := articleEngine(t)
+	guard := ScopeGuard[*article](engine)
+
+	editor := crud.ActorContext{ActorID: "u1", Role: "editor", TenantID: "t1"}
+	ctx := requestContext{ctx: crud.ContextWithActor(context.Background(), editor)}
+	actor, scope, err := guard(ctx, crud.OpUpdate)
+	require.NoError(t, err)
+	assert.Equal(t, "u1", actor.ActorID)
+	assert.Len(t, scope.ColumnFilters, 2)
+
+	_, _, err = guard(ctx, crud.OpDelete)
+	var denied *goerrors.Error
+	require.ErrorAs(t, err, &denied)
+	assert.Equal(t, goerrors.CategoryAuthz, denied.Category)
+	assert.Equal(t, "POLICY_DENIED", denied.TextCode)
+
+	provider := FieldPolicyProvider[*article](engine)
+	fields, err := provider(crud.FieldPolicyRequest[*article]{Actor: editor, Resource: "article", Operation: crud.OpUpdate})
+	require.NoError(t, err)
+	assert.Equal(t, "editors-own-articles", fields.Name)
+	assert.Equal(t, []string{"owner_id"}, fields.WritableDeny)
+}
@@ -3,6 +3,7 @@ package crud
 import (
 	"errors"
 	"fmt"
+	"reflect"
 	"sort"
 	"strings"
 
@@ -10,13 +11,15 @@ import (
 	"github.com/goliatone/go-repository-bun"
 	"github.com/goliatone/go-router"
 	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
 )
 
 type relationFilter struct {
-	field    string
-	operator string
-	value    string
-	column   string
+	field     string
+	operator  string
+	canonical string
+	value     string
+	column    string
 }
 
 type relationIncludeNode struct {
@@ -24,6 +27,10 @@ type relationIncludeNode struct {
 	requestName string
 	filters     []relationFilter
 	children    map[string]*relationIncludeNode
+	// columns is the sparse column set loaded for the relation; nil loads
+	// every column. parent is the metadata of the model declaring it.
+	columns []string
+	parent  *relationMetadata
 }
 
 type QueryBuilderOption func(*queryBuilderConfig)
@@ -32,8 +39,16 @@ type queryBuilderConfig struct {
 	trace               *queryTraceOptions
 	allowedFields       map[string]string
 	searchColumns       []string
+	searchStrategy      querybun.SearchStrategy
 	strictValidation    *bool
 	strictSearchColumns *bool
+	cursorSigningKey    []byte
+	fieldPolicy         resolvedFieldPolicy
+	limits              QueryLimits
+	// tenancy keeps relation filters and included relations to the request
+	// tenant, resolved into tenant when criteria are built from a request.
+	tenancy *TenancyConfig
+	tenant  relationTenant
 }
 
 func WithAllowedFields(fields map[string]string) QueryBuilderOption {
@@ -65,6 +80,15 @@ func WithSearchColumns(columns ...string) QueryBuilderOption {
 	}
 }
 
+// WithSearchStrategy sets how _search matches and ranks rows for this build
+// call. Columns passed to WithSearchColumns may carry a weight such as
+// "title:A", used by ranking strategies. The default is LikeSearch.
+func WithSearchStrategy(strategy SearchStrategy) QueryBuilderOption {
+	return func(cfg *queryBuilderConfig) {
+		cfg.searchStrategy = strategy
+	}
+}
+
 // WithStrictSearchColumns makes strict mode return a typed error when _search
 // is provided and no searchable columns are configured/resolved.
 func WithStrictSearchColumns(enabled bool) QueryBuilderOption {
@@ -73,6 +97,35 @@ func WithStrictSearchColumns(enabled bool) QueryBuilderOption {
 	}
 }
 
+// WithCursorSigningKey sets the key used to sign keyset pagination cursors
+// for this build call, overriding SetCursorSigningKey.
+func WithCursorSigningKey(key []byte) QueryBuilderOption {
+	return func(cfg *queryBuilderConfig) {
+		cfg.cursorSigningKey = append([]byte{}, key...)
+	}
+}
+
+// withFieldPolicy constrains relation fieldsets by the request field policy.
+func withFieldPolicy(policy resolvedFieldPolicy) QueryBuilderOption {
+	return func(cfg *queryBuilderConfig) {
+		cfg.fieldPolicy = policy
+	}
+}
+
+func withTenancy(tenancy TenancyConfig) QueryBuilderOption {
+	return func(cfg *queryBuilderConfig) {
+		cfg.tenancy = &tenancy
+	}
+}
+
+// withRequestTenant resolves the tenant of the request when tenancy applies.
+func (cfg queryBuilderConfig) withRequestTenant(ctx Context) queryBuilderConfig {
+	if cfg.tenancy != nil && ctx != nil {
+		cfg.tenant = relationTenant{value: TenantFromContext(ctx.UserContext()), fallback: cfg.tenancy.Column}
+	}
+	return cfg
+}
+
 func (cfg queryBuilderConfig) strictValidationEnabled() bool {
 	if cfg.strictValidation != nil {
 		return *cfg.strictValidation
@@ -132,31 +185,70 @@ func paginationCriteria(limit, offset int) repository.SelectCriteria {
 // GET /users?name__or=John,Jack
 // GET /users?include=Company,Profile
 // GET /users?include=Profile.status=outdated
+// GET /users?include=Profile&fields[Profile]=id,avatar
+// GET /users?cursor=&limit=20&order=created_at desc
+// GET /users?filter=(status = 'active' AND age > 3) OR owner_id = 'me'
+// GET /users?with_deleted=true (soft-deletable models)
+// GET /users?facets=status,role (value counts, each without its own filter)
+// GET /users/aggregate?group_by=status&sum=amount (filters and search only)
+// GET /users/export?format=csv&select=id,name (no order or pagination criteria)
+// PATCH|DELETE /users?status__eq=draft (filters and search only, one required)
 // TODO: Support /projects?include=Message&include=Company
 func buildQueryCriteria[T any](ctx Context, op CrudOperation, cfg queryBuilderConfig) ([]repository.SelectCriteria, *Filters, error) {
+	cfg = cfg.withRequestTenant(ctx)
 	queryParams := ctx.Queries()
 	opts := queryBunOptionsFromContext(ctx, queryParams)
+	switch op {
+	case OpList:
+		delete(opts.Filters, FacetsQueryParam)
+	case OpAggregate:
+		for _, param := range aggregateQueryParams {
+			delete(opts.Filters, param)
+		}
+	case OpExport:
+		delete(opts.Filters, ExportFormatQueryParam)
+	case OpUpdateByFilter, OpDeleteByFilter:
+		delete(opts.Filters, FilterMutationDryRunQueryParam)
+	}
 	plan, err := querybun.BuildQueryPlan(opts, queryBunConfig[T](cfg))
 	if err != nil {
 		return nil, nil, convertQueryBunError(err)
 	}
 
 	filters := filtersFromQueryBunPlan(plan, op)
+	filters.cursorKey = cfg.resolvedCursorSigningKey()
 	criteria := adaptQueryBunCriteria(plan.ListCriteria())
-	if op != OpList {
+	switch op {
+	case OpList:
+	case OpAggregate:
+		criteria = aggregateCriteria(plan)
+		criteria = append(criteria, softDeleteCriteria(typeOf[T](), queryFlag(ctx, WithDeletedQueryParam), queryFlag(ctx, OnlyDeletedQueryParam))...)
+		return criteria, filters, nil
+	case OpExport:
+		criteria = exportCriteria(plan)
+	case OpUpdateByFilter, OpDeleteByFilter:
+		if criteria, err = filterMutationCriteria(plan); err != nil {
+			return nil, nil, err
+		}
+		return criteria, filters, nil
+	default:
 		criteria = adaptQueryBunCriteria(plan.ReadCriteria())
 	}
 
-	includeCriteria, includePaths, relations, err := buildIncludeCriteriaForType[T](strings.Join(filters.Include, ","), cfg.strictValidationEnabled())
+	fieldsets := fieldsetsFromQuery(queryParams)
+	includeCriteria, includePaths, relations, err := buildIncludeCriteriaForType[T](strings.Join(filters.Include, ","), fieldsets, cfg)
 	if err != nil {
 		return nil, nil, err
 	}
 	if len(includePaths) > 0 {
 		filters.Include = includePaths
 		filters.Relations = relations
+		filters.Fieldsets = fieldsets
 		criteria = append(criteria, includeCriteria...)
 	}
 
+	criteria = append(criteria, softDeleteCriteria(typeOf[T](), queryFlag(ctx, WithDeletedQueryParam), queryFlag(ctx, OnlyDeletedQueryParam))...)
+
 	if cfg.trace != nil {
 		cfg.trace.debug(filters, queryParams)
 	}
@@ -187,6 +279,8 @@ func queryBunOptionsFromContext(ctx Context, queryParams map[string]string) quer
 		includes = []string{include}
 	}
 
+	_, cursorSet := queryParams["cursor"]
+
 	return querybun.ListOptions{
 		Limit:     limit,
 		LimitSet:  true,
@@ -195,21 +289,26 @@ func queryBunOptionsFromContext(ctx Context, queryParams map[string]string) quer
 		Order:     ctx.Query("order"),
 		Search:    ctx.Query("_search"),
 		Filters:   filters,
+		Filter:    ctx.Query(querybun.FilterExprParam),
 		Select:    selectFields,
 		Include:   includes,
+		Cursor:    ctx.Query("cursor"),
+		CursorSet: cursorSet,
 	}
 }
 
 func isReservedQueryParam(param string) bool {
 	switch param {
-	case "limit", "offset", "order", "select", "include", "_search":
+	case "limit", "offset", "order", "select", "include", "_search", "cursor", querybun.FilterExprParam, WithDeletedQueryParam, OnlyDeletedQueryParam:
 		return true
 	default:
-		return false
+		_, fieldset := fieldsetParamPath(param)
+		return fieldset
 	}
 }
 
 func queryBunConfig[T any](cfg queryBuilderConfig) querybun.Config {
+	limits := cfg.resolvedQueryLimits()
 	allowedFieldsMap := cfg.allowedFields
 	if len(allowedFieldsMap) == 0 {
 		allowedFieldsMap = getAllowedFields[T]()
@@ -217,12 +316,21 @@ func queryBunConfig[T any](cfg queryBuilderConfig) querybun.Config {
 	return querybun.Config{
 		AllowedFields:                allowedFieldsMap,
 		SearchColumns:                cfg.searchColumns,
+		SearchStrategy:               cfg.searchStrategy,
 		OperatorMap:                  operatorMap,
 		StrictValidation:             cfg.strictValidationEnabled(),
 		StrictSearchColumns:          cfg.strictSearchColumnsEnabled(),
 		FallbackUnsupportedOperators: true,
 		DefaultLimit:                 DefaultLimit,
 		DefaultOffset:                DefaultOffset,
+		CursorSecret:                 cfg.resolvedCursorSigningKey(),
+		KeyColumns:                   keyColumnsForType(typeOf[T]()),
+		FieldTypes:                   getFieldTypes(typeOf[T]()),
+		RelationFields:               relationFieldResolver(getRelationMetadataForType(typeOf[T]()), cfg.fieldPolicy, cfg.tenant),
+		MaxLimit:                     limits.MaxLimit,
+		MaxPredicates:                limits.MaxFilterPredicates,
+		MaxSearchLength:              limits.MaxSearchLength,
+		MaxRelationDepth:             limits.MaxIncludeDepth,
 	}
 }
 
@@ -250,6 +358,11 @@ func filtersFromQueryBunPlan(plan querybun.Plan, op CrudOperation) *Filters {
 		Offset:    plan.Metadata.Offset,
 		Search:    plan.Metadata.Search,
 	}
+	if plan.Metadata.LimitClamped {
+		filters.LimitClamped = true
+		filters.RequestedLimit = plan.Metadata.RequestedLimit
+	}
+	filters.SearchStrategy = plan.Metadata.SearchStrategy
 	if len(plan.Metadata.Order) > 0 {
 		filters.Order = make([]Order, len(plan.Metadata.Order))
 		for i, order := range plan.Metadata.Order {
@@ -258,18 +371,25 @@ func filtersFromQueryBunPlan(plan querybun.Plan, op CrudOperation) *Filters {
 	}
 	filters.Fields = append([]string{}, plan.Metadata.Fields...)
 	filters.Include = append([]string{}, plan.Metadata.Include...)
+	filters.keyset = plan.Metadata.Keyset
 	return filters
 }
 
-func buildIncludeCriteriaForType[T any](include string, strictValidation bool) ([]repository.SelectCriteria, []string, []RelationInfo, error) {
-	if strings.TrimSpace(include) == "" {
+func buildIncludeCriteriaForType[T any](include string, fieldsets map[string][]string, cfg queryBuilderConfig) ([]repository.SelectCriteria, []string, []RelationInfo, error) {
+	if strings.TrimSpace(include) == "" && len(fieldsets) == 0 {
 		return nil, nil, nil, nil
 	}
 	meta := getRelationMetadataForType(typeOf[T]())
-	includeNodes, err := buildIncludeTree(include, meta, strictValidation)
+	includeNodes, err := buildIncludeTree(include, meta, cfg.strictValidationEnabled())
 	if err != nil {
 		return nil, nil, nil, err
 	}
+	if err := checkIncludeLimits(includeNodes, cfg.resolvedQueryLimits()); err != nil {
+		return nil, nil, nil, err
+	}
+	if err := applyIncludeFieldsets(includeNodes, meta, fieldsets, cfg.fieldPolicy); err != nil {
+		return nil, nil, nil, err
+	}
 	if len(includeNodes) == 0 {
 		return nil, nil, nil, nil
 	}
@@ -287,7 +407,7 @@ func buildIncludeCriteriaForType[T any](include string, strictValidation bool) (
 		}
 		criteria = append(criteria, func(n *relationIncludeNode) repository.SelectCriteria {
 			return func(q *bun.SelectQuery) *bun.SelectQuery {
-				return includeRelation(q, n)
+				return includeRelation(q, typeOf[T](), n, cfg.tenant)
 			}
 		}(node))
 	}
@@ -299,6 +419,9 @@ func convertQueryBunError(err error) error {
 	if err == nil {
 		return nil
 	}
+	if errors.Is(err, querybun.ErrCursorSecretRequired) {
+		return ErrCursorSigningKeyRequired
+	}
 	var validationErr *querybun.ValidationError
 	if !errors.As(err, &validationErr) {
 		return err
@@ -310,6 +433,7 @@ func convertQueryBunError(err error) error {
 			Field:    validationErr.Field,
 			Operator: validationErr.Operator,
 			Search:   validationErr.Search,
+			Position: validationErr.Position,
 		}
 	case querybun.ValidationSearchColumnsRequired:
 		return &QueryValidationError{
@@ -317,6 +441,58 @@ func convertQueryBunError(err error) error {
 			Field:  validationErr.Field,
 			Search: validationErr.Search,
 		}
+	case querybun.ValidationInvalidCursor:
+		return &QueryValidationError{
+			Code:   QueryValidationInvalidCursor,
+			Reason: validationErr.Reason,
+		}
+	case querybun.ValidationInvalidFilter:
+		return &QueryValidationError{
+			Code:     QueryValidationInvalidFilter,
+			Field:    validationErr.Field,
+			Operator: validationErr.Operator,
+			Reason:   validationErr.Reason,
+			Position: validationErr.Position,
+		}
+	case querybun.ValidationTooManyFilters:
+		return &QueryValidationError{
+			Code:   QueryValidationTooManyFilters,
+			Reason: validationErr.Reason,
+		}
+	case querybun.ValidationSearchTooLong:
+		return &QueryValidationError{
+			Code:   QueryValidationSearchTooLong,
+			Search: validationErr.Search,
+			Reason: validationErr.Reason,
+		}
+	case querybun.ValidationRelationTooDeep:
+		return &QueryValidationError{
+			Code:   QueryValidationIncludeTooDeep,
+			Field:  validationErr.Field,
+			Reason: validationErr.Reason,
+		}
+	case querybun.ValidationFieldNotAllowed:
+		return &QueryValidationError{
+			Code:     QueryValidationFieldNotAllowed,
+			Field:    validationErr.Field,
+			Position: validationErr.Position,
+		}
+	case querybun.ValidationInvalidValue:
+		return &QueryValidationError{
+			Code:     QueryValidationInvalidValue,
+			Field:    validationErr.Field,
+			Operator: validationErr.Operator,
+			Reason:   validationErr.Reason,
+			Position: validationErr.Position,
+		}
+	case querybun.ValidationInvalidArity:
+		return &QueryValidationError{
+			Code:     QueryValidationInvalidArity,
+			Field:    validationErr.Field,
+			Operator: validationErr.Operator,
+			Reason:   validationErr.Reason,
+			Position: validationErr.Position,
+		}
 	default:
 		return err
 	}
@@ -406,18 +582,19 @@ func parseIncludePath(path string, meta *relationMetadata, strictValidation bool
 			if err != nil {
 				return nil, err
 			}
-			columnName := ""
-			if currentMeta != nil && currentMeta.fields != nil {
-				columnName = currentMeta.fields[fieldName]
-			}
-			if columnName == "" {
+			if !relationReadable(currentMeta, fieldName) {
 				return nil, fmt.Errorf("unsupported filter field %q on relation %q", fieldName, current.requestName)
 			}
+			columnName := currentMeta.fields[fieldName]
+			if resolvedOperator.canonical == "between" {
+				return nil, fmt.Errorf("operator %q takes two values and is not supported on relation %q", resolvedOperator.token, current.requestName)
+			}
 			current.filters = append(current.filters, relationFilter{
-				field:    fieldName,
-				operator: resolvedOperator.sql,
-				value:    value,
-				column:   columnName,
+				field:     fieldName,
+				operator:  resolvedOperator.sql,
+				canonical: resolvedOperator.canonical,
+				value:     value,
+				column:    columnName,
 			})
 			continue
 		}
@@ -516,9 +693,14 @@ func collectRelationDetails(node *relationIncludeNode, path []string, includes *
 	if len(node.filters) > 0 {
 		relationFilters := make([]RelationFilter, len(node.filters))
 		for i, filter := range node.filters {
+			operator := filter.operator
+			if operator == "" {
+				// Comparison operators have no SQL fragment; report them by name.
+				operator = filter.canonical
+			}
 			relationFilters[i] = RelationFilter{
 				Field:    filter.field,
-				Operator: filter.operator,
+				Operator: operator,
 				Value:    filter.value,
 			}
 		}
@@ -534,19 +716,46 @@ func collectRelationDetails(node *relationIncludeNode, path []string, includes *
 	}
 }
 
-func includeRelation(q *bun.SelectQuery, node *relationIncludeNode) *bun.SelectQuery {
+// includeRelation loads the relation node of the parent model. Related
+// models with a tenant column only load rows of the request tenant: to-many
+// relations filter their query and to-one relations their join.
+func includeRelation(q *bun.SelectQuery, parent reflect.Type, node *relationIncludeNode, tenant relationTenant) *bun.SelectQuery {
 	if node == nil {
 		return q
 	}
 
-	return q.Relation(node.name, func(rel *bun.SelectQuery) *bun.SelectQuery {
+	var child reflect.Type
+	var tenantColumn string
+	opts := bun.RelationOpts{}
+	if relation, ok := q.DB().Table(parent).Relations[node.name]; ok {
+		child = relation.JoinTable.Type
+		if column, scoped := tenant.column(child); scoped {
+			switch relation.Type {
+			case schema.HasOneRelation, schema.BelongsToRelation:
+				opts.AdditionalJoinOnConditions = []schema.QueryWithArgs{
+					schema.SafeQuery("?.? = ?", []any{bun.Ident(relation.Field.Name), bun.Ident(column), tenant.value}),
+				}
+			default:
+				tenantColumn = column
+			}
+		}
+	}
+
+	opts.Apply = func(rel *bun.SelectQuery) *bun.SelectQuery {
+		if node.columns != nil {
+			rel = rel.Column(relationSelectColumns(rel, node)...)
+		}
+		if tenantColumn != "" {
+			rel = rel.Where("?TableAlias.? = ?", bun.Ident(tenantColumn), tenant.value)
+		}
 		rel = applyRelationFilters(rel, node.filters)
 		childKeys := sortedRelationKeys(node.children)
 		for _, key := range childKeys {
-			rel = includeRelation(rel, node.children[key])
+			rel = includeRelation(rel, child, node.children[key], tenant)
 		}
 		return rel
-	})
+	}
+	return q.RelationWithOpts(node.name, opts)
 }
 
 func applyRelationFilters(q *bun.SelectQuery, filters []relationFilter) *bun.SelectQuery {
@@ -555,7 +764,9 @@ func applyRelationFilters(q *bun.SelectQuery, filters []relationFilter) *bun.Sel
 		if column == "" {
 			column = filter.field
 		}
-		q = q.Where(fmt.Sprintf("%s %s ?", column, filter.operator), filter.value)
+		op := querybun.Operator{Canonical: filter.canonical, SQL: filter.operator}
+		cond, args := querybun.ComparisonCondition(q.Dialect().Name(), column, op, []any{filter.value})
+		q = q.Where(cond, args...)
 	}
 	return q
 }
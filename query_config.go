@@ -13,8 +13,15 @@ import (
 
 type relationMetadata struct {
 	relationName string
-	fields       map[string]string
-	children     map[string]*relationMetadata
+	// jsonName is the relation's field name in the parent's field map.
+	jsonName string
+	typ      reflect.Type
+	// toMany marks has-many relations, filtered with EXISTS rather than joined.
+	toMany bool
+	// exposed lists the fields a relation descriptor exposes; nil exposes all.
+	exposed  map[string]struct{}
+	fields   map[string]string
+	children map[string]*relationMetadata
 }
 
 type queryConfig struct {
@@ -28,6 +35,7 @@ type modelFieldsProvider interface {
 var (
 	queryConfigRegistry sync.Map // map[reflect.Type]*queryConfig
 	fieldsCache         sync.Map // map[reflect.Type]map[string]string
+	fieldTypesCache     sync.Map // map[reflect.Type]map[string]reflect.Type
 )
 
 func newFieldMapProviderFromRepo(repo any, resourceType reflect.Type) FieldMapProvider {
@@ -108,6 +116,7 @@ func buildRelationMetadata(typ reflect.Type, provider FieldMapProvider, visited
 	if visited[base] {
 		// Prevent infinite recursion on cyclical relations
 		return &relationMetadata{
+			typ:      base,
 			fields:   map[string]string{},
 			children: map[string]*relationMetadata{},
 		}
@@ -117,6 +126,7 @@ func buildRelationMetadata(typ reflect.Type, provider FieldMapProvider, visited
 	fields := getOrBuildFieldMap(base, provider)
 
 	meta := &relationMetadata{
+		typ:      base,
 		fields:   fields,
 		children: make(map[string]*relationMetadata),
 	}
@@ -146,6 +156,7 @@ func buildRelationMetadata(typ reflect.Type, provider FieldMapProvider, visited
 		}
 
 		childMeta.relationName = field.Name
+		childMeta.toMany = strings.Contains(bunTag, "rel:has-many")
 
 		registerChild(meta, field, childMeta)
 	}
@@ -159,11 +170,13 @@ func registerChild(parent *relationMetadata, field reflect.StructField, child *r
 	}
 
 	parent.children[strings.ToLower(field.Name)] = child
+	child.jsonName = strcase.ToSnake(field.Name)
 
 	if jsonTag := field.Tag.Get(TAG_JSON); jsonTag != "" {
 		alias := strings.Split(jsonTag, ",")[0]
 		if alias != "" && alias != "-" {
 			parent.children[strings.ToLower(alias)] = child
+			child.jsonName = alias
 		}
 	}
 }
@@ -228,6 +241,38 @@ func buildDefaultFieldMap(typ reflect.Type) map[string]string {
 	return fields
 }
 
+// getFieldTypes maps the JSON names of typ's fields, as used by the default
+// field map, to their Go types so filter values can be parsed.
+func getFieldTypes(typ reflect.Type) map[string]reflect.Type {
+	base := indirectType(typ)
+	if base == nil || base.Kind() != reflect.Struct {
+		return nil
+	}
+	if m, ok := fieldTypesCache.Load(base); ok {
+		return m.(map[string]reflect.Type)
+	}
+
+	types := make(map[string]reflect.Type)
+	for field := range base.Fields() {
+		if !field.IsExported() || field.Anonymous || field.Tag.Get(TAG_CRUD) == "-" {
+			continue
+		}
+		if bunTag := field.Tag.Get(TAG_BUN); strings.Contains(bunTag, "rel:") || strings.Contains(bunTag, "m2m:") {
+			continue
+		}
+		jsonKey := strcase.ToSnake(field.Name)
+		if jsonTag := field.Tag.Get(TAG_JSON); jsonTag != "" {
+			if name := strings.Split(jsonTag, ",")[0]; name != "" {
+				jsonKey = name
+			}
+		}
+		types[jsonKey] = field.Type
+	}
+
+	fieldTypesCache.Store(base, types)
+	return types
+}
+
 func normalizeFieldMap(in map[string]string) map[string]string {
 	out := make(map[string]string, len(in))
 	for k, v := range in {
@@ -258,6 +303,13 @@ func pruneRelationNode(meta *relationMetadata, node *router.RelationNode) {
 		return
 	}
 
+	if len(node.Fields) > 0 {
+		meta.exposed = make(map[string]struct{}, len(node.Fields))
+		for _, field := range node.Fields {
+			meta.exposed[field] = struct{}{}
+		}
+	}
+
 	if len(meta.children) == 0 {
 		return
 	}
@@ -0,0 +1,123 @@
+package crud
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// QueryLimits caps the work a single query can ask of the database. Zero
+// fields are unlimited. Controller limits (WithQueryLimitsConfig) and build
+// call limits (WithQueryLimits) take precedence over the global limits
+// (SetQueryLimits) field by field.
+type QueryLimits struct {
+	// MaxIncludeDepth caps the relations in one include path, and in the
+	// dotted relation fields of filters and order; Company.Address and
+	// company.address.city are two deep.
+	MaxIncludeDepth int
+	// MaxIncludes caps the relations an include loads, counting each
+	// relation of a nested path once.
+	MaxIncludes int
+	// MaxLimit clamps limit, including the unbounded limit=0. Clamped
+	// requests are served and report limit_clamped in $meta.
+	MaxLimit int
+	// MaxFilterPredicates caps the filters: field params plus the
+	// comparisons of a filter expression.
+	MaxFilterPredicates int
+	// MaxSearchLength caps the _search term in characters.
+	MaxSearchLength int
+}
+
+var queryLimits atomic.Pointer[QueryLimits]
+
+// SetQueryLimits sets the global query limits.
+func SetQueryLimits(limits QueryLimits) {
+	queryLimits.Store(&limits)
+}
+
+// GlobalQueryLimits returns the limits set with SetQueryLimits.
+func GlobalQueryLimits() QueryLimits {
+	if limits := queryLimits.Load(); limits != nil {
+		return *limits
+	}
+	return QueryLimits{}
+}
+
+// WithQueryLimits sets the query limits for this build call, overriding the
+// global limits field by field.
+func WithQueryLimits(limits QueryLimits) QueryBuilderOption {
+	return func(cfg *queryBuilderConfig) {
+		cfg.limits = limits
+	}
+}
+
+// WithQueryLimitsConfig sets the query limits of the controller's list,
+// read, aggregate, export and filter mutation routes, overriding the global
+// limits field by field.
+func WithQueryLimitsConfig[T any](limits QueryLimits) Option[T] {
+	return func(c *Controller[T]) {
+		c.queryLimits = limits
+	}
+}
+
+// orElse fills the unset fields of l from fallback.
+func (l QueryLimits) orElse(fallback QueryLimits) QueryLimits {
+	pick := func(value, fallback int) int {
+		if value > 0 {
+			return value
+		}
+		return fallback
+	}
+	return QueryLimits{
+		MaxIncludeDepth:     pick(l.MaxIncludeDepth, fallback.MaxIncludeDepth),
+		MaxIncludes:         pick(l.MaxIncludes, fallback.MaxIncludes),
+		MaxLimit:            pick(l.MaxLimit, fallback.MaxLimit),
+		MaxFilterPredicates: pick(l.MaxFilterPredicates, fallback.MaxFilterPredicates),
+		MaxSearchLength:     pick(l.MaxSearchLength, fallback.MaxSearchLength),
+	}
+}
+
+func (cfg queryBuilderConfig) resolvedQueryLimits() QueryLimits {
+	return cfg.limits.orElse(GlobalQueryLimits())
+}
+
+// checkIncludeLimits enforces MaxIncludeDepth and MaxIncludes on an include
+// tree.
+func checkIncludeLimits(nodes map[string]*relationIncludeNode, limits QueryLimits) error {
+	if limits.MaxIncludeDepth <= 0 && limits.MaxIncludes <= 0 {
+		return nil
+	}
+	count := 0
+	var walk func(nodes map[string]*relationIncludeNode, path []string) error
+	walk = func(nodes map[string]*relationIncludeNode, path []string) error {
+		for _, key := range sortedRelationKeys(nodes) {
+			node := nodes[key]
+			if node == nil {
+				continue
+			}
+			current := append(path[:len(path):len(path)], node.requestName)
+			if limits.MaxIncludeDepth > 0 && len(current) > limits.MaxIncludeDepth {
+				return &QueryValidationError{
+					Code:   QueryValidationIncludeTooDeep,
+					Field:  strings.Join(current, "."),
+					Reason: fmt.Sprintf("%d relations deep exceeds the limit of %d", len(current), limits.MaxIncludeDepth),
+				}
+			}
+			count++
+			if err := walk(node.children, current); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(nodes, nil); err != nil {
+		return err
+	}
+	if limits.MaxIncludes > 0 && count > limits.MaxIncludes {
+		return &QueryValidationError{
+			Code:   QueryValidationTooManyIncludes,
+			Reason: fmt.Sprintf("%d relations exceed the limit of %d", count, limits.MaxIncludes),
+		}
+	}
+	return nil
+}
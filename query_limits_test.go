@@ -0,0 +1,86 @@
+package crud
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestController_Index_QueryLimits(t *testing.T) {
+	app, db := setupApp(t, WithQueryLimitsConfig[*TestUser](QueryLimits{
+		MaxLimit:            2,
+		MaxIncludes:         1,
+		MaxFilterPredicates: 2,
+		MaxSearchLength:     8,
+	}))
+	defer db.Close()
+
+	insertTestUsers(t, db, &TestUser{Name: "Ann"}, &TestUser{Name: "Bob"}, &TestUser{Name: "Cid"})
+
+	status, payload := listRequest(t, app, "/test-users?limit=500")
+	require.Equal(t, http.StatusOK, status)
+	assert.Len(t, payload.Data, 2)
+	assert.Equal(t, 2, payload.Meta.Limit)
+	assert.True(t, payload.Meta.LimitClamped)
+	assert.Equal(t, 500, payload.Meta.RequestedLimit)
+
+	status, payload = listRequest(t, app, "/test-users?limit=1&include=profiles&name__ne=Bob&age__gte=0")
+	require.Equal(t, http.StatusOK, status)
+	assert.Len(t, payload.Data, 1)
+	assert.False(t, payload.Meta.LimitClamped)
+
+	for _, path := range []string{
+		"/test-users?name=Ann&age=1&filter=age%20%3E%200",
+		"/test-users?_search=abcdefghi",
+	} {
+		status, _ = listRequest(t, app, path)
+		assert.Equal(t, http.StatusBadRequest, status, path)
+	}
+}
+
+func TestBuildQueryCriteria_GlobalQueryLimits(t *testing.T) {
+	SetQueryLimits(QueryLimits{MaxLimit: 10, MaxFilterPredicates: 1})
+	t.Cleanup(func() { SetQueryLimits(QueryLimits{}) })
+
+	ctx := newMockContextWithQuery(map[string]string{"limit": "0"})
+	_, filters, err := BuildQueryCriteria[TestUser](ctx, OpList)
+	require.NoError(t, err)
+	assert.Equal(t, 10, filters.Limit, "unbounded limits are clamped")
+	assert.True(t, filters.LimitClamped)
+
+	ctx = newMockContextWithQuery(map[string]string{"limit": "50"})
+	_, filters, err = BuildQueryCriteria[TestUser](ctx, OpList, WithQueryLimits(QueryLimits{MaxLimit: 100}))
+	require.NoError(t, err)
+	assert.Equal(t, 50, filters.Limit, "build call limits override the global limits")
+
+	ctx = newMockContextWithQuery(map[string]string{"name": "Ann", "age": "3"})
+	_, _, err = BuildQueryCriteria[TestUser](ctx, OpList, WithQueryLimits(QueryLimits{MaxLimit: 100}))
+	var typedErr *QueryValidationError
+	require.ErrorAs(t, err, &typedErr, "unset fields fall back to the global limits")
+	assert.Equal(t, QueryValidationTooManyFilters, typedErr.Code)
+}
+
+func TestCheckIncludeLimits(t *testing.T) {
+	nodes := map[string]*relationIncludeNode{
+		"company": {requestName: "Company", children: map[string]*relationIncludeNode{
+			"address": {requestName: "Address", children: map[string]*relationIncludeNode{
+				"country": {requestName: "Country"},
+			}},
+		}},
+		"profiles": {requestName: "Profiles"},
+	}
+
+	require.NoError(t, checkIncludeLimits(nodes, QueryLimits{MaxIncludeDepth: 3, MaxIncludes: 4}))
+
+	err := checkIncludeLimits(nodes, QueryLimits{MaxIncludeDepth: 2})
+	var typedErr *QueryValidationError
+	require.ErrorAs(t, err, &typedErr)
+	assert.Equal(t, QueryValidationIncludeTooDeep, typedErr.Code)
+	assert.Equal(t, `relation path "Company.Address.Country" is too deep: 3 relations deep exceeds the limit of 2`, typedErr.Error())
+
+	err = checkIncludeLimits(nodes, QueryLimits{MaxIncludes: 3})
+	require.ErrorAs(t, err, &typedErr)
+	assert.Equal(t, QueryValidationTooManyIncludes, typedErr.Code)
+}
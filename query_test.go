@@ -10,10 +10,12 @@ import (
 	"net/url"
 	"reflect"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	repository "github.com/goliatone/go-repository-bun"
+	"github.com/goliatone/go-router"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -1034,6 +1036,122 @@ func TestBuildListCriteriaFromOptions_CanonicalOperatorsWorkWithCustomAliases(t
 	assert.Equal(t, "Alice", rows[0].Name)
 }
 
+func TestBuildQueryCriteria_FilterExpression(t *testing.T) {
+	SetOperatorMap(DefaultOperatorMap())
+	db := setupTestDB(t)
+	defer db.Close()
+	seedQueryUsers(t, db)
+
+	ctx := newMockContextWithQuery(map[string]string{
+		"order":  "name asc",
+		"filter": "(email like '%example.com' AND age > 35) OR name = 'Bob'",
+	})
+	httpCriteria, _, err := BuildQueryCriteria[TestUser](ctx, OpList)
+	require.NoError(t, err)
+
+	rows := executeUserCriteria(t, db, httpCriteria)
+	require.Len(t, rows, 2)
+	assert.Equal(t, []string{"Bob", "Carol"}, []string{rows[0].Name, rows[1].Name})
+
+	typedCriteria, _, err := BuildListCriteriaFromOptions[TestUser](ListQueryOptions{
+		Order: "name asc",
+		FilterTree: &FilterExpr{Or: []FilterExpr{
+			{And: []FilterExpr{
+				{Field: "email", Operator: "like", Values: []string{"%example.com"}},
+				{Field: "age", Operator: "gt", Values: []string{"35"}},
+			}},
+			{Field: "name", Values: []string{"Bob"}},
+		}},
+	})
+	require.NoError(t, err)
+	typedRows := executeUserCriteria(t, db, typedCriteria)
+	require.Len(t, typedRows, 2)
+	assert.Equal(t, rows[0].ID, typedRows[0].ID)
+	assert.Equal(t, rows[1].ID, typedRows[1].ID)
+}
+
+func TestBuildQueryCriteria_FilterExpressionErrors(t *testing.T) {
+	ctx := newMockContextWithQuery(map[string]string{"filter": "name = 'Alice' AND"})
+	_, _, err := BuildQueryCriteria[TestUser](ctx, OpList)
+	var typedErr *QueryValidationError
+	require.True(t, errors.As(err, &typedErr))
+	assert.Equal(t, QueryValidationInvalidFilter, typedErr.Code)
+	assert.Equal(t, 19, typedErr.Position)
+
+	ctx = newMockContextWithQuery(map[string]string{"filter": "name = 'Alice' OR nickname = 'x'"})
+	_, _, err = BuildQueryCriteria[TestUser](ctx, OpList)
+	require.NoError(t, err, "unknown fields are dropped outside strict mode")
+
+	_, _, err = BuildQueryCriteria[TestUser](ctx, OpList, WithStrictQueryValidation(true))
+	require.True(t, errors.As(err, &typedErr))
+	assert.Equal(t, QueryValidationFieldNotAllowed, typedErr.Code)
+	assert.Equal(t, "nickname", typedErr.Field)
+	assert.Equal(t, 19, typedErr.Position)
+}
+
+func TestBuildQueryCriteria_RichOperators(t *testing.T) {
+	SetOperatorMap(DefaultOperatorMap())
+	db := setupTestDB(t)
+	defer db.Close()
+	seedQueryUsers(t, db)
+
+	ctx := newMockContextWithQuery(map[string]string{
+		"order":           "name asc",
+		"age__between":    "26,40",
+		"email__endswith": "@sample.com",
+		"name__nin":       "Alice",
+	})
+	criteria, _, err := BuildQueryCriteria[TestUser](ctx, OpList)
+	require.NoError(t, err)
+	rows := executeUserCriteria(t, db, criteria)
+	require.Len(t, rows, 1)
+	assert.Equal(t, "Dave", rows[0].Name)
+
+	ctx = newMockContextWithQuery(map[string]string{"age__between": "30"})
+	_, _, err = BuildQueryCriteria[TestUser](ctx, OpList, WithStrictQueryValidation(true))
+	var typedErr *QueryValidationError
+	require.True(t, errors.As(err, &typedErr))
+	assert.Equal(t, QueryValidationInvalidArity, typedErr.Code)
+	assert.Equal(t, `operator "between" on field "age" takes exactly 2 value(s), got 1`, typedErr.Error())
+}
+
+func TestBuildQueryCriteria_TypedValues(t *testing.T) {
+	SetOperatorMap(DefaultOperatorMap())
+	db := setupTestDB(t)
+	defer db.Close()
+	seedQueryUsers(t, db)
+
+	params := map[string]string{
+		"order":              "name asc",
+		"created_at__gte":    "now-30h",
+		"created_at__lt":     time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+		"created_at__gt":     "2000-01-01",
+		"age__between":       "30,40",
+		"age__ne":            "031",
+		"created_at__isnull": "",
+	}
+	criteria, _, err := BuildQueryCriteria[TestUser](newMockContextWithQuery(params), OpList)
+	require.NoError(t, err)
+	assert.Empty(t, executeUserCriteria(t, db, criteria), "created_at is never null")
+
+	delete(params, "created_at__isnull")
+	criteria, _, err = BuildQueryCriteria[TestUser](newMockContextWithQuery(params), OpList)
+	require.NoError(t, err)
+	rows := executeUserCriteria(t, db, criteria)
+	require.Len(t, rows, 2)
+	assert.Equal(t, []string{"Carol", "Dave"}, []string{rows[0].Name, rows[1].Name})
+
+	ctx := newMockContextWithQuery(map[string]string{"age__gt": "thirty"})
+	_, _, err = BuildQueryCriteria[TestUser](ctx, OpList)
+	require.NoError(t, err, "invalid values pass through outside strict mode")
+
+	_, _, err = BuildQueryCriteria[TestUser](ctx, OpList, WithStrictQueryValidation(true))
+	var typedErr *QueryValidationError
+	require.True(t, errors.As(err, &typedErr))
+	assert.Equal(t, QueryValidationInvalidValue, typedErr.Code)
+	assert.Equal(t, `invalid value for field "age": invalid value "thirty", expected an integer`, typedErr.Error())
+}
+
 func seedQueryUsers(t *testing.T, db *bun.DB) {
 	t.Helper()
 
@@ -1064,3 +1182,20 @@ func executeUserCriteria(t *testing.T, db *bun.DB, criteria []repository.SelectC
 	require.NoError(t, query.Scan(context.Background()))
 	return rows
 }
+
+func TestController_ListRouteDescribesFilterPattern(t *testing.T) {
+	_, _, controller := setupSerialWidgetApp(t)
+
+	var list *router.RouteDefinition
+	for _, route := range controller.GetMetadata().Routes {
+		for _, param := range route.Parameters {
+			assert.NotEqual(t, "{field}__startswith", param.Name, "operators are not listed as literal parameters")
+		}
+		if route.Method == "GET" && strings.HasSuffix(route.Name, ":"+string(OpList)) {
+			list = &route
+		}
+	}
+	require.NotNil(t, list)
+	assert.Contains(t, list.Description, "`{field}__{operator}={value}`")
+	assert.Contains(t, list.Description, "`startswith`")
+}
@@ -14,8 +14,35 @@ type ListQueryPredicate struct {
 	Values   []string
 }
 
+// FilterExpr is a boolean filter tree (AND/OR/NOT over field predicates),
+// the structured form of the filter query parameter.
+type FilterExpr = querybun.FilterExpr
+
+// ParseFilterExpr parses a filter expression such as
+// "(status = 'a' AND age > 3) OR owner_id = 'me'" or a JSON filter tree.
+func ParseFilterExpr(input string) (*FilterExpr, error) {
+	expr, err := querybun.ParseFilterExpr(input)
+	return expr, convertQueryBunError(err)
+}
+
+// FilterOperator describes a canonical filter operator and how many values it
+// takes. MaxValues is zero when unbounded.
+type FilterOperator = querybun.OperatorInfo
+
+// FilterOperators lists the canonical operators accepted by field__operator
+// filters and filter expressions.
+func FilterOperators() []FilterOperator {
+	return querybun.FilterOperators()
+}
+
+// EnumValuer is implemented by string field types with a fixed set of
+// values; filters on such fields reject anything else under strict validation.
+type EnumValuer = querybun.EnumValuer
+
 // ListQueryOptions provides a non-HTTP contract to build list criteria.
-// Supported parity keys are: limit/offset, order, _search, and field__operator filters.
+// Supported parity keys are: limit/offset, order, _search, filter, and field__operator filters.
+// Cursor (or Keyset for the first page) switches to keyset pagination; pass
+// the resulting Filters to ApplyCursorPage to finalize the page.
 type ListQueryOptions struct {
 	Page       int
 	PerPage    int
@@ -27,8 +54,27 @@ type ListQueryOptions struct {
 	Search     string
 	Filters    map[string]any
 	Predicates []ListQueryPredicate
+	// Filter takes a filter expression or JSON tree, like the filter query
+	// param; FilterTree takes the parsed tree. Both are ANDed with Filters.
+	Filter     string
+	FilterTree *FilterExpr
 	Select     []string
 	Include    []string
+	// Fieldsets limits the columns loaded for included relations, keyed by
+	// include path, like the fields[relation] query params.
+	Fieldsets map[string][]string
+	Cursor    string
+	Keyset    bool
+	// WithDeleted and OnlyDeleted mirror the with_deleted/only_deleted query
+	// params for soft-deletable models.
+	WithDeleted bool
+	OnlyDeleted bool
+	// Aggregate selects the groups and aggregates read by
+	// BuildAggregateCriteriaFromOptions callers; list builders ignore it.
+	Aggregate AggregateSpec
+	// Facets lists the fields counted by BuildFacetCriteriaFromOptions
+	// callers; list builders ignore it.
+	Facets []string
 }
 
 // BuildListCriteriaFromOptions builds list criteria without requiring a synthetic HTTP context.
@@ -46,17 +92,20 @@ func BuildListCriteriaFromOptions[T any](opts ListQueryOptions, qbOpts ...QueryB
 	}
 
 	filters := filtersFromQueryBunPlan(plan, OpList)
+	filters.cursorKey = cfg.resolvedCursorSigningKey()
 	criteria := adaptQueryBunCriteria(plan.ListCriteria())
 
-	includeCriteria, includePaths, relations, err := buildIncludeCriteriaForType[T](strings.Join(filters.Include, ","), cfg.strictValidationEnabled())
+	includeCriteria, includePaths, relations, err := buildIncludeCriteriaForType[T](strings.Join(filters.Include, ","), opts.Fieldsets, cfg)
 	if err != nil {
 		return nil, nil, err
 	}
 	if len(includePaths) > 0 {
 		filters.Include = includePaths
 		filters.Relations = relations
+		filters.Fieldsets = opts.Fieldsets
 		criteria = append(criteria, includeCriteria...)
 	}
+	criteria = append(criteria, softDeleteCriteria(typeOf[T](), opts.WithDeleted, opts.OnlyDeleted)...)
 
 	return criteria, filters, nil
 }
@@ -81,7 +130,11 @@ func toQueryBunListOptions(opts ListQueryOptions) querybun.ListOptions {
 		Search:     opts.Search,
 		Filters:    opts.Filters,
 		Predicates: predicates,
+		Filter:     opts.Filter,
+		FilterTree: opts.FilterTree,
 		Select:     append([]string{}, opts.Select...),
 		Include:    append([]string{}, opts.Include...),
+		Cursor:     opts.Cursor,
+		CursorSet:  opts.Keyset,
 	}
 }
@@ -14,6 +14,17 @@ type QueryValidationErrorCode string
 const (
 	QueryValidationUnsupportedOperator   QueryValidationErrorCode = "unsupported_operator"
 	QueryValidationSearchColumnsRequired QueryValidationErrorCode = "search_columns_required"
+	QueryValidationInvalidCursor         QueryValidationErrorCode = "invalid_cursor"
+	QueryValidationFilterRequired        QueryValidationErrorCode = "filter_required"
+	QueryValidationInvalidFilter         QueryValidationErrorCode = "invalid_filter"
+	QueryValidationFieldNotAllowed       QueryValidationErrorCode = "field_not_allowed"
+	QueryValidationInvalidArity          QueryValidationErrorCode = "invalid_arity"
+	QueryValidationInvalidValue          QueryValidationErrorCode = "invalid_value"
+	QueryValidationInvalidFieldset       QueryValidationErrorCode = "invalid_fieldset"
+	QueryValidationIncludeTooDeep        QueryValidationErrorCode = "include_too_deep"
+	QueryValidationTooManyIncludes       QueryValidationErrorCode = "too_many_includes"
+	QueryValidationTooManyFilters        QueryValidationErrorCode = "too_many_filters"
+	QueryValidationSearchTooLong         QueryValidationErrorCode = "search_too_long"
 )
 
 // QueryValidationError provides typed query validation failures for strict mode.
@@ -22,13 +33,22 @@ type QueryValidationError struct {
 	Field    string
 	Operator string
 	Search   string
+	Reason   string
+	// Position is the 1-based position in the filter expression, or zero.
+	Position int
 }
 
 func (e *QueryValidationError) Error() string {
 	if e == nil {
 		return "query validation error"
 	}
+	if e.Position > 0 {
+		return fmt.Sprintf("%s at position %d", e.message(), e.Position)
+	}
+	return e.message()
+}
 
+func (e *QueryValidationError) message() string {
 	switch e.Code {
 	case QueryValidationUnsupportedOperator:
 		if e.Field != "" {
@@ -37,6 +57,68 @@ func (e *QueryValidationError) Error() string {
 		return fmt.Sprintf("unsupported operator %q", e.Operator)
 	case QueryValidationSearchColumnsRequired:
 		return "search term provided but no search columns are configured"
+	case QueryValidationInvalidCursor:
+		if e.Reason != "" {
+			return e.Reason
+		}
+		return "invalid cursor"
+	case QueryValidationFilterRequired:
+		return "at least one filter or search term is required"
+	case QueryValidationInvalidFilter:
+		if e.Reason != "" {
+			return "invalid filter: " + e.Reason
+		}
+		return "invalid filter"
+	case QueryValidationFieldNotAllowed:
+		if e.Field != "" {
+			return fmt.Sprintf("field %q is not allowed", e.Field)
+		}
+		return "field is not allowed"
+	case QueryValidationInvalidArity:
+		message := fmt.Sprintf("operator %q", e.Operator)
+		if e.Field != "" {
+			message += fmt.Sprintf(" on field %q", e.Field)
+		}
+		if e.Reason != "" {
+			return message + " " + e.Reason
+		}
+		return message + " has the wrong number of values"
+	case QueryValidationInvalidValue:
+		message := "invalid value"
+		if e.Field != "" {
+			message += fmt.Sprintf(" for field %q", e.Field)
+		}
+		if e.Reason != "" {
+			return message + ": " + e.Reason
+		}
+		return message
+	case QueryValidationInvalidFieldset:
+		message := fmt.Sprintf("invalid fieldset for relation %q", e.Field)
+		if e.Reason != "" {
+			return message + ": " + e.Reason
+		}
+		return message
+	case QueryValidationIncludeTooDeep:
+		message := fmt.Sprintf("relation path %q is too deep", e.Field)
+		if e.Reason != "" {
+			return message + ": " + e.Reason
+		}
+		return message
+	case QueryValidationTooManyIncludes:
+		if e.Reason != "" {
+			return "too many includes: " + e.Reason
+		}
+		return "too many includes"
+	case QueryValidationTooManyFilters:
+		if e.Reason != "" {
+			return "too many filters: " + e.Reason
+		}
+		return "too many filters"
+	case QueryValidationSearchTooLong:
+		if e.Reason != "" {
+			return "search term is too long: " + e.Reason
+		}
+		return "search term is too long"
 	default:
 		return "query validation error"
 	}
@@ -0,0 +1,163 @@
+package crud
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	querybun "github.com/goliatone/go-crud/pkg/go-query-bun"
+	"github.com/uptrace/bun"
+)
+
+// relationFieldResolver resolves dotted filter and order fields such as
+// company.name through the relation metadata of a model. Paths made only of
+// belongs-to and has-one relations are joined; paths crossing a has-many
+// relation are matched with EXISTS. Relations pruned by the relation
+// descriptor, fields it does not expose, and fields denied by the field
+// policy do not resolve. Related models with a tenant column only match rows
+// of tenant.
+func relationFieldResolver(meta *relationMetadata, policy resolvedFieldPolicy, tenant relationTenant) querybun.RelationFieldResolver {
+	if meta == nil || len(meta.children) == 0 {
+		return nil
+	}
+	rules := canonicalRelationRules(meta, policy)
+
+	return func(field string) (querybun.RelationField, bool) {
+		dot := strings.LastIndex(field, ".")
+		if dot <= 0 {
+			return querybun.RelationField{}, false
+		}
+		path, name := field[:dot], strings.TrimSpace(field[dot+1:])
+
+		chain := make([]*relationMetadata, 0, strings.Count(path, ".")+1)
+		current := meta
+		for segment := range strings.SplitSeq(path, ".") {
+			child := current.children[strings.ToLower(strings.TrimSpace(segment))]
+			if child == nil {
+				return querybun.RelationField{}, false
+			}
+			chain = append(chain, child)
+			current = child
+		}
+
+		if !policy.allowsField(chain[0].jsonName) || !relationReadable(current, name) {
+			return querybun.RelationField{}, false
+		}
+		if !rules[relationChainPath(chain)].allows(name) {
+			return querybun.RelationField{}, false
+		}
+		return relationField(meta, chain, current.fields[name], getFieldTypes(current.typ)[name], tenant), true
+	}
+}
+
+// relationReadable reports whether name is a column of the relation that
+// its relation descriptor exposes, directly or through a field mapped to the
+// same column.
+func relationReadable(meta *relationMetadata, name string) bool {
+	if meta == nil || !relationHasColumn(meta, name) {
+		return false
+	}
+	if meta.exposed == nil {
+		return true
+	}
+	if _, ok := meta.exposed[name]; ok {
+		return true
+	}
+	for exposed := range meta.exposed {
+		if meta.fields[exposed] == meta.fields[name] {
+			return true
+		}
+	}
+	return false
+}
+
+func relationChainPath(chain []*relationMetadata) string {
+	names := make([]string, len(chain))
+	for i, rel := range chain {
+		names[i] = strings.ToLower(rel.relationName)
+	}
+	return strings.Join(names, ".")
+}
+
+// relationAlias names the table of the relation at chain[:n] in the query.
+func relationAlias(chain []*relationMetadata, n int) string {
+	return "rel__" + strings.ReplaceAll(relationChainPath(chain[:n]), ".", "__")
+}
+
+func relationField(root *relationMetadata, chain []*relationMetadata, column string, typ reflect.Type, tenant relationTenant) querybun.RelationField {
+	field := querybun.RelationField{
+		Column: relationAlias(chain, len(chain)) + "." + column,
+		Type:   typ,
+	}
+
+	toMany := false
+	for _, rel := range chain {
+		toMany = toMany || rel.toMany
+	}
+	if toMany {
+		field.Exists = func(q *bun.SelectQuery, cond string) string {
+			return relationExists(q, root, chain, cond, tenant)
+		}
+		return field
+	}
+
+	parent := root
+	for i, rel := range chain {
+		parentAlias := "?TableAlias"
+		if i > 0 {
+			parentAlias = relationAlias(chain, i)
+		}
+		base, alias := parent, relationAlias(chain, i+1)
+		field.Joins = append(field.Joins, querybun.RelationJoin{
+			Key: alias,
+			Apply: func(q *bun.SelectQuery) *bun.SelectQuery {
+				table, on := relationJoinClause(q, base, rel, parentAlias, alias)
+				if scoped := tenant.predicate(q, alias, rel.typ); scoped != "" {
+					on += " AND " + scoped
+				}
+				return q.Join(fmt.Sprintf("LEFT JOIN %s AS %s ON %s", table, alias, on))
+			},
+		})
+		parent = rel
+	}
+	return field
+}
+
+// relationExists renders EXISTS over the whole relation chain, correlated to
+// the outer row, so to-many relations do not multiply the outer rows.
+func relationExists(q *bun.SelectQuery, root *relationMetadata, chain []*relationMetadata, cond string, tenant relationTenant) string {
+	var from, where string
+	parent := root
+	for i, rel := range chain {
+		parentAlias := "?TableAlias"
+		if i > 0 {
+			parentAlias = relationAlias(chain, i)
+		}
+		alias := relationAlias(chain, i+1)
+		table, on := relationJoinClause(q, parent, rel, parentAlias, alias)
+		if scoped := tenant.predicate(q, alias, rel.typ); scoped != "" {
+			on += " AND " + scoped
+		}
+		if i == 0 {
+			from, where = fmt.Sprintf("%s AS %s", table, alias), on
+		} else {
+			from += fmt.Sprintf(" JOIN %s AS %s ON %s", table, alias, on)
+		}
+		parent = rel
+	}
+	return fmt.Sprintf("EXISTS (SELECT 1 FROM %s WHERE %s AND (%s))", from, where, cond)
+}
+
+// relationJoinClause returns the related table and the condition linking it
+// to its parent, from the bun relation between them.
+func relationJoinClause(q *bun.SelectQuery, parent, rel *relationMetadata, parentAlias, alias string) (string, string) {
+	relation, ok := q.DB().Table(parent.typ).Relations[rel.relationName]
+	if !ok {
+		return string(q.DB().Table(rel.typ).SQLName), "1 = 0"
+	}
+	parts := make([]string, len(relation.JoinPKs))
+	for i := range relation.JoinPKs {
+		parts[i] = fmt.Sprintf("%s.%s = %s.%s", alias, relation.JoinPKs[i].Name, parentAlias, relation.BasePKs[i].Name)
+	}
+	return string(relation.JoinTable.SQLName), strings.Join(parts, " AND ")
+}
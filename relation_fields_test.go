@@ -0,0 +1,107 @@
+package crud
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/goliatone/go-router"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestController_Index_RelationFilters(t *testing.T) {
+	app, db := setupApp(t)
+	defer db.Close()
+
+	ann, bob := &TestUser{Name: "Ann"}, &TestUser{Name: "Bob"}
+	insertTestUsers(t, db, ann, bob, &TestUser{Name: "Cid"})
+	insertTestProfile(t, db, ann.ID, "Remote")
+	insertTestProfile(t, db, ann.ID, "Office")
+	insertTestProfile(t, db, bob.ID, "Office")
+
+	status, payload := listRequest(t, app, "/test-users?profiles.bio=Remote")
+	require.Equal(t, http.StatusOK, status)
+	require.Len(t, payload.Data, 1)
+	assert.Equal(t, ann.ID, payload.Data[0].ID)
+
+	status, payload = listRequest(t, app, "/test-users?filter=profiles.bio%20%3D%20'Office'%20AND%20name%20!%3D%20'Ann'")
+	require.Equal(t, http.StatusOK, status)
+	require.Len(t, payload.Data, 1, "to-many matches do not repeat rows")
+	assert.Equal(t, bob.ID, payload.Data[0].ID)
+
+	status, payload = listRequest(t, app, "/test-users?profiles.bio__in=Office&order=profiles.bio%20asc")
+	require.Equal(t, http.StatusOK, status)
+	assert.Len(t, payload.Data, 2, "to-many fields are not ordered by")
+}
+
+func TestController_Index_RelationFiltersHonourFieldPolicy(t *testing.T) {
+	provider := func(req FieldPolicyRequest[*TestUser]) (FieldPolicy, error) {
+		return FieldPolicy{Name: "list:no-bio", Deny: []string{"profiles.bio"}}, nil
+	}
+	app, db := setupApp(t, WithFieldPolicyProvider(provider))
+	defer db.Close()
+
+	user := &TestUser{Name: "Ann"}
+	insertTestUsers(t, db, user, &TestUser{Name: "Bob"})
+	insertTestProfile(t, db, user.ID, "Remote")
+
+	status, payload := listRequest(t, app, "/test-users?profiles.bio=Remote")
+	require.Equal(t, http.StatusOK, status)
+	assert.Len(t, payload.Data, 2, "denied relation fields are not filtered on")
+}
+
+func TestBuildListCriteriaFromOptions_JoinedRelationFilters(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+	require.NoError(t, db.ResetModel(ctx, (*fieldsetAuthor)(nil), (*fieldsetPost)(nil)))
+	_, err := db.NewInsert().Model(&[]fieldsetAuthor{{ID: 1, Name: "Ann"}, {ID: 2, Name: "Bea"}, {ID: 3, Name: "Cid"}}).Exec(ctx)
+	require.NoError(t, err)
+	_, err = db.NewInsert().Model(&[]fieldsetPost{
+		{ID: 1, Title: "first", AuthorID: 1},
+		{ID: 2, Title: "second", AuthorID: 2},
+		{ID: 3, Title: "third", AuthorID: 3},
+	}).Exec(ctx)
+	require.NoError(t, err)
+
+	criteria, _, err := BuildListCriteriaFromOptions[*fieldsetPost](ListQueryOptions{
+		Filters: map[string]any{"author.name__in": "Ann,Bea", "id__gte": "1"},
+		Order:   "author.name desc",
+	})
+	require.NoError(t, err)
+
+	var posts []fieldsetPost
+	query := db.NewSelect().Model(&posts)
+	for _, criterion := range criteria {
+		query = criterion(query)
+	}
+	assert.Contains(t, query.String(), `LEFT JOIN "fieldset_authors" AS rel__author ON rel__author.id = "fp".author_id`)
+	require.NoError(t, query.Scan(ctx))
+	require.Len(t, posts, 2)
+	assert.Equal(t, []int64{2, 1}, []int64{posts[0].ID, posts[1].ID})
+
+	_, _, err = BuildListCriteriaFromOptions[*fieldsetPost](ListQueryOptions{Order: "author.name desc", Keyset: true})
+	var typedErr *QueryValidationError
+	require.ErrorAs(t, err, &typedErr)
+	assert.Equal(t, QueryValidationInvalidCursor, typedErr.Code)
+}
+
+func TestRelationFieldResolver_HonoursRelationDescriptor(t *testing.T) {
+	meta := buildRelationMetadata(reflect.TypeFor[fieldsetPost](), nil, make(map[reflect.Type]bool))
+	resolve := relationFieldResolver(meta, resolvedFieldPolicy{}, relationTenant{})
+	_, ok := resolve("author.name")
+	assert.True(t, ok)
+
+	meta = pruneRelationMetadata(meta, &router.RelationDescriptor{Tree: &router.RelationNode{
+		Children: map[string]*router.RelationNode{"author": {Fields: []string{"id"}}},
+	}})
+	resolve = relationFieldResolver(meta, resolvedFieldPolicy{}, relationTenant{})
+	_, ok = resolve("author.name")
+	assert.False(t, ok, "fields the descriptor does not expose do not resolve")
+	_, ok = resolve("author.id")
+	assert.True(t, ok)
+
+	meta = pruneRelationMetadata(meta, &router.RelationDescriptor{Tree: &router.RelationNode{}})
+	assert.Nil(t, relationFieldResolver(meta, resolvedFieldPolicy{}, relationTenant{}), "pruned relations do not resolve")
+}
@@ -2,6 +2,7 @@ package crud
 
 import (
 	"context"
+	"io"
 	"strings"
 
 	"github.com/goliatone/go-crud/pkg/activity"
@@ -104,6 +105,18 @@ type headerProvider interface {
 	Header(string) string
 }
 
+type routeParamsProvider interface {
+	RouteParams() map[string]string
+}
+
+type requestHeadersProvider interface {
+	RequestHeaders() map[string]string
+}
+
+type requestBodyStreamer interface {
+	BodyStream() io.Reader
+}
+
 func attachActorToRequestContext(ctx Context, actor ActorContext) {
 	if ctx == nil || actor.IsZero() {
 		return
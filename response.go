@@ -2,11 +2,16 @@ package crud
 
 import (
 	"net/http"
+
+	querybun "github.com/goliatone/go-crud/pkg/go-query-bun"
 )
 
 type NotFoundError struct{ error }
 type ValidationError struct{ error }
 
+// Unwrap exposes the wrapped error, such as a NotWritableError.
+func (e *ValidationError) Unwrap() error { return e.error }
+
 type APIResponse[T any] struct {
 	Success bool   `json:"success"`
 	Data    T      `json:"data,omitempty"`
@@ -42,6 +47,25 @@ type Filters struct {
 	Fields    []string       `json:"fields,omitempty"`
 	Include   []string       `json:"include,omitempty"`
 	Relations []RelationInfo `json:"relations,omitempty"`
+	// Fieldsets echoes the sparse fieldsets applied to included relations.
+	Fieldsets map[string][]string `json:"fieldsets,omitempty"`
+	// NextCursor and PrevCursor are set for keyset (cursor) pagination.
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+	// SearchStrategy names the strategy that resolved Search.
+	SearchStrategy string `json:"search_strategy,omitempty"`
+	// Highlights holds search snippets by record ID and then field name,
+	// when highlighting is enabled with WithSearchConfig.
+	Highlights map[string]map[string]string `json:"highlights,omitempty"`
+	// Facets holds the value counts requested with the facets query param.
+	Facets map[string][]FacetCount `json:"facets,omitempty"`
+	// LimitClamped reports that the requested limit, kept in RequestedLimit,
+	// exceeded QueryLimits.MaxLimit and Limit was lowered to it.
+	LimitClamped   bool `json:"limit_clamped,omitempty"`
+	RequestedLimit int  `json:"requested_limit,omitempty"`
+
+	keyset    *querybun.KeysetMetadata
+	cursorKey []byte
 }
 
 type Order struct {
@@ -57,6 +81,23 @@ type ResponseHandler[T any] interface {
 	OnList(ctx Context, data []T, op CrudOperation, filters *Filters) error
 }
 
+// ResultResponseHandler is implemented by response handlers that also write
+// responses other than records: batch results, aggregates, import summaries
+// and filter mutation counts. Handlers without it fall back to writing result
+// as JSON with status.
+type ResultResponseHandler interface {
+	OnResult(ctx Context, status int, result any, op CrudOperation) error
+}
+
+// writeResult responds with result through h when it implements
+// ResultResponseHandler.
+func writeResult[T any](h ResponseHandler[T], ctx Context, status int, result any, op CrudOperation) error {
+	if handler, ok := h.(ResultResponseHandler); ok {
+		return handler.OnResult(ctx, status, result, op)
+	}
+	return ctx.Status(status).JSON(result)
+}
+
 type errorEncoderAware interface {
 	setErrorEncoder(ErrorEncoder)
 }
@@ -117,6 +158,10 @@ func (h *DefaultResponseHandler[T]) OnList(c Context, data []T, op CrudOperation
 	})
 }
 
+func (h *DefaultResponseHandler[T]) OnResult(c Context, status int, result any, op CrudOperation) error {
+	return c.Status(status).JSON(result)
+}
+
 type errorEncoderResponseHandler[T any] struct {
 	base    ResponseHandler[T]
 	encoder ErrorEncoder
@@ -157,3 +202,10 @@ func (h *errorEncoderResponseHandler[T]) OnList(c Context, data []T, op CrudOper
 	}
 	return NewDefaultResponseHandler[T]().OnList(c, data, op, filters)
 }
+
+func (h *errorEncoderResponseHandler[T]) OnResult(c Context, status int, result any, op CrudOperation) error {
+	if h.base != nil {
+		return writeResult(h.base, c, status, result, op)
+	}
+	return NewDefaultResponseHandler[T]().(ResultResponseHandler).OnResult(c, status, result, op)
+}
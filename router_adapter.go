@@ -1,7 +1,9 @@
 package crud
 
 import (
+	"bytes"
 	"context"
+	"io"
 	"net/http"
 	"strings"
 
@@ -113,6 +115,15 @@ func (ca *contextAdapter) Body() []byte {
 	return ca.c.Body()
 }
 
+// BodyStream returns the request body as a reader, streaming it when the
+// router exposes the underlying net/http request.
+func (ca *contextAdapter) BodyStream() io.Reader {
+	if hc, ok := ca.c.(router.HTTPContext); ok && hc.Request() != nil && hc.Request().Body != nil {
+		return hc.Request().Body
+	}
+	return bytes.NewReader(ca.c.Body())
+}
+
 func (ca *contextAdapter) BodyParser(out any) error {
 	return ca.c.Bind(out)
 }
@@ -145,6 +156,34 @@ func (ca *contextAdapter) Header(key string) string {
 	return ca.c.Header(key)
 }
 
+func (ca *contextAdapter) SetHeader(key, value string) {
+	ca.c.SetHeader(key, value)
+}
+
+func (ca *contextAdapter) RouteParams() map[string]string {
+	return ca.c.RouteParams()
+}
+
+// RequestHeaders returns the first value of every request header when the
+// router exposes the underlying net/http request.
+func (ca *contextAdapter) RequestHeaders() map[string]string {
+	hc, ok := ca.c.(router.HTTPContext)
+	if !ok || hc.Request() == nil {
+		return nil
+	}
+	headers := make(map[string]string, len(hc.Request().Header))
+	for key, values := range hc.Request().Header {
+		if len(values) > 0 {
+			headers[key] = values[0]
+		}
+	}
+	return headers
+}
+
+func (ca *contextAdapter) SendStream(r io.Reader) error {
+	return ca.c.SendStream(r)
+}
+
 // Response interface implementation
 func (ca *contextAdapter) Status(status int) Response {
 	ca.status = status
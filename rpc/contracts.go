@@ -2,6 +2,7 @@ package rpc
 
 import (
 	commandrpc "github.com/goliatone/go-command/rpc"
+	"github.com/goliatone/go-crud"
 	repository "github.com/goliatone/go-repository-bun"
 )
 
@@ -22,14 +23,26 @@ type CreateBatchData[T any] struct {
 type UpdateData[T any] struct {
 	ID     string `json:"id"`
 	Record T      `json:"record"`
+	// ExpectedVersion mirrors HTTP If-Match: the update fails with
+	// crud.PreconditionFailedError when the stored version differs.
+	ExpectedVersion string `json:"expected_version,omitempty"`
 }
 
 type UpdateBatchData[T any] struct {
 	Records []T `json:"records"`
 }
 
+type UpsertData[T any] struct {
+	Record T `json:"record"`
+}
+
+type UpsertBatchData[T any] struct {
+	Records []T `json:"records"`
+}
+
 type DeleteData struct {
-	ID string `json:"id"`
+	ID              string `json:"id"`
+	ExpectedVersion string `json:"expected_version,omitempty"`
 }
 
 type DeleteBatchData[T any] struct {
@@ -37,6 +50,14 @@ type DeleteBatchData[T any] struct {
 	IDs     []string `json:"ids,omitempty"`
 }
 
+type RestoreData struct {
+	ID string `json:"id"`
+}
+
+type PurgeData struct {
+	ID string `json:"id"`
+}
+
 type ShowData struct {
 	ID       string                      `json:"id"`
 	Criteria []repository.SelectCriteria `json:"criteria,omitempty"`
@@ -48,8 +69,12 @@ type IndexData[Opts any] struct {
 }
 
 type ListResult[T any] struct {
-	Items []T `json:"items"`
-	Count int `json:"count"`
+	Items      []T    `json:"items"`
+	Count      int    `json:"count"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+	// Facets holds the value counts of the fields in Options.Facets.
+	Facets map[string][]crud.FacetCount `json:"facets,omitempty"`
 }
 
 type DeleteResult struct {
@@ -59,3 +84,7 @@ type DeleteResult struct {
 type DeleteBatchResult struct {
 	Count int `json:"count"`
 }
+
+type PurgeResult struct {
+	Purged bool `json:"purged"`
+}
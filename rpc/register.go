@@ -66,7 +66,9 @@ func RegisterResourceEndpoints[T any](
 			req RequestEnvelope[CreateData[T]],
 		) (ResponseEnvelope[T], error) {
 			rpcCtx := newRequestContext(ctx, req.Meta)
-			record, err := controller.CreateRecord(rpcCtx, req.Data.Record)
+			record, err := crud.RunIdempotent(rpcCtx, controller, crud.OpCreate, req.Data, func() (T, error) {
+				return controller.CreateRecord(rpcCtx, req.Data.Record)
+			})
 			if err != nil {
 				return ResponseEnvelope[T]{}, err
 			}
@@ -80,7 +82,9 @@ func RegisterResourceEndpoints[T any](
 			req RequestEnvelope[CreateBatchData[T]],
 		) (ResponseEnvelope[ListResult[T]], error) {
 			rpcCtx := newRequestContext(ctx, req.Meta)
-			records, err := controller.CreateRecords(rpcCtx, req.Data.Records)
+			records, err := crud.RunIdempotent(rpcCtx, controller, crud.OpCreateBatch, req.Data, func() ([]T, error) {
+				return controller.CreateRecords(rpcCtx, req.Data.Records)
+			})
 			if err != nil {
 				return ResponseEnvelope[ListResult[T]]{}, err
 			}
@@ -114,7 +118,7 @@ func RegisterResourceEndpoints[T any](
 			req RequestEnvelope[IndexData[crud.ListQueryOptions]],
 		) (ResponseEnvelope[ListResult[T]], error) {
 			rpcCtx := newRequestContext(ctx, req.Meta)
-			criteria, err := buildIndexCriteria[T](req.Data.Options, req.Data.Criteria)
+			criteria, filters, err := buildIndexCriteria[T](req.Data.Options, req.Data.Criteria, controller.QueryOptions()...)
 			if err != nil {
 				return ResponseEnvelope[ListResult[T]]{}, err
 			}
@@ -122,9 +126,45 @@ func RegisterResourceEndpoints[T any](
 			if err != nil {
 				return ResponseEnvelope[ListResult[T]]{}, err
 			}
-			return ResponseEnvelope[ListResult[T]]{
-				Data: ListResult[T]{Items: records, Count: count},
-			}, nil
+			result := ListResult[T]{Items: records, Count: count}
+			if filters != nil {
+				result.Items, err = crud.ApplyCursorPage(filters, records, count)
+				if err != nil {
+					return ResponseEnvelope[ListResult[T]]{}, err
+				}
+				result.NextCursor = filters.NextCursor
+				result.PrevCursor = filters.PrevCursor
+			}
+			if len(req.Data.Options.Facets) > 0 {
+				facetCriteria, err := crud.BuildFacetCriteriaFromOptions[T](req.Data.Options, controller.QueryOptions()...)
+				if err != nil {
+					return ResponseEnvelope[ListResult[T]]{}, err
+				}
+				result.Facets, err = controller.FacetsWith(rpcCtx, facetCriteria)
+				if err != nil {
+					return ResponseEnvelope[ListResult[T]]{}, err
+				}
+			}
+			return ResponseEnvelope[ListResult[T]]{Data: result}, nil
+		}),
+		commandrpc.NewEndpoint[IndexData[crud.ListQueryOptions], crud.AggregateResult](commandrpc.EndpointSpec{
+			Method: methodFor("aggregate"),
+			Kind:   commandrpc.MethodKindQuery,
+		}, func(
+			ctx context.Context,
+			req RequestEnvelope[IndexData[crud.ListQueryOptions]],
+		) (ResponseEnvelope[crud.AggregateResult], error) {
+			rpcCtx := newRequestContext(ctx, req.Meta)
+			criteria, err := crud.BuildAggregateCriteriaFromOptions[T](req.Data.Options, controller.QueryOptions()...)
+			if err != nil {
+				return ResponseEnvelope[crud.AggregateResult]{}, err
+			}
+			criteria = append(criteria, req.Data.Criteria...)
+			result, err := controller.AggregateWith(rpcCtx, req.Data.Options.Aggregate, criteria)
+			if err != nil {
+				return ResponseEnvelope[crud.AggregateResult]{}, err
+			}
+			return ResponseEnvelope[crud.AggregateResult]{Data: result}, nil
 		}),
 		commandrpc.NewEndpoint[UpdateData[T], T](commandrpc.EndpointSpec{
 			Method: methodFor("update"),
@@ -133,12 +173,14 @@ func RegisterResourceEndpoints[T any](
 			ctx context.Context,
 			req RequestEnvelope[UpdateData[T]],
 		) (ResponseEnvelope[T], error) {
-			rpcCtx := newRequestContext(ctx, req.Meta)
+			rpcCtx := newRequestContext(crud.ContextWithExpectedVersion(ctx, req.Data.ExpectedVersion), req.Meta)
 			id := strings.TrimSpace(req.Data.ID)
 			if id == "" {
 				id = strings.TrimSpace(req.Meta.Params["id"])
 			}
-			record, err := controller.UpdateRecord(rpcCtx, id, req.Data.Record)
+			record, err := crud.RunIdempotent(rpcCtx, controller, crud.OpUpdate, []any{id, req.Data}, func() (T, error) {
+				return controller.UpdateRecord(rpcCtx, id, req.Data.Record)
+			})
 			if err != nil {
 				return ResponseEnvelope[T]{}, err
 			}
@@ -160,6 +202,36 @@ func RegisterResourceEndpoints[T any](
 				Data: ListResult[T]{Items: records, Count: len(records)},
 			}, nil
 		}),
+		commandrpc.NewEndpoint[UpsertData[T], T](commandrpc.EndpointSpec{
+			Method: methodFor("upsert"),
+			Kind:   commandrpc.MethodKindCommand,
+		}, func(
+			ctx context.Context,
+			req RequestEnvelope[UpsertData[T]],
+		) (ResponseEnvelope[T], error) {
+			rpcCtx := newRequestContext(ctx, req.Meta)
+			record, err := controller.UpsertRecord(rpcCtx, req.Data.Record)
+			if err != nil {
+				return ResponseEnvelope[T]{}, err
+			}
+			return ResponseEnvelope[T]{Data: record}, nil
+		}),
+		commandrpc.NewEndpoint[UpsertBatchData[T], ListResult[T]](commandrpc.EndpointSpec{
+			Method: methodFor("upsert_batch"),
+			Kind:   commandrpc.MethodKindCommand,
+		}, func(
+			ctx context.Context,
+			req RequestEnvelope[UpsertBatchData[T]],
+		) (ResponseEnvelope[ListResult[T]], error) {
+			rpcCtx := newRequestContext(ctx, req.Meta)
+			records, err := controller.UpsertRecords(rpcCtx, req.Data.Records)
+			if err != nil {
+				return ResponseEnvelope[ListResult[T]]{}, err
+			}
+			return ResponseEnvelope[ListResult[T]]{
+				Data: ListResult[T]{Items: records, Count: len(records)},
+			}, nil
+		}),
 		commandrpc.NewEndpoint[DeleteData, DeleteResult](commandrpc.EndpointSpec{
 			Method: methodFor("delete"),
 			Kind:   commandrpc.MethodKindCommand,
@@ -167,7 +239,7 @@ func RegisterResourceEndpoints[T any](
 			ctx context.Context,
 			req RequestEnvelope[DeleteData],
 		) (ResponseEnvelope[DeleteResult], error) {
-			rpcCtx := newRequestContext(ctx, req.Meta)
+			rpcCtx := newRequestContext(crud.ContextWithExpectedVersion(ctx, req.Data.ExpectedVersion), req.Meta)
 			id := strings.TrimSpace(req.Data.ID)
 			if id == "" {
 				id = strings.TrimSpace(req.Meta.Params["id"])
@@ -199,10 +271,103 @@ func RegisterResourceEndpoints[T any](
 			return ResponseEnvelope[DeleteBatchResult]{Data: DeleteBatchResult{Count: len(records)}}, nil
 		}),
 	}
+	if controller.SupportsSoftDelete() {
+		defs = append(defs, softDeleteEndpoints(controller, methodFor)...)
+	}
 
 	return server.RegisterEndpoints(defs...)
 }
 
+// softDeleteEndpoints registers restore commands for models with a
+// soft_delete column, and purge commands when the controller's RouteConfig
+// enables crud.OpPurge and crud.OpPurgeBatch.
+func softDeleteEndpoints[T any](controller *crud.Controller[T], methodFor func(string) string) []commandrpc.EndpointDefinition {
+	recordsOf := func(data DeleteBatchData[T]) ([]T, error) {
+		if len(data.Records) == 0 && len(data.IDs) > 0 {
+			return controller.RecordsFromIDs(data.IDs)
+		}
+		return data.Records, nil
+	}
+	idOf := func(id string, meta RequestMeta) string {
+		if id = strings.TrimSpace(id); id == "" {
+			id = strings.TrimSpace(meta.Params["id"])
+		}
+		return id
+	}
+
+	defs := []commandrpc.EndpointDefinition{
+		commandrpc.NewEndpoint[RestoreData, T](commandrpc.EndpointSpec{
+			Method: methodFor("restore"),
+			Kind:   commandrpc.MethodKindCommand,
+		}, func(
+			ctx context.Context,
+			req RequestEnvelope[RestoreData],
+		) (ResponseEnvelope[T], error) {
+			rpcCtx := newRequestContext(ctx, req.Meta)
+			record, err := controller.RestoreByID(rpcCtx, idOf(req.Data.ID, req.Meta))
+			if err != nil {
+				return ResponseEnvelope[T]{}, err
+			}
+			return ResponseEnvelope[T]{Data: record}, nil
+		}),
+		commandrpc.NewEndpoint[DeleteBatchData[T], ListResult[T]](commandrpc.EndpointSpec{
+			Method: methodFor("restore_batch"),
+			Kind:   commandrpc.MethodKindCommand,
+		}, func(
+			ctx context.Context,
+			req RequestEnvelope[DeleteBatchData[T]],
+		) (ResponseEnvelope[ListResult[T]], error) {
+			rpcCtx := newRequestContext(ctx, req.Meta)
+			records, err := recordsOf(req.Data)
+			if err != nil {
+				return ResponseEnvelope[ListResult[T]]{}, err
+			}
+			restored, err := controller.RestoreRecords(rpcCtx, records)
+			if err != nil {
+				return ResponseEnvelope[ListResult[T]]{}, err
+			}
+			return ResponseEnvelope[ListResult[T]]{
+				Data: ListResult[T]{Items: restored, Count: len(restored)},
+			}, nil
+		}),
+	}
+	if controller.RouteEnabled(crud.OpPurge) {
+		defs = append(defs, commandrpc.NewEndpoint[PurgeData, PurgeResult](commandrpc.EndpointSpec{
+			Method: methodFor("purge"),
+			Kind:   commandrpc.MethodKindCommand,
+		}, func(
+			ctx context.Context,
+			req RequestEnvelope[PurgeData],
+		) (ResponseEnvelope[PurgeResult], error) {
+			rpcCtx := newRequestContext(ctx, req.Meta)
+			if err := controller.PurgeByID(rpcCtx, idOf(req.Data.ID, req.Meta)); err != nil {
+				return ResponseEnvelope[PurgeResult]{}, err
+			}
+			return ResponseEnvelope[PurgeResult]{Data: PurgeResult{Purged: true}}, nil
+		}))
+	}
+	if controller.RouteEnabled(crud.OpPurgeBatch) {
+		defs = append(defs, commandrpc.NewEndpoint[DeleteBatchData[T], DeleteBatchResult](commandrpc.EndpointSpec{
+			Method: methodFor("purge_batch"),
+			Kind:   commandrpc.MethodKindCommand,
+		}, func(
+			ctx context.Context,
+			req RequestEnvelope[DeleteBatchData[T]],
+		) (ResponseEnvelope[DeleteBatchResult], error) {
+			rpcCtx := newRequestContext(ctx, req.Meta)
+			records, err := recordsOf(req.Data)
+			if err != nil {
+				return ResponseEnvelope[DeleteBatchResult]{}, err
+			}
+			if err := controller.PurgeRecords(rpcCtx, records); err != nil {
+				return ResponseEnvelope[DeleteBatchResult]{}, err
+			}
+			return ResponseEnvelope[DeleteBatchResult]{Data: DeleteBatchResult{Count: len(records)}}, nil
+		}))
+	}
+	return defs
+}
+
 func resolveResourceName[T any](explicit string) string {
 	if value := strings.TrimSpace(explicit); value != "" {
 		return value
@@ -224,26 +389,27 @@ func resolveResourceName[T any](explicit string) string {
 	return strings.TrimSpace(resource)
 }
 
-func buildIndexCriteria[T any](opts crud.ListQueryOptions, criteria []repository.SelectCriteria) ([]repository.SelectCriteria, error) {
+func buildIndexCriteria[T any](opts crud.ListQueryOptions, criteria []repository.SelectCriteria, qbOpts ...crud.QueryBuilderOption) ([]repository.SelectCriteria, *crud.Filters, error) {
 	out := append([]repository.SelectCriteria(nil), criteria...)
 	if hasListQueryOptions(opts) {
-		built, _, err := crud.BuildListCriteriaFromOptions[T](opts)
+		built, filters, err := crud.BuildListCriteriaFromOptions[T](opts, qbOpts...)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		out = append(out, built...)
-		return out, nil
+		return out, filters, nil
 	}
 
 	if len(out) == 0 {
-		defaulted, _, err := crud.BuildListCriteriaFromOptions[T](crud.ListQueryOptions{})
+		defaulted, filters, err := crud.BuildListCriteriaFromOptions[T](crud.ListQueryOptions{}, qbOpts...)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		out = append(out, defaulted...)
+		return out, filters, nil
 	}
 
-	return out, nil
+	return out, nil, nil
 }
 
 func hasListQueryOptions(opts crud.ListQueryOptions) bool {
@@ -257,6 +423,13 @@ func hasListQueryOptions(opts crud.ListQueryOptions) bool {
 		opts.Search != "" ||
 		len(opts.Filters) > 0 ||
 		len(opts.Predicates) > 0 ||
+		opts.Filter != "" ||
+		opts.FilterTree != nil ||
 		len(opts.Select) > 0 ||
-		len(opts.Include) > 0
+		len(opts.Include) > 0 ||
+		len(opts.Fieldsets) > 0 ||
+		opts.Cursor != "" ||
+		opts.Keyset ||
+		opts.WithDeleted ||
+		opts.OnlyDeleted
 }
@@ -60,7 +60,7 @@ func (f *fakeRegistrar) RegisterEndpoints(defs ...commandrpc.EndpointDefinition)
 	return nil
 }
 
-func setupRPCController(t *testing.T) (*crud.Controller[*rpcUser], repository.Repository[*rpcUser], *bun.DB) {
+func setupRPCController(t *testing.T, opts ...crud.Option[*rpcUser]) (*crud.Controller[*rpcUser], repository.Repository[*rpcUser], *bun.DB) {
 	t.Helper()
 
 	sqldb, err := sql.Open("sqlite3", "file::memory:?cache=shared")
@@ -86,13 +86,13 @@ func setupRPCController(t *testing.T) (*crud.Controller[*rpcUser], repository.Re
 
 	controller := crud.NewController[*rpcUser](
 		repo,
-		crud.WithScopeGuard[*rpcUser](func(ctx crud.Context, _ crud.CrudOperation) (crud.ActorContext, crud.ScopeFilter, error) {
+		append([]crud.Option[*rpcUser]{crud.WithScopeGuard[*rpcUser](func(ctx crud.Context, _ crud.CrudOperation) (crud.ActorContext, crud.ScopeFilter, error) {
 			actor := crud.ActorFromContext(ctx.UserContext())
 			if actor.ActorID == "" {
 				return crud.ActorContext{}, crud.ScopeFilter{}, fmt.Errorf("actor required")
 			}
 			return actor, crud.ScopeFilter{}, nil
-		}),
+		})}, opts...)...,
 	)
 
 	return controller, repo, db
@@ -135,6 +135,7 @@ func TestRegisterResourceEndpointsRegistersExpectedMethods(t *testing.T) {
 	assert.Contains(t, registrar.endpoints, "crud.user.update_batch")
 	assert.Contains(t, registrar.endpoints, "crud.user.delete")
 	assert.Contains(t, registrar.endpoints, "crud.user.delete_batch")
+	assert.NotContains(t, registrar.endpoints, "crud.user.restore", "restore needs a soft_delete column")
 
 	assert.Equal(t, commandrpc.MethodKindCommand, mustEndpoint(t, registrar, "crud.user.create").Spec().Kind)
 	assert.Equal(t, commandrpc.MethodKindQuery, mustEndpoint(t, registrar, "crud.user.show").Spec().Kind)
@@ -289,3 +290,205 @@ func TestRegisterResourceEndpointsRoundTrip(t *testing.T) {
 	require.NoError(t, err)
 	assert.Len(t, records, 0)
 }
+
+func TestRegisterResourceEndpointsCreateIsIdempotent(t *testing.T) {
+	controller, _, db := setupRPCController(t, crud.WithIdempotency[*rpcUser](crud.NewMemoryIdempotencyStore()))
+	registrar := newFakeRegistrar()
+	require.NoError(t, RegisterResourceEndpoints(registrar, controller, ResourceRegistrationOptions{Resource: "user"}))
+
+	req := RequestEnvelope[CreateData[*rpcUser]]{
+		Data: CreateData[*rpcUser]{
+			Record: &rpcUser{ID: uuid.New(), Name: "Idem", Email: "idem@example.com"},
+		},
+		Meta: RequestMeta{
+			ActorID: "actor-1",
+			Headers: map[string]string{crud.IdempotencyKeyHeader: "rpc-create-1"},
+		},
+	}
+	endpoint := mustEndpoint(t, registrar, "crud.user.create")
+	first := mustInvokeEndpoint[CreateData[*rpcUser], *rpcUser](t, endpoint, req)
+	second := mustInvokeEndpoint[CreateData[*rpcUser], *rpcUser](t, endpoint, req)
+	require.NotNil(t, first.Data)
+	require.NotNil(t, second.Data)
+	assert.Equal(t, first.Data.ID, second.Data.ID)
+
+	count, err := db.NewSelect().Model((*rpcUser)(nil)).Count(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+type rpcNote struct {
+	bun.BaseModel `bun:"table:rpc_notes"`
+	ID            uuid.UUID `bun:"id,pk,notnull" json:"id"`
+	Title         string    `bun:"title" json:"title"`
+	DeletedAt     time.Time `bun:"deleted_at,soft_delete,nullzero" json:"deleted_at,omitempty"`
+}
+
+func TestRegisterResourceEndpointsSoftDelete(t *testing.T) {
+	_, _, db := setupRPCController(t)
+	ctx := context.Background()
+	_, err := db.NewCreateTable().Model((*rpcNote)(nil)).IfNotExists().Exec(ctx)
+	require.NoError(t, err)
+
+	repo := repository.NewRepository(db, repository.ModelHandlers[*rpcNote]{
+		NewRecord:     func() *rpcNote { return &rpcNote{} },
+		GetID:         func(record *rpcNote) uuid.UUID { return record.ID },
+		SetID:         func(record *rpcNote, id uuid.UUID) { record.ID = id },
+		GetIdentifier: func() string { return "Title" },
+	})
+	registrar := newFakeRegistrar()
+	require.NoError(t, RegisterResourceEndpoints(registrar, crud.NewController(repo), ResourceRegistrationOptions{Resource: "note"}))
+	assert.Contains(t, registrar.endpoints, "crud.note.restore")
+	assert.NotContains(t, registrar.endpoints, "crud.note.purge", "purge is opt-in")
+	assert.NotContains(t, registrar.endpoints, "crud.note.purge_batch")
+
+	registrar = newFakeRegistrar()
+	controller := crud.NewController(repo, crud.WithRouteConfig[*rpcNote](crud.RouteConfig{
+		Operations: map[crud.CrudOperation]crud.RouteOptions{
+			crud.OpPurge:      {Enabled: new(true)},
+			crud.OpPurgeBatch: {Enabled: new(true)},
+		},
+	}))
+	require.NoError(t, RegisterResourceEndpoints(registrar, controller, ResourceRegistrationOptions{Resource: "note"}))
+
+	note := &rpcNote{ID: uuid.New(), Title: "Trashed"}
+	_, err = db.NewInsert().Model(note).Exec(ctx)
+	require.NoError(t, err)
+	_, err = db.NewDelete().Model(note).WherePK().Exec(ctx)
+	require.NoError(t, err)
+
+	restored := mustInvokeEndpoint[RestoreData, *rpcNote](t, mustEndpoint(t, registrar, "crud.note.restore"), RequestEnvelope[RestoreData]{
+		Data: RestoreData{ID: note.ID.String()},
+	})
+	require.NotNil(t, restored.Data)
+	assert.True(t, restored.Data.DeletedAt.IsZero())
+
+	purged := mustInvokeEndpoint[PurgeData, PurgeResult](t, mustEndpoint(t, registrar, "crud.note.purge"), RequestEnvelope[PurgeData]{
+		Data: PurgeData{ID: note.ID.String()},
+	})
+	assert.True(t, purged.Data.Purged)
+
+	count, err := db.NewSelect().Model((*rpcNote)(nil)).WhereAllWithDeleted().Count(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, count)
+	assert.Contains(t, registrar.endpoints, "crud.note.restore_batch")
+	assert.Contains(t, registrar.endpoints, "crud.note.purge_batch")
+}
+
+func TestRegisterResourceEndpointsAggregate(t *testing.T) {
+	controller, _, db := setupRPCController(t)
+	registrar := newFakeRegistrar()
+	require.NoError(t, RegisterResourceEndpoints(registrar, controller, ResourceRegistrationOptions{Resource: "user"}))
+
+	now := time.Now().UTC()
+	for _, name := range []string{"Ann", "Ann", "Bob"} {
+		_, err := db.NewInsert().Model(&rpcUser{
+			ID: uuid.New(), Name: name, Email: uuid.NewString() + "@example.com", CreatedAt: now, UpdatedAt: now,
+		}).Exec(context.Background())
+		require.NoError(t, err)
+	}
+
+	endpoint := mustEndpoint(t, registrar, "crud.user.aggregate")
+	assert.Equal(t, commandrpc.MethodKindQuery, endpoint.Spec().Kind)
+	res := mustInvokeEndpoint[IndexData[crud.ListQueryOptions], crud.AggregateResult](t, endpoint, RequestEnvelope[IndexData[crud.ListQueryOptions]]{
+		Data: IndexData[crud.ListQueryOptions]{
+			Options: crud.ListQueryOptions{
+				Filters:   map[string]any{"name": "Ann"},
+				Aggregate: crud.AggregateSpec{GroupBy: []string{"name"}},
+			},
+		},
+		Meta: RequestMeta{ActorID: "actor-1"},
+	})
+	require.Len(t, res.Data.Groups, 1)
+	assert.Equal(t, "Ann", res.Data.Groups[0].Key["name"])
+	assert.EqualValues(t, 2, res.Data.Groups[0].Count)
+}
+
+func TestRegisterResourceEndpointsIndexFacets(t *testing.T) {
+	controller, _, db := setupRPCController(t)
+	registrar := newFakeRegistrar()
+	require.NoError(t, RegisterResourceEndpoints(registrar, controller, ResourceRegistrationOptions{Resource: "user"}))
+
+	now := time.Now().UTC()
+	for _, name := range []string{"Ann", "Ann", "Bob"} {
+		_, err := db.NewInsert().Model(&rpcUser{
+			ID: uuid.New(), Name: name, Email: uuid.NewString() + "@example.com", CreatedAt: now, UpdatedAt: now,
+		}).Exec(context.Background())
+		require.NoError(t, err)
+	}
+
+	res := mustInvokeEndpoint[IndexData[crud.ListQueryOptions], ListResult[*rpcUser]](t, mustEndpoint(t, registrar, "crud.user.index"), RequestEnvelope[IndexData[crud.ListQueryOptions]]{
+		Data: IndexData[crud.ListQueryOptions]{
+			Options: crud.ListQueryOptions{
+				Filters: map[string]any{"name": "Bob"},
+				Facets:  []string{"name"},
+			},
+		},
+		Meta: RequestMeta{ActorID: "actor-1"},
+	})
+	assert.Equal(t, 1, res.Data.Count)
+	assert.Equal(t, map[string][]crud.FacetCount{
+		"name": {{Value: "Ann", Count: 2}, {Value: "Bob", Count: 1}},
+	}, res.Data.Facets, "the facet ignores its own filter")
+}
+
+func TestRegisterResourceEndpointsIndexUsesControllerQueryLimits(t *testing.T) {
+	controller, _, db := setupRPCController(t, crud.WithQueryLimitsConfig[*rpcUser](crud.QueryLimits{MaxLimit: 2, MaxFilterPredicates: 1}))
+	registrar := newFakeRegistrar()
+	require.NoError(t, RegisterResourceEndpoints(registrar, controller, ResourceRegistrationOptions{Resource: "user"}))
+
+	now := time.Now().UTC()
+	for _, name := range []string{"Ann", "Bob", "Cid"} {
+		_, err := db.NewInsert().Model(&rpcUser{
+			ID: uuid.New(), Name: name, Email: uuid.NewString() + "@example.com", CreatedAt: now, UpdatedAt: now,
+		}).Exec(context.Background())
+		require.NoError(t, err)
+	}
+
+	index := mustEndpoint(t, registrar, "crud.user.index")
+	res := mustInvokeEndpoint[IndexData[crud.ListQueryOptions], ListResult[*rpcUser]](t, index, RequestEnvelope[IndexData[crud.ListQueryOptions]]{
+		Data: IndexData[crud.ListQueryOptions]{Options: crud.ListQueryOptions{Limit: 500}},
+		Meta: RequestMeta{ActorID: "actor-1"},
+	})
+	assert.Len(t, res.Data.Items, 2, "the controller's MaxLimit clamps RPC lists")
+
+	_, err := index.Invoke(context.Background(), &RequestEnvelope[IndexData[crud.ListQueryOptions]]{
+		Data: IndexData[crud.ListQueryOptions]{Options: crud.ListQueryOptions{
+			Filters: map[string]any{"name": "Ann", "email": "ann@example.com"},
+		}},
+		Meta: RequestMeta{ActorID: "actor-1"},
+	})
+	var typedErr *crud.QueryValidationError
+	require.ErrorAs(t, err, &typedErr)
+	assert.Equal(t, crud.QueryValidationTooManyFilters, typedErr.Code)
+}
+
+func TestRegisterResourceEndpointsUpsert(t *testing.T) {
+	controller, _, db := setupRPCController(t)
+	registrar := newFakeRegistrar()
+	require.NoError(t, RegisterResourceEndpoints(registrar, controller, ResourceRegistrationOptions{Resource: "user"}))
+
+	upsert := mustEndpoint(t, registrar, "crud.user.upsert")
+	assert.Equal(t, commandrpc.MethodKindCommand, upsert.Spec().Kind)
+	meta := RequestMeta{ActorID: "actor-1"}
+
+	first := mustInvokeEndpoint[UpsertData[*rpcUser], *rpcUser](t, upsert, RequestEnvelope[UpsertData[*rpcUser]]{
+		Data: UpsertData[*rpcUser]{Record: &rpcUser{Name: "Ann", Email: "ann@example.com"}},
+		Meta: meta,
+	})
+	require.NotEqual(t, uuid.Nil, first.Data.ID)
+
+	batch := mustInvokeEndpoint[UpsertBatchData[*rpcUser], ListResult[*rpcUser]](t, mustEndpoint(t, registrar, "crud.user.upsert_batch"), RequestEnvelope[UpsertBatchData[*rpcUser]]{
+		Data: UpsertBatchData[*rpcUser]{Records: []*rpcUser{
+			{Name: "Annie", Email: "ann@example.com"},
+			{Name: "Bob", Email: "bob@example.com"},
+		}},
+		Meta: meta,
+	})
+	require.Equal(t, 2, batch.Data.Count)
+	assert.Equal(t, first.Data.ID, batch.Data.Items[0].ID, "conflict on the identifier keeps the stored row")
+
+	count, err := db.NewSelect().Model((*rpcUser)(nil)).Count(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
@@ -21,6 +21,8 @@ type ActorContext struct {
 	Metadata       map[string]any
 	ImpersonatorID string
 	IsImpersonated bool
+	// Capabilities grants actor-wide privileges such as BypassTenancy.
+	Capabilities []string
 }
 
 // Clone returns a shallow copy guarding internal maps from mutation.
@@ -34,9 +36,15 @@ func (a ActorContext) Clone() ActorContext {
 		clone.Metadata = make(map[string]any, len(a.Metadata))
 		maps.Copy(clone.Metadata, a.Metadata)
 	}
+	clone.Capabilities = slices.Clone(a.Capabilities)
 	return clone
 }
 
+// HasCapability reports whether the actor was granted capability.
+func (a ActorContext) HasCapability(capability string) bool {
+	return slices.Contains(a.Capabilities, capability)
+}
+
 // IsZero reports whether the actor context carries any meaningful identifier.
 func (a ActorContext) IsZero() bool {
 	return a.ActorID == "" &&
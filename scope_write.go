@@ -0,0 +1,221 @@
+package crud
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ScopeViolationError reports a write whose record falls outside the scope
+// returned by the guard: Column holds Value where the scope allows Allowed.
+// The JSON error encoder reports it as 403 SCOPE_VIOLATION.
+type ScopeViolationError struct {
+	Operation CrudOperation
+	Column    string
+	Value     string
+	Allowed   []string
+}
+
+func (e *ScopeViolationError) Error() string {
+	if e.Value == "" {
+		return fmt.Sprintf("%s requires %s to be one of %s", e.Operation, e.Column, strings.Join(e.Allowed, ", "))
+	}
+	return fmt.Sprintf("%s of %s %q is outside the request scope", e.Operation, e.Column, e.Value)
+}
+
+// enforceScopeOnRecord checks the columns restricted by scope on a record
+// being written. Empty columns the scope pins to a single value are filled
+// with it; any other value must satisfy the filter. Filters on columns the
+// record does not map, and comparison filters (<, >, …), are left to the
+// read path.
+func enforceScopeOnRecord[T any](scope ScopeFilter, op CrudOperation, record T) (T, error) {
+	return enforceScopeOnFields(scope, op, record, nil)
+}
+
+// enforceScopeOnFields is enforceScopeOnRecord limited to the JSON fields a
+// filter update assigns; nil fields checks every column.
+func enforceScopeOnFields[T any](scope ScopeFilter, op CrudOperation, record T, fields []string) (T, error) {
+	if scope.Bypass || !scope.HasFilters() || isNil(record) {
+		return record, nil
+	}
+	target := reflect.ValueOf(record)
+	if target.Kind() != reflect.Pointer {
+		ptr := reflect.New(target.Type())
+		ptr.Elem().Set(target)
+		target = ptr
+	}
+	rv, ok := recordStructValue(target.Interface())
+	if !ok {
+		return record, nil
+	}
+	if err := applyScopeColumns(scope, op, rv, fields); err != nil {
+		return record, err
+	}
+	if reflect.ValueOf(record).Kind() != reflect.Pointer {
+		return target.Elem().Interface().(T), nil
+	}
+	return record, nil
+}
+
+// enforceScopeOnRecords applies enforceScopeOnRecord to records in place.
+func enforceScopeOnRecords[T any](scope ScopeFilter, op CrudOperation, records []T) error {
+	for i, record := range records {
+		var err error
+		if records[i], err = enforceScopeOnRecord(scope, op, record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scopedBaseline returns a new record holding only the values scope assigns,
+// or the zero value when it assigns none. Writable field checks compare
+// creates against it so scope-filled columns never count as client writes.
+func scopedBaseline[T any](scope ScopeFilter) T {
+	var zero T
+	if scope.Bypass || !scope.HasFilters() {
+		return zero
+	}
+	typ := reflect.TypeFor[T]()
+	pointer := typ.Kind() == reflect.Pointer
+	if pointer {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return zero
+	}
+	baseline := reflect.New(typ)
+	// The baseline is only compared against, so unassignable values are
+	// left zero; the write itself reports them.
+	_ = applyScopeColumns(scope, "", baseline.Elem(), nil)
+	if pointer {
+		return baseline.Interface().(T)
+	}
+	return baseline.Elem().Interface().(T)
+}
+
+func applyScopeColumns(scope ScopeFilter, op CrudOperation, rv reflect.Value, fields []string) error {
+	indexes := columnIndexes(rv.Type())
+	for _, filter := range scope.ColumnFilters {
+		column := filter.Column
+		if idx := strings.LastIndex(column, "."); idx >= 0 {
+			column = column[idx+1:]
+		}
+		index, ok := indexes[column]
+		if !ok || len(filter.Values) == 0 {
+			continue
+		}
+		if fields != nil && !slices.Contains(fields, jsonFieldName(rv.Type().FieldByIndex(index))) {
+			continue
+		}
+		field, err := rv.FieldByIndexErr(index)
+		if err != nil {
+			continue
+		}
+
+		operator := strings.ToUpper(strings.TrimSpace(filter.Operator))
+		if operator == "" {
+			operator = "="
+		}
+		if len(filter.Values) > 1 && operator == "=" {
+			operator = "IN"
+		}
+		value, set := scopeFieldString(field)
+		var allowed bool
+		switch operator {
+		case "=", "IN":
+			if !set && len(filter.Values) == 1 {
+				if err := assignScopeValue(field, filter.Values[0]); err != nil {
+					return fmt.Errorf("crud: assign scope column %s: %w", filter.Column, err)
+				}
+				continue
+			}
+			allowed = set && slices.Contains(filter.Values, value)
+		case "!=", "<>", "NOT IN":
+			allowed = !slices.Contains(filter.Values, value)
+		default:
+			continue
+		}
+		if !allowed {
+			return &ScopeViolationError{Operation: op, Column: filter.Column, Value: value, Allowed: slices.Clone(filter.Values)}
+		}
+	}
+	return nil
+}
+
+// scopeFieldString formats a column value the way scope filters spell it.
+// Zero values are reported as unset.
+func scopeFieldString(field reflect.Value) (string, bool) {
+	for field.Kind() == reflect.Pointer {
+		if field.IsNil() {
+			return "", false
+		}
+		field = field.Elem()
+	}
+	if field.IsZero() {
+		return "", false
+	}
+	return fmt.Sprint(field.Interface()), true
+}
+
+func assignScopeValue(field reflect.Value, raw string) error {
+	if field.Kind() == reflect.Pointer {
+		elem := reflect.New(field.Type().Elem())
+		if err := assignScopeValue(elem.Elem(), raw); err != nil {
+			return err
+		}
+		field.Set(elem)
+		return nil
+	}
+	if unmarshaler, ok := field.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return unmarshaler.UnmarshalText([]byte(raw))
+	}
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported %s field", field.Type())
+	}
+	return nil
+}
+
+// enforceCreateWrite applies the writable fields of policy and then scope to a
+// record about to be created or upserted.
+func enforceCreateWrite[T any](policy resolvedFieldPolicy, scope ScopeFilter, op CrudOperation, record T) (T, error) {
+	record, err := enforceWritableCreate(policy, scope, op, record)
+	if err != nil {
+		return record, err
+	}
+	return enforceScopeOnRecord(scope, op, record)
+}
+
+// enforceUpdateWrite applies the writable fields of policy and then scope to
+// record, an update of existing.
+func enforceUpdateWrite[T any](policy resolvedFieldPolicy, scope ScopeFilter, op CrudOperation, record, existing T) (T, error) {
+	record, err := enforceWritableUpdate(policy, op, record, existing)
+	if err != nil {
+		return record, err
+	}
+	return enforceScopeOnRecord(scope, op, record)
+}
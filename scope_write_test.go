@@ -0,0 +1,176 @@
+package crud
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func nameScopeGuard(names ...string) ScopeGuardFunc[*TestUser] {
+	return func(ctx Context, op CrudOperation) (ActorContext, ScopeFilter, error) {
+		scope := ScopeFilter{}
+		scope.AddColumnFilter("name", "IN", names...)
+		return ActorContext{ActorID: "actor-scope"}, scope, nil
+	}
+}
+
+func errorTextCode(t *testing.T, payload map[string]any) string {
+	t.Helper()
+	errPayload, ok := payload["error"].(map[string]any)
+	require.True(t, ok, "error payload missing: %v", payload)
+	code, _ := errPayload["text_code"].(string)
+	return code
+}
+
+func TestController_ScopeEnforcedOnCreate(t *testing.T) {
+	app, db := setupApp(t, WithScopeGuard(nameScopeGuard("Scoped")))
+	defer db.Close()
+
+	status, payload := writeRequest(t, app, http.MethodPost, "/test-user", map[string]any{"email": "filled@example.com"})
+	require.Equal(t, http.StatusCreated, status, "payload: %v", payload)
+	assert.Equal(t, "Scoped", payload["name"], "empty scope columns are filled")
+
+	status, payload = writeRequest(t, app, http.MethodPost, "/test-user", map[string]any{"name": "Other", "email": "other@example.com"})
+	require.Equal(t, http.StatusForbidden, status)
+	assert.Equal(t, "SCOPE_VIOLATION", errorTextCode(t, payload))
+
+	status, _ = writeRequest(t, app, http.MethodPost, "/test-user/batch", []map[string]any{
+		{"email": "batch-1@example.com"},
+		{"name": "Other", "email": "batch-2@example.com"},
+	})
+	assert.Equal(t, http.StatusForbidden, status)
+	assert.Equal(t, 1, countTestUsers(t, db))
+}
+
+func TestController_ScopeEnforcedOnUpdate(t *testing.T) {
+	app, db := setupApp(t, WithScopeGuard(nameScopeGuard("Scoped", "Also Scoped")))
+	defer db.Close()
+
+	user := &TestUser{Name: "Scoped", Email: "scoped@example.com"}
+	insertTestUsers(t, db, user)
+
+	status, payload := writeRequest(t, app, http.MethodPut, "/test-user/"+user.ID.String(), map[string]any{"name": "Other"})
+	require.Equal(t, http.StatusForbidden, status)
+	assert.Equal(t, "SCOPE_VIOLATION", errorTextCode(t, payload))
+
+	status, _ = writeRequest(t, app, http.MethodPut, "/test-user/"+user.ID.String(), map[string]any{"name": "Also Scoped"})
+	assert.Equal(t, http.StatusOK, status, "moving within the scope is allowed")
+
+	status, _ = filterMutationRequest(t, app, http.MethodPatch, "/test-users?email=scoped@example.com", map[string]any{"name": "Other"})
+	assert.Equal(t, http.StatusForbidden, status)
+	status, _ = filterMutationRequest(t, app, http.MethodPatch, "/test-users?email=scoped@example.com", map[string]any{"age": 40})
+	assert.Equal(t, http.StatusOK, status, "filter updates only check the fields they assign")
+}
+
+func TestController_DeleteBatchRestrictedToScope(t *testing.T) {
+	app, db := setupApp(t, WithScopeGuard(nameScopeGuard("Scoped")))
+	defer db.Close()
+
+	inside := &TestUser{Name: "Scoped", Email: "inside@example.com"}
+	outside := &TestUser{Name: "Other", Email: "outside@example.com"}
+	insertTestUsers(t, db, inside, outside)
+
+	body, err := json.Marshal([]string{inside.ID.String(), outside.ID.String()})
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodDelete, "/test-user/batch", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req, -1)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Equal(t, 2, countTestUsers(t, db), "the atomic batch is rolled back")
+
+	body, err = json.Marshal([]string{inside.ID.String()})
+	require.NoError(t, err)
+	req = httptest.NewRequest(http.MethodDelete, "/test-user/batch", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err = app.Test(req, -1)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Equal(t, 1, countTestUsers(t, db))
+}
+
+func TestController_ScopeFilledColumnsAreNotClientWrites(t *testing.T) {
+	provider := func(req FieldPolicyRequest[*TestUser]) (FieldPolicy, error) {
+		return FieldPolicy{WritableDeny: []string{"name"}}, nil
+	}
+	app, db := setupApp(t, WithScopeGuard(nameScopeGuard("Scoped")), WithFieldPolicyProvider(provider))
+	defer db.Close()
+
+	status, payload := writeRequest(t, app, http.MethodPost, "/test-user", map[string]any{"email": "policy@example.com"})
+	require.Equal(t, http.StatusCreated, status, "payload: %v", payload)
+	assert.Equal(t, "Scoped", payload["name"])
+}
+
+func TestScopeGuardService_EnforcesScopeOnWrites(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	require.NoError(t, createSchema(context.Background(), db))
+
+	svc := NewService(ServiceConfig[*TestUser]{
+		Repository: newTestUserRepository(db),
+		ScopeGuard: nameScopeGuard("Scoped"),
+	})
+	ctx := newBenchContext()
+
+	created, err := svc.Create(ctx, &TestUser{ID: uuid.New(), Email: "svc@example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, "Scoped", created.Name)
+
+	_, err = svc.Create(ctx, &TestUser{ID: uuid.New(), Name: "Other", Email: "svc-other@example.com"})
+	var violation *ScopeViolationError
+	require.ErrorAs(t, err, &violation)
+	assert.Equal(t, "name", violation.Column)
+	assert.Equal(t, "Other", violation.Value)
+
+	outside := &TestUser{Name: "Other", Email: "svc-outside@example.com"}
+	insertTestUsers(t, db, outside)
+	err = svc.DeleteBatch(ctx, []*TestUser{{ID: outside.ID}})
+	var notFound *NotFoundError
+	require.ErrorAs(t, err, &notFound)
+	assert.Equal(t, 2, countTestUsers(t, db))
+
+	moved := &TestUser{ID: outside.ID, Name: "Scoped", Email: outside.Email}
+	_, err = svc.Update(ctx, moved)
+	assert.ErrorAs(t, err, &notFound, "updates cannot move rows into the scope")
+	_, err = svc.UpdateBatch(ctx, []*TestUser{moved})
+	assert.ErrorAs(t, err, &notFound)
+
+	up, err := upsertServiceOf[*TestUser](svc, OpUpsert)
+	require.NoError(t, err)
+	_, _, err = up.Upsert(ctx, &TestUser{Name: "Scoped", Email: outside.Email})
+	assert.ErrorAs(t, err, &notFound, "upserts cannot take over stored rows outside the scope")
+
+	var stored TestUser
+	require.NoError(t, db.NewSelect().Model(&stored).Where("id = ?", outside.ID).Scan(context.Background()))
+	assert.Equal(t, "Other", stored.Name)
+}
+
+func TestEnforceScopeOnRecord(t *testing.T) {
+	scope := ScopeFilter{}
+	scope.AddColumnFilter("u.name", "IN", "a", "b")
+	scope.AddColumnFilter("age", "NOT IN", "13")
+
+	_, err := enforceScopeOnRecord(scope, OpCreate, &TestUser{})
+	var violation *ScopeViolationError
+	require.ErrorAs(t, err, &violation)
+	assert.Equal(t, "create requires u.name to be one of a, b", violation.Error())
+
+	_, err = enforceScopeOnRecord(scope, OpCreate, &TestUser{Name: "b", Age: 13})
+	require.ErrorAs(t, err, &violation)
+	assert.Equal(t, "age", violation.Column)
+
+	record, err := enforceScopeOnRecord(scope, OpCreate, TestUser{Name: "a"})
+	require.NoError(t, err)
+	assert.Equal(t, "a", record.Name)
+
+	bypass := ScopeFilter{Bypass: true, ColumnFilters: scope.ColumnFilters}
+	_, err = enforceScopeOnRecord(bypass, OpCreate, &TestUser{Name: "z"})
+	assert.NoError(t, err)
+}
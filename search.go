@@ -0,0 +1,160 @@
+package crud
+
+import (
+	"html"
+	"strings"
+	"unicode/utf8"
+
+	querybun "github.com/goliatone/go-crud/pkg/go-query-bun"
+)
+
+// SearchStrategy resolves the _search term; see querybun.SearchStrategy.
+type SearchStrategy = querybun.SearchStrategy
+
+// LikeSearch is the default substring search across the search columns.
+type LikeSearch = querybun.LikeSearch
+
+// PostgresFullTextSearch searches with tsvector/websearch_to_tsquery and
+// orders by ts_rank.
+type PostgresFullTextSearch = querybun.PostgresFullTextSearch
+
+// SQLiteFTS5Search searches an FTS5 virtual table and orders by bm25.
+type SQLiteFTS5Search = querybun.SQLiteFTS5Search
+
+// searchSnippetRadius is the number of runes kept on each side of the first
+// match in a highlight snippet.
+const searchSnippetRadius = 40
+
+// SearchConfig enables _search on the controller routes that filter rows.
+type SearchConfig struct {
+	// Columns are the searched fields, optionally weighted from A (highest)
+	// to D, such as "title:A". Weights apply to ranking strategies.
+	Columns []string
+	// Strategy defaults to LikeSearch.
+	Strategy SearchStrategy
+	// Highlight adds per-record snippets of the matching columns to the list
+	// response Filters, with matches wrapped in <mark>.
+	Highlight bool
+}
+
+// WithSearchConfig sets the search columns, strategy and highlighting used
+// for _search on list, aggregate, export and filter mutation routes.
+func WithSearchConfig[T any](cfg SearchConfig) Option[T] {
+	return func(c *Controller[T]) {
+		c.searchConfig = cfg
+	}
+}
+
+func (cfg SearchConfig) queryOptions() []QueryBuilderOption {
+	if len(cfg.Columns) == 0 {
+		return nil
+	}
+	opts := []QueryBuilderOption{WithSearchColumns(cfg.Columns...)}
+	if cfg.Strategy != nil {
+		opts = append(opts, WithSearchStrategy(cfg.Strategy))
+	}
+	return opts
+}
+
+// searchHighlights builds snippets keyed by record ID and then field name for
+// the records whose search columns contain a word of the term.
+func (c *Controller[T]) searchHighlights(records []T, term string) map[string]map[string]string {
+	words := strings.Fields(strings.ToLower(term))
+	if !c.searchConfig.Highlight || len(words) == 0 || len(records) == 0 {
+		return nil
+	}
+	fields := make([]string, 0, len(c.searchConfig.Columns))
+	for _, column := range c.searchConfig.Columns {
+		field, _, _ := strings.Cut(column, ":")
+		fields = append(fields, strings.TrimSpace(field))
+	}
+
+	highlights := make(map[string]map[string]string)
+	for _, record := range records {
+		id := c.recordID(record)
+		if id == "" {
+			continue
+		}
+		for _, field := range fields {
+			text, ok := jsonFieldAsString(record, field)
+			if !ok {
+				continue
+			}
+			snippet, ok := highlightSnippet(text, words)
+			if !ok {
+				continue
+			}
+			if highlights[id] == nil {
+				highlights[id] = make(map[string]string)
+			}
+			highlights[id][field] = snippet
+		}
+	}
+	if len(highlights) == 0 {
+		return nil
+	}
+	return highlights
+}
+
+// highlightSnippet marks every case-insensitive occurrence of words in text
+// and trims it around the first match. The text is HTML-escaped.
+func highlightSnippet(text string, words []string) (string, bool) {
+	lower := strings.ToLower(text)
+	if len(lower) != len(text) {
+		// Case folding changed byte offsets; match on the original text.
+		lower = text
+	}
+
+	type span struct{ start, end int }
+	var spans []span
+	for i := 0; i < len(lower); {
+		matched := 0
+		for _, word := range words {
+			if strings.HasPrefix(lower[i:], word) && len(word) > matched {
+				matched = len(word)
+			}
+		}
+		if matched == 0 {
+			_, size := utf8.DecodeRuneInString(lower[i:])
+			i += size
+			continue
+		}
+		spans = append(spans, span{i, i + matched})
+		i += matched
+	}
+	if len(spans) == 0 {
+		return "", false
+	}
+
+	start := spans[0].start
+	for n := 0; n < searchSnippetRadius && start > 0; n++ {
+		_, size := utf8.DecodeLastRuneInString(text[:start])
+		start -= size
+	}
+	end := spans[0].end
+	for n := 0; n < searchSnippetRadius && end < len(text); n++ {
+		_, size := utf8.DecodeRuneInString(text[end:])
+		end += size
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	cursor := start
+	for _, s := range spans {
+		if s.start < start || s.end > end {
+			continue
+		}
+		b.WriteString(html.EscapeString(text[cursor:s.start]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(text[s.start:s.end]))
+		b.WriteString("</mark>")
+		cursor = s.end
+	}
+	b.WriteString(html.EscapeString(text[cursor:end]))
+	if end < len(text) {
+		b.WriteString("…")
+	}
+	return b.String(), true
+}
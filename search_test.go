@@ -0,0 +1,61 @@
+package crud
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestController_Index_SearchConfigHighlights(t *testing.T) {
+	app, db := setupApp(t, WithSearchConfig[*TestUser](SearchConfig{
+		Columns:   []string{"name:A", "email"},
+		Highlight: true,
+	}))
+	defer db.Close()
+
+	alice := &TestUser{Name: "Alice <Admin>", Email: "alice@example.com"}
+	insertTestUsers(t, db, alice, &TestUser{Name: "Bob", Email: "bob@example.com"})
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/test-users?_search=ALI", nil), -1)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var payload APIListResponse[TestUser]
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&payload))
+	require.Len(t, payload.Data, 1)
+	assert.Equal(t, alice.ID, payload.Data[0].ID)
+	require.NotNil(t, payload.Meta)
+	assert.Equal(t, "like", payload.Meta.SearchStrategy)
+	assert.Equal(t, map[string]map[string]string{
+		alice.ID.String(): {
+			"name":  "<mark>Ali</mark>ce &lt;Admin&gt;",
+			"email": "<mark>ali</mark>ce@example.com",
+		},
+	}, payload.Meta.Highlights)
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/test-users", nil), -1)
+	require.NoError(t, err)
+	payload = APIListResponse[TestUser]{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&payload))
+	assert.Len(t, payload.Data, 2)
+	assert.Empty(t, payload.Meta.SearchStrategy)
+	assert.Nil(t, payload.Meta.Highlights)
+}
+
+func TestHighlightSnippet(t *testing.T) {
+	snippet, ok := highlightSnippet("the quick brown fox", []string{"quick", "fox"})
+	require.True(t, ok)
+	assert.Equal(t, "the <mark>quick</mark> brown <mark>fox</mark>", snippet)
+
+	long := "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa match bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+	snippet, ok = highlightSnippet(long, []string{"match"})
+	require.True(t, ok)
+	assert.Equal(t, "…aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa <mark>match</mark> bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb…", snippet)
+
+	_, ok = highlightSnippet("nothing here", []string{"fox"})
+	assert.False(t, ok)
+}
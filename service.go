@@ -1,7 +1,11 @@
 package crud
 
 import (
+	"context"
+	"reflect"
+
 	"github.com/goliatone/go-repository-bun"
+	"github.com/uptrace/bun"
 )
 
 // Service defines pluggable CRUD behaviours that the controller can delegate to.
@@ -32,6 +36,28 @@ type ServiceFuncs[T any] struct {
 
 	Index func(ctx Context, criteria []repository.SelectCriteria) ([]T, int, error)
 	Show  func(ctx Context, id string, criteria []repository.SelectCriteria) (T, error)
+
+	// Aggregate falls through to the defaults when unset and they implement
+	// AggregateService.
+	Aggregate func(ctx Context, spec AggregateSpec, criteria []repository.SelectCriteria) (AggregateResult, error)
+
+	// Restore and Purge overrides apply to soft-deletable models; unset ones
+	// fall through to the defaults when they implement SoftDeleteService.
+	Restore      func(ctx Context, record T) (T, error)
+	RestoreBatch func(ctx Context, records []T) ([]T, error)
+	Purge        func(ctx Context, record T) error
+	PurgeBatch   func(ctx Context, records []T) error
+
+	// Upsert overrides fall through to the defaults when unset and they
+	// implement UpsertService.
+	Upsert      func(ctx Context, record T) (T, UpsertAction, error)
+	UpsertBatch func(ctx Context, records []T) ([]T, []UpsertAction, error)
+
+	// Filter mutation overrides fall through to the defaults when unset and
+	// they implement FilterMutationService.
+	UpdateWhere func(ctx Context, values T, fields []string, criteria []repository.SelectCriteria) (int, error)
+	DeleteWhere func(ctx Context, criteria []repository.SelectCriteria) (int, error)
+	CountWhere  func(ctx Context, criteria []repository.SelectCriteria) (int, error)
 }
 
 // ComposeService returns a Service implementation that uses the given defaults
@@ -65,6 +91,13 @@ func NewRepositoryService[T any](repo repository.Repository[T]) Service[T] {
 type RepositoryServiceOptions struct {
 	BatchInsertCriteria []repository.InsertCriteria
 	BatchUpdateCriteria []repository.UpdateCriteria
+	// IDCodec resolves primary keys for Show and DeleteBatch. When nil the
+	// service keeps using the uuid-based repository handlers.
+	IDCodec IDCodec
+	// UpsertConflictColumns lists the columns (or Go field names) used as the
+	// ON CONFLICT target. Empty falls back to the repository identifier and
+	// then the primary key.
+	UpsertConflictColumns []string
 }
 
 // NewRepositoryServiceWithOptions returns a Service[T] that delegates to repository.Repository[T].
@@ -73,6 +106,9 @@ func NewRepositoryServiceWithOptions[T any](repo repository.Repository[T], opts
 		repo:           repo,
 		insertCriteria: opts.BatchInsertCriteria,
 		updateCriteria: opts.BatchUpdateCriteria,
+		idCodec:        opts.IDCodec,
+
+		upsertConflictColumns: opts.UpsertConflictColumns,
 	}
 }
 
@@ -85,35 +121,173 @@ type repositoryService[T any] struct {
 	repo           repository.Repository[T]
 	insertCriteria []repository.InsertCriteria
 	updateCriteria []repository.UpdateCriteria
+	idCodec        IDCodec
+
+	upsertConflictColumns []string
 }
 
 func (s *repositoryService[T]) Create(ctx Context, record T) (T, error) {
+	if tx, ok := TxFromContext(ctx.UserContext()); ok {
+		return s.repo.CreateTx(ctx.UserContext(), tx, record)
+	}
 	return s.repo.Create(ctx.UserContext(), record)
 }
 
 func (s *repositoryService[T]) CreateBatch(ctx Context, records []T) ([]T, error) {
+	if tx, ok := TxFromContext(ctx.UserContext()); ok {
+		return s.repo.CreateManyTx(ctx.UserContext(), tx, records, s.insertCriteria...)
+	}
 	if len(s.insertCriteria) == 0 {
 		return s.repo.CreateMany(ctx.UserContext(), records)
 	}
 	return s.repo.CreateMany(ctx.UserContext(), records, s.insertCriteria...)
 }
 
+// Update persists record. Models with an explicit `crud:"version"` field get
+// it bumped, and an expected version stored on the context turns the
+// statement into a conditional UPDATE ... WHERE version = ? that also bumps
+// timestamp versions such as the `updated_at` fallback.
 func (s *repositoryService[T]) Update(ctx Context, record T) (T, error) {
-	return s.repo.Update(ctx.UserContext(), record)
+	expected := expectedVersionAt(ctx.UserContext(), 0)
+	criteria, err := prepareVersionedUpdate(record, expected)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	var updated T
+	if tx, ok := TxFromContext(ctx.UserContext()); ok {
+		updated, err = s.repo.UpdateTx(ctx.UserContext(), tx, record, criteria...)
+	} else {
+		updated, err = s.repo.Update(ctx.UserContext(), record, criteria...)
+	}
+	if err != nil && expected != "" && repository.IsSQLExpectedCountViolation(err) {
+		var zero T
+		return zero, newPreconditionFailed(expected)
+	}
+	return updated, err
 }
 
 func (s *repositoryService[T]) UpdateBatch(ctx Context, records []T) ([]T, error) {
+	if len(ExpectedVersionsFromContext(ctx.UserContext())) > 0 {
+		return s.updateBatchVersioned(ctx, records)
+	}
+	for _, record := range records {
+		if _, err := prepareVersionedUpdate(record, ""); err != nil {
+			return nil, err
+		}
+	}
+	if tx, ok := TxFromContext(ctx.UserContext()); ok {
+		return s.repo.UpdateManyTx(ctx.UserContext(), tx, records, s.updateCriteria...)
+	}
 	if len(s.updateCriteria) == 0 {
 		return s.repo.UpdateMany(ctx.UserContext(), records)
 	}
 	return s.repo.UpdateMany(ctx.UserContext(), records, s.updateCriteria...)
 }
 
+// updateBatchVersioned issues one conditional UPDATE per record inside a
+// transaction so a single version conflict rolls back the whole batch. A
+// transaction already stored on the context is joined instead of nested.
+func (s *repositoryService[T]) updateBatchVersioned(ctx Context, records []T) ([]T, error) {
+	uctx := ctx.UserContext()
+	update := func(ctx context.Context, tx bun.IDB) ([]T, error) {
+		updated := make([]T, 0, len(records))
+		for i, record := range records {
+			expected := expectedVersionAt(uctx, i)
+			criteria, err := prepareVersionedUpdate(record, expected)
+			if err != nil {
+				return nil, err
+			}
+			criteria = append(criteria, s.updateCriteria...)
+			var result T
+			if tx != nil {
+				result, err = s.repo.UpdateTx(ctx, tx, record, criteria...)
+			} else {
+				result, err = s.repo.Update(ctx, record, criteria...)
+			}
+			if err != nil {
+				if expected != "" && repository.IsSQLExpectedCountViolation(err) {
+					return nil, newPreconditionFailed(expected)
+				}
+				return nil, err
+			}
+			updated = append(updated, result)
+		}
+		return updated, nil
+	}
+
+	if tx, ok := TxFromContext(uctx); ok {
+		return update(uctx, tx)
+	}
+	provider, ok := s.repo.(repository.DBProvider)
+	if !ok || provider.DB() == nil {
+		return update(uctx, nil)
+	}
+	var out []T
+	err := provider.DB().RunInTx(uctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var err error
+		out, err = update(ctx, tx)
+		return err
+	})
+	return out, err
+}
+
+// Delete removes record. When an expected version is stored on the context
+// the DELETE is conditioned on it and deleting no row reports a conflict.
 func (s *repositoryService[T]) Delete(ctx Context, record T) error {
-	return s.repo.Delete(ctx.UserContext(), record)
+	uctx := ctx.UserContext()
+	expected := expectedVersionAt(uctx, 0)
+	field, versioned := versionFieldFor(reflect.TypeOf(record))
+	if expected == "" || !versioned {
+		if tx, ok := TxFromContext(uctx); ok {
+			return s.repo.DeleteTx(uctx, tx, record)
+		}
+		return s.repo.Delete(uctx, record)
+	}
+
+	arg, err := field.versionArg(record, expected)
+	if err != nil {
+		return err
+	}
+	keyFields, keyValues, err := recordKeyValues(s.idCodec, record)
+	if err != nil {
+		return err
+	}
+	column := field.column
+	criteria := []repository.DeleteCriteria{
+		deleteByKeys(keyFields, [][]any{keyValues}),
+		func(q *bun.DeleteQuery) *bun.DeleteQuery {
+			return q.Where("?TableAlias.? = ?", bun.Ident(column), arg)
+		},
+	}
+	db, err := s.filterMutationDB(ctx, OpDelete)
+	if err != nil {
+		return err
+	}
+	q := db.NewDelete().Model(s.repo.Handlers().NewRecord())
+	for _, c := range criteria {
+		q = q.Apply(c)
+	}
+	deleted, err := rowsAffected(q.Exec(uctx))
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return newPreconditionFailed(expected)
+	}
+	return nil
 }
 
 func (s *repositoryService[T]) DeleteBatch(ctx Context, records []T) error {
+	return s.deleteRecords(ctx, records)
+}
+
+// deleteRecords deletes records by primary key; extra criteria such as
+// repository.DeleteForReal are applied to the same statement.
+func (s *repositoryService[T]) deleteRecords(ctx Context, records []T, extra ...repository.DeleteCriteria) error {
+	if s.idCodec != nil {
+		return s.deleteBatchByKeys(ctx, records, extra...)
+	}
 	ids := make([]string, 0, len(records))
 	for _, record := range records {
 		id := s.repo.Handlers().GetID(record)
@@ -122,15 +296,58 @@ func (s *repositoryService[T]) DeleteBatch(ctx Context, records []T) error {
 	if len(ids) == 0 {
 		return nil
 	}
-	return s.repo.DeleteWhere(ctx.UserContext(), repository.DeleteByIDs(ids))
+	return s.deleteWhere(ctx, append([]repository.DeleteCriteria{repository.DeleteByIDs(ids)}, extra...)...)
+}
+
+func (s *repositoryService[T]) deleteBatchByKeys(ctx Context, records []T, extra ...repository.DeleteCriteria) error {
+	var fields []primaryKeyField
+	keys := make([][]any, 0, len(records))
+	for _, record := range records {
+		recordFields, values, err := recordKeyValues(s.idCodec, record)
+		if err != nil {
+			return err
+		}
+		fields = recordFields
+		keys = append(keys, values)
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	return s.deleteWhere(ctx, append([]repository.DeleteCriteria{deleteByKeys(fields, keys)}, extra...)...)
+}
+
+func (s *repositoryService[T]) deleteWhere(ctx Context, criteria ...repository.DeleteCriteria) error {
+	if tx, ok := TxFromContext(ctx.UserContext()); ok {
+		return s.repo.DeleteWhereTx(ctx.UserContext(), tx, criteria...)
+	}
+	return s.repo.DeleteWhere(ctx.UserContext(), criteria...)
 }
 
 func (s *repositoryService[T]) Index(ctx Context, criteria []repository.SelectCriteria) ([]T, int, error) {
+	if tx, ok := TxFromContext(ctx.UserContext()); ok {
+		return s.repo.ListTx(ctx.UserContext(), tx, criteria...)
+	}
 	return s.repo.List(ctx.UserContext(), criteria...)
 }
 
 func (s *repositoryService[T]) Show(ctx Context, id string, criteria []repository.SelectCriteria) (T, error) {
-	return s.repo.GetByID(ctx.UserContext(), id, criteria...)
+	tx, inTx := TxFromContext(ctx.UserContext())
+	if s.idCodec == nil {
+		if inTx {
+			return s.repo.GetByIDTx(ctx.UserContext(), tx, id, criteria...)
+		}
+		return s.repo.GetByID(ctx.UserContext(), id, criteria...)
+	}
+	var zero T
+	fields, values, err := typedKeyValues(reflect.TypeOf(zero), s.idCodec, id)
+	if err != nil {
+		return zero, err
+	}
+	lookup := append([]repository.SelectCriteria{selectByKey(fields, values)}, criteria...)
+	if inTx {
+		return s.repo.GetTx(ctx.UserContext(), tx, lookup...)
+	}
+	return s.repo.Get(ctx.UserContext(), lookup...)
 }
 
 type serviceFuncAdapter[T any] struct {
@@ -193,3 +410,113 @@ func (a *serviceFuncAdapter[T]) Show(ctx Context, id string, criteria []reposito
 	}
 	return a.defaults.Show(ctx, id, criteria)
 }
+
+func (a *serviceFuncAdapter[T]) Aggregate(ctx Context, spec AggregateSpec, criteria []repository.SelectCriteria) (AggregateResult, error) {
+	if a.funcs.Aggregate != nil {
+		return a.funcs.Aggregate(ctx, spec, criteria)
+	}
+	agg, err := aggregateServiceOf[T](a.defaults)
+	if err != nil {
+		return AggregateResult{}, err
+	}
+	return agg.Aggregate(ctx, spec, criteria)
+}
+
+func (a *serviceFuncAdapter[T]) Restore(ctx Context, record T) (T, error) {
+	if a.funcs.Restore != nil {
+		return a.funcs.Restore(ctx, record)
+	}
+	soft, err := softDeleteServiceOf[T](a.defaults, OpRestore)
+	if err != nil {
+		return record, err
+	}
+	return soft.Restore(ctx, record)
+}
+
+func (a *serviceFuncAdapter[T]) RestoreBatch(ctx Context, records []T) ([]T, error) {
+	if a.funcs.RestoreBatch != nil {
+		return a.funcs.RestoreBatch(ctx, records)
+	}
+	soft, err := softDeleteServiceOf[T](a.defaults, OpRestoreBatch)
+	if err != nil {
+		return nil, err
+	}
+	return soft.RestoreBatch(ctx, records)
+}
+
+func (a *serviceFuncAdapter[T]) Purge(ctx Context, record T) error {
+	if a.funcs.Purge != nil {
+		return a.funcs.Purge(ctx, record)
+	}
+	soft, err := softDeleteServiceOf[T](a.defaults, OpPurge)
+	if err != nil {
+		return err
+	}
+	return soft.Purge(ctx, record)
+}
+
+func (a *serviceFuncAdapter[T]) PurgeBatch(ctx Context, records []T) error {
+	if a.funcs.PurgeBatch != nil {
+		return a.funcs.PurgeBatch(ctx, records)
+	}
+	soft, err := softDeleteServiceOf[T](a.defaults, OpPurgeBatch)
+	if err != nil {
+		return err
+	}
+	return soft.PurgeBatch(ctx, records)
+}
+
+func (a *serviceFuncAdapter[T]) Upsert(ctx Context, record T) (T, UpsertAction, error) {
+	if a.funcs.Upsert != nil {
+		return a.funcs.Upsert(ctx, record)
+	}
+	up, err := upsertServiceOf[T](a.defaults, OpUpsert)
+	if err != nil {
+		return record, "", err
+	}
+	return up.Upsert(ctx, record)
+}
+
+func (a *serviceFuncAdapter[T]) UpsertBatch(ctx Context, records []T) ([]T, []UpsertAction, error) {
+	if a.funcs.UpsertBatch != nil {
+		return a.funcs.UpsertBatch(ctx, records)
+	}
+	up, err := upsertServiceOf[T](a.defaults, OpUpsertBatch)
+	if err != nil {
+		return nil, nil, err
+	}
+	return up.UpsertBatch(ctx, records)
+}
+
+func (a *serviceFuncAdapter[T]) UpdateWhere(ctx Context, values T, fields []string, criteria []repository.SelectCriteria) (int, error) {
+	if a.funcs.UpdateWhere != nil {
+		return a.funcs.UpdateWhere(ctx, values, fields, criteria)
+	}
+	mut, err := filterMutationServiceOf[T](a.defaults, OpUpdateByFilter)
+	if err != nil {
+		return 0, err
+	}
+	return mut.UpdateWhere(ctx, values, fields, criteria)
+}
+
+func (a *serviceFuncAdapter[T]) DeleteWhere(ctx Context, criteria []repository.SelectCriteria) (int, error) {
+	if a.funcs.DeleteWhere != nil {
+		return a.funcs.DeleteWhere(ctx, criteria)
+	}
+	mut, err := filterMutationServiceOf[T](a.defaults, OpDeleteByFilter)
+	if err != nil {
+		return 0, err
+	}
+	return mut.DeleteWhere(ctx, criteria)
+}
+
+func (a *serviceFuncAdapter[T]) CountWhere(ctx Context, criteria []repository.SelectCriteria) (int, error) {
+	if a.funcs.CountWhere != nil {
+		return a.funcs.CountWhere(ctx, criteria)
+	}
+	mut, err := filterMutationServiceOf[T](a.defaults, OpList)
+	if err != nil {
+		return 0, err
+	}
+	return mut.CountWhere(ctx, criteria)
+}
@@ -93,6 +93,14 @@ func (s *readOnlyServiceAdapter[T]) Show(ctx Context, id string, criteria []repo
 	return s.read.Show(ctx, id, criteria)
 }
 
+func (s *readOnlyServiceAdapter[T]) Aggregate(ctx Context, spec AggregateSpec, criteria []repository.SelectCriteria) (AggregateResult, error) {
+	agg, err := aggregateServiceOf[T](s.read)
+	if err != nil {
+		return AggregateResult{}, err
+	}
+	return agg.Aggregate(ctx, spec, criteria)
+}
+
 type writeOnlyServiceAdapter[T any] struct {
 	write        WriteService[T]
 	readFallback Service[T]
@@ -136,3 +144,83 @@ func (s *writeOnlyServiceAdapter[T]) Show(ctx Context, id string, criteria []rep
 	}
 	return s.readFallback.Show(ctx, id, criteria)
 }
+
+func (s *writeOnlyServiceAdapter[T]) Aggregate(ctx Context, spec AggregateSpec, criteria []repository.SelectCriteria) (AggregateResult, error) {
+	agg, err := aggregateServiceOf[T](s.readFallback)
+	if err != nil {
+		return AggregateResult{}, err
+	}
+	return agg.Aggregate(ctx, spec, criteria)
+}
+
+func (s *writeOnlyServiceAdapter[T]) Restore(ctx Context, record T) (T, error) {
+	soft, err := softDeleteServiceOf[T](s.write, OpRestore)
+	if err != nil {
+		return record, err
+	}
+	return soft.Restore(ctx, record)
+}
+
+func (s *writeOnlyServiceAdapter[T]) RestoreBatch(ctx Context, records []T) ([]T, error) {
+	soft, err := softDeleteServiceOf[T](s.write, OpRestoreBatch)
+	if err != nil {
+		return nil, err
+	}
+	return soft.RestoreBatch(ctx, records)
+}
+
+func (s *writeOnlyServiceAdapter[T]) Purge(ctx Context, record T) error {
+	soft, err := softDeleteServiceOf[T](s.write, OpPurge)
+	if err != nil {
+		return err
+	}
+	return soft.Purge(ctx, record)
+}
+
+func (s *writeOnlyServiceAdapter[T]) PurgeBatch(ctx Context, records []T) error {
+	soft, err := softDeleteServiceOf[T](s.write, OpPurgeBatch)
+	if err != nil {
+		return err
+	}
+	return soft.PurgeBatch(ctx, records)
+}
+
+func (s *writeOnlyServiceAdapter[T]) Upsert(ctx Context, record T) (T, UpsertAction, error) {
+	up, err := upsertServiceOf[T](s.write, OpUpsert)
+	if err != nil {
+		return record, "", err
+	}
+	return up.Upsert(ctx, record)
+}
+
+func (s *writeOnlyServiceAdapter[T]) UpsertBatch(ctx Context, records []T) ([]T, []UpsertAction, error) {
+	up, err := upsertServiceOf[T](s.write, OpUpsertBatch)
+	if err != nil {
+		return nil, nil, err
+	}
+	return up.UpsertBatch(ctx, records)
+}
+
+func (s *writeOnlyServiceAdapter[T]) UpdateWhere(ctx Context, values T, fields []string, criteria []repository.SelectCriteria) (int, error) {
+	mut, err := filterMutationServiceOf[T](s.write, OpUpdateByFilter)
+	if err != nil {
+		return 0, err
+	}
+	return mut.UpdateWhere(ctx, values, fields, criteria)
+}
+
+func (s *writeOnlyServiceAdapter[T]) DeleteWhere(ctx Context, criteria []repository.SelectCriteria) (int, error) {
+	mut, err := filterMutationServiceOf[T](s.write, OpDeleteByFilter)
+	if err != nil {
+		return 0, err
+	}
+	return mut.DeleteWhere(ctx, criteria)
+}
+
+func (s *writeOnlyServiceAdapter[T]) CountWhere(ctx Context, criteria []repository.SelectCriteria) (int, error) {
+	mut, err := filterMutationServiceOf[T](s.write, OpList)
+	if err != nil {
+		return 0, err
+	}
+	return mut.CountWhere(ctx, criteria)
+}
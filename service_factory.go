@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"slices"
 
 	"github.com/goliatone/go-crud/pkg/activity"
 	repository "github.com/goliatone/go-repository-bun"
@@ -35,6 +36,12 @@ type ServiceConfig[T any] struct {
 	ResourceName         string
 	ResourceType         reflect.Type
 	BatchReturnOrderByID bool
+	IDCodec              IDCodec
+	// UpsertConflictColumns overrides the conflict target used by upserts.
+	UpsertConflictColumns []string
+	// Tenancy scopes every operation to the request tenant, on top of
+	// ScopeGuard.
+	Tenancy *TenancyConfig
 }
 
 // NewService composes the repository-backed service with optional layers in the
@@ -42,7 +49,7 @@ type ServiceConfig[T any] struct {
 // scope guard → field policy → activity/notifications. Alternate orderings
 // should be implemented as custom wrappers by callers.
 func NewService[T any](cfg ServiceConfig[T]) Service[T] {
-	opts := RepositoryServiceOptions{}
+	opts := RepositoryServiceOptions{IDCodec: cfg.IDCodec, UpsertConflictColumns: cfg.UpsertConflictColumns}
 	if cfg.BatchReturnOrderByID {
 		opts.BatchInsertCriteria = []repository.InsertCriteria{repository.InsertReturnOrderByID()}
 		opts.BatchUpdateCriteria = []repository.UpdateCriteria{repository.UpdateReturnOrderByID()}
@@ -63,8 +70,16 @@ func NewService[T any](cfg ServiceConfig[T]) Service[T] {
 		svc = &hooksService[T]{next: svc, hooks: cfg.Hooks}
 	}
 
-	if cfg.ScopeGuard != nil {
-		svc = &scopeGuardService[T]{next: svc, guard: cfg.ScopeGuard}
+	guard := cfg.ScopeGuard
+	if cfg.Tenancy != nil {
+		guard = tenancyGuard(*cfg.Tenancy, guard)
+	}
+	if guard != nil {
+		guardSvc := &scopeGuardService[T]{next: svc, guard: guard, tenancy: cfg.Tenancy, idCodec: cfg.IDCodec}
+		if cfg.Repository != nil {
+			guardSvc.handlers = cfg.Repository.Handlers()
+		}
+		svc = guardSvc
 	}
 
 	if cfg.FieldPolicy != nil {
@@ -73,12 +88,17 @@ func NewService[T any](cfg ServiceConfig[T]) Service[T] {
 			var zero T
 			resourceType = reflect.TypeOf(zero)
 		}
-		svc = &fieldPolicyService[T]{
+		policySvc := &fieldPolicyService[T]{
 			next:         svc,
 			provider:     cfg.FieldPolicy,
 			resourceName: cfg.ResourceName,
 			resourceType: resourceType,
+			idCodec:      cfg.IDCodec,
 		}
+		if cfg.Repository != nil {
+			policySvc.handlers = cfg.Repository.Handlers()
+		}
+		svc = policySvc
 	}
 
 	emitter := activity.NewEmitter(cfg.ActivityHooks, cfg.ActivityConfig)
@@ -108,7 +128,11 @@ func hooksEmpty[T any](hooks LifecycleHooks[T]) bool {
 		len(hooks.BeforeDelete) == 0 &&
 		len(hooks.AfterDelete) == 0 &&
 		len(hooks.BeforeDeleteBatch) == 0 &&
-		len(hooks.AfterDeleteBatch) == 0
+		len(hooks.AfterDeleteBatch) == 0 &&
+		len(hooks.BeforeRestore) == 0 &&
+		len(hooks.AfterRestore) == 0 &&
+		len(hooks.BeforeUpsert) == 0 &&
+		len(hooks.AfterUpsert) == 0
 }
 
 // hookContextFor builds a HookContext populated with request metadata, actor,
@@ -174,10 +198,18 @@ type hooksService[T any] struct {
 }
 
 // scopeGuardService resolves actor/scope and annotates the request context once
-// per operation before invoking the next service.
+// per operation before invoking the next service. Written records must satisfy
+// the scope, and updated, deleted, restored and purged records must be visible
+// through it. Upserts check the stored row in the repository service.
 type scopeGuardService[T any] struct {
 	next  Service[T]
 	guard ScopeGuardFunc[T]
+	// handlers and idCodec identify deleted records so they can be looked up
+	// within the scope.
+	handlers repository.ModelHandlers[T]
+	idCodec  IDCodec
+	// tenancy removes other tenants' records from loaded relations.
+	tenancy *TenancyConfig
 }
 
 // fieldPolicyService enforces row/column level policies returned by the
@@ -187,6 +219,10 @@ type fieldPolicyService[T any] struct {
 	provider     FieldPolicyProvider[T]
 	resourceName string
 	resourceType reflect.Type
+	// handlers and idCodec identify updated records so the writable fields
+	// can be checked against the stored ones.
+	handlers repository.ModelHandlers[T]
+	idCodec  IDCodec
 }
 
 // activityService emits activity and notifications after successful mutations.
@@ -282,6 +318,116 @@ func (s *virtualFieldService[T]) Show(ctx Context, id string, criteria []reposit
 	return res, nil
 }
 
+func (s *virtualFieldService[T]) Aggregate(ctx Context, spec AggregateSpec, criteria []repository.SelectCriteria) (AggregateResult, error) {
+	agg, err := aggregateServiceOf[T](s.next)
+	if err != nil {
+		return AggregateResult{}, err
+	}
+	return agg.Aggregate(ctx, spec, criteria)
+}
+
+func (s *virtualFieldService[T]) Restore(ctx Context, record T) (T, error) {
+	soft, err := softDeleteServiceOf[T](s.next, OpRestore)
+	if err != nil {
+		return record, err
+	}
+	res, err := soft.Restore(ctx, record)
+	if err != nil {
+		return res, err
+	}
+	_ = s.handler.AfterLoad(hookContextFor(ctx, OpRestore), res)
+	return res, nil
+}
+
+func (s *virtualFieldService[T]) RestoreBatch(ctx Context, records []T) ([]T, error) {
+	soft, err := softDeleteServiceOf[T](s.next, OpRestoreBatch)
+	if err != nil {
+		return nil, err
+	}
+	res, err := soft.RestoreBatch(ctx, records)
+	if err != nil {
+		return res, err
+	}
+	_ = s.handler.AfterLoadBatch(hookContextFor(ctx, OpRestoreBatch), res)
+	return res, nil
+}
+
+func (s *virtualFieldService[T]) Purge(ctx Context, record T) error {
+	soft, err := softDeleteServiceOf[T](s.next, OpPurge)
+	if err != nil {
+		return err
+	}
+	return soft.Purge(ctx, record)
+}
+
+func (s *virtualFieldService[T]) PurgeBatch(ctx Context, records []T) error {
+	soft, err := softDeleteServiceOf[T](s.next, OpPurgeBatch)
+	if err != nil {
+		return err
+	}
+	return soft.PurgeBatch(ctx, records)
+}
+
+func (s *virtualFieldService[T]) Upsert(ctx Context, record T) (T, UpsertAction, error) {
+	up, err := upsertServiceOf[T](s.next, OpUpsert)
+	if err != nil {
+		return record, "", err
+	}
+	hctx := hookContextFor(ctx, OpUpsert)
+	if err := s.handler.BeforeSave(hctx, record); err != nil {
+		return record, "", err
+	}
+	res, action, err := up.Upsert(ctx, record)
+	if err != nil {
+		return res, action, err
+	}
+	_ = s.handler.AfterLoad(hctx, res)
+	return res, action, nil
+}
+
+func (s *virtualFieldService[T]) UpsertBatch(ctx Context, records []T) ([]T, []UpsertAction, error) {
+	up, err := upsertServiceOf[T](s.next, OpUpsertBatch)
+	if err != nil {
+		return nil, nil, err
+	}
+	hctx := hookContextFor(ctx, OpUpsertBatch)
+	for i := range records {
+		if err := s.handler.BeforeSave(hctx, records[i]); err != nil {
+			return nil, nil, err
+		}
+	}
+	res, actions, err := up.UpsertBatch(ctx, records)
+	if err != nil {
+		return res, actions, err
+	}
+	_ = s.handler.AfterLoadBatch(hctx, res)
+	return res, actions, nil
+}
+
+func (s *virtualFieldService[T]) UpdateWhere(ctx Context, values T, fields []string, criteria []repository.SelectCriteria) (int, error) {
+	mut, err := filterMutationServiceOf[T](s.next, OpUpdateByFilter)
+	if err != nil {
+		return 0, err
+	}
+	return mut.UpdateWhere(ctx, values, fields, criteria)
+}
+
+func (s *virtualFieldService[T]) DeleteWhere(ctx Context, criteria []repository.SelectCriteria) (int, error) {
+	mut, err := filterMutationServiceOf[T](s.next, OpDeleteByFilter)
+	if err != nil {
+		return 0, err
+	}
+	return mut.DeleteWhere(ctx, criteria)
+}
+
+func (s *virtualFieldService[T]) CountWhere(ctx Context, criteria []repository.SelectCriteria) (int, error) {
+	mut, err := filterMutationServiceOf[T](s.next, OpList)
+	if err != nil {
+		return 0, err
+	}
+	return mut.CountWhere(ctx, criteria)
+}
+
 // --- validation ---
 
 func (s *validationService[T]) Create(ctx Context, record T) (T, error) {
@@ -332,6 +478,99 @@ func (s *validationService[T]) Show(ctx Context, id string, criteria []repositor
 	return s.next.Show(ctx, id, criteria)
 }
 
+func (s *validationService[T]) Aggregate(ctx Context, spec AggregateSpec, criteria []repository.SelectCriteria) (AggregateResult, error) {
+	agg, err := aggregateServiceOf[T](s.next)
+	if err != nil {
+		return AggregateResult{}, err
+	}
+	return agg.Aggregate(ctx, spec, criteria)
+}
+
+func (s *validationService[T]) Restore(ctx Context, record T) (T, error) {
+	soft, err := softDeleteServiceOf[T](s.next, OpRestore)
+	if err != nil {
+		return record, err
+	}
+	return soft.Restore(ctx, record)
+}
+
+func (s *validationService[T]) RestoreBatch(ctx Context, records []T) ([]T, error) {
+	soft, err := softDeleteServiceOf[T](s.next, OpRestoreBatch)
+	if err != nil {
+		return nil, err
+	}
+	return soft.RestoreBatch(ctx, records)
+}
+
+func (s *validationService[T]) Purge(ctx Context, record T) error {
+	soft, err := softDeleteServiceOf[T](s.next, OpPurge)
+	if err != nil {
+		return err
+	}
+	return soft.Purge(ctx, record)
+}
+
+func (s *validationService[T]) PurgeBatch(ctx Context, records []T) error {
+	soft, err := softDeleteServiceOf[T](s.next, OpPurgeBatch)
+	if err != nil {
+		return err
+	}
+	return soft.PurgeBatch(ctx, records)
+}
+
+func (s *validationService[T]) Upsert(ctx Context, record T) (T, UpsertAction, error) {
+	up, err := upsertServiceOf[T](s.next, OpUpsert)
+	if err != nil {
+		return record, "", err
+	}
+	if err := s.validate(ctx, record); err != nil {
+		return record, "", err
+	}
+	return up.Upsert(ctx, record)
+}
+
+func (s *validationService[T]) UpsertBatch(ctx Context, records []T) ([]T, []UpsertAction, error) {
+	up, err := upsertServiceOf[T](s.next, OpUpsertBatch)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range records {
+		if err := s.validate(ctx, records[i]); err != nil {
+			return nil, nil, err
+		}
+	}
+	return up.UpsertBatch(ctx, records)
+}
+
+// UpdateWhere validates values, the record holding the assigned fields, once
+// before the rows are updated.
+func (s *validationService[T]) UpdateWhere(ctx Context, values T, fields []string, criteria []repository.SelectCriteria) (int, error) {
+	mut, err := filterMutationServiceOf[T](s.next, OpUpdateByFilter)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.validate(ctx, values); err != nil {
+		return 0, err
+	}
+	return mut.UpdateWhere(ctx, values, fields, criteria)
+}
+
+func (s *validationService[T]) DeleteWhere(ctx Context, criteria []repository.SelectCriteria) (int, error) {
+	mut, err := filterMutationServiceOf[T](s.next, OpDeleteByFilter)
+	if err != nil {
+		return 0, err
+	}
+	return mut.DeleteWhere(ctx, criteria)
+}
+
+func (s *validationService[T]) CountWhere(ctx Context, criteria []repository.SelectCriteria) (int, error) {
+	mut, err := filterMutationServiceOf[T](s.next, OpList)
+	if err != nil {
+		return 0, err
+	}
+	return mut.CountWhere(ctx, criteria)
+}
+
 // --- hooks ---
 
 func (s *hooksService[T]) Create(ctx Context, record T) (T, error) {
@@ -440,6 +679,189 @@ func (s *hooksService[T]) Show(ctx Context, id string, criteria []repository.Sel
 	return res, nil
 }
 
+func (s *hooksService[T]) Aggregate(ctx Context, spec AggregateSpec, criteria []repository.SelectCriteria) (AggregateResult, error) {
+	agg, err := aggregateServiceOf[T](s.next)
+	if err != nil {
+		return AggregateResult{}, err
+	}
+	return agg.Aggregate(ctx, spec, criteria)
+}
+
+func (s *hooksService[T]) Restore(ctx Context, record T) (T, error) {
+	soft, err := softDeleteServiceOf[T](s.next, OpRestore)
+	if err != nil {
+		return record, err
+	}
+	meta := hookContextFor(ctx, OpRestore)
+	if err := runHookFuncs(meta, s.hooks.BeforeRestore, record); err != nil {
+		return record, err
+	}
+	res, err := soft.Restore(ctx, record)
+	if err != nil {
+		return res, err
+	}
+	if err := runHookFuncs(meta, s.hooks.AfterRestore, res); err != nil {
+		return res, err
+	}
+	return res, nil
+}
+
+func (s *hooksService[T]) RestoreBatch(ctx Context, records []T) ([]T, error) {
+	soft, err := softDeleteServiceOf[T](s.next, OpRestoreBatch)
+	if err != nil {
+		return nil, err
+	}
+	meta := hookContextFor(ctx, OpRestoreBatch)
+	for _, record := range records {
+		if err := runHookFuncs(meta, s.hooks.BeforeRestore, record); err != nil {
+			return nil, err
+		}
+	}
+	res, err := soft.RestoreBatch(ctx, records)
+	if err != nil {
+		return res, err
+	}
+	for _, record := range res {
+		if err := runHookFuncs(meta, s.hooks.AfterRestore, record); err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
+
+func (s *hooksService[T]) Purge(ctx Context, record T) error {
+	soft, err := softDeleteServiceOf[T](s.next, OpPurge)
+	if err != nil {
+		return err
+	}
+	return soft.Purge(ctx, record)
+}
+
+func (s *hooksService[T]) PurgeBatch(ctx Context, records []T) error {
+	soft, err := softDeleteServiceOf[T](s.next, OpPurgeBatch)
+	if err != nil {
+		return err
+	}
+	return soft.PurgeBatch(ctx, records)
+}
+
+// Upsert runs BeforeUpsert, then BeforeCreate or BeforeUpdate once the
+// repository service knows which write the upsert performs, and the matching
+// after hooks. Hooks report OpUpsert with Metadata.UpsertAction set.
+func (s *hooksService[T]) Upsert(ctx Context, record T) (T, UpsertAction, error) {
+	up, err := upsertServiceOf[T](s.next, OpUpsert)
+	if err != nil {
+		return record, "", err
+	}
+	meta := hookContextFor(ctx, OpUpsert)
+	if err := runHookFuncs(meta, s.hooks.BeforeUpsert, record); err != nil {
+		return record, "", err
+	}
+	restore := s.attachUpsertWrite(ctx, meta)
+	res, action, err := up.Upsert(ctx, record)
+	restore()
+	if err != nil {
+		return res, action, err
+	}
+	if err := s.runAfterUpsert(meta, action, res); err != nil {
+		return res, action, err
+	}
+	return res, action, nil
+}
+
+func (s *hooksService[T]) UpsertBatch(ctx Context, records []T) ([]T, []UpsertAction, error) {
+	up, err := upsertServiceOf[T](s.next, OpUpsertBatch)
+	if err != nil {
+		return nil, nil, err
+	}
+	meta := hookContextFor(ctx, OpUpsertBatch)
+	for _, record := range records {
+		if err := runHookFuncs(meta, s.hooks.BeforeUpsert, record); err != nil {
+			return nil, nil, err
+		}
+	}
+	restore := s.attachUpsertWrite(ctx, meta)
+	res, actions, err := up.UpsertBatch(ctx, records)
+	restore()
+	if err != nil {
+		return res, actions, err
+	}
+	for i, record := range res {
+		var action UpsertAction
+		if i < len(actions) {
+			action = actions[i]
+		}
+		if err := s.runAfterUpsert(meta, action, record); err != nil {
+			return res, actions, err
+		}
+	}
+	return res, actions, nil
+}
+
+// attachUpsertWrite stores the callback running the create or update before
+// hooks on ctx, after any callback already there, and returns a function
+// restoring the previous user context.
+func (s *hooksService[T]) attachUpsertWrite(ctx Context, meta HookContext) func() {
+	setter, ok := ctx.(userContextSetter)
+	if !ok || (len(s.hooks.BeforeCreate) == 0 && len(s.hooks.BeforeUpdate) == 0) {
+		return func() {}
+	}
+	previous := ctx.UserContext()
+	outer := upsertWriteFromContext[T](previous)
+	setter.SetUserContext(contextWithUpsertWrite(previous, func(action UpsertAction, record, stored T) error {
+		if outer != nil {
+			if err := outer(action, record, stored); err != nil {
+				return err
+			}
+		}
+		before := meta
+		before.Metadata.UpsertAction = action
+		if action == UpsertUpdated {
+			return runHookFuncs(before, s.hooks.BeforeUpdate, record)
+		}
+		return runHookFuncs(before, s.hooks.BeforeCreate, record)
+	}))
+	return func() { setter.SetUserContext(previous) }
+}
+
+func (s *hooksService[T]) runAfterUpsert(meta HookContext, action UpsertAction, record T) error {
+	meta.Metadata.UpsertAction = action
+	after := s.hooks.AfterCreate
+	if action == UpsertUpdated {
+		after = s.hooks.AfterUpdate
+	}
+	if err := runHookFuncs(meta, after, record); err != nil {
+		return err
+	}
+	return runHookFuncs(meta, s.hooks.AfterUpsert, record)
+}
+
+// UpdateWhere and DeleteWhere write the matching rows in one statement
+// without loading them, so no record or batch lifecycle hooks run.
+func (s *hooksService[T]) UpdateWhere(ctx Context, values T, fields []string, criteria []repository.SelectCriteria) (int, error) {
+	mut, err := filterMutationServiceOf[T](s.next, OpUpdateByFilter)
+	if err != nil {
+		return 0, err
+	}
+	return mut.UpdateWhere(ctx, values, fields, criteria)
+}
+
+func (s *hooksService[T]) DeleteWhere(ctx Context, criteria []repository.SelectCriteria) (int, error) {
+	mut, err := filterMutationServiceOf[T](s.next, OpDeleteByFilter)
+	if err != nil {
+		return 0, err
+	}
+	return mut.DeleteWhere(ctx, criteria)
+}
+
+func (s *hooksService[T]) CountWhere(ctx Context, criteria []repository.SelectCriteria) (int, error) {
+	mut, err := filterMutationServiceOf[T](s.next, OpList)
+	if err != nil {
+		return 0, err
+	}
+	return mut.CountWhere(ctx, criteria)
+}
+
 func runHookFuncs[T any](ctx HookContext, hooks []HookFunc[T], record T) error {
 	for _, h := range hooks {
 		if h == nil {
@@ -467,55 +889,75 @@ func runBatchHookFuncs[T any](ctx HookContext, hooks []HookBatchFunc[T], records
 // --- scope guard ---
 
 func (s *scopeGuardService[T]) Create(ctx Context, record T) (T, error) {
-	if meta, err := s.resolveGuard(ctx, OpCreate); err != nil {
+	ctx, err := s.resolveGuard(ctx, OpCreate)
+	if err != nil {
+		return record, err
+	}
+	if record, err = enforceScopeOnRecord(ScopeFromContext(ctx.UserContext()), OpCreate, record); err != nil {
 		return record, err
-	} else {
-		ctx = meta
 	}
 	return s.next.Create(ctx, record)
 }
 
 func (s *scopeGuardService[T]) CreateBatch(ctx Context, records []T) ([]T, error) {
-	if meta, err := s.resolveGuard(ctx, OpCreateBatch); err != nil {
+	ctx, err := s.resolveGuard(ctx, OpCreateBatch)
+	if err != nil {
+		return nil, err
+	}
+	records = slices.Clone(records)
+	if err := enforceScopeOnRecords(ScopeFromContext(ctx.UserContext()), OpCreateBatch, records); err != nil {
 		return nil, err
-	} else {
-		ctx = meta
 	}
 	return s.next.CreateBatch(ctx, records)
 }
 
 func (s *scopeGuardService[T]) Update(ctx Context, record T) (T, error) {
-	if meta, err := s.resolveGuard(ctx, OpUpdate); err != nil {
+	ctx, err := s.resolveGuard(ctx, OpUpdate)
+	if err != nil {
+		return record, err
+	}
+	if err := s.requireInScope(ctx, []T{record}); err != nil {
+		return record, err
+	}
+	if record, err = enforceScopeOnRecord(ScopeFromContext(ctx.UserContext()), OpUpdate, record); err != nil {
 		return record, err
-	} else {
-		ctx = meta
 	}
 	return s.next.Update(ctx, record)
 }
 
 func (s *scopeGuardService[T]) UpdateBatch(ctx Context, records []T) ([]T, error) {
-	if meta, err := s.resolveGuard(ctx, OpUpdateBatch); err != nil {
+	ctx, err := s.resolveGuard(ctx, OpUpdateBatch)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.requireInScope(ctx, records); err != nil {
+		return nil, err
+	}
+	records = slices.Clone(records)
+	if err := enforceScopeOnRecords(ScopeFromContext(ctx.UserContext()), OpUpdateBatch, records); err != nil {
 		return nil, err
-	} else {
-		ctx = meta
 	}
 	return s.next.UpdateBatch(ctx, records)
 }
 
 func (s *scopeGuardService[T]) Delete(ctx Context, record T) error {
-	if meta, err := s.resolveGuard(ctx, OpDelete); err != nil {
+	ctx, err := s.resolveGuard(ctx, OpDelete)
+	if err != nil {
+		return err
+	}
+	if err := s.requireInScope(ctx, []T{record}); err != nil {
 		return err
-	} else {
-		ctx = meta
 	}
 	return s.next.Delete(ctx, record)
 }
 
 func (s *scopeGuardService[T]) DeleteBatch(ctx Context, records []T) error {
-	if meta, err := s.resolveGuard(ctx, OpDeleteBatch); err != nil {
+	ctx, err := s.resolveGuard(ctx, OpDeleteBatch)
+	if err != nil {
+		return err
+	}
+	if err := s.requireInScope(ctx, records); err != nil {
 		return err
-	} else {
-		ctx = meta
 	}
 	return s.next.DeleteBatch(ctx, records)
 }
@@ -528,7 +970,11 @@ func (s *scopeGuardService[T]) Index(ctx Context, criteria []repository.SelectCr
 	}
 	scope := ScopeFromContext(guardCtx.UserContext())
 	criteria = append(criteria, scope.selectCriteria()...)
-	return s.next.Index(guardCtx, criteria)
+	records, count, err := s.next.Index(guardCtx, criteria)
+	if err == nil {
+		filterTenantRelations(guardCtx, s.tenancy, records)
+	}
+	return records, count, err
 }
 
 func (s *scopeGuardService[T]) Show(ctx Context, id string, criteria []repository.SelectCriteria) (T, error) {
@@ -539,7 +985,155 @@ func (s *scopeGuardService[T]) Show(ctx Context, id string, criteria []repositor
 	}
 	scope := ScopeFromContext(guardCtx.UserContext())
 	criteria = append(criteria, scope.selectCriteria()...)
-	return s.next.Show(guardCtx, id, criteria)
+	record, err := s.next.Show(guardCtx, id, criteria)
+	if err != nil {
+		return record, err
+	}
+	return filterTenantRelation(guardCtx, s.tenancy, record), nil
+}
+
+func (s *scopeGuardService[T]) Aggregate(ctx Context, spec AggregateSpec, criteria []repository.SelectCriteria) (AggregateResult, error) {
+	agg, err := aggregateServiceOf[T](s.next)
+	if err != nil {
+		return AggregateResult{}, err
+	}
+	guardCtx, err := s.resolveGuard(ctx, OpAggregate)
+	if err != nil {
+		return AggregateResult{}, err
+	}
+	scope := ScopeFromContext(guardCtx.UserContext())
+	criteria = append(criteria, scope.selectCriteria()...)
+	return agg.Aggregate(guardCtx, spec, criteria)
+}
+
+func (s *scopeGuardService[T]) Restore(ctx Context, record T) (T, error) {
+	soft, err := softDeleteServiceOf[T](s.next, OpRestore)
+	if err != nil {
+		return record, err
+	}
+	if ctx, err = s.resolveGuard(ctx, OpRestore); err != nil {
+		return record, err
+	}
+	if err := s.requireInScope(ctx, []T{record}, repository.SelectDeletedAlso()); err != nil {
+		return record, err
+	}
+	return soft.Restore(ctx, record)
+}
+
+func (s *scopeGuardService[T]) RestoreBatch(ctx Context, records []T) ([]T, error) {
+	soft, err := softDeleteServiceOf[T](s.next, OpRestoreBatch)
+	if err != nil {
+		return nil, err
+	}
+	if ctx, err = s.resolveGuard(ctx, OpRestoreBatch); err != nil {
+		return nil, err
+	}
+	if err := s.requireInScope(ctx, records, repository.SelectDeletedAlso()); err != nil {
+		return nil, err
+	}
+	return soft.RestoreBatch(ctx, records)
+}
+
+func (s *scopeGuardService[T]) Purge(ctx Context, record T) error {
+	soft, err := softDeleteServiceOf[T](s.next, OpPurge)
+	if err != nil {
+		return err
+	}
+	if ctx, err = s.resolveGuard(ctx, OpPurge); err != nil {
+		return err
+	}
+	if err := s.requireInScope(ctx, []T{record}, repository.SelectDeletedAlso()); err != nil {
+		return err
+	}
+	return soft.Purge(ctx, record)
+}
+
+func (s *scopeGuardService[T]) PurgeBatch(ctx Context, records []T) error {
+	soft, err := softDeleteServiceOf[T](s.next, OpPurgeBatch)
+	if err != nil {
+		return err
+	}
+	if ctx, err = s.resolveGuard(ctx, OpPurgeBatch); err != nil {
+		return err
+	}
+	if err := s.requireInScope(ctx, records, repository.SelectDeletedAlso()); err != nil {
+		return err
+	}
+	return soft.PurgeBatch(ctx, records)
+}
+
+func (s *scopeGuardService[T]) Upsert(ctx Context, record T) (T, UpsertAction, error) {
+	up, err := upsertServiceOf[T](s.next, OpUpsert)
+	if err != nil {
+		return record, "", err
+	}
+	if ctx, err = s.resolveGuard(ctx, OpUpsert); err != nil {
+		return record, "", err
+	}
+	if record, err = enforceScopeOnRecord(ScopeFromContext(ctx.UserContext()), OpUpsert, record); err != nil {
+		return record, "", err
+	}
+	return up.Upsert(ctx, record)
+}
+
+func (s *scopeGuardService[T]) UpsertBatch(ctx Context, records []T) ([]T, []UpsertAction, error) {
+	up, err := upsertServiceOf[T](s.next, OpUpsertBatch)
+	if err != nil {
+		return nil, nil, err
+	}
+	if ctx, err = s.resolveGuard(ctx, OpUpsertBatch); err != nil {
+		return nil, nil, err
+	}
+	records = slices.Clone(records)
+	if err := enforceScopeOnRecords(ScopeFromContext(ctx.UserContext()), OpUpsertBatch, records); err != nil {
+		return nil, nil, err
+	}
+	return up.UpsertBatch(ctx, records)
+}
+
+func (s *scopeGuardService[T]) UpdateWhere(ctx Context, values T, fields []string, criteria []repository.SelectCriteria) (int, error) {
+	mut, err := filterMutationServiceOf[T](s.next, OpUpdateByFilter)
+	if err != nil {
+		return 0, err
+	}
+	guardCtx, err := s.resolveGuard(ctx, OpUpdateByFilter)
+	if err != nil {
+		return 0, err
+	}
+	scope := ScopeFromContext(guardCtx.UserContext())
+	if values, err = enforceScopeOnFields(scope, OpUpdateByFilter, values, fields); err != nil {
+		return 0, err
+	}
+	criteria = append(criteria, scope.selectCriteria()...)
+	return mut.UpdateWhere(guardCtx, values, fields, criteria)
+}
+
+func (s *scopeGuardService[T]) DeleteWhere(ctx Context, criteria []repository.SelectCriteria) (int, error) {
+	mut, err := filterMutationServiceOf[T](s.next, OpDeleteByFilter)
+	if err != nil {
+		return 0, err
+	}
+	guardCtx, err := s.resolveGuard(ctx, OpDeleteByFilter)
+	if err != nil {
+		return 0, err
+	}
+	scope := ScopeFromContext(guardCtx.UserContext())
+	criteria = append(criteria, scope.selectCriteria()...)
+	return mut.DeleteWhere(guardCtx, criteria)
+}
+
+func (s *scopeGuardService[T]) CountWhere(ctx Context, criteria []repository.SelectCriteria) (int, error) {
+	mut, err := filterMutationServiceOf[T](s.next, OpList)
+	if err != nil {
+		return 0, err
+	}
+	guardCtx, err := s.resolveGuard(ctx, OpList)
+	if err != nil {
+		return 0, err
+	}
+	scope := ScopeFromContext(guardCtx.UserContext())
+	criteria = append(criteria, scope.selectCriteria()...)
+	return mut.CountWhere(guardCtx, criteria)
 }
 
 func (s *scopeGuardService[T]) resolveGuard(ctx Context, op CrudOperation) (Context, error) {
@@ -553,21 +1147,57 @@ func (s *scopeGuardService[T]) resolveGuard(ctx Context, op CrudOperation) (Cont
 	return ctx, nil
 }
 
+// requireInScope looks each record up through the scope so writes addressed
+// by ID cannot reach rows outside it. extra is added to each lookup, e.g.
+// repository.SelectDeletedAlso for restores and purges.
+func (s *scopeGuardService[T]) requireInScope(ctx Context, records []T, extra ...repository.SelectCriteria) error {
+	scope := ScopeFromContext(ctx.UserContext())
+	if len(scope.selectCriteria()) == 0 {
+		return nil
+	}
+	for _, record := range records {
+		id, err := formatRecordID(s.handlers, s.idCodec, record)
+		if err != nil {
+			return &ValidationError{err}
+		}
+		if _, err := s.next.Show(ctx, id, append(scope.selectCriteria(), extra...)); err != nil {
+			return &NotFoundError{err}
+		}
+	}
+	return nil
+}
+
 // --- field policy ---
 
 func (s *fieldPolicyService[T]) Create(ctx Context, record T) (T, error) {
-	return s.next.Create(ctx, record)
+	records, err := s.enforceWritable(ctx, OpCreate, []T{record})
+	if err != nil {
+		return record, err
+	}
+	return s.next.Create(ctx, records[0])
 }
 
 func (s *fieldPolicyService[T]) CreateBatch(ctx Context, records []T) ([]T, error) {
+	records, err := s.enforceWritable(ctx, OpCreateBatch, records)
+	if err != nil {
+		return nil, err
+	}
 	return s.next.CreateBatch(ctx, records)
 }
 
 func (s *fieldPolicyService[T]) Update(ctx Context, record T) (T, error) {
-	return s.next.Update(ctx, record)
+	records, err := s.enforceWritable(ctx, OpUpdate, []T{record})
+	if err != nil {
+		return record, err
+	}
+	return s.next.Update(ctx, records[0])
 }
 
 func (s *fieldPolicyService[T]) UpdateBatch(ctx Context, records []T) ([]T, error) {
+	records, err := s.enforceWritable(ctx, OpUpdateBatch, records)
+	if err != nil {
+		return nil, err
+	}
 	return s.next.UpdateBatch(ctx, records)
 }
 
@@ -609,6 +1239,121 @@ func (s *fieldPolicyService[T]) Show(ctx Context, id string, criteria []reposito
 	return record, nil
 }
 
+func (s *fieldPolicyService[T]) Aggregate(ctx Context, spec AggregateSpec, criteria []repository.SelectCriteria) (AggregateResult, error) {
+	agg, err := aggregateServiceOf[T](s.next)
+	if err != nil {
+		return AggregateResult{}, err
+	}
+	decision, err := s.resolvePolicy(ctx, OpAggregate)
+	if err != nil {
+		return AggregateResult{}, err
+	}
+	if _, err := planPolicyAggregate(spec, getAllowedFields[T](), getFieldTypes(typeOf[T]()), decision); err != nil {
+		return AggregateResult{}, err
+	}
+	return agg.Aggregate(ctx, spec, s.applyCriteria(criteria, decision))
+}
+
+func (s *fieldPolicyService[T]) Restore(ctx Context, record T) (T, error) {
+	soft, err := softDeleteServiceOf[T](s.next, OpRestore)
+	if err != nil {
+		return record, err
+	}
+	return soft.Restore(ctx, record)
+}
+
+func (s *fieldPolicyService[T]) RestoreBatch(ctx Context, records []T) ([]T, error) {
+	soft, err := softDeleteServiceOf[T](s.next, OpRestoreBatch)
+	if err != nil {
+		return nil, err
+	}
+	return soft.RestoreBatch(ctx, records)
+}
+
+func (s *fieldPolicyService[T]) Purge(ctx Context, record T) error {
+	soft, err := softDeleteServiceOf[T](s.next, OpPurge)
+	if err != nil {
+		return err
+	}
+	return soft.Purge(ctx, record)
+}
+
+func (s *fieldPolicyService[T]) PurgeBatch(ctx Context, records []T) error {
+	soft, err := softDeleteServiceOf[T](s.next, OpPurgeBatch)
+	if err != nil {
+		return err
+	}
+	return soft.PurgeBatch(ctx, records)
+}
+
+func (s *fieldPolicyService[T]) Upsert(ctx Context, record T) (T, UpsertAction, error) {
+	up, err := upsertServiceOf[T](s.next, OpUpsert)
+	if err != nil {
+		return record, "", err
+	}
+	records, err := s.enforceWritable(ctx, OpUpsert, []T{record})
+	if err != nil {
+		return record, "", err
+	}
+	return up.Upsert(ctx, records[0])
+}
+
+func (s *fieldPolicyService[T]) UpsertBatch(ctx Context, records []T) ([]T, []UpsertAction, error) {
+	up, err := upsertServiceOf[T](s.next, OpUpsertBatch)
+	if err != nil {
+		return nil, nil, err
+	}
+	records, err = s.enforceWritable(ctx, OpUpsertBatch, records)
+	if err != nil {
+		return nil, nil, err
+	}
+	return up.UpsertBatch(ctx, records)
+}
+
+func (s *fieldPolicyService[T]) UpdateWhere(ctx Context, values T, fields []string, criteria []repository.SelectCriteria) (int, error) {
+	mut, err := filterMutationServiceOf[T](s.next, OpUpdateByFilter)
+	if err != nil {
+		return 0, err
+	}
+	decision, err := s.resolvePolicy(ctx, OpUpdateByFilter)
+	if err != nil {
+		return 0, err
+	}
+	for _, field := range fields {
+		if !decision.allowsField(field) {
+			return 0, &ValidationError{fmt.Errorf("field %q cannot be updated", field)}
+		}
+	}
+	if fields, err = decision.filterWritableFields(OpUpdateByFilter, fields); err != nil || len(fields) == 0 {
+		return 0, err
+	}
+	return mut.UpdateWhere(ctx, values, fields, s.applyCriteria(criteria, decision))
+}
+
+func (s *fieldPolicyService[T]) DeleteWhere(ctx Context, criteria []repository.SelectCriteria) (int, error) {
+	mut, err := filterMutationServiceOf[T](s.next, OpDeleteByFilter)
+	if err != nil {
+		return 0, err
+	}
+	decision, err := s.resolvePolicy(ctx, OpDeleteByFilter)
+	if err != nil {
+		return 0, err
+	}
+	return mut.DeleteWhere(ctx, s.applyCriteria(criteria, decision))
+}
+
+func (s *fieldPolicyService[T]) CountWhere(ctx Context, criteria []repository.SelectCriteria) (int, error) {
+	mut, err := filterMutationServiceOf[T](s.next, OpList)
+	if err != nil {
+		return 0, err
+	}
+	decision, err := s.resolvePolicy(ctx, OpList)
+	if err != nil {
+		return 0, err
+	}
+	return mut.CountWhere(ctx, s.applyCriteria(criteria, decision))
+}
+
 func (s *fieldPolicyService[T]) resolvePolicy(ctx Context, op CrudOperation) (resolvedFieldPolicy, error) {
 	if s.provider == nil {
 		return resolvedFieldPolicy{}, nil
@@ -635,6 +1380,48 @@ func (s *fieldPolicyService[T]) resolvePolicy(ctx Context, op CrudOperation) (re
 	return buildResolvedFieldPolicy[T](policy, getAllowedFields[T](), resource, op), nil
 }
 
+// enforceWritable enforces the writable fields of the op policy on records.
+// Creates and upserts are checked against the zero value and updates against
+// the stored records.
+func (s *fieldPolicyService[T]) enforceWritable(ctx Context, op CrudOperation, records []T) ([]T, error) {
+	decision, err := s.resolvePolicy(ctx, op)
+	if err != nil {
+		return nil, err
+	}
+	if len(decision.writableRules(op)) == 0 {
+		return records, nil
+	}
+	update := op == OpUpdate || op == OpUpdateBatch
+	out := make([]T, len(records))
+	for i, record := range records {
+		if !update {
+			out[i], err = enforceWritableCreate(decision, ScopeFromContext(ctx.UserContext()), op, record)
+		} else {
+			var existing T
+			if existing, err = s.storedRecord(ctx, record, decision); err != nil {
+				return nil, err
+			}
+			out[i], err = enforceWritableUpdate(decision, op, record, existing)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func (s *fieldPolicyService[T]) storedRecord(ctx Context, record T, decision resolvedFieldPolicy) (T, error) {
+	id, err := formatRecordID(s.handlers, s.idCodec, record)
+	if err != nil {
+		return record, &ValidationError{err}
+	}
+	existing, err := s.next.Show(ctx, id, s.applyCriteria(nil, decision))
+	if err != nil {
+		return existing, &NotFoundError{err}
+	}
+	return existing, nil
+}
+
 func (s *fieldPolicyService[T]) applyCriteria(criteria []repository.SelectCriteria, decision resolvedFieldPolicy) []repository.SelectCriteria {
 	if decision.isZero() {
 		return criteria
@@ -688,6 +1475,102 @@ func (s *activityService[T]) Show(ctx Context, id string, criteria []repository.
 	return s.next.Show(ctx, id, criteria)
 }
 
+func (s *activityService[T]) Aggregate(ctx Context, spec AggregateSpec, criteria []repository.SelectCriteria) (AggregateResult, error) {
+	agg, err := aggregateServiceOf[T](s.next)
+	if err != nil {
+		return AggregateResult{}, err
+	}
+	return agg.Aggregate(ctx, spec, criteria)
+}
+
+func (s *activityService[T]) Restore(ctx Context, record T) (T, error) {
+	soft, err := softDeleteServiceOf[T](s.next, OpRestore)
+	if err != nil {
+		return record, err
+	}
+	res, err := soft.Restore(ctx, record)
+	s.emit(ctx, OpRestore, []T{res}, err)
+	return res, err
+}
+
+func (s *activityService[T]) RestoreBatch(ctx Context, records []T) ([]T, error) {
+	soft, err := softDeleteServiceOf[T](s.next, OpRestoreBatch)
+	if err != nil {
+		return nil, err
+	}
+	res, err := soft.RestoreBatch(ctx, records)
+	s.emit(ctx, OpRestoreBatch, res, err)
+	return res, err
+}
+
+func (s *activityService[T]) Purge(ctx Context, record T) error {
+	soft, err := softDeleteServiceOf[T](s.next, OpPurge)
+	if err != nil {
+		return err
+	}
+	err = soft.Purge(ctx, record)
+	s.emit(ctx, OpPurge, []T{record}, err)
+	return err
+}
+
+func (s *activityService[T]) PurgeBatch(ctx Context, records []T) error {
+	soft, err := softDeleteServiceOf[T](s.next, OpPurgeBatch)
+	if err != nil {
+		return err
+	}
+	err = soft.PurgeBatch(ctx, records)
+	s.emit(ctx, OpPurgeBatch, records, err)
+	return err
+}
+
+func (s *activityService[T]) Upsert(ctx Context, record T) (T, UpsertAction, error) {
+	up, err := upsertServiceOf[T](s.next, OpUpsert)
+	if err != nil {
+		return record, "", err
+	}
+	res, action, err := up.Upsert(ctx, record)
+	s.emit(ctx, OpUpsert, []T{res}, err)
+	return res, action, err
+}
+
+func (s *activityService[T]) UpsertBatch(ctx Context, records []T) ([]T, []UpsertAction, error) {
+	up, err := upsertServiceOf[T](s.next, OpUpsertBatch)
+	if err != nil {
+		return nil, nil, err
+	}
+	res, actions, err := up.UpsertBatch(ctx, records)
+	s.emit(ctx, OpUpsertBatch, res, err)
+	return res, actions, err
+}
+
+func (s *activityService[T]) UpdateWhere(ctx Context, values T, fields []string, criteria []repository.SelectCriteria) (int, error) {
+	mut, err := filterMutationServiceOf[T](s.next, OpUpdateByFilter)
+	if err != nil {
+		return 0, err
+	}
+	affected, err := mut.UpdateWhere(ctx, values, fields, criteria)
+	s.emitAffected(ctx, OpUpdateByFilter, affected, err)
+	return affected, err
+}
+
+func (s *activityService[T]) DeleteWhere(ctx Context, criteria []repository.SelectCriteria) (int, error) {
+	mut, err := filterMutationServiceOf[T](s.next, OpDeleteByFilter)
+	if err != nil {
+		return 0, err
+	}
+	affected, err := mut.DeleteWhere(ctx, criteria)
+	s.emitAffected(ctx, OpDeleteByFilter, affected, err)
+	return affected, err
+}
+
+func (s *activityService[T]) CountWhere(ctx Context, criteria []repository.SelectCriteria) (int, error) {
+	mut, err := filterMutationServiceOf[T](s.next, OpList)
+	if err != nil {
+		return 0, err
+	}
+	return mut.CountWhere(ctx, criteria)
+}
+
 func (s *activityService[T]) emit(ctx Context, op CrudOperation, records []T, err error) {
 	if err != nil {
 		return
@@ -708,6 +1591,22 @@ func (s *activityService[T]) emit(ctx Context, op CrudOperation, records []T, er
 	}
 }
 
+// emitAffected emits a single event for writes that have no record list.
+func (s *activityService[T]) emitAffected(ctx Context, op CrudOperation, affected int, err error) {
+	if err != nil || s.emitter == nil || !s.emitter.Enabled() {
+		return
+	}
+	objType := reflect.TypeOf((*T)(nil)).Elem()
+	if objType.Kind() == reflect.Pointer {
+		objType = objType.Elem()
+	}
+	_ = s.emitter.Emit(ctx.UserContext(), activity.Event{
+		Verb:       string(op),
+		ObjectType: objType.String(),
+		Metadata:   map[string]any{"affected": affected},
+	})
+}
+
 func extractObjectInfo[T any](record T) (string, string) {
 	rv := reflect.ValueOf(record)
 	rt := reflect.TypeOf(record)
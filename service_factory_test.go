@@ -255,6 +255,7 @@ func TestNewService_ComposesWrappersInDefaultOrder(t *testing.T) {
 	assert.Equal(t, model, created)
 
 	expectedOrder := []string{
+		"fieldpolicy:resolve",
 		"scope:guard",
 		"hook:beforeCreate",
 		"validate",
@@ -0,0 +1,500 @@
+package crud
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ettle/strcase"
+	"github.com/goliatone/go-repository-bun"
+)
+
+const (
+	// WithDeletedQueryParam includes soft-deleted rows in Index and Show.
+	WithDeletedQueryParam = "with_deleted"
+	// OnlyDeletedQueryParam restricts Index and Show to soft-deleted rows.
+	OnlyDeletedQueryParam = "only_deleted"
+
+	// TAG_BUN_SOFT_DELETE is the bun tag option that enables soft deletes.
+	TAG_BUN_SOFT_DELETE = "soft_delete"
+)
+
+// SoftDeleteService is implemented by services that can restore and purge
+// soft-deleted records. The repository-backed service and the NewService
+// layers implement it; custom services opt in by implementing it too.
+type SoftDeleteService[T any] interface {
+	Restore(ctx Context, record T) (T, error)
+	RestoreBatch(ctx Context, records []T) ([]T, error)
+
+	Purge(ctx Context, record T) error
+	PurgeBatch(ctx Context, records []T) error
+}
+
+// softDeleteServiceOf returns svc as a SoftDeleteService, or an
+// UnsupportedOperationError for op when it does not implement one.
+func softDeleteServiceOf[T any](svc any, op CrudOperation) (SoftDeleteService[T], error) {
+	if soft, ok := svc.(SoftDeleteService[T]); ok && soft != nil {
+		return soft, nil
+	}
+	return nil, UnsupportedOperationError{Operation: op}
+}
+
+type softDeleteField struct {
+	index  []int
+	column string
+	json   string
+}
+
+var softDeleteFieldCache sync.Map // map[reflect.Type]softDeleteField
+
+// softDeleteFieldFor resolves the field tagged `bun:",soft_delete"`.
+func softDeleteFieldFor(typ reflect.Type) (softDeleteField, bool) {
+	for typ != nil && typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return softDeleteField{}, false
+	}
+	if cached, ok := softDeleteFieldCache.Load(typ); ok {
+		field := cached.(softDeleteField)
+		return field, field.index != nil
+	}
+	var field softDeleteField
+	collectSoftDeleteField(typ, nil, &field)
+	softDeleteFieldCache.Store(typ, field)
+	return field, field.index != nil
+}
+
+func collectSoftDeleteField(typ reflect.Type, parent []int, out *softDeleteField) {
+	for i := 0; i < typ.NumField() && out.index == nil; i++ {
+		field := typ.Field(i)
+		index := append(append([]int{}, parent...), i)
+		bunTag := field.Tag.Get(TAG_BUN)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && bunTag == "" {
+			collectSoftDeleteField(field.Type, index, out)
+			continue
+		}
+		if !field.IsExported() || !hasTagOption(bunTag, TAG_BUN_SOFT_DELETE) {
+			continue
+		}
+		column := strings.TrimSpace(strings.Split(bunTag, ",")[0])
+		if column == "" {
+			column = strcase.ToSnake(field.Name)
+		}
+		*out = softDeleteField{index: index, column: column, json: jsonFieldName(field)}
+	}
+}
+
+// clear resets the soft delete column on record so the row reads as live.
+func (f softDeleteField) clear(record any) {
+	rv := reflect.ValueOf(record)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return
+	}
+	value, err := rv.FieldByIndexErr(f.index)
+	if err != nil || !value.CanSet() {
+		return
+	}
+	value.Set(reflect.Zero(value.Type()))
+}
+
+// softDeleteCriteria widens or narrows a select on a soft-deletable model.
+// Models without a soft_delete column are left untouched.
+func softDeleteCriteria(typ reflect.Type, withDeleted, onlyDeleted bool) []repository.SelectCriteria {
+	if _, ok := softDeleteFieldFor(typ); !ok {
+		return nil
+	}
+	switch {
+	case onlyDeleted:
+		return []repository.SelectCriteria{repository.SelectDeletedOnly()}
+	case withDeleted:
+		return []repository.SelectCriteria{repository.SelectDeletedAlso()}
+	default:
+		return nil
+	}
+}
+
+func queryFlag(ctx Context, name string) bool {
+	flag, err := strconv.ParseBool(strings.TrimSpace(ctx.Query(name)))
+	return err == nil && flag
+}
+
+// annotateSoftDeleteInSchema marks the soft delete property with
+// `x-soft-delete` so generators can expose restore and purge operations.
+func annotateSoftDeleteInSchema(doc map[string]any, schemaName string, modelType reflect.Type) {
+	field, ok := softDeleteFieldFor(modelType)
+	if !ok || len(doc) == 0 || schemaName == "" {
+		return
+	}
+	props, _ := ensureSchemaProperties(doc, schemaName)
+	if prop, ok := props[field.json].(map[string]any); ok {
+		prop["x-soft-delete"] = true
+	}
+}
+
+// SupportsSoftDelete reports whether the controller model has a soft_delete
+// column, which enables the restore and purge operations.
+func (c *Controller[T]) SupportsSoftDelete() bool {
+	_, ok := softDeleteFieldFor(c.resourceType)
+	return ok
+}
+
+// --- repository service ---
+
+// Restore clears the soft delete column of record. Rows that are not
+// soft-deleted report a NotFoundError.
+func (s *repositoryService[T]) Restore(ctx Context, record T) (T, error) {
+	field, ok := softDeleteFieldFor(reflect.TypeOf(record))
+	if !ok {
+		return record, UnsupportedOperationError{Operation: OpRestore}
+	}
+	field.clear(record)
+	criteria := []repository.UpdateCriteria{
+		repository.UpdateDeletedOnly(),
+		repository.UpdateColumns(field.column),
+	}
+	var (
+		restored T
+		err      error
+	)
+	if tx, inTx := TxFromContext(ctx.UserContext()); inTx {
+		restored, err = s.repo.UpdateTx(ctx.UserContext(), tx, record, criteria...)
+	} else {
+		restored, err = s.repo.Update(ctx.UserContext(), record, criteria...)
+	}
+	if err != nil && repository.IsSQLExpectedCountViolation(err) {
+		return restored, &NotFoundError{fmt.Errorf("no deleted record to restore")}
+	}
+	return restored, err
+}
+
+func (s *repositoryService[T]) RestoreBatch(ctx Context, records []T) ([]T, error) {
+	restored := make([]T, 0, len(records))
+	for _, record := range records {
+		result, err := s.Restore(ctx, record)
+		if err != nil {
+			return nil, err
+		}
+		restored = append(restored, result)
+	}
+	return restored, nil
+}
+
+// Purge permanently removes record, whether or not it was soft-deleted.
+func (s *repositoryService[T]) Purge(ctx Context, record T) error {
+	if tx, ok := TxFromContext(ctx.UserContext()); ok {
+		return s.repo.ForceDeleteTx(ctx.UserContext(), tx, record)
+	}
+	return s.repo.ForceDelete(ctx.UserContext(), record)
+}
+
+func (s *repositoryService[T]) PurgeBatch(ctx Context, records []T) error {
+	return s.deleteRecords(ctx, records, repository.DeleteForReal())
+}
+
+// --- controller ---
+
+func (c *Controller[T]) registerSoftDeleteRoutes(resource, batchPath string, register func(op CrudOperation, defaultMethod, path string, handler func(Context) error, routeName string)) {
+	if !c.SupportsSoftDelete() {
+		return
+	}
+	showPath := fmt.Sprintf("/%s/:id", resource)
+
+	// /user/batch/restore and /user/batch/purge go first so :id does not shadow them.
+	register(OpRestoreBatch, http.MethodPost, batchPath+"/restore", c.RestoreBatch, fmt.Sprintf("%s:%s", resource, OpRestoreBatch))
+	register(OpPurgeBatch, http.MethodDelete, batchPath+"/purge", c.PurgeBatch, fmt.Sprintf("%s:%s", resource, OpPurgeBatch))
+
+	// /user/:id/restore and /user/:id/purge
+	register(OpRestore, http.MethodPost, showPath+"/restore", c.Restore, fmt.Sprintf("%s:%s", resource, OpRestore))
+	register(OpPurge, http.MethodDelete, showPath+"/purge", c.Purge, fmt.Sprintf("%s:%s", resource, OpPurge))
+}
+
+// Restore brings back a soft-deleted record:
+// POST /user/:id/restore
+func (c *Controller[T]) Restore(ctx Context) error {
+	ctx = c.applyContextFactory(ctx)
+	record, policy, err := c.restoreByID(ctx, ctx.Params("id"))
+	if err != nil {
+		return c.resp.OnError(ctx, err, OpRestore)
+	}
+	applyFieldPolicyToRecord(record, policy)
+	return c.resp.OnData(ctx, record, OpRestore)
+}
+
+// Purge permanently removes a record, including soft-deleted ones:
+// DELETE /user/:id/purge
+func (c *Controller[T]) Purge(ctx Context) error {
+	ctx = c.applyContextFactory(ctx)
+	if err := c.purgeByID(ctx, ctx.Params("id")); err != nil {
+		return c.resp.OnError(ctx, err, OpPurge)
+	}
+	return c.resp.OnEmpty(ctx, OpPurge)
+}
+
+// RestoreBatch restores the records (or ids) listed in the body:
+// POST /user/batch/restore
+func (c *Controller[T]) RestoreBatch(ctx Context) error {
+	ctx = c.applyContextFactory(ctx)
+	svc := c.resolvedWriteService()
+	meta, policy, err := c.prepareSoftDeleteOp(ctx, OpRestoreBatch)
+	if err != nil {
+		return c.resp.OnError(ctx, err, OpRestoreBatch)
+	}
+
+	records, err := c.decodeIDBatch(ctx, OpRestoreBatch)
+	if err != nil {
+		c.emitActivityEvents(ctx, OpRestoreBatch, meta, records, err)
+		return c.resp.OnError(ctx, &ValidationError{err}, OpRestoreBatch)
+	}
+	soft, err := softDeleteServiceOf[T](svc, OpRestoreBatch)
+	if err != nil {
+		return c.resp.OnError(ctx, err, OpRestoreBatch)
+	}
+	criteria := c.softDeleteTargetCriteria(meta, policy, repository.SelectDeletedOnly())
+
+	if !isAtomicBatch(ctx) {
+		results := c.runBatchItems(ctx, OpRestoreBatch, meta, records, http.StatusOK, func(record T) (T, error) {
+			stored, err := c.loadBatchTargets(ctx, svc, OpRestoreBatch, criteria, []T{record})
+			if err != nil {
+				return record, err
+			}
+			return soft.Restore(ctx, stored[0])
+		})
+		return c.writeBatchResults(ctx, OpRestoreBatch, results)
+	}
+
+	var restored []T
+	err = c.runInTx(ctx, func() error {
+		stored, err := c.loadBatchTargets(ctx, svc, OpRestoreBatch, criteria, records)
+		if err != nil {
+			return err
+		}
+		restored, err = soft.RestoreBatch(ctx, stored)
+		return err
+	})
+	if err != nil {
+		c.emitActivityEvents(ctx, OpRestoreBatch, meta, records, err)
+		return c.resp.OnError(ctx, err, OpRestoreBatch)
+	}
+
+	c.emitActivityEvents(ctx, OpRestoreBatch, meta, restored, nil)
+	applyFieldPolicyToSlice(restored, policy)
+	return c.resp.OnList(ctx, restored, OpRestoreBatch, &Filters{
+		Count:     len(restored),
+		Operation: string(OpRestoreBatch),
+	})
+}
+
+// PurgeBatch permanently removes the records (or ids) listed in the body:
+// DELETE /user/batch/purge
+func (c *Controller[T]) PurgeBatch(ctx Context) error {
+	ctx = c.applyContextFactory(ctx)
+	svc := c.resolvedWriteService()
+	meta, policy, err := c.prepareSoftDeleteOp(ctx, OpPurgeBatch)
+	if err != nil {
+		return c.resp.OnError(ctx, err, OpPurgeBatch)
+	}
+
+	records, err := c.decodeIDBatch(ctx, OpPurgeBatch)
+	if err != nil {
+		c.emitActivityEvents(ctx, OpPurgeBatch, meta, records, err)
+		return c.resp.OnError(ctx, &ValidationError{err}, OpPurgeBatch)
+	}
+	soft, err := softDeleteServiceOf[T](svc, OpPurgeBatch)
+	if err != nil {
+		return c.resp.OnError(ctx, err, OpPurgeBatch)
+	}
+	criteria := c.softDeleteTargetCriteria(meta, policy, repository.SelectDeletedAlso())
+
+	if !isAtomicBatch(ctx) {
+		results := c.runBatchItems(ctx, OpPurgeBatch, meta, records, http.StatusNoContent, func(record T) (T, error) {
+			stored, err := c.loadBatchTargets(ctx, svc, OpPurgeBatch, criteria, []T{record})
+			if err != nil {
+				return record, err
+			}
+			return record, soft.Purge(ctx, stored[0])
+		})
+		return c.writeBatchResults(ctx, OpPurgeBatch, results)
+	}
+
+	err = c.runInTx(ctx, func() error {
+		stored, err := c.loadBatchTargets(ctx, svc, OpPurgeBatch, criteria, records)
+		if err != nil {
+			return err
+		}
+		return soft.PurgeBatch(ctx, stored)
+	})
+	if err != nil {
+		c.emitActivityEvents(ctx, OpPurgeBatch, meta, records, err)
+		return c.resp.OnError(ctx, err, OpPurgeBatch)
+	}
+
+	c.emitActivityEvents(ctx, OpPurgeBatch, meta, records, nil)
+	return c.resp.OnEmpty(ctx, OpPurgeBatch)
+}
+
+// RestoreByID restores a single soft-deleted record after scoped lookup.
+func (c *Controller[T]) RestoreByID(ctx Context, id string) (T, error) {
+	ctx = c.applyContextFactory(ctx)
+	record, policy, err := c.restoreByID(ctx, id)
+	if err != nil {
+		return record, err
+	}
+	applyFieldPolicyToRecord(record, policy)
+	return record, nil
+}
+
+// RestoreRecords restores records in batch.
+func (c *Controller[T]) RestoreRecords(ctx Context, records []T) ([]T, error) {
+	ctx = c.applyContextFactory(ctx)
+	svc := c.resolvedWriteService()
+	meta, policy, err := c.prepareSoftDeleteOp(ctx, OpRestoreBatch)
+	if err != nil {
+		return nil, err
+	}
+	soft, err := softDeleteServiceOf[T](svc, OpRestoreBatch)
+	if err != nil {
+		return nil, err
+	}
+	criteria := c.softDeleteTargetCriteria(meta, policy, repository.SelectDeletedOnly())
+
+	var restored []T
+	err = c.runInTx(ctx, func() error {
+		stored, err := c.loadBatchTargets(ctx, svc, OpRestoreBatch, criteria, records)
+		if err != nil {
+			return err
+		}
+		restored, err = soft.RestoreBatch(ctx, stored)
+		return err
+	})
+	if err != nil {
+		c.emitActivityEvents(ctx, OpRestoreBatch, meta, records, err)
+		return nil, err
+	}
+
+	c.emitActivityEvents(ctx, OpRestoreBatch, meta, restored, nil)
+	applyFieldPolicyToSlice(restored, policy)
+	return restored, nil
+}
+
+// PurgeByID permanently removes a single record after scoped lookup.
+func (c *Controller[T]) PurgeByID(ctx Context, id string) error {
+	return c.purgeByID(c.applyContextFactory(ctx), id)
+}
+
+// PurgeRecords permanently removes records in batch.
+func (c *Controller[T]) PurgeRecords(ctx Context, records []T) error {
+	ctx = c.applyContextFactory(ctx)
+	svc := c.resolvedWriteService()
+	meta, policy, err := c.prepareSoftDeleteOp(ctx, OpPurgeBatch)
+	if err != nil {
+		return err
+	}
+	soft, err := softDeleteServiceOf[T](svc, OpPurgeBatch)
+	if err != nil {
+		return err
+	}
+	criteria := c.softDeleteTargetCriteria(meta, policy, repository.SelectDeletedAlso())
+
+	err = c.runInTx(ctx, func() error {
+		stored, err := c.loadBatchTargets(ctx, svc, OpPurgeBatch, criteria, records)
+		if err != nil {
+			return err
+		}
+		return soft.PurgeBatch(ctx, stored)
+	})
+	c.emitActivityEvents(ctx, OpPurgeBatch, meta, records, err)
+	return err
+}
+
+// prepareSoftDeleteOp runs the guard and field policy steps shared by the
+// restore and purge handlers.
+func (c *Controller[T]) prepareSoftDeleteOp(ctx Context, op CrudOperation) (guardRequestContext, resolvedFieldPolicy, error) {
+	meta, err := c.resolveGuardContext(ctx, op)
+	if err != nil {
+		return meta, resolvedFieldPolicy{}, err
+	}
+	policy, err := c.resolveFieldPolicy(ctx, op, meta)
+	if err != nil {
+		return meta, policy, err
+	}
+	c.logFieldPolicyDecision(policy)
+	c.attachHookContext(ctx, op)
+	return meta, policy, nil
+}
+
+// softDeleteTargetCriteria returns the lookup criteria for restore and purge
+// targets: deleted selects which rows qualify, and the scope and field policy
+// of the request are applied on top.
+func (c *Controller[T]) softDeleteTargetCriteria(meta guardRequestContext, policy resolvedFieldPolicy, deleted repository.SelectCriteria) []repository.SelectCriteria {
+	criteria := c.applyScopeCriteria([]repository.SelectCriteria{deleted}, meta.scope)
+	return c.applyFieldPolicyCriteria(criteria, policy)
+}
+
+func (c *Controller[T]) restoreByID(ctx Context, id string) (T, resolvedFieldPolicy, error) {
+	svc := c.resolvedWriteService()
+	meta, policy, err := c.prepareSoftDeleteOp(ctx, OpRestore)
+	if err != nil {
+		var zero T
+		return zero, policy, err
+	}
+
+	criteria := c.softDeleteTargetCriteria(meta, policy, repository.SelectDeletedOnly())
+	record, err := svc.Show(ctx, strings.TrimSpace(id), criteria)
+	if err != nil {
+		c.emitActivityEvents(ctx, OpRestore, meta, nil, err)
+		return record, policy, &NotFoundError{err}
+	}
+	if err := c.authorizeRecord(ctx, OpRestore, record); err != nil {
+		c.emitActivityEvents(ctx, OpRestore, meta, []T{record}, err)
+		return record, policy, err
+	}
+	soft, err := softDeleteServiceOf[T](svc, OpRestore)
+	if err != nil {
+		return record, policy, err
+	}
+
+	restored, err := soft.Restore(ctx, record)
+	if err != nil {
+		c.emitActivityEvents(ctx, OpRestore, meta, []T{record}, err)
+		return restored, policy, err
+	}
+	c.emitActivityEvents(ctx, OpRestore, meta, []T{restored}, nil)
+	return restored, policy, nil
+}
+
+func (c *Controller[T]) purgeByID(ctx Context, id string) error {
+	svc := c.resolvedWriteService()
+	meta, policy, err := c.prepareSoftDeleteOp(ctx, OpPurge)
+	if err != nil {
+		return err
+	}
+
+	criteria := c.softDeleteTargetCriteria(meta, policy, repository.SelectDeletedAlso())
+	record, err := svc.Show(ctx, strings.TrimSpace(id), criteria)
+	if err != nil {
+		c.emitActivityEvents(ctx, OpPurge, meta, nil, err)
+		return &NotFoundError{err}
+	}
+	if err := c.authorizeRecord(ctx, OpPurge, record); err != nil {
+		c.emitActivityEvents(ctx, OpPurge, meta, []T{record}, err)
+		return err
+	}
+	soft, err := softDeleteServiceOf[T](svc, OpPurge)
+	if err != nil {
+		return err
+	}
+
+	err = soft.Purge(ctx, record)
+	c.emitActivityEvents(ctx, OpPurge, meta, []T{record}, err)
+	return err
+}
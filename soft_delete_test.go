@@ -0,0 +1,193 @@
+package crud
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+
+	"github.com/goliatone/go-repository-bun"
+)
+
+type trashedNote struct {
+	bun.BaseModel `bun:"table:trashed_notes,alias:tn"`
+
+	ID        uuid.UUID `bun:"id,pk,notnull" json:"id"`
+	Title     string    `bun:"title" json:"title"`
+	DeletedAt time.Time `bun:"deleted_at,soft_delete,nullzero" json:"deleted_at,omitempty"`
+}
+
+func setupTrashedNoteApp(t *testing.T, opts ...Option[*trashedNote]) (*fiber.App, *bun.DB, *trashedNote) {
+	t.Helper()
+	db := newCodecTestDB(t, (*trashedNote)(nil))
+	repo := repository.NewRepository(db, repository.ModelHandlers[*trashedNote]{
+		NewRecord:     func() *trashedNote { return &trashedNote{} },
+		GetID:         func(note *trashedNote) uuid.UUID { return note.ID },
+		SetID:         func(note *trashedNote, id uuid.UUID) { note.ID = id },
+		GetIdentifier: func() string { return "Title" },
+	})
+	note := &trashedNote{ID: uuid.New(), Title: "Draft"}
+	_, err := db.NewInsert().Model(note).Exec(context.Background())
+	require.NoError(t, err)
+
+	app := fiber.New()
+	opts = append([]Option[*trashedNote]{withOptInRoutes[*trashedNote]()}, opts...)
+	NewController(repo, opts...).RegisterRoutes(NewFiberAdapter(app))
+	return app, db, note
+}
+
+func trashedNoteCount(t *testing.T, app *fiber.App, query string) int {
+	t.Helper()
+	resp, err := app.Test(batchRequest(http.MethodGet, "/trashed-notes"+query, nil), -1)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var payload APIListResponse[trashedNote]
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&payload))
+	return len(payload.Data)
+}
+
+func TestController_SoftDelete_ListRestoreAndPurge(t *testing.T) {
+	var restored []string
+	app, db, note := setupTrashedNoteApp(t, WithLifecycleHooks(LifecycleHooks[*trashedNote]{
+		AfterRestore: []HookFunc[*trashedNote]{
+			func(_ HookContext, record *trashedNote) error {
+				restored = append(restored, record.Title)
+				return nil
+			},
+		},
+	}))
+	path := "/trashed-note/" + note.ID.String()
+
+	resp, err := app.Test(batchRequest(http.MethodDelete, path, nil), -1)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	assert.Equal(t, 0, trashedNoteCount(t, app, ""))
+	assert.Equal(t, 1, trashedNoteCount(t, app, "?with_deleted=true"))
+	assert.Equal(t, 1, trashedNoteCount(t, app, "?only_deleted=true"))
+
+	resp, err = app.Test(batchRequest(http.MethodGet, path+"?with_deleted=true", nil), -1)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, err = app.Test(batchRequest(http.MethodPost, path+"/restore", nil), -1)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, []string{"Draft"}, restored)
+	assert.Equal(t, 1, trashedNoteCount(t, app, ""))
+	assert.Equal(t, 0, trashedNoteCount(t, app, "?only_deleted=true"))
+
+	resp, err = app.Test(batchRequest(http.MethodPost, path+"/restore", nil), -1)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode, "live records cannot be restored")
+
+	resp, err = app.Test(batchRequest(http.MethodDelete, path+"/purge", nil), -1)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	rows, err := db.NewSelect().Model((*trashedNote)(nil)).WhereAllWithDeleted().Count(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, rows)
+}
+
+func TestController_SoftDelete_BatchRestore(t *testing.T) {
+	app, db, note := setupTrashedNoteApp(t)
+	other := &trashedNote{ID: uuid.New(), Title: "Other"}
+	_, err := db.NewInsert().Model(other).Exec(context.Background())
+	require.NoError(t, err)
+	_, err = db.NewDelete().Model((*trashedNote)(nil)).Where("id IN (?)", bun.In([]uuid.UUID{note.ID, other.ID})).Exec(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 2, trashedNoteCount(t, app, "?only_deleted=true"))
+
+	resp, err := app.Test(batchRequest(http.MethodPost, "/trashed-note/batch/restore", []string{note.ID.String(), other.ID.String()}), -1)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 2, trashedNoteCount(t, app, ""))
+
+	resp, err = app.Test(batchRequest(http.MethodDelete, "/trashed-note/batch/purge", []string{note.ID.String()}), -1)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Equal(t, 1, trashedNoteCount(t, app, "?with_deleted=true"))
+}
+
+func TestController_SoftDelete_BatchesStayInScope(t *testing.T) {
+	app, db, note := setupTrashedNoteApp(t, WithScopeGuard[*trashedNote](func(Context, CrudOperation) (ActorContext, ScopeFilter, error) {
+		var scope ScopeFilter
+		scope.AddColumnFilter("title", "=", "Mine")
+		return ActorContext{ActorID: "actor"}, scope, nil
+	}))
+	_, err := db.NewDelete().Model(note).WherePK().Exec(context.Background())
+	require.NoError(t, err)
+	ids := []string{note.ID.String()}
+
+	resp, err := app.Test(batchRequest(http.MethodPost, "/trashed-note/batch/restore", ids), -1)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, err = app.Test(batchRequest(http.MethodPost, "/trashed-note/batch/restore?atomic=false", ids), -1)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusMultiStatus, resp.StatusCode)
+	var out struct {
+		Data []BatchItemResult `json:"data"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+	assert.Equal(t, http.StatusNotFound, out.Data[0].Status)
+
+	resp, err = app.Test(batchRequest(http.MethodDelete, "/trashed-note/batch/purge", ids), -1)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	var stored trashedNote
+	require.NoError(t, db.NewSelect().Model(&stored).WhereAllWithDeleted().Where("id = ?", note.ID).Scan(context.Background()))
+	assert.False(t, stored.DeletedAt.IsZero(), "the out-of-scope note stays deleted and is not purged")
+}
+
+func TestNewService_SoftDeleteStaysInScope(t *testing.T) {
+	db := newCodecTestDB(t, (*trashedNote)(nil))
+	note := &trashedNote{ID: uuid.New(), Title: "Draft", DeletedAt: time.Now()}
+	_, err := db.NewInsert().Model(note).Exec(context.Background())
+	require.NoError(t, err)
+	svc := NewService(ServiceConfig[*trashedNote]{
+		Repository: repository.NewRepository(db, repository.ModelHandlers[*trashedNote]{
+			NewRecord: func() *trashedNote { return &trashedNote{} },
+			GetID:     func(note *trashedNote) uuid.UUID { return note.ID },
+			SetID:     func(note *trashedNote, id uuid.UUID) { note.ID = id },
+		}),
+		ScopeGuard: func(Context, CrudOperation) (ActorContext, ScopeFilter, error) {
+			var scope ScopeFilter
+			scope.AddColumnFilter("title", "=", "Mine")
+			return ActorContext{ActorID: "actor"}, scope, nil
+		},
+	})
+	soft, err := softDeleteServiceOf[*trashedNote](svc, OpRestoreBatch)
+	require.NoError(t, err)
+
+	_, err = soft.RestoreBatch(newBenchContext(), []*trashedNote{{ID: note.ID}})
+	var notFound *NotFoundError
+	assert.ErrorAs(t, err, &notFound)
+	assert.ErrorAs(t, soft.PurgeBatch(newBenchContext(), []*trashedNote{{ID: note.ID}}), &notFound)
+	_, err = soft.Restore(newBenchContext(), &trashedNote{ID: note.ID})
+	assert.ErrorAs(t, err, &notFound)
+	assert.ErrorAs(t, soft.Purge(newBenchContext(), &trashedNote{ID: note.ID}), &notFound)
+
+	count, err := db.NewSelect().Model((*trashedNote)(nil)).WhereAllWithDeleted().Count(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestController_SoftDelete_RoutesRequireSoftDeleteColumn(t *testing.T) {
+	app, db := setupApp(t)
+	defer db.Close()
+
+	resp, err := app.Test(batchRequest(http.MethodPost, "/test-user/"+uuid.NewString()+"/restore", nil), -1)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.False(t, NewController(repository.Repository[*TestUser](nil)).SupportsSoftDelete())
+}
@@ -0,0 +1,311 @@
+package crud
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/ettle/strcase"
+	"github.com/uptrace/bun"
+)
+
+const (
+	// TAG_CRUD_TENANT marks the tenant column of a model: `crud:"tenant"`.
+	TAG_CRUD_TENANT = "tenant"
+
+	// BypassTenancy is the actor capability that lifts tenant scoping, for
+	// system jobs that work across tenants.
+	BypassTenancy = "bypass_tenancy"
+
+	ctxKeyTenant requestContextKey = "crud.tenant"
+)
+
+// TenancyConfig configures WithTenancy and ServiceConfig.Tenancy.
+//
+// The tenant is taken from ActorContext.TenantID, or from Header when the
+// actor carries none and TrustHeader is set. Operations on models with a
+// tenant column are scoped to it: reads and deletes only see the tenant's
+// rows, creates have the column filled, and writes naming another tenant fail
+// with ScopeViolationError. Relation filters and included relations only see
+// related records of the tenant.
+type TenancyConfig struct {
+	// Column is the tenant column of models without a `crud:"tenant"` field.
+	// Models with neither are shared between tenants.
+	Column string
+	// Header names the request header carrying the tenant, e.g. X-Tenant-ID.
+	// When the actor has a tenant the header must match it.
+	Header string
+	// TrustHeader lets Header name the tenant of actors that carry none.
+	// Leave it off unless a gateway in front of the service sets or checks
+	// the header: clients could otherwise pick any tenant. Without it such
+	// requests fail with TENANT_REQUIRED.
+	TrustHeader bool
+}
+
+// TenantError reports a request whose tenant cannot be resolved. The JSON
+// error encoder reports it as 403 TENANT_REQUIRED, or TENANT_MISMATCH when
+// the header names a tenant other than the actor's.
+type TenantError struct {
+	// Tenant is the tenant named by the header; empty when none was given.
+	Tenant string
+}
+
+func (e *TenantError) Error() string {
+	if e.Tenant == "" {
+		return "request has no tenant"
+	}
+	return fmt.Sprintf("tenant %q does not match the actor tenant", e.Tenant)
+}
+
+// TenantFromContext returns the tenant resolved for the current request, or
+// an empty string when tenancy is disabled or bypassed.
+func TenantFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	if tenant, ok := ctx.Value(ctxKeyTenant).(string); ok {
+		return tenant
+	}
+	return ""
+}
+
+func attachTenantToRequestContext(ctx Context, tenant string) {
+	if ctx == nil || tenant == "" {
+		return
+	}
+	updated := context.WithValue(ctx.UserContext(), ctxKeyTenant, tenant)
+	if setter, ok := ctx.(userContextSetter); ok {
+		setter.SetUserContext(updated)
+	}
+}
+
+// tenancyGuard wraps next so the scope it returns is also restricted to the
+// request tenant. A nil next starts from the actor and scope already on the
+// request context.
+func tenancyGuard[T any](cfg TenancyConfig, next ScopeGuardFunc[T]) ScopeGuardFunc[T] {
+	column, _ := tenantFieldFor(typeOf[T](), cfg.Column)
+	return func(ctx Context, op CrudOperation) (ActorContext, ScopeFilter, error) {
+		var actor ActorContext
+		var scope ScopeFilter
+		if next != nil {
+			var err error
+			if actor, scope, err = next(ctx, op); err != nil {
+				return actor, scope, err
+			}
+		} else if ctx != nil {
+			scope = ScopeFromContext(ctx.UserContext())
+		}
+		if actor.IsZero() && ctx != nil {
+			actor = ActorFromContext(ctx.UserContext())
+		}
+		if scope.Bypass || actor.HasCapability(BypassTenancy) {
+			return actor, scope, nil
+		}
+
+		tenant, err := resolveTenant(ctx, actor, cfg)
+		if err != nil {
+			return actor, scope, err
+		}
+		if tenant == "" {
+			if column.index == nil {
+				// Shared models need no tenant.
+				return actor, scope, nil
+			}
+			return actor, scope, &TenantError{}
+		}
+		attachTenantToRequestContext(ctx, tenant)
+		if column.index == nil {
+			return actor, scope, nil
+		}
+		scope = scope.clone()
+		if !slices.ContainsFunc(scope.ColumnFilters, func(filter ScopeColumnFilter) bool {
+			return filter.Column == column.column && slices.Equal(filter.Values, []string{tenant})
+		}) {
+			scope.AddColumnFilter(column.column, "=", tenant)
+		}
+		return actor, scope, nil
+	}
+}
+
+// resolveTenant returns the actor tenant, or the header tenant when the actor
+// has none and cfg trusts the header. It returns an empty tenant when neither
+// applies.
+func resolveTenant(ctx Context, actor ActorContext, cfg TenancyConfig) (string, error) {
+	tenant := strings.TrimSpace(actor.TenantID)
+	if cfg.Header == "" {
+		return tenant, nil
+	}
+	var requested string
+	if provider, ok := ctx.(headerProvider); ok {
+		requested = strings.TrimSpace(provider.Header(cfg.Header))
+	}
+	switch {
+	case tenant == "" && cfg.TrustHeader:
+		return requested, nil
+	case tenant == "":
+		return "", nil
+	case requested != "" && requested != tenant:
+		return "", &TenantError{Tenant: requested}
+	}
+	return tenant, nil
+}
+
+// relationTenant is the request tenant applied to relation filters and
+// included relations, with the tenant column fallback of TenancyConfig.
+type relationTenant struct {
+	value    string
+	fallback string
+}
+
+// column returns the tenant column of the related model typ when its rows
+// must be kept to the tenant.
+func (t relationTenant) column(typ reflect.Type) (string, bool) {
+	if t.value == "" {
+		return "", false
+	}
+	field, ok := tenantFieldFor(typ, t.fallback)
+	return field.column, ok
+}
+
+// predicate renders the condition keeping the rows aliased alias of the
+// related model typ to the tenant, or "" when they are not scoped. The
+// condition is escaped for the query it is formatted into.
+func (t relationTenant) predicate(q *bun.SelectQuery, alias string, typ reflect.Type) string {
+	column, ok := t.column(typ)
+	if !ok {
+		return ""
+	}
+	cond := q.DB().QueryGen().FormatQuery("?.? = ?", bun.Ident(alias), bun.Ident(column), t.value)
+	return strings.ReplaceAll(cond, "?", `\?`)
+}
+
+type tenantField struct {
+	index  []int
+	column string
+}
+
+var tenantFieldCache sync.Map // map[reflect.Type]tenantField
+
+// tenantFieldFor resolves the field tagged `crud:"tenant"`, falling back to
+// the field mapping fallback.
+func tenantFieldFor(typ reflect.Type, fallback string) (tenantField, bool) {
+	for typ != nil && typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return tenantField{}, false
+	}
+	var field tenantField
+	if cached, ok := tenantFieldCache.Load(typ); ok {
+		field = cached.(tenantField)
+	} else {
+		collectTenantField(typ, nil, &field)
+		tenantFieldCache.Store(typ, field)
+	}
+	if field.index == nil && fallback != "" {
+		if index, ok := columnIndexes(typ)[fallback]; ok {
+			field = tenantField{index: index, column: fallback}
+		}
+	}
+	return field, field.index != nil
+}
+
+func collectTenantField(typ reflect.Type, parent []int, out *tenantField) {
+	for i := 0; i < typ.NumField() && out.index == nil; i++ {
+		field := typ.Field(i)
+		index := append(append([]int{}, parent...), i)
+		bunTag := field.Tag.Get(TAG_BUN)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && bunTag == "" {
+			collectTenantField(field.Type, index, out)
+			continue
+		}
+		if !field.IsExported() || bunTag == "-" || !hasTagOption(field.Tag.Get(TAG_CRUD), TAG_CRUD_TENANT) {
+			continue
+		}
+		column := strings.TrimSpace(strings.Split(bunTag, ",")[0])
+		if column == "" {
+			column = strcase.ToSnake(field.Name)
+		}
+		*out = tenantField{index: index, column: column}
+	}
+}
+
+// filterTenantRelations removes related records of other tenants from the
+// relations loaded on records, recursively. Related models without a tenant
+// column are kept.
+func filterTenantRelations[T any](ctx Context, cfg *TenancyConfig, records []T) {
+	if cfg == nil || ctx == nil {
+		return
+	}
+	tenant := TenantFromContext(ctx.UserContext())
+	if tenant == "" {
+		return
+	}
+	for i := range records {
+		if rv, ok := recordStructValue(&records[i]); ok {
+			filterTenantRelationFields(rv, tenant, cfg.Column)
+		}
+	}
+}
+
+// filterTenantRelation is filterTenantRelations for a single record.
+func filterTenantRelation[T any](ctx Context, cfg *TenancyConfig, record T) T {
+	records := []T{record}
+	filterTenantRelations(ctx, cfg, records)
+	return records[0]
+}
+
+func filterTenantRelationFields(rv reflect.Value, tenant, fallback string) {
+	typ := rv.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		bunTag := field.Tag.Get(TAG_BUN)
+		if !field.IsExported() || (!strings.Contains(bunTag, "rel:") && !strings.Contains(bunTag, "m2m:")) {
+			continue
+		}
+		value := rv.Field(i)
+		if value.Kind() != reflect.Slice {
+			if !sameTenant(value, tenant, fallback) {
+				value.Set(reflect.Zero(value.Type()))
+			}
+			continue
+		}
+		kept := reflect.MakeSlice(value.Type(), 0, value.Len())
+		for j := 0; j < value.Len(); j++ {
+			if sameTenant(value.Index(j), tenant, fallback) {
+				kept = reflect.Append(kept, value.Index(j))
+			}
+		}
+		if kept.Len() != value.Len() {
+			value.Set(kept)
+		}
+	}
+}
+
+// sameTenant reports whether a related record belongs to tenant, filtering
+// its own relations when it does.
+func sameTenant(value reflect.Value, tenant, fallback string) bool {
+	for value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return true
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return true
+	}
+	if field, ok := tenantFieldFor(value.Type(), fallback); ok {
+		fv, err := value.FieldByIndexErr(field.index)
+		if err != nil {
+			return false
+		}
+		if got, _ := scopeFieldString(fv); got != tenant {
+			return false
+		}
+	}
+	filterTenantRelationFields(value, tenant, fallback)
+	return true
+}
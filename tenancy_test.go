@@ -0,0 +1,378 @@
+package crud
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+
+	"github.com/goliatone/go-repository-bun"
+)
+
+type tenantProject struct {
+	bun.BaseModel `bun:"table:tenant_projects,alias:tp"`
+
+	ID        uuid.UUID     `bun:"id,pk,notnull" json:"id"`
+	TenantID  string        `bun:"tenant_id,notnull" json:"tenant_id" crud:"tenant"`
+	Name      string        `bun:"name" json:"name"`
+	Tasks     []*tenantTask `bun:"rel:has-many,join:id=project_id" json:"tasks,omitempty"`
+	DeletedAt time.Time     `bun:"deleted_at,soft_delete,nullzero" json:"deleted_at,omitempty"`
+}
+
+type tenantTask struct {
+	bun.BaseModel `bun:"table:tenant_tasks,alias:tt"`
+
+	ID        uuid.UUID      `bun:"id,pk,notnull" json:"id"`
+	TenantID  string         `bun:"tenant_id,notnull" json:"tenant_id" crud:"tenant"`
+	ProjectID uuid.UUID      `bun:"project_id" json:"project_id"`
+	Title     string         `bun:"title" json:"title"`
+	Project   *tenantProject `bun:"rel:belongs-to,join:project_id=id" json:"project,omitempty"`
+}
+
+func newTenantProjectRepository(db *bun.DB) repository.Repository[*tenantProject] {
+	return repository.NewRepository(db, repository.ModelHandlers[*tenantProject]{
+		NewRecord:     func() *tenantProject { return &tenantProject{} },
+		GetID:         func(project *tenantProject) uuid.UUID { return project.ID },
+		SetID:         func(project *tenantProject, id uuid.UUID) { project.ID = id },
+		GetIdentifier: func() string { return "Name" },
+	})
+}
+
+// setupTenantApp seeds one project per tenant; the t1 project also holds a
+// task stamped with t2. Requests authenticate with the X-Actor-Tenant header,
+// and X-Actor-Bypass grants BypassTenancy.
+func setupTenantApp(t *testing.T, opts ...Option[*tenantProject]) (*fiber.App, *bun.DB, map[string]*tenantProject) {
+	t.Helper()
+	db := newCodecTestDB(t, (*tenantProject)(nil), (*tenantTask)(nil))
+	projects := map[string]*tenantProject{
+		"t1": {ID: uuid.New(), TenantID: "t1", Name: "Apollo"},
+		"t2": {ID: uuid.New(), TenantID: "t2", Name: "Gemini"},
+	}
+	for _, project := range projects {
+		_, err := db.NewInsert().Model(project).Exec(context.Background())
+		require.NoError(t, err)
+	}
+	tasks := []*tenantTask{
+		{ID: uuid.New(), TenantID: "t1", ProjectID: projects["t1"].ID, Title: "Launch"},
+		{ID: uuid.New(), TenantID: "t2", ProjectID: projects["t1"].ID, Title: "Leaked"},
+	}
+	_, err := db.NewInsert().Model(&tasks).Exec(context.Background())
+	require.NoError(t, err)
+
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		actor := ActorContext{ActorID: "actor", TenantID: c.Get("X-Actor-Tenant")}
+		if c.Get("X-Actor-Bypass") != "" {
+			actor.Capabilities = []string{BypassTenancy}
+		}
+		c.SetUserContext(ContextWithActor(c.UserContext(), actor))
+		return c.Next()
+	})
+	opts = append([]Option[*tenantProject]{WithTenancy[*tenantProject](TenancyConfig{Header: "X-Tenant-ID"}), withOptInRoutes[*tenantProject]()}, opts...)
+	controller := NewController(newTenantProjectRepository(db), opts...)
+	controller.RegisterRoutes(NewFiberAdapter(app))
+	return app, db, projects
+}
+
+func tenantRequest(t *testing.T, app *fiber.App, method, path string, headers map[string]string, body any) (int, map[string]any) {
+	t.Helper()
+	req := batchRequest(method, path, body)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := app.Test(req, -1)
+	require.NoError(t, err)
+	var payload map[string]any
+	_ = json.NewDecoder(resp.Body).Decode(&payload)
+	return resp.StatusCode, payload
+}
+
+// trustTenantHeader lets X-Tenant-ID name the tenant of actors without one.
+func trustTenantHeader() Option[*tenantProject] {
+	return WithTenancy[*tenantProject](TenancyConfig{Header: "X-Tenant-ID", TrustHeader: true})
+}
+
+func TestController_Tenancy_ResolvesTenant(t *testing.T) {
+	app, _, _ := setupTenantApp(t, trustTenantHeader())
+
+	cases := []struct {
+		name     string
+		headers  map[string]string
+		status   int
+		textCode string
+		names    []string
+	}{
+		{name: "actor tenant", headers: map[string]string{"X-Actor-Tenant": "t1"}, status: http.StatusOK, names: []string{"Apollo"}},
+		{name: "header tenant", headers: map[string]string{"X-Tenant-ID": "t2"}, status: http.StatusOK, names: []string{"Gemini"}},
+		{name: "matching header", headers: map[string]string{"X-Actor-Tenant": "t1", "X-Tenant-ID": "t1"}, status: http.StatusOK, names: []string{"Apollo"}},
+		{name: "mismatched header", headers: map[string]string{"X-Actor-Tenant": "t1", "X-Tenant-ID": "t2"}, status: http.StatusForbidden, textCode: "TENANT_MISMATCH"},
+		{name: "no tenant", status: http.StatusForbidden, textCode: "TENANT_REQUIRED"},
+		{name: "bypass", headers: map[string]string{"X-Actor-Bypass": "1"}, status: http.StatusOK, names: []string{"Apollo", "Gemini"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			status, payload := tenantRequest(t, app, http.MethodGet, "/tenant-projects?order=name", tc.headers, nil)
+			require.Equal(t, tc.status, status, "payload: %v", payload)
+			if tc.textCode != "" {
+				assert.Equal(t, tc.textCode, errorTextCode(t, payload))
+				return
+			}
+			var names []string
+			for _, item := range payload["data"].([]any) {
+				names = append(names, item.(map[string]any)["name"].(string))
+			}
+			assert.Equal(t, tc.names, names)
+		})
+	}
+}
+
+func TestController_Tenancy_IgnoresUntrustedHeader(t *testing.T) {
+	app, _, _ := setupTenantApp(t)
+
+	status, payload := tenantRequest(t, app, http.MethodGet, "/tenant-projects", map[string]string{"X-Tenant-ID": "t2"}, nil)
+	require.Equal(t, http.StatusForbidden, status, "payload: %v", payload)
+	assert.Equal(t, "TENANT_REQUIRED", errorTextCode(t, payload))
+
+	status, payload = tenantRequest(t, app, http.MethodGet, "/tenant-projects", map[string]string{"X-Actor-Tenant": "t1", "X-Tenant-ID": "t2"}, nil)
+	require.Equal(t, http.StatusForbidden, status, "payload: %v", payload)
+	assert.Equal(t, "TENANT_MISMATCH", errorTextCode(t, payload), "the header is still checked against the actor tenant")
+}
+
+func TestController_Tenancy_Operations(t *testing.T) {
+	t1 := map[string]string{"X-Actor-Tenant": "t1"}
+	bypass := map[string]string{"X-Actor-Bypass": "1"}
+
+	cases := []struct {
+		name     string
+		method   string
+		path     func(projects map[string]*tenantProject) string
+		headers  map[string]string
+		body     any
+		status   int
+		textCode string
+	}{
+		{name: "read own", method: http.MethodGet, path: projectPath("t1"), headers: t1, status: http.StatusOK},
+		{name: "read other", method: http.MethodGet, path: projectPath("t2"), headers: t1, status: http.StatusNotFound},
+		{name: "read other with bypass", method: http.MethodGet, path: projectPath("t2"), headers: bypass, status: http.StatusOK},
+		{name: "update other", method: http.MethodPut, path: projectPath("t2"), headers: t1, body: map[string]any{"name": "Renamed"}, status: http.StatusNotFound},
+		{name: "move own to other", method: http.MethodPut, path: projectPath("t1"), headers: t1, body: map[string]any{"tenant_id": "t2"}, status: http.StatusForbidden, textCode: "SCOPE_VIOLATION"},
+		{name: "create for other", method: http.MethodPost, path: staticPath("/tenant-project"), headers: t1, body: map[string]any{"name": "Mercury", "tenant_id": "t2"}, status: http.StatusForbidden, textCode: "SCOPE_VIOLATION"},
+		{name: "delete other", method: http.MethodDelete, path: projectPath("t2"), headers: t1, status: http.StatusNotFound},
+		{name: "delete own", method: http.MethodDelete, path: projectPath("t1"), headers: t1, status: http.StatusNoContent},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			app, db, projects := setupTenantApp(t)
+			status, payload := tenantRequest(t, app, tc.method, tc.path(projects), tc.headers, tc.body)
+			require.Equal(t, tc.status, status, "payload: %v", payload)
+			if tc.textCode != "" {
+				assert.Equal(t, tc.textCode, errorTextCode(t, payload))
+			}
+
+			var stored []tenantProject
+			require.NoError(t, db.NewSelect().Model(&stored).Where("tenant_id = ?", "t2").Scan(context.Background()))
+			assert.Len(t, stored, 1, "the t2 project is never changed by t1")
+			assert.Equal(t, "Gemini", stored[0].Name)
+		})
+	}
+}
+
+func projectPath(tenant string) func(map[string]*tenantProject) string {
+	return func(projects map[string]*tenantProject) string {
+		return "/tenant-project/" + projects[tenant].ID.String()
+	}
+}
+
+func staticPath(path string) func(map[string]*tenantProject) string {
+	return func(map[string]*tenantProject) string { return path }
+}
+
+func TestController_Tenancy_StampsCreates(t *testing.T) {
+	app, _, _ := setupTenantApp(t, trustTenantHeader())
+
+	status, payload := tenantRequest(t, app, http.MethodPost, "/tenant-project", map[string]string{"X-Tenant-ID": "t2"}, map[string]any{"name": "Mercury"})
+	require.Equal(t, http.StatusCreated, status, "payload: %v", payload)
+	assert.Equal(t, "t2", payload["tenant_id"])
+}
+
+func TestController_Tenancy_PartitionsIdempotencyKeys(t *testing.T) {
+	app, db, _ := setupTenantApp(t, trustTenantHeader(), WithIdempotency[*tenantProject](NewMemoryIdempotencyStore()))
+
+	for _, tenant := range []string{"t1", "t2"} {
+		headers := map[string]string{"X-Tenant-ID": tenant, IdempotencyKeyHeader: "create-mercury"}
+		status, payload := tenantRequest(t, app, http.MethodPost, "/tenant-project", headers, map[string]any{"name": "Mercury"})
+		require.Equal(t, http.StatusCreated, status, "payload: %v", payload)
+		assert.Equal(t, tenant, payload["tenant_id"], "header tenants do not share cached responses")
+	}
+
+	count, err := db.NewSelect().Model((*tenantProject)(nil)).Where("name = ?", "Mercury").Count(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
+
+func TestController_Tenancy_FiltersIncludedRelations(t *testing.T) {
+	app, _, projects := setupTenantApp(t)
+	path := "/tenant-project/" + projects["t1"].ID.String() + "?include=tasks"
+
+	titles := func(payload map[string]any) []string {
+		var out []string
+		for _, task := range payload["data"].(map[string]any)["tasks"].([]any) {
+			out = append(out, task.(map[string]any)["title"].(string))
+		}
+		return out
+	}
+
+	status, payload := tenantRequest(t, app, http.MethodGet, path, map[string]string{"X-Actor-Tenant": "t1"}, nil)
+	require.Equal(t, http.StatusOK, status, "payload: %v", payload)
+	assert.Equal(t, []string{"Launch"}, titles(payload))
+
+	status, payload = tenantRequest(t, app, http.MethodGet, path, map[string]string{"X-Actor-Bypass": "1"}, nil)
+	require.Equal(t, http.StatusOK, status, "payload: %v", payload)
+	assert.ElementsMatch(t, []string{"Launch", "Leaked"}, titles(payload))
+}
+
+func TestController_Tenancy_FiltersByRelations(t *testing.T) {
+	app, _, _ := setupTenantApp(t)
+
+	names := func(payload map[string]any) []string {
+		var out []string
+		for _, item := range payload["data"].([]any) {
+			out = append(out, item.(map[string]any)["name"].(string))
+		}
+		return out
+	}
+
+	status, payload := tenantRequest(t, app, http.MethodGet, "/tenant-projects?tasks.title=Leaked", map[string]string{"X-Actor-Tenant": "t1"}, nil)
+	require.Equal(t, http.StatusOK, status, "payload: %v", payload)
+	assert.Empty(t, names(payload), "the t2 task does not match for t1")
+
+	status, payload = tenantRequest(t, app, http.MethodGet, "/tenant-projects?tasks.title=Launch", map[string]string{"X-Actor-Tenant": "t1"}, nil)
+	require.Equal(t, http.StatusOK, status, "payload: %v", payload)
+	assert.Equal(t, []string{"Apollo"}, names(payload))
+
+	status, payload = tenantRequest(t, app, http.MethodGet, "/tenant-projects?tasks.title=Leaked", map[string]string{"X-Actor-Bypass": "1"}, nil)
+	require.Equal(t, http.StatusOK, status, "payload: %v", payload)
+	assert.Equal(t, []string{"Apollo"}, names(payload))
+}
+
+func TestRelationTenant_ScopesJoinsAndPreloads(t *testing.T) {
+	_, db, _ := setupTenantApp(t)
+	ctx := context.Background()
+	t2 := relationTenant{value: "t2"}
+
+	field, ok := relationFieldResolver(getRelationMetadataForType(typeOf[*tenantTask]()), resolvedFieldPolicy{}, t2)("project.name")
+	require.True(t, ok)
+	var tasks []*tenantTask
+	q := db.NewSelect().Model(&tasks)
+	for _, join := range field.Joins {
+		q = join.Apply(q)
+	}
+	require.NoError(t, q.Where(field.Column+" = ?", "Apollo").Scan(ctx))
+	assert.Empty(t, tasks, "the joined t1 project does not match for t2")
+
+	tasks = nil
+	q = includeRelation(db.NewSelect().Model(&tasks), typeOf[*tenantTask](), &relationIncludeNode{name: "Project"}, t2)
+	require.NoError(t, q.Scan(ctx))
+	require.Len(t, tasks, 2)
+	for _, task := range tasks {
+		assert.Nil(t, task.Project, "the t1 project is not joined for t2")
+	}
+
+	var projects []*tenantProject
+	q = includeRelation(db.NewSelect().Model(&projects).Where("?TableAlias.name = ?", "Apollo"), typeOf[*tenantProject](), &relationIncludeNode{name: "Tasks"}, t2)
+	require.NoError(t, q.Scan(ctx))
+	require.Len(t, projects, 1)
+	require.Len(t, projects[0].Tasks, 1)
+	assert.Equal(t, "Leaked", projects[0].Tasks[0].Title, "only t2 tasks are loaded")
+}
+
+func TestController_Tenancy_BulkOperations(t *testing.T) {
+	t1 := map[string]string{"X-Actor-Tenant": "t1"}
+	otherID := func(projects map[string]*tenantProject) any { return []string{projects["t2"].ID.String()} }
+
+	cases := []struct {
+		name    string
+		method  string
+		path    string
+		body    func(projects map[string]*tenantProject) any
+		deleted bool
+		status  int
+	}{
+		{name: "update batch", method: http.MethodPut, path: "/tenant-project/batch", body: func(projects map[string]*tenantProject) any {
+			return []map[string]any{{"id": projects["t2"].ID.String(), "name": "Renamed"}}
+		}, status: http.StatusNotFound},
+		{name: "delete batch", method: http.MethodDelete, path: "/tenant-project/batch", body: otherID, status: http.StatusNotFound},
+		{name: "upsert", method: http.MethodPut, path: "/tenant-project", body: func(projects map[string]*tenantProject) any {
+			return map[string]any{"name": "Gemini", "tenant_id": "t2"}
+		}, status: http.StatusForbidden},
+		{name: "upsert batch", method: http.MethodPut, path: "/tenant-project/batch/upsert", body: func(projects map[string]*tenantProject) any {
+			return []map[string]any{{"name": "Gemini", "tenant_id": "t2"}}
+		}, status: http.StatusForbidden},
+		{name: "import upsert", method: http.MethodPost, path: "/tenant-project/import?format=ndjson&upsert=true", body: func(projects map[string]*tenantProject) any {
+			return json.RawMessage(`{"name":"Gemini","tenant_id":"t2"}`)
+		}, status: http.StatusMultiStatus},
+		{name: "update by filter", method: http.MethodPatch, path: "/tenant-projects?name__eq=Gemini", body: func(projects map[string]*tenantProject) any {
+			return map[string]any{"name": "Renamed"}
+		}, status: http.StatusOK},
+		{name: "delete by filter", method: http.MethodDelete, path: "/tenant-projects?name__eq=Gemini", status: http.StatusOK},
+		{name: "restore batch", method: http.MethodPost, path: "/tenant-project/batch/restore", body: otherID, deleted: true, status: http.StatusNotFound},
+		{name: "purge batch", method: http.MethodDelete, path: "/tenant-project/batch/purge", body: otherID, deleted: true, status: http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			app, db, projects := setupTenantApp(t)
+			if tc.deleted {
+				_, err := db.NewDelete().Model(projects["t2"]).WherePK().Exec(context.Background())
+				require.NoError(t, err)
+			}
+			var body any
+			if tc.body != nil {
+				body = tc.body(projects)
+			}
+			status, payload := tenantRequest(t, app, tc.method, tc.path, t1, body)
+			require.Equal(t, tc.status, status, "payload: %v", payload)
+
+			var stored []tenantProject
+			require.NoError(t, db.NewSelect().Model(&stored).WhereAllWithDeleted().Where("tenant_id = ?", "t2").Scan(context.Background()))
+			require.Len(t, stored, 1, "the t2 project is never changed by t1")
+			assert.Equal(t, "Gemini", stored[0].Name)
+			assert.Equal(t, tc.deleted, !stored[0].DeletedAt.IsZero())
+		})
+	}
+}
+
+func TestNewService_Tenancy(t *testing.T) {
+	db := newCodecTestDB(t, (*tenantProject)(nil), (*tenantTask)(nil))
+	svc := NewService(ServiceConfig[*tenantProject]{
+		Repository: newTenantProjectRepository(db),
+		Tenancy:    &TenancyConfig{},
+	})
+	withActor := func(actor ActorContext) Context {
+		ctx := newBenchContext()
+		ctx.SetUserContext(ContextWithActor(context.Background(), actor))
+		return ctx
+	}
+
+	created, err := svc.Create(withActor(ActorContext{ActorID: "a", TenantID: "t1"}), &tenantProject{ID: uuid.New(), Name: "Apollo"})
+	require.NoError(t, err)
+	assert.Equal(t, "t1", created.TenantID)
+
+	records, _, err := svc.Index(withActor(ActorContext{ActorID: "b", TenantID: "t2"}), nil)
+	require.NoError(t, err)
+	assert.Empty(t, records)
+
+	_, _, err = svc.Index(withActor(ActorContext{ActorID: "c"}), nil)
+	var tenantErr *TenantError
+	require.ErrorAs(t, err, &tenantErr)
+
+	records, _, err = svc.Index(withActor(ActorContext{ActorID: "job", Capabilities: []string{BypassTenancy}}), nil)
+	require.NoError(t, err)
+	assert.Len(t, records, 1)
+}
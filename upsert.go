@@ -0,0 +1,651 @@
+package crud
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/goliatone/go-repository-bun"
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/feature"
+	"github.com/uptrace/bun/schema"
+)
+
+// UpsertAction reports whether an upsert inserted a new row or updated the row
+// matching its conflict columns.
+type UpsertAction string
+
+const (
+	UpsertInserted UpsertAction = "inserted"
+	UpsertUpdated  UpsertAction = "updated"
+)
+
+// UpsertService is implemented by services that can insert or update records
+// in a single statement. The repository-backed service and the NewService
+// layers implement it; custom services opt in by implementing it too.
+type UpsertService[T any] interface {
+	Upsert(ctx Context, record T) (T, UpsertAction, error)
+	UpsertBatch(ctx Context, records []T) ([]T, []UpsertAction, error)
+}
+
+const (
+	ctxKeyUpsertFields requestContextKey = "crud.upsert_fields"
+	ctxKeyUpsertWrite  requestContextKey = "crud.upsert_write"
+)
+
+// ContextWithUpsertFields lists, per record, the JSON fields an upsert writes
+// when it updates the stored row, so zero values can be stored. Lists align
+// by position with the records passed to UpsertBatch; records without a list
+// write their non-zero fields and keep the stored value of the others.
+func ContextWithUpsertFields(ctx context.Context, fields ...[]string) context.Context {
+	if ctx == nil || len(fields) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, ctxKeyUpsertFields, slices.Clone(fields))
+}
+
+func upsertFieldsAt(ctx context.Context, index int) []string {
+	fields, _ := ctx.Value(ctxKeyUpsertFields).([][]string)
+	if index < 0 || index >= len(fields) {
+		return nil
+	}
+	return fields[index]
+}
+
+func attachUpsertFields(ctx Context, fields ...[]string) {
+	if ctx == nil || len(fields) == 0 {
+		return
+	}
+	if setter, ok := ctx.(userContextSetter); ok {
+		setter.SetUserContext(ContextWithUpsertFields(ctx.UserContext(), fields...))
+	}
+}
+
+// upsertWriteFunc is called by the repository service once it knows whether
+// an upsert inserts or updates record, before the statement runs. stored holds
+// the matched row on updates. The controller uses it to authorize the stored
+// row and the hooks layer to run the create or update hooks.
+type upsertWriteFunc[T any] func(action UpsertAction, record, stored T) error
+
+func contextWithUpsertWrite[T any](ctx context.Context, fn upsertWriteFunc[T]) context.Context {
+	return context.WithValue(ctx, ctxKeyUpsertWrite, fn)
+}
+
+func upsertWriteFromContext[T any](ctx context.Context) upsertWriteFunc[T] {
+	fn, _ := ctx.Value(ctxKeyUpsertWrite).(upsertWriteFunc[T])
+	return fn
+}
+
+// upsertBodyFields lists the JSON members of each object in an upsert body,
+// a single object or an array of objects. Bodies of another shape yield nil.
+func upsertBodyFields(body []byte) [][]string {
+	var objects []map[string]json.RawMessage
+	if err := json.Unmarshal(body, &objects); err != nil {
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(body, &object); err != nil {
+			return nil
+		}
+		objects = []map[string]json.RawMessage{object}
+	}
+	out := make([][]string, len(objects))
+	for i, object := range objects {
+		out[i] = slices.Sorted(maps.Keys(object))
+	}
+	return out
+}
+
+// upsertServiceOf returns svc as an UpsertService, or an
+// UnsupportedOperationError for op when it does not implement one.
+func upsertServiceOf[T any](svc any, op CrudOperation) (UpsertService[T], error) {
+	if up, ok := svc.(UpsertService[T]); ok && up != nil {
+		return up, nil
+	}
+	return nil, UnsupportedOperationError{Operation: op}
+}
+
+// --- repository service ---
+
+// Upsert inserts record or updates the row matching its conflict columns.
+// See UpsertBatch.
+func (s *repositoryService[T]) Upsert(ctx Context, record T) (T, UpsertAction, error) {
+	res, actions, err := s.upsertRecords(ctx, OpUpsert, []T{record})
+	if err != nil {
+		return record, "", err
+	}
+	return res[0], actions[0], nil
+}
+
+// UpsertBatch upserts records in one transaction. Conflict columns come from
+// RepositoryServiceOptions.UpsertConflictColumns, then the repository
+// identifier, then the primary key.
+//
+// A stored row matching the conflict columns must be visible through the
+// request scope, otherwise the upsert fails with NotFoundError. Updates bump
+// the record version, restore soft-deleted rows and never rewrite the
+// conflict, primary key or scope columns. Zero-valued fields keep the stored
+// value unless ContextWithUpsertFields lists them.
+//
+// Inserts use ON CONFLICT DO NOTHING (ON DUPLICATE KEY on MySQL) and updates
+// match the stored row only while it is in scope, so the affected row count
+// reports the action. A row inserted or moved by a concurrent request in
+// between sends the record through the lookup again.
+func (s *repositoryService[T]) UpsertBatch(ctx Context, records []T) ([]T, []UpsertAction, error) {
+	return s.upsertRecords(ctx, OpUpsertBatch, records)
+}
+
+func (s *repositoryService[T]) upsertRecords(ctx Context, op CrudOperation, records []T) ([]T, []UpsertAction, error) {
+	if len(records) == 0 {
+		return nil, nil, nil
+	}
+
+	driver := "unknown"
+	var db bun.IDB
+	if provider, ok := s.repo.(repository.DBProvider); ok && provider.DB() != nil {
+		db = provider.DB()
+		driver = repository.DetectDriver(provider.DB())
+	}
+	tx, inTx := TxFromContext(ctx.UserContext())
+	if inTx {
+		db = tx
+	}
+	if db == nil {
+		return nil, nil, UnsupportedOperationError{Operation: op}
+	}
+
+	uctx := ctx.UserContext()
+	target := upsertTarget[T]{
+		scope: ScopeFromContext(uctx),
+		write: upsertWriteFromContext[T](uctx),
+	}
+	results := make([]T, 0, len(records))
+	actions := make([]UpsertAction, 0, len(records))
+	run := func(uc context.Context, idb bun.IDB) error {
+		results, actions = results[:0], actions[:0]
+		for i, record := range records {
+			target.fields = upsertFieldsAt(uctx, i)
+			res, action, err := s.upsertOne(uc, idb, record, target)
+			if err != nil {
+				return err
+			}
+			results = append(results, res)
+			actions = append(actions, action)
+		}
+		return nil
+	}
+
+	var err error
+	if inTx {
+		err = run(ctx.UserContext(), db)
+	} else {
+		err = db.RunInTx(ctx.UserContext(), nil, func(uc context.Context, tx bun.Tx) error {
+			return run(uc, tx)
+		})
+	}
+	var rejected *upsertRejection
+	if errors.As(err, &rejected) {
+		return nil, nil, rejected.err
+	}
+	if err != nil {
+		return nil, nil, repository.MapDatabaseError(err, driver)
+	}
+	return results, actions, nil
+}
+
+// upsertRejection carries errors that are not database errors, such as scope
+// and hook failures, out of the upsert transaction unmapped.
+type upsertRejection struct{ err error }
+
+func (e *upsertRejection) Error() string { return e.err.Error() }
+
+// upsertTarget carries the request state an upsert of one record needs.
+type upsertTarget[T any] struct {
+	scope  ScopeFilter
+	fields []string
+	write  upsertWriteFunc[T]
+}
+
+// upsertAttempts bounds how often an upsert looks the row up again after a
+// concurrent write changed the outcome of its lookup.
+const upsertAttempts = 3
+
+func (s *repositoryService[T]) upsertOne(ctx context.Context, db bun.IDB, record T, target upsertTarget[T]) (T, UpsertAction, error) {
+	handlers := s.repo.Handlers()
+	if handlers.GetID != nil && handlers.SetID != nil && handlers.GetID(record) == uuid.Nil {
+		handlers.SetID(record, uuid.New())
+	}
+
+	strct := reflect.Indirect(reflect.ValueOf(record))
+	if strct.Kind() != reflect.Struct {
+		return record, "", fmt.Errorf("crud: upsert expects a struct record, got %T", record)
+	}
+	table := db.Dialect().Tables().Get(strct.Type())
+	conflict, err := s.upsertConflictFields(table)
+	if err != nil {
+		return record, "", err
+	}
+
+	for range upsertAttempts {
+		action, err := s.upsertAttempt(ctx, db, record, strct, table, conflict, target)
+		if err != nil || action != "" {
+			return record, action, err
+		}
+	}
+	return record, "", fmt.Errorf("crud: upsert of %s kept racing concurrent writes to its conflict columns", table.TypeName)
+}
+
+// upsertAttempt looks up the row matching the conflict columns and writes
+// record with a statement that only succeeds if the lookup still holds: the
+// insert does nothing on conflict and the update only matches the stored row
+// while it is inside the request scope. The statement result decides the
+// action; an empty action means a concurrent write got in between and the
+// caller should look again.
+func (s *repositoryService[T]) upsertAttempt(ctx context.Context, db bun.IDB, record T, strct reflect.Value, table *schema.Table, conflict []*schema.Field, target upsertTarget[T]) (UpsertAction, error) {
+	where := func(q *bun.SelectQuery) *bun.SelectQuery {
+		for _, field := range conflict {
+			q = q.Where("?TableAlias.? = ?", field.SQLName, field.Value(strct).Interface())
+		}
+		return q
+	}
+
+	stored := s.repo.Handlers().NewRecord()
+	lookup := func(criteria ...repository.SelectCriteria) *bun.SelectQuery {
+		q := where(db.NewSelect().Model(stored))
+		if table.SoftDeleteField != nil {
+			q = q.WhereAllWithDeleted()
+		}
+		for _, c := range criteria {
+			q = q.Apply(c)
+		}
+		return q
+	}
+	exists := true
+	if err := lookup().Limit(1).Scan(ctx); err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return "", err
+		}
+		exists = false
+	}
+	scope := target.scope.selectCriteria()
+	action := UpsertInserted
+	if exists {
+		action = UpsertUpdated
+		inScope, err := lookup(scope...).Exists(ctx)
+		if err != nil {
+			return "", err
+		}
+		if !inScope {
+			return "", &upsertRejection{&NotFoundError{fmt.Errorf("the %s matching the upsert conflict columns is outside the request scope", table.TypeName)}}
+		}
+	}
+	if target.write != nil {
+		if err := target.write(action, record, stored); err != nil {
+			return "", &upsertRejection{err}
+		}
+	}
+
+	features := db.Dialect().Features()
+	var affected int
+	var err error
+	returning := false
+	if exists {
+		if field, ok := versionFieldFor(strct.Type()); ok {
+			var from any
+			if version, ok := recordVersion(stored); ok {
+				if from, err = field.versionArg(record, version); err != nil {
+					return "", err
+				}
+			}
+			field.bump(record, from)
+		}
+
+		// The scope is checked again by the statement, so a row moved out
+		// of scope since the lookup is left alone.
+		matched := lookup(scope...).ColumnExpr("?PKs")
+		q := db.NewUpdate().
+			Model(record).
+			Where("?PKs IN (?)", db.NewSelect().TableExpr("(?) AS matched", matched).ColumnExpr("*"))
+		if set := upsertSetFields(table, conflict, strct, target); len(set) > 0 {
+			columns := make([]string, len(set))
+			for i, field := range set {
+				columns[i] = field.Name
+			}
+			q = q.Column(columns...)
+		} else if table.SoftDeleteField == nil {
+			q = q.Set("? = ?", conflict[0].SQLName, conflict[0].SQLName)
+		}
+		if table.SoftDeleteField != nil {
+			q = q.Set("? = NULL", table.SoftDeleteField.SQLName).WhereAllWithDeleted()
+		}
+		if returning = features.Has(feature.Returning); returning {
+			q = q.Returning("*")
+		}
+		affected, err = rowsAffected(q.Exec(ctx))
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return "", err
+		}
+		if affected == 0 {
+			// MySQL reports unchanged rows as unaffected; the row is only
+			// gone if the scoped lookup no longer finds it.
+			if inScope, err := lookup(scope...).Exists(ctx); err != nil || !inScope {
+				return "", err
+			}
+		}
+	} else {
+		q := db.NewInsert().Model(record)
+		switch {
+		case features.Has(feature.InsertOnConflict):
+			q = q.On("CONFLICT (?) DO NOTHING", bun.Safe(joinFieldNames(conflict)))
+		case features.Has(feature.InsertOnDuplicateKey):
+			// Rewriting a column onto itself leaves the row unchanged and
+			// reports it as unaffected.
+			q = q.On("DUPLICATE KEY UPDATE").Set("? = ?", conflict[0].SQLName, conflict[0].SQLName)
+		default:
+			return "", UnsupportedOperationError{Operation: OpUpsert}
+		}
+		if returning = features.Has(feature.InsertReturning); returning {
+			q = q.Returning("*")
+		}
+		affected, err = rowsAffected(q.Exec(ctx))
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return "", err
+		}
+		if affected == 0 {
+			return "", nil
+		}
+	}
+
+	if !returning {
+		// MySQL has no RETURNING; reload so generated and stored values match.
+		if err := where(db.NewSelect().Model(record)).Scan(ctx); err != nil {
+			return "", err
+		}
+	}
+	return action, nil
+}
+
+// upsertConflictFields resolves the configured conflict columns, accepting
+// either column or Go field names, and falls back to the primary key.
+func (s *repositoryService[T]) upsertConflictFields(table *schema.Table) ([]*schema.Field, error) {
+	names := s.upsertConflictColumns
+	if len(names) == 0 {
+		if getIdentifier := s.repo.Handlers().GetIdentifier; getIdentifier != nil {
+			if identifier := strings.TrimSpace(getIdentifier()); identifier != "" {
+				names = []string{identifier}
+			}
+		}
+	}
+	if len(names) == 0 {
+		if len(table.PKs) == 0 {
+			return nil, fmt.Errorf("crud: %s has no upsert conflict columns", table.TypeName)
+		}
+		return table.PKs, nil
+	}
+
+	fields := make([]*schema.Field, 0, len(names))
+	for _, name := range names {
+		field := upsertFieldByName(table, strings.TrimSpace(name))
+		if field == nil {
+			return nil, fmt.Errorf("crud: unknown upsert conflict column %q on %s", name, table.TypeName)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+func upsertFieldByName(table *schema.Table, name string) *schema.Field {
+	if field, ok := table.FieldMap[name]; ok {
+		return field
+	}
+	for _, field := range table.Fields {
+		if strings.EqualFold(field.GoName, name) || strings.EqualFold(field.Name, name) {
+			return field
+		}
+	}
+	return nil
+}
+
+// upsertSetFields lists the columns rewritten when the row already exists:
+// the fields listed by target, or every non-zero field, plus the version
+// column. Conflict, scope and tenant columns are never rewritten.
+func upsertSetFields[T any](table *schema.Table, conflict []*schema.Field, strct reflect.Value, target upsertTarget[T]) []*schema.Field {
+	skip := make(map[string]struct{}, len(conflict))
+	for _, field := range conflict {
+		skip[field.Name] = struct{}{}
+	}
+	for _, filter := range target.scope.ColumnFilters {
+		skip[filter.Column] = struct{}{}
+	}
+	if tenant, ok := tenantFieldFor(strct.Type(), ""); ok {
+		skip[tenant.column] = struct{}{}
+	}
+	var version string
+	if field, ok := versionFieldFor(strct.Type()); ok {
+		version = field.column
+	}
+	var out []*schema.Field
+	for _, field := range table.DataFields {
+		if _, ok := skip[field.Name]; ok || field.SkipUpdate() {
+			continue
+		}
+		written := !field.HasZeroValue(strct)
+		if target.fields != nil {
+			written = slices.Contains(target.fields, jsonFieldName(field.StructField))
+		}
+		if written || field.Name == version {
+			out = append(out, field)
+		}
+	}
+	return out
+}
+
+func joinFieldNames(fields []*schema.Field) string {
+	names := make([]string, len(fields))
+	for i, field := range fields {
+		names[i] = string(field.SQLName)
+	}
+	return strings.Join(names, ", ")
+}
+
+// --- controller ---
+
+// Upsert inserts the record or updates the row matching its conflict columns:
+// PUT /user
+func (c *Controller[T]) Upsert(ctx Context) error {
+	ctx = c.applyContextFactory(ctx)
+	meta, policy, err := c.prepareWriteOp(ctx, OpUpsert)
+	if err != nil {
+		return c.resp.OnError(ctx, err, OpUpsert)
+	}
+
+	record, err := c.deserializer(OpUpsert, ctx)
+	if err != nil {
+		c.emitActivityEvents(ctx, OpUpsert, meta, []T{record}, err)
+		return c.resp.OnError(ctx, &ValidationError{err}, OpUpsert)
+	}
+
+	if fields := c.upsertRequestFields(ctx, policy, OpUpsert, 1); fields != nil {
+		attachUpsertFields(ctx, fields...)
+	}
+
+	result, err := c.upsert(ctx, meta, policy, record)
+	if err != nil {
+		return c.resp.OnError(ctx, err, OpUpsert)
+	}
+	applyFieldPolicyToRecord(result, policy)
+	return c.resp.OnData(ctx, result, OpUpsert)
+}
+
+// UpsertBatch upserts the records listed in the body:
+// PUT /user/batch/upsert
+func (c *Controller[T]) UpsertBatch(ctx Context) error {
+	ctx = c.applyContextFactory(ctx)
+	meta, policy, err := c.prepareWriteOp(ctx, OpUpsertBatch)
+	if err != nil {
+		return c.resp.OnError(ctx, err, OpUpsertBatch)
+	}
+
+	records, err := c.deserialiMany(OpUpsertBatch, ctx)
+	if err != nil {
+		c.emitActivityEvents(ctx, OpUpsertBatch, meta, records, err)
+		return c.resp.OnError(ctx, &ValidationError{err}, OpUpsertBatch)
+	}
+	up, err := upsertServiceOf[T](c.resolvedWriteService(), OpUpsertBatch)
+	if err != nil {
+		return c.resp.OnError(ctx, err, OpUpsertBatch)
+	}
+
+	fields := c.upsertRequestFields(ctx, policy, OpUpsertBatch, len(records))
+
+	if !isAtomicBatch(ctx) {
+		// Items run in order, so next indexes the fields sent for each one.
+		next := 0
+		restore := c.attachUpsertAuthorization(ctx, OpUpsertBatch)
+		results := c.runBatchItems(ctx, OpUpsertBatch, meta, records, http.StatusOK, func(record T) (T, error) {
+			if fields != nil {
+				attachUpsertFields(ctx, fields[next])
+			}
+			next++
+			record, err := enforceCreateWrite(policy, meta.scope, OpUpsertBatch, record)
+			if err != nil {
+				return record, err
+			}
+			res, _, err := up.Upsert(ctx, record)
+			return res, err
+		})
+		restore()
+		return c.writeBatchResults(ctx, OpUpsertBatch, results)
+	}
+	if fields != nil {
+		attachUpsertFields(ctx, fields...)
+	}
+
+	upserted, err := c.upsertBatch(ctx, meta, policy, up, records)
+	if err != nil {
+		return c.resp.OnError(ctx, err, OpUpsertBatch)
+	}
+	applyFieldPolicyToSlice(upserted, policy)
+	return c.resp.OnList(ctx, upserted, OpUpsertBatch, &Filters{
+		Count:     len(upserted),
+		Operation: string(OpUpsertBatch),
+	})
+}
+
+// UpsertRecord upserts a single record using guard/activity semantics.
+func (c *Controller[T]) UpsertRecord(ctx Context, record T) (T, error) {
+	ctx = c.applyContextFactory(ctx)
+	meta, policy, err := c.prepareWriteOp(ctx, OpUpsert)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	result, err := c.upsert(ctx, meta, policy, record)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	applyFieldPolicyToRecord(result, policy)
+	return result, nil
+}
+
+// UpsertRecords upserts records in batch using guard/activity semantics.
+func (c *Controller[T]) UpsertRecords(ctx Context, records []T) ([]T, error) {
+	ctx = c.applyContextFactory(ctx)
+	meta, policy, err := c.prepareWriteOp(ctx, OpUpsertBatch)
+	if err != nil {
+		return nil, err
+	}
+	up, err := upsertServiceOf[T](c.resolvedWriteService(), OpUpsertBatch)
+	if err != nil {
+		return nil, err
+	}
+	upserted, err := c.upsertBatch(ctx, meta, policy, up, records)
+	if err != nil {
+		return nil, err
+	}
+	applyFieldPolicyToSlice(upserted, policy)
+	return upserted, nil
+}
+
+// upsertRequestFields lists the writable JSON members sent for each of count
+// records so upserts can store zero values. It returns nil when the body does
+// not hold count objects, e.g. with a custom deserializer.
+func (c *Controller[T]) upsertRequestFields(ctx Context, policy resolvedFieldPolicy, op CrudOperation, count int) [][]string {
+	fields := upsertBodyFields(ctx.Body())
+	if len(fields) != count {
+		return nil
+	}
+	for i, names := range fields {
+		fields[i] = slices.DeleteFunc(names, func(name string) bool {
+			return !policy.writable(op, name)
+		})
+	}
+	return fields
+}
+
+// prepareWriteOp runs the guard and field policy steps shared by handlers that
+// write without a prior lookup.
+func (c *Controller[T]) prepareWriteOp(ctx Context, op CrudOperation) (guardRequestContext, resolvedFieldPolicy, error) {
+	meta, err := c.resolveGuardContext(ctx, op)
+	if err != nil {
+		return meta, resolvedFieldPolicy{}, err
+	}
+	policy, err := c.resolveFieldPolicy(ctx, op, meta)
+	if err != nil {
+		return meta, policy, err
+	}
+	c.logFieldPolicyDecision(policy)
+	c.attachHookContext(ctx, op)
+	return meta, policy, nil
+}
+
+func (c *Controller[T]) upsert(ctx Context, meta guardRequestContext, policy resolvedFieldPolicy, record T) (T, error) {
+	up, err := upsertServiceOf[T](c.resolvedWriteService(), OpUpsert)
+	if err != nil {
+		return record, err
+	}
+	if record, err = enforceCreateWrite(policy, meta.scope, OpUpsert, record); err != nil {
+		c.emitActivityEvents(ctx, OpUpsert, meta, []T{record}, err)
+		return record, err
+	}
+	restore := c.attachUpsertAuthorization(ctx, OpUpsert)
+	result, _, err := up.Upsert(ctx, record)
+	restore()
+	if err != nil {
+		c.emitActivityEvents(ctx, OpUpsert, meta, []T{record}, err)
+		return result, err
+	}
+	c.emitActivityEvents(ctx, OpUpsert, meta, []T{result}, nil)
+	return result, nil
+}
+
+func (c *Controller[T]) upsertBatch(ctx Context, meta guardRequestContext, policy resolvedFieldPolicy, up UpsertService[T], records []T) ([]T, error) {
+	for i, record := range records {
+		var err error
+		if records[i], err = enforceCreateWrite(policy, meta.scope, OpUpsertBatch, record); err != nil {
+			c.emitActivityEvents(ctx, OpUpsertBatch, meta, records, err)
+			return nil, err
+		}
+	}
+	var upserted []T
+	restore := c.attachUpsertAuthorization(ctx, OpUpsertBatch)
+	err := c.runInTx(ctx, func() error {
+		var err error
+		upserted, _, err = up.UpsertBatch(ctx, records)
+		return err
+	})
+	restore()
+	if err != nil {
+		c.emitActivityEvents(ctx, OpUpsertBatch, meta, records, err)
+		return nil, err
+	}
+	c.emitActivityEvents(ctx, OpUpsertBatch, meta, upserted, nil)
+	return upserted, nil
+}
@@ -0,0 +1,217 @@
+package crud
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/goliatone/go-crud/pkg/activity"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func upsertRequest(t *testing.T, app *fiber.App, path string, body any) *http.Response {
+	t.Helper()
+	payload, err := json.Marshal(body)
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPut, path, bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req, -1)
+	require.NoError(t, err)
+	return resp
+}
+
+func TestController_Upsert_InsertsThenUpdatesByIdentifier(t *testing.T) {
+	var actions []UpsertAction
+	capture := &activity.CaptureHook{}
+	app, db := setupApp(t,
+		WithLifecycleHooks(LifecycleHooks[*TestUser]{
+			AfterUpsert: []HookFunc[*TestUser]{
+				func(hctx HookContext, _ *TestUser) error {
+					actions = append(actions, hctx.Metadata.UpsertAction)
+					return nil
+				},
+			},
+		}),
+		WithActivityHooks[*TestUser](activity.Hooks{capture}, activity.Config{Enabled: true}),
+	)
+	defer db.Close()
+
+	resp := upsertRequest(t, app, "/test-user", map[string]any{"name": "Ann", "email": "ann@example.com", "age": 20})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var first struct {
+		Data TestUser `json:"data"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&first))
+	require.NotEqual(t, uuid.Nil, first.Data.ID)
+
+	resp = upsertRequest(t, app, "/test-user", map[string]any{"name": "Annie", "email": "ann@example.com"})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var second struct {
+		Data TestUser `json:"data"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&second))
+	assert.Equal(t, first.Data.ID, second.Data.ID)
+	assert.Equal(t, "Annie", second.Data.Name)
+	assert.Equal(t, 20, second.Data.Age, "zero-valued fields keep the stored value")
+
+	assert.Equal(t, []UpsertAction{UpsertInserted, UpsertUpdated}, actions)
+	assert.Equal(t, 1, countTestUsers(t, db))
+	require.Len(t, capture.Events, 2)
+	assert.Equal(t, "crud.test-user.upsert", capture.Events[1].Verb)
+}
+
+func TestController_UpsertBatch_ConflictColumnsOverride(t *testing.T) {
+	var actions []UpsertAction
+	app, db := setupApp(t,
+		WithUpsertConflictColumns[*TestUser]("id"),
+		WithLifecycleHooks(LifecycleHooks[*TestUser]{
+			AfterUpsert: []HookFunc[*TestUser]{
+				func(hctx HookContext, _ *TestUser) error {
+					actions = append(actions, hctx.Metadata.UpsertAction)
+					return nil
+				},
+			},
+		}),
+	)
+	defer db.Close()
+	existing := &TestUser{Name: "Ann", Email: "ann@example.com", Age: 20}
+	insertTestUsers(t, db, existing)
+
+	resp := upsertRequest(t, app, "/test-user/batch/upsert", []map[string]any{
+		{"id": existing.ID.String(), "name": "Ann", "email": "ann@new.example.com"},
+		{"name": "Bob", "email": "bob@example.com"},
+	})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, []UpsertAction{UpsertUpdated, UpsertInserted}, actions)
+	assert.Equal(t, 2, countTestUsers(t, db))
+
+	var stored TestUser
+	require.NoError(t, db.NewSelect().Model(&stored).Where("id = ?", existing.ID).Scan(context.Background()))
+	assert.Equal(t, "ann@new.example.com", stored.Email)
+}
+
+func TestController_UpsertBatch_AtomicRollsBack(t *testing.T) {
+	app, db := setupApp(t, WithLifecycleHooks(LifecycleHooks[*TestUser]{
+		BeforeUpsert: []HookFunc[*TestUser]{
+			func(_ HookContext, user *TestUser) error {
+				if user.Name == "" {
+					return &ValidationError{assert.AnError}
+				}
+				return nil
+			},
+		},
+	}))
+	defer db.Close()
+
+	resp := upsertRequest(t, app, "/test-user/batch/upsert", []map[string]any{
+		{"name": "Ann", "email": "ann@example.com"},
+		{"email": "bob@example.com"},
+	})
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+	assert.Zero(t, countTestUsers(t, db))
+
+	resp = upsertRequest(t, app, "/test-user/batch/upsert?atomic=false", []map[string]any{
+		{"name": "Ann", "email": "ann@example.com"},
+		{"email": "bob@example.com"},
+	})
+	assert.Equal(t, http.StatusMultiStatus, resp.StatusCode)
+	assert.Equal(t, 1, countTestUsers(t, db))
+}
+
+func TestController_Upsert_UpdatesLikeUpdate(t *testing.T) {
+	var calls []string
+	hook := func(name string) []HookFunc[*TestUser] {
+		return []HookFunc[*TestUser]{func(hctx HookContext, _ *TestUser) error {
+			calls = append(calls, name+":"+string(hctx.Metadata.UpsertAction))
+			return nil
+		}}
+	}
+	app, db := setupApp(t, WithLifecycleHooks(LifecycleHooks[*TestUser]{
+		BeforeCreate: hook("before_create"),
+		BeforeUpdate: hook("before_update"),
+		AfterUpdate:  hook("after_update"),
+	}))
+	defer db.Close()
+	stored := &TestUser{ID: uuid.New(), Name: "Ann", Email: "ann@example.com", Age: 20}
+	insertTestUsers(t, db, stored)
+	require.NoError(t, db.NewSelect().Model(stored).WherePK().Scan(context.Background()))
+
+	resp := upsertRequest(t, app, "/test-user", map[string]any{"name": "Ann", "email": "ann@example.com", "age": 0})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var updated TestUser
+	require.NoError(t, db.NewSelect().Model(&updated).Where("email = ?", "ann@example.com").Scan(context.Background()))
+	assert.Zero(t, updated.Age, "fields sent with zero values are written")
+	assert.True(t, updated.UpdatedAt.After(stored.UpdatedAt), "the version is bumped")
+	assert.Equal(t, []string{"before_update:updated", "after_update:updated"}, calls)
+}
+
+func TestController_Upsert_RestoresSoftDeletedRow(t *testing.T) {
+	app, db, note := setupTrashedNoteApp(t, WithUpsertConflictColumns[*trashedNote]("id"))
+	_, err := db.NewDelete().Model(note).WherePK().Exec(context.Background())
+	require.NoError(t, err)
+	require.Zero(t, trashedNoteCount(t, app, ""))
+
+	resp := upsertRequest(t, app, "/trashed-note", map[string]any{"id": note.ID, "title": "Final"})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 1, trashedNoteCount(t, app, ""), "the soft-deleted row is restored, not left deleted")
+	assert.Equal(t, 1, trashedNoteCount(t, app, "?with_deleted=true"))
+}
+
+func TestController_Upsert_StaysInScope(t *testing.T) {
+	app, db, projects := setupTenantApp(t, WithUpsertConflictColumns[*tenantProject]("id"))
+	t1 := map[string]string{"X-Actor-Tenant": "t1"}
+
+	status, payload := tenantRequest(t, app, http.MethodPut, "/tenant-project", t1, map[string]any{"id": projects["t2"].ID, "name": "Hijacked"})
+	assert.Equal(t, http.StatusNotFound, status, "payload: %v", payload)
+
+	status, payload = tenantRequest(t, app, http.MethodPut, "/tenant-project", t1, map[string]any{"id": projects["t1"].ID, "name": "Renamed"})
+	require.Equal(t, http.StatusOK, status, "payload: %v", payload)
+
+	var stored []tenantProject
+	require.NoError(t, db.NewSelect().Model(&stored).Order("tenant_id").Scan(context.Background()))
+	require.Len(t, stored, 2)
+	assert.Equal(t, "Renamed", stored[0].Name)
+	assert.Equal(t, "t1", stored[0].TenantID)
+	assert.Equal(t, "Gemini", stored[1].Name)
+	assert.Equal(t, "t2", stored[1].TenantID)
+}
+
+func TestRepositoryService_Upsert_RetriesAfterConcurrentInsert(t *testing.T) {
+	_, db := setupApp(t)
+	defer db.Close()
+	svc := NewRepositoryService(newTestUserRepository(db)).(*repositoryService[*TestUser])
+
+	// The rival row lands between the lookup and the insert, as a concurrent
+	// request would.
+	rival := &TestUser{ID: uuid.New(), Name: "Rival", Email: "ann@example.com", Age: 30}
+	var writes []UpsertAction
+	target := upsertTarget[*TestUser]{
+		write: func(action UpsertAction, _, _ *TestUser) error {
+			writes = append(writes, action)
+			if len(writes) == 1 {
+				insertTestUsers(t, db, rival)
+			}
+			return nil
+		},
+	}
+
+	record := &TestUser{Name: "Ann", Email: "ann@example.com", Age: 20}
+	result, action, err := svc.upsertOne(context.Background(), db, record, target)
+	require.NoError(t, err)
+	assert.Equal(t, UpsertUpdated, action)
+	assert.Equal(t, []UpsertAction{UpsertInserted, UpsertUpdated}, writes, "the write is checked again as an update")
+	assert.Equal(t, rival.ID, result.ID)
+
+	var stored TestUser
+	require.NoError(t, db.NewSelect().Model(&stored).Where("email = ?", "ann@example.com").Scan(context.Background()))
+	assert.Equal(t, "Ann", stored.Name, "the incoming values are written")
+	assert.Equal(t, 20, stored.Age)
+	assert.Equal(t, 1, countTestUsers(t, db))
+}